@@ -21,6 +21,8 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/fubarhouse/ansible-role-tester/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -36,7 +38,8 @@ var destroyCmd = &cobra.Command{
 		dist, _ := util.GetDistribution(image, image, "/sbin/init", "/sys/fs/cgroup:/sys/fs/cgroup:ro", user, distro)
 		dist.CID = containerID
 		if dist.DockerCheck() {
-			dist.DockerKill(quiet)
+			report := util.AnsibleReport{}
+			dist.DockerStop(&util.AnsibleConfig{Quiet: quiet, StopTimeout: stopTimeout}, &report)
 		} else {
 			if !quiet {
 				log.Warnf("Container %v is not currently running", dist.CID)
@@ -49,5 +52,6 @@ func init() {
 	rootCmd.AddCommand(destroyCmd)
 	destroyCmd.Flags().StringVarP(&containerID, "name", "n", "", "Container ID")
 	destroyCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Enable quiet mode")
+	destroyCmd.Flags().DurationVarP(&stopTimeout, "stop-timeout", "", 10*time.Second, "Time the container has to stop before it is killed")
 	destroyCmd.MarkFlagRequired("name")
 }
@@ -21,60 +21,332 @@
 package cmd
 
 import (
+	"bytes"
 	"os"
 
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fubarhouse/ansible-role-tester/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func newFullCmd() *cobra.Command {
 	// Shared state between Run and PostRun
 	var config util.AnsibleConfig
-	var report util.AnsibleReport
+	var aggregate *util.AggregateReport
+	var exitCode int
 
 	return &cobra.Command{
 		Use:   "full",
 		Short: "Complete end-to-end test process.",
 		Long: `Runs a complete end-to-end process which performs the following:
   - creates a container
-  - installs a requirements file
   - test the role syntax
+  - installs a requirements file
+  - runs the role in check mode (optional)
   - runs the role
   - tests for idempotence
+  - runs the verify playbook (optional)
   - removes the container
 You should be able to dockerRun all of this from the role folder on
 the local file system. If you encounter errors, there's a lot
 of flexibility in configuration, just change the defaults as
 required.
-`,
+
+With --reuse the container of a previous run with --reuse for the role
+and distribution is used instead of creating one, skipping the prepare
+playbook, and it is kept after the tests until --destroy is given. The
+role has already run in a reused container, so the converge starts from
+the state of earlier runs and the idempotence test can't detect changes
+which only the first run of the role makes.
+
+Several distributions are tested in turn when --distribution is repeated
+or comma-separated, or with --all or --family, each in its own container
+which is removed before the next is created. The tests continue when a
+distribution fails unless --fail-fast is given, and the summary and exit
+code cover every distribution. With --parallel several distributions
+are tested at the same time, and the output of each is printed once it
+is tested, unless --prefix-output is given.
+
+With --platforms-from-meta every platform of galaxy_info.platforms in
+meta/main.yml is tested on its closest distribution in turn, ie EL 8 on
+rockylinux8. Platforms without a distribution are skipped with a warning,
+and --exclude leaves out platforms, versions or distributions.
+` + util.ExitCodeHelp,
 		Run: func(cmd *cobra.Command, args []string) {
+			util.HandleSignals()
+			aggregate = util.NewAggregateReport(failFast)
+
+			// Keep stdout for the TAP stream and the result lines.
+			if tap || quiet {
+				log.SetOutput(os.Stderr)
+			}
+
+			if step && quiet {
+				util.ConfigError("The --step flag cannot be combined with --quiet.")
+			}
+
+			if parallel < 1 {
+				util.ConfigError("The --parallel flag requires at least 1 distribution.")
+			}
+
+			if step && parallel > 1 {
+				util.ConfigError("The --step flag cannot be combined with --parallel.")
+			}
+
+			if startAtTask != "" && !quiet {
+				log.Warnln("--start-at-task only applies to the role run, the idempotence test will run the entire playbook and may report changes.")
+			}
+
+			if noInit && initCommand != "" {
+				util.ConfigError("The --no-init flag cannot be combined with --init-command.")
+			}
+
+			if cmd.Flags().Changed("install-ansible-version") && strings.TrimSpace(installAnsibleVersion) == "" {
+				util.ConfigError("The --install-ansible-version flag requires a version.")
+			}
+
+			// The servers of the distributions file are used unless any are given.
+			galaxyServers, galaxyServerTokens = util.GalaxyServers(galaxyServers)
+			if galaxyToken != "" && len(galaxyServers) == 0 {
+				util.ConfigError("The --galaxy-token flag requires --galaxy-server or the galaxy_servers of the distributions file.")
+			}
+
+			if cmd.Flags().Changed("limit") && strings.TrimSpace(limit) == "" {
+				util.ConfigError("The --limit flag requires a non-empty host pattern.")
+			}
+
+			vars, varsFiles, err := util.ParseExtraVars(extraVars)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			env, err := util.ParseEnv(ansibleEnv)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			containerEnv, err := util.ParseDockerEnv(dockerEnv, dockerEnvFiles)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			ports, err := util.ParsePublish(publish)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			memoryLimit, err := util.ParseMemory(memory)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			cpuLimit, err := util.ParseCPUs(cpus)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			budgets, err := util.ParseBudgets(stageBudgets)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			if pullPolicy != "" {
+				if err := util.CheckPullPolicy(pullPolicy); err != nil {
+					util.ConfigError("%v", err)
+				}
+			}
+
+			if err := util.CheckExecUser(execUser, createUser); err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			if err := util.CheckTmpfs(tmpfs); err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			if _, err := util.ParseBuildArgs(buildArgs); err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			containerPlatforms, err := util.ParsePlatforms(platforms)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			// Credentials are kept out of the config, and so the reports.
+			if err := util.SetRegistryAuth(registryUsername, registryPassword); err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			// A role from a git repository is tested from a checkout,
+			// which is removed however the tool exits.
+			gitRole := util.GitRole{URL: roleURL, Branch: gitBranch, Tag: gitTag, Commit: gitCommit, Full: fullClone}
+			if err := gitRole.Validate(); err != nil {
+				util.ConfigError("%v", err)
+			}
+			if roleURL == "" && (gitBranch != "" || gitTag != "" || gitCommit != "" || fullClone) {
+				util.ConfigError("The --branch, --git-tag, --commit and --full-clone flags require --role.")
+			}
+			if roleURL != "" {
+				if cmd.Flags().Changed("source") {
+					util.ConfigError("The --role flag cannot be combined with --source.")
+				}
+				dir, cleanup, err := gitRole.Clone(quiet)
+				if err != nil {
+					util.ConfigError("Unable to clone the role: %v", err)
+				}
+				util.AtExit(cleanup)
+				source = dir
+			}
+
 			config = util.AnsibleConfig{
-				HostPath:         source,
-				Inventory:        inventory,
-				RemotePath:       destination,
-				ExtraRolesPath:   extraRoles,
-				LibraryPath:      libraryPath,
-				RequirementsFile: requirements,
-				PlaybookFile:     playbook,
-				Verbose:          verbose,
-				Remote:           remote,
-				Quiet:            quiet,
-			}
-
-			var dist util.Distribution
-
-			if !custom {
-				var e error
-				dist, e = util.GetDistribution(image, image, "/sbin/init", "/sys/fs/cgroup:/sys/fs/cgroup:ro", user, distro)
-				if e != nil && !quiet {
-					log.Fatalln("Incompatible distribution was inputted.")
+				HostPath:                source,
+				Inventory:               inventory,
+				InventoryFile:           inventoryFile,
+				RoleName:                roleName,
+				RolesPath:               rolesPath,
+				RemotePath:              destination,
+				ExtraRolesPath:          extraRoles,
+				LibraryPath:             libraryPath,
+				ModulePath:              modulePath,
+				FilterPluginsPath:       filterPluginsPath,
+				RequirementsFile:        requirements,
+				ForceRequirements:       forceRequirements,
+				DepsFrom:                depsFrom,
+				GalaxyServers:           galaxyServers,
+				GalaxyToken:             util.GalaxyToken(galaxyToken),
+				GalaxyServerTokens:      galaxyServerTokens,
+				PythonRequirements:      pythonRequirements,
+				PipFlags:                pipFlags,
+				CollectionsPath:         collectionsPath,
+				CollectionsOffline:      collectionsOffline,
+				AnsibleCfg:              ansibleCfg,
+				PlaybookFile:            playbook,
+				PlaybookFiles:           playbooks,
+				PreparePlaybook:         preparePlaybook,
+				CleanupPlaybook:         cleanupPlaybook,
+				VerifyPlaybook:          verifyPlaybook,
+				IdempotencePasses:       idempotencePasses,
+				AllowedChangedTasks:     allowedChangedTasks,
+				IdempotenceAllPlaybooks: idempotenceAllPlaybooks,
+				IdempotenceMaxChanged:   idempotenceMaxChanged,
+				SkipSyntax:              skipSyntax,
+				SkipConverge:            skipConverge,
+				SkipIdempotence:         skipIdempotence,
+				VaultPasswordFile:       vaultPasswordFile,
+				ExtraVars:               vars,
+				ExtraVarsFiles:          varsFiles,
+				Tags:                    tags,
+				SkipTags:                skipTags,
+				Limit:                   limit,
+				Forks:                   forks,
+				Become:                  become,
+				BecomeUser:              becomeUser,
+				BecomeMethod:            becomeMethod,
+				ExecUser:                execUser,
+				CreateUser:              createUser,
+				StartAtTask:             startAtTask,
+				Step:                    step,
+				Diff:                    diff,
+				StdoutCallback:          stdoutCallback,
+				AnsibleBinary:           ansibleBinary,
+				MinAnsibleVersion:       minAnsibleVersion,
+				CollectWarnings:         reportSARIF != "",
+				GitHub:                  github || util.GitHubActions(),
+				PrefixOutput:            prefixOutput,
+				LogDir:                  logDir,
+				NoDiagnostics:           noDiagnostics,
+				ProfileTasks:            profileTasks,
+				FailOnIgnored:           failOnIgnored,
+				Strict:                  strict,
+				IgnoreWarnings:          ignoreWarnings,
+				Retries:                 retries,
+				Timeout:                 timeout,
+				MaxDuration:             maxDuration,
+				StageBudgets:            budgets,
+				Volumes:                 volumes,
+				DockerEnv:               containerEnv,
+				Network:                 network,
+				Memory:                  memoryLimit,
+				CPUs:                    cpuLimit,
+				Publish:                 ports,
+				DockerArgs:              dockerArgs,
+				AnsibleArgs:             ansibleArgs,
+				PullPolicy:              pullPolicy,
+				ReadyTimeout:            readyTimeout,
+				SkipBootstrap:           skipBootstrap,
+				EOLMirrors:              eolMirrors,
+				InstallAnsible:          installAnsible || installAnsibleVersion != "",
+				InstallAnsibleVersion:   installAnsibleVersion,
+				Dockerfile:              dockerfile,
+				BuildArgs:               buildArgs,
+				BuildAlways:             buildAlways,
+				KeepOnFailure:           keepOnFailure,
+				KeepAlways:              keepAlways,
+				Reuse:                   reuse,
+				Copy:                    copyRole,
+				Tmpfs:                   tmpfs,
+				Destroy:                 destroy,
+				StopTimeout:             stopTimeout,
+				AutoRemove:              autoRemove,
+				Connection:              connection,
+				FactCache:               !noFactCache,
+				Env:                     env,
+				Verbose:                 verbose,
+				Remote:                  remote,
+				Quiet:                   quiet,
+			}
+
+			if !config.IsAnsibleRole() {
+				if !quiet {
+					log.Errorf("Path %v is not recognized as an Ansible role.", config.HostPath)
+				}
+				util.Exit(util.NotARoleCode)
+			}
+
+			distributions := []util.Distribution{}
+
+			if platformsFromMeta {
+				if custom || cmd.Flags().Changed("distribution") || allDistributions || distributionFamily != "" {
+					util.ConfigError("The --platforms-from-meta flag cannot be combined with --custom, --distribution, --all or --family.")
 				}
+				metaPlatforms, err := util.ReadMetaPlatforms(config.HostPath)
+				if err != nil {
+					util.ConfigError("%v", err)
+				}
+				distributions, aggregate.Skipped = util.MetaDistributions(metaPlatforms, user, excludePlatforms)
+				for _, platform := range aggregate.Skipped {
+					log.Warnf("Skipping %v, there is no distribution of the platform.", platform)
+				}
+				if len(distributions) == 0 {
+					util.ConfigError("There are no distributions of the platforms of the role to test.")
+				}
+			} else if !custom && (allDistributions || distributionFamily != "" || len(distros) > 1) {
+				if image != "" {
+					util.ConfigError("The --image flag cannot be combined with several distributions.")
+				}
+				distributions, err = util.SelectDistributions(distros, allDistributions, distributionFamily, user)
+				if err != nil {
+					util.ConfigError("%v", err)
+				}
+			} else if !custom {
+				name := ""
+				if len(distros) > 0 {
+					name = distros[0]
+				}
+				dist, e := util.GetDistribution(image, image, "/sbin/init", "/sys/fs/cgroup:/sys/fs/cgroup:ro", user, name)
+				if e != nil {
+					util.ConfigError("Incompatible distribution was inputted: %v", e)
+				}
+				distributions = append(distributions, dist)
 			} else {
-				dist = *util.NewCustomDistribution()
+				dist := *util.NewCustomDistribution()
 				user := strings.Split(image, "/")[0]
 				container := strings.Split(image, ":")[0]
 				container = strings.Split(container, "/")[1]
@@ -87,111 +359,449 @@ required.
 				util.CustomDistributionValueSet(&dist, "User", user)
 				util.CustomDistributionValueSet(&dist, "Distro", image)
 				util.CustomFamilyValueSet(&dist.Family, "Initialise", initialise)
-				util.CustomFamilyValueSet(&dist.Family, "Volume", volume)
+				util.CustomFamilyValueSet(&dist.Family, "Volume", util.CgroupVolume(volumes))
+				distributions = append(distributions, dist)
+			}
+
+			// Containers of several distributions can't share a name.
+			for i := range distributions {
+				distributions[i].CID = containerID
+				if containerID != "" && len(distributions) > 1 {
+					distributions[i].CID = containerID + "-" + distributions[i].Distro
+				}
 			}
 
-			dist.CID = containerID
+			if noEOL {
+				if err := util.CheckEOL(distributions); err != nil {
+					util.ConfigError("%v", err)
+				}
+			}
 
-			if !config.IsAnsibleRole() {
-				if !quiet {
-					log.Fatalf("Path %v is not recognized as an Ansible role.", config.HostPath)
+			// The config of every distribution is mapped before any of
+			// them is tested, so a distribution with invalid config
+			// stops the run before a container is started.
+			configs := make([]util.AnsibleConfig, len(distributions))
+			for i, dist := range distributions {
+				configs[i] = distributionConfig(config.Clone(), dist, containerPlatforms)
+			}
+
+			if dryRun {
+				for i, dist := range distributions {
+					printConfig(dist, configs[i])
 				}
-				os.Exit(util.NotARoleCode)
+				exitCode = util.OKCode
+				return
 			}
 
-			util.MapInventory(dist.CID, &config)
-			util.MapRequirements(&config)
-			util.MapPlaybook(&config)
+			exitCode = testDistributions(cmd, configs, distributions, aggregate)
 
-			report = util.NewReport(&config)
-			report.Meta.ReportFile = reportFilename
+			writeReports(aggregate)
+			printSummary(aggregate)
+		},
+		// Analyze report and return the proper exit code.
+		PostRun: func(cmd *cobra.Command, args []string) {
+			util.Exit(exitCode)
+		},
+	}
+}
+
+// testDistribution will run the full test sequence on the distribution
+// with its mapped config, returning its report and the exit code of the
+// distribution.
+func testDistribution(cmd *cobra.Command, config util.AnsibleConfig, dist util.Distribution) (util.AnsibleReport, int) {
+
+	dist.Override(privilegedOverride(cmd), noInit, initCommand)
+	config.CgroupV2 = util.CgroupV2()
+
+	report := util.NewReport(&config)
+	report.Meta.ReportFile = reportFilename
+
+	// A failed build leaves nothing to test.
+	if config.Dockerfile != "" {
+		if err := dist.DockerBuild(&config, &report); err != nil {
+			log.Errorln(err)
 			report.Ansible.Distribution = dist
+			report.Ansible.Config = config
+			return report, util.DockerBuildCode
+		}
+	}
+	report.Ansible.Distribution = dist
+	report.Ansible.Check.Enabled = checkMode
+
+	if config.Reuse && dist.DockerReuse(&config, &report) {
+		report.Docker.Run = true
+	} else if !dist.DockerCheck() {
+		report.Docker.Run = dist.DockerRun(&config, &report) && dist.DockerCheck()
 
-			if !dist.DockerCheck() {
-				dist.DockerRun(&config, &report)
-				report.Docker.Run = dist.DockerCheck()
+		// There is nothing to test without the container,
+		// or with a container which didn't become ready.
+		if !report.Docker.Run {
+			if dist.DockerCheck() {
+				dist.DockerCleanup(&config, &report)
 			}
-			hosts, _ := dist.AnsibleHosts(&config, &report)
-			report.Ansible.Hosts = hosts
-			if remote {
-				for _, host := range hosts {
-					if host == "localhost" {
-						log.Errorln("remote runs should be run directly, not through this tool")
-						dist.DockerKill(quiet)
-					}
-				}
+			report.Ansible.Config = config
+			return report, util.DockerRunCode
+		}
+	}
+	report.Docker.Version, _ = util.DockerVersion()
+	report.CollectMetadata(&config, &dist)
+	if config.MinAnsibleVersion != "" {
+		if err := util.CheckAnsibleVersion(report.Ansible.Version, config.MinAnsibleVersion); err != nil {
+			log.Errorln(err)
+			dist.DockerCleanup(&config, &report)
+			report.Ansible.Config = config
+			return report, util.AnsibleVersionCode
+		}
+	}
+	hosts, _ := dist.AnsibleHosts(&config, &report)
+	report.Ansible.Hosts = hosts
+	if config.Remote {
+		for _, host := range hosts {
+			if host == "localhost" {
+				log.Errorln("remote runs should be run directly, not through this tool")
+				dist.DockerKill(config.Quiet)
 			}
+		}
+	}
+
+	_, unlink := dist.RoleLink(&config)
+	clearFacts := dist.FactCache(&config, &report)
+	report.RunStages(&config, dist.Stages(&config, &report))
+
+	if report.Passed("syntax", report.Ansible.Syntax) && report.Ansible.Cleanup.Enabled && !report.Ansible.Interrupted {
+		report.Ansible.Cleanup.Result, report.Ansible.Cleanup.Time = dist.RoleCleanup(&config)
+	}
+
+	clearFacts()
+	unlink()
+	dist.CollectDiagnostics(&config, &report)
+	dist.DockerCleanup(&config, &report)
+
+	report.Ansible.Config = config
+
+	// Tests of a container which already existed don't pass.
+	if !report.Docker.Run {
+		return report, util.DockerRunCode
+	}
+	return report, report.ExitCode()
+}
+
+// distributionConfig will return the config of the distribution, which
+// is the config of the flags with the overrides of the distribution
+// merged over it and its paths mapped.
+func distributionConfig(config util.AnsibleConfig, dist util.Distribution, containerPlatforms map[string]string) util.AnsibleConfig {
+
+	if util.ApplyOverrides(&config, dist.Distro) && !config.Quiet {
+		log.Infof("Applying the overrides of %v", dist.Distro)
+	}
+	config.Platform = util.PlatformFor(containerPlatforms, dist.Distro)
+
+	util.MapInventory(dist.CID, &config)
+	util.MapRequirements(&config)
+	util.MapPlaybook(&config)
+	util.MapVaultPasswordFile(&config)
+	util.MapAnsibleCfg(&config)
+	util.MapInventoryFile(&config)
+	util.MapVolumes(&config)
+	util.MapPythonRequirements(&config)
+	util.MapAnsibleBinary(&config)
+	return config
+}
+
+// printConfig will print the config of the distribution for --dry-run.
+func printConfig(dist util.Distribution, config util.AnsibleConfig) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	fmt.Printf("==> %v\n%s\n", dist.Distro, data)
+}
+
+// testDistributions will test the distributions, each with its mapped
+// config, with up to --parallel of them at the same time, adding their reports to the aggregate and
+// returning the exit code of the first distribution which failed. No
+// further distributions are tested once the run is interrupted, or
+// once a distribution fails with --fail-fast. The output of each
+// distribution is printed as a block once it is tested, unless it is
+// prefixed with --prefix-output, which is printed as it runs.
+func testDistributions(cmd *cobra.Command, configs []util.AnsibleConfig, distributions []util.Distribution, aggregate *util.AggregateReport) int {
+
+	workers := parallel
+	if workers > len(distributions) {
+		workers = len(distributions)
+	}
 
-			report.Ansible.Requirements = dist.RoleInstall(&config)
-			if !remote {
-				report.Ansible.Syntax = dist.RoleSyntaxCheck(&config)
-				if report.Ansible.Syntax {
-					report.Ansible.Run.Result, report.Ansible.Run.Time = dist.RoleTest(&config)
+	// lock guards the exit code and stopping the run, and keeps the
+	// output of the distributions from interleaving.
+	var lock sync.Mutex
+	exitCode := util.OKCode
+	stopped := false
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				dist := distributions[job]
+				lock.Lock()
+				skip := stopped || util.Interrupted()
+				lock.Unlock()
+				if skip {
+					continue
 				}
-				if report.Ansible.Run.Result {
-					report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time = dist.IdempotenceTest(&config)
+
+				// Only a single distribution may use the terminal.
+				var buffer bytes.Buffer
+				distConfig := configs[job]
+				if workers > 1 && prefixOutput {
+					distConfig.Output = &util.Output{Stdout: os.Stdout}
+				} else if workers > 1 {
+					distConfig.Output = &util.Output{Stdout: &buffer}
 				}
-			} else {
-				report.Ansible.Syntax = dist.RoleSyntaxCheckRemote(&config)
-				if report.Ansible.Syntax {
-					report.Ansible.Run.Result, report.Ansible.Run.Time = dist.RoleTestRemote(&config)
+
+				report, code := testDistribution(cmd, distConfig, dist)
+
+				lock.Lock()
+				if buffer.Len() > 0 {
+					fmt.Printf("==> %v\n", dist.Distro)
+					buffer.WriteTo(os.Stdout)
 				}
-				if report.Ansible.Run.Result {
-					report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time = dist.IdempotenceTestRemote(&config)
+				printReport(&report)
+				if exitCode == util.OKCode {
+					exitCode = code
 				}
+				if !aggregate.Add(report) || code == util.InterruptedCode || (failFast && code != util.OKCode) {
+					stopped = true
+				}
+				lock.Unlock()
 			}
+		}()
+	}
 
-			dist.DockerKill(quiet)
-			if !dist.DockerCheck() {
-				report.Docker.Kill = true
-			}
+	for job := range distributions {
+		jobs <- job
+	}
+	close(jobs)
+	wg.Wait()
 
-			if reportProvided {
-				report.Ansible.Config = config
-				report.Printf()
-			}
-		},
-		// Analyze report and return the proper exit code.
-		PostRun: func(cmd *cobra.Command, args []string) {
-			// fmt.Println("PostRun called")
-			if !report.Docker.Run {
-				os.Exit(util.DockerRunCode)
-			} else if !report.Ansible.Syntax {
-				os.Exit(util.AnsibleSyntaxCode)
-			} else if !report.Ansible.Run.Result {
-				os.Exit(util.AnsibleRunCode)
-			} else if !report.Ansible.Idempotence.Result {
-				os.Exit(util.AnsibleIdempotenceCode)
-			} else {
-				os.Exit(util.OKCode)
+	return exitCode
+}
+
+// printReport will print the report of a distribution once it is
+// tested, as TAP or when a report was requested.
+func printReport(report *util.AnsibleReport) {
+	if tap {
+		if reportProvided {
+			report.WriteFile()
+		}
+		fmt.Print(report.TAP())
+	} else if reportProvided {
+		report.Printf()
+	}
+}
+
+// privilegedOverride will return the value of --privileged when it was
+// given, so the setting of the distribution is kept otherwise.
+func privilegedOverride(cmd *cobra.Command) *bool {
+	if !cmd.Flags().Changed("privileged") {
+		return nil
+	}
+	return &privileged
+}
+
+// writeReports will write the reports which were requested, which
+// is also done when the tests stop early.
+func writeReports(aggregate *util.AggregateReport) {
+	if reportJUnit != "" {
+		if err := aggregate.WriteJUnit(reportJUnit); err != nil {
+			log.Errorln(err)
+		}
+	}
+	if reportJSON != "" {
+		if err := aggregate.WriteJSON(reportJSON); err != nil {
+			log.Errorln(err)
+		}
+	}
+	if reportMarkdown != "" {
+		if err := aggregate.WriteMarkdown(reportMarkdown); err != nil {
+			log.Errorln(err)
+		}
+	}
+	if reportSARIF != "" {
+		if err := aggregate.WriteSARIF(reportSARIF); err != nil {
+			log.Errorln(err)
+		}
+	}
+	if github || util.GitHubActions() {
+		for i := range aggregate.Reports {
+			fmt.Print(aggregate.Reports[i].GitHubCommands())
+		}
+		if err := aggregate.WriteGitHubSummary(); err != nil {
+			log.Errorln(err)
+		}
+	}
+	if notifyWebhook != "" {
+		if err := aggregate.Notify(notifyWebhook); err != nil {
+			log.Warnf("could not send the notification: %v", err)
+		}
+	}
+}
+
+// printSummary will print the summary table of the whole run,
+// which is printed to stderr when stdout is used for TAP. In quiet
+// mode a result line is printed for each distribution instead.
+func printSummary(aggregate *util.AggregateReport) {
+	if quiet {
+		if !tap {
+			for i := range aggregate.Reports {
+				fmt.Println(aggregate.Reports[i].ResultLine())
 			}
-		},
+		}
+		return
+	}
+	if tap {
+		fmt.Fprint(os.Stderr, aggregate.Summary(!noColor && util.Terminal(os.Stderr)))
+	} else {
+		fmt.Print(aggregate.Summary(!noColor && util.Terminal(os.Stdout)))
 	}
 }
 
 func addFullFlags(fullCmd *cobra.Command, dir string) {
 	fullCmd.Flags().StringVarP(&containerID, "name", "n", containerID, "Name of the container")
+	fullCmd.Flags().StringVarP(&containerID, "container-name", "", containerID, "Name of the container (default art-<role>-<distribution>-<run id>)")
+	fullCmd.Flags().StringVarP(&ansibleCfg, "ansible-cfg", "", "", "Path to an ansible.cfg file to use for Ansible commands")
+	fullCmd.Flags().StringArrayVarP(&ansibleEnv, "ansible-env", "", []string{}, "Environment variables for Ansible commands as KEY=VALUE, can be repeated")
+	fullCmd.Flags().StringVarP(&modulePath, "module-path", "", "", "Path to custom modules inside the role, ie library")
+	fullCmd.Flags().StringVarP(&filterPluginsPath, "filter-plugins-path", "", "", "Path to filter plugins inside the role, ie filter_plugins")
+	fullCmd.Flags().StringVarP(&roleName, "role-name", "", "", "Name to link the role as in the roles path, defaults to the galaxy name")
+	fullCmd.Flags().StringVarP(&rolesPath, "roles-path", "", "", "Roles path to link the role into")
 	fullCmd.Flags().StringVarP(&source, "source", "s", dir, "Location of the role to test")
+	fullCmd.Flags().StringVarP(&roleURL, "role", "", "", "URL of a git repository of the role to test, which is cloned into a temporary directory instead of using --source")
+	fullCmd.Flags().StringVarP(&gitBranch, "branch", "", "", "Branch of the git repository of --role to test")
+	fullCmd.Flags().StringVarP(&gitTag, "git-tag", "", "", "Tag of the git repository of --role to test")
+	fullCmd.Flags().StringVarP(&gitCommit, "commit", "", "", "Commit of the git repository of --role to test")
+	fullCmd.Flags().BoolVarP(&fullClone, "full-clone", "", false, "Clone the whole history of --role instead of a shallow clone")
 	fullCmd.Flags().StringVarP(&destination, "destination", "d", "", "Location which the role will be mounted to")
-	fullCmd.Flags().StringVarP(&requirements, "requirements", "r", "", "Path to requirements file.")
+	fullCmd.Flags().StringVarP(&requirements, "requirements", "r", "", "Path to requirements file, defaults to requirements.yml or tests/requirements.yml of the role")
+	fullCmd.Flags().BoolVarP(&forceRequirements, "force-requirements", "", false, "Reinstall the requirements which are already installed")
+	fullCmd.Flags().StringArrayVarP(&galaxyServers, "galaxy-server", "", []string{}, "URL of a Galaxy server the requirements are installed from, ie a private Automation Hub, can be repeated to try the servers in order")
+	fullCmd.Flags().StringVarP(&galaxyToken, "galaxy-token", "", "", "Token of the Galaxy servers, defaults to $ANSIBLE_GALAXY_TOKEN")
+	fullCmd.Flags().StringVarP(&depsFrom, "deps-from", "", "", "Directory of the roles the role depends on, which are mounted instead of being installed from Galaxy")
+	fullCmd.Flags().StringVarP(&collectionsPath, "collections-path", "", "", "Path the collections are installed into, exported as ANSIBLE_COLLECTIONS_PATH")
+	fullCmd.Flags().StringVarP(&collectionsOffline, "collections-offline", "", "", "Directory of collection tarballs on the host which are installed instead of using Galaxy")
 	fullCmd.Flags().StringVarP(&extraRoles, "extra-roles", "x", "", "Path to roles folder with dependencies.")
 	fullCmd.Flags().StringVarP(&playbook, "playbook", "p", "playbook.yml", "The filename of the playbook")
+	fullCmd.Flags().StringSliceVarP(&playbooks, "playbooks", "", []string{}, "Comma-separated list of playbooks to run in order, replaces --playbook")
+	fullCmd.Flags().StringVarP(&preparePlaybook, "prepare-playbook", "", "", "The filename of a playbook to prepare the container before the role runs")
+	fullCmd.Flags().StringVarP(&cleanupPlaybook, "cleanup-playbook", "", "", "The filename of a playbook to clean up the container after the tests")
+	fullCmd.Flags().StringVarP(&verifyPlaybook, "verify-playbook", "", "tests/verify.yml", "The filename of a playbook of assertions to run after the idempotence test, skipped if missing")
+	fullCmd.Flags().IntVarP(&idempotencePasses, "idempotence-passes", "", 1, "Number of idempotence passes, only the final pass must report no changes")
+	fullCmd.Flags().IntVarP(&idempotenceMaxChanged, "idempotence-max-changed", "", 0, "Number of changed tasks across all hosts the idempotence test allows")
+	fullCmd.Flags().StringArrayVarP(&allowedChangedTasks, "allow-changed-task", "", []string{}, "Name or glob pattern of a task which may report changes during the idempotence test, can be repeated")
+	fullCmd.Flags().BoolVarP(&idempotenceAllPlaybooks, "idempotence-all-playbooks", "", false, "Run every playbook in the idempotence test instead of only the first")
+	fullCmd.Flags().BoolVarP(&skipSyntax, "skip-syntax", "", false, "Skip the syntax check stage")
+	fullCmd.Flags().BoolVarP(&skipConverge, "skip-converge", "", false, "Skip the converge stage which runs the role, along with prepare and check mode")
+	fullCmd.Flags().BoolVarP(&skipIdempotence, "skip-idempotence", "", false, "Skip the idempotence test stage")
+	fullCmd.Flags().StringSliceVarP(&tags, "tags", "", []string{}, "Only run plays and tasks tagged with these values")
+	fullCmd.Flags().StringSliceVarP(&skipTags, "skip-tags", "", []string{}, "Only run plays and tasks whose tags do not match these values")
+	fullCmd.Flags().StringVarP(&vaultPasswordFile, "vault-password-file", "", "", "Path to a file containing the vault password")
+	fullCmd.Flags().BoolVarP(&become, "become", "", false, "Run the playbook with privilege escalation")
+	fullCmd.Flags().StringVarP(&becomeUser, "become-user", "", "", "The user to become, implies --become")
+	fullCmd.Flags().StringVarP(&becomeMethod, "become-method", "", "", "The privilege escalation method to use")
+	fullCmd.Flags().StringVarP(&execUser, "exec-user", "", "", "User Ansible runs as in the container instead of root, which implies --become (--user selects the image)")
+	fullCmd.Flags().BoolVarP(&createUser, "create-user", "", false, "Create the --exec-user in the container with passwordless sudo")
+	fullCmd.Flags().IntVarP(&forks, "forks", "", 0, "Number of parallel processes used by Ansible")
+	fullCmd.Flags().BoolVarP(&step, "step", "", false, "Confirm each task interactively during the role run")
+	fullCmd.Flags().StringVarP(&startAtTask, "start-at-task", "", "", "Start the role run at the task matching this name")
+	fullCmd.Flags().StringVarP(&limit, "limit", "", "", "Limit the playbook run to the specified host pattern")
+	fullCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "", []string{}, "Extra variables as key=value or @file, can be repeated")
 	fullCmd.Flags().BoolVarP(&noOutput, "no-output", "o", false, "Hide output from all Docker commands")
 	fullCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Enable quiet mode")
+	fullCmd.Flags().StringVarP(&stdoutCallback, "stdout-callback", "", "", "Ansible stdout callback plugin to use (ie yaml, debug, json)")
+	fullCmd.Flags().StringVarP(&ansibleBinary, "ansible-binary", "", "", "Path to the ansible-playbook binary for remote runs, instead of $PATH")
+	fullCmd.Flags().StringVarP(&minAnsibleVersion, "min-ansible-version", "", "", "Minimum version of Ansible to test with, ie 2.9")
+	fullCmd.Flags().BoolVarP(&strict, "strict", "", false, "Fail the syntax check and role run on Ansible warnings and deprecation warnings")
+	fullCmd.Flags().BoolVarP(&failOnIgnored, "fail-on-ignored", "", false, "Fail the role run when tasks fail with ignore_errors")
+	fullCmd.Flags().IntVarP(&profileTasks, "profile-tasks", "", 0, "Number of the slowest tasks of the role run to report, using the profile_tasks callback")
+	fullCmd.Flags().StringArrayVarP(&ignoreWarnings, "ignore-warning", "", util.DefaultIgnoredWarnings, "Text of a warning to ignore in strict mode, can be repeated")
+	fullCmd.Flags().IntVarP(&retries, "retries", "", 0, "Number of times to retry a failed role run, idempotence is never retried")
+	fullCmd.Flags().DurationVarP(&timeout, "timeout", "", 0, "Maximum time each stage may run for before it is killed, ie 30m (default no timeout)")
+	fullCmd.Flags().DurationVarP(&maxDuration, "max-duration", "", 0, "Maximum time the stages may take together, the run fails once they complete when exceeded (default no limit)")
+	fullCmd.Flags().StringArrayVarP(&stageBudgets, "stage-budget", "", []string{}, "Maximum time a stage may take as STAGE=DURATION, ie converge=5m, the run fails once the stages complete when exceeded, can be repeated")
+	fullCmd.Flags().BoolVarP(&privileged, "privileged", "", true, "Run the container privileged, use --privileged=false where privileged containers are forbidden (default is the setting of the distribution)")
+	fullCmd.Flags().BoolVarP(&noInit, "no-init", "", false, "Run the container without an init system, for roles which don't need systemd")
+	fullCmd.Flags().StringVarP(&initCommand, "init-command", "", "", "Command the container is started with instead of the init system of the distribution")
+	fullCmd.Flags().StringVarP(&memory, "memory", "", "", "Memory limit of the container, ie 512m or 2g (default no limit)")
+	fullCmd.Flags().StringVarP(&cpus, "cpus", "", "", "CPU limit of the container, ie 1.5 (default no limit)")
+	fullCmd.Flags().StringVarP(&network, "network", "", "", "Docker network of the container, ie host or a user-defined network which is created when it doesn't exist")
+	fullCmd.Flags().StringArrayVarP(&ansibleArgs, "ansible-arg", "", []string{}, "Extra argument of ansible-playbook for the syntax check, role run and idempotence test, added after the arguments of the tool, can be repeated")
+	fullCmd.Flags().StringArrayVarP(&dockerArgs, "docker-arg", "", []string{}, "Extra argument of docker run, added after the arguments of the tool, can be repeated")
+	fullCmd.Flags().StringArrayVarP(&publish, "publish", "", []string{}, "Publish a port of the container to the host as HOSTPORT:CONTAINERPORT[/PROTO], where a host port of 0 is assigned by docker, can be repeated")
+	fullCmd.Flags().StringArrayVarP(&dockerEnv, "docker-env", "", []string{}, "Environment variable of the container as KEY=VALUE, or KEY to use the value of this environment, can be repeated")
+	fullCmd.Flags().StringArrayVarP(&dockerEnvFiles, "docker-env-file", "", []string{}, "File of environment variables of the container, one KEY=VALUE per line, can be repeated")
+	fullCmd.Flags().BoolVarP(&reuse, "reuse", "", false, "Reuse the container of a previous run with --reuse for the role and distribution, and keep it after the tests")
+	fullCmd.Flags().StringArrayVarP(&tmpfs, "tmpfs", "", []string{}, "Tmpfs mount of the container as PATH[:OPTIONS] instead of those of the distribution, ie /run for systemd, or none, can be repeated")
+	fullCmd.Flags().BoolVarP(&copyRole, "copy", "", false, "Copy the role into the container instead of mounting it, leaving out the files of its .artignore, changed files are copied again into reused containers")
+	fullCmd.Flags().DurationVarP(&stopTimeout, "stop-timeout", "", 10*time.Second, "Time the container has to stop after the tests before it is killed")
+	fullCmd.Flags().BoolVarP(&autoRemove, "rm", "", false, "Have the daemon remove the container once it stops, unless it may be kept with --keep-on-failure, --keep-always or --reuse")
+	fullCmd.Flags().BoolVarP(&destroy, "destroy", "", false, "Remove the reused container after the tests")
+	fullCmd.Flags().BoolVarP(&keepOnFailure, "keep-on-failure", "", false, "Leave the container running for debugging when the tests fail")
+	fullCmd.Flags().BoolVarP(&keepAlways, "keep-always", "", false, "Leave the container running for debugging after the tests")
+	fullCmd.Flags().StringArrayVarP(&platforms, "platform", "", []string{}, "Platform of the container, ie linux/arm64, or DISTRIBUTION=PLATFORM to override the platform of a distribution, can be repeated")
+	fullCmd.Flags().StringVarP(&pullPolicy, "pull", "", "", "When to pull the image: always, missing or never (default is the policy of the distribution, or missing)")
+	fullCmd.Flags().StringVarP(&registryUsername, "registry-username", "", "", "Username of the registry of the image, instead of the docker config")
+	fullCmd.Flags().StringVarP(&registryPassword, "registry-password", "", "", "Password of the registry of the image (REGISTRY_AUTH, the base64 of username:password, avoids showing it in the process list)")
+	fullCmd.Flags().DurationVarP(&readyTimeout, "ready-timeout", "", time.Minute, "Maximum time to wait for the init process of the container to be ready, ie systemd, where 0 disables the check")
+	fullCmd.Flags().BoolVarP(&noEOL, "no-eol", "", false, "Fail instead of testing distributions which are past their end of life")
+	fullCmd.Flags().BoolVarP(&eolMirrors, "eol-mirrors", "", false, "Install packages from the archive of the repositories of distributions past their end of life, ie vault.centos.org")
+	fullCmd.Flags().StringVarP(&pythonRequirements, "python-requirements", "", "", "Requirements file of the Python libraries the modules need, which are installed in the container with pip, defaults to tests/requirements.txt of the role")
+	fullCmd.Flags().StringVarP(&pipFlags, "pip-flags", "", "", "Flags added to pip install for the Python requirements, ie --no-cache-dir")
+	fullCmd.Flags().BoolVarP(&skipBootstrap, "skip-bootstrap", "", false, "Don't run the bootstrap commands of the distribution once the container is ready, ie installing Python")
+	fullCmd.Flags().BoolVarP(&installAnsible, "install-ansible", "", false, "Install Ansible in the container with the package manager of the distribution when the image doesn't include it")
+	fullCmd.Flags().StringVarP(&installAnsibleVersion, "install-ansible-version", "", "", "Version of Ansible to install with pip when the image doesn't include it, ie 9.* or >=8,<10, implies --install-ansible")
+	fullCmd.Flags().StringVarP(&dockerfile, "dockerfile", "", "", "Build the image of the container from this Dockerfile, in the context of its directory")
+	fullCmd.Flags().StringArrayVarP(&buildArgs, "build-arg", "", []string{}, "Build argument of the Dockerfile as KEY=VALUE, or KEY to use the value of this environment, can be repeated")
+	fullCmd.Flags().BoolVarP(&buildAlways, "build-always", "", false, "Build the image from the Dockerfile even when it already exists")
+	fullCmd.Flags().StringVarP(&connection, "connection", "", "", "Connection plugin for remote runs, ie docker or community.docker.docker (detected by default)")
+	fullCmd.Flags().BoolVarP(&noFactCache, "no-fact-cache", "", false, "Gather facts in every playbook run instead of caching them")
+	fullCmd.Flags().BoolVarP(&diff, "diff", "", false, "Show the differences of changed files during the role runs")
 	fullCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose mode for Ansible commands.")
+	fullCmd.Flags().BoolVarP(&checkMode, "check-mode", "", false, "Run the role in check mode before the real run")
 	fullCmd.Flags().BoolVarP(&custom, "custom", "c", false, "Provide my own custom distribution.")
 	fullCmd.Flags().StringVarP(&inventory, "inventory", "e", "", "Inventory file")
+	fullCmd.Flags().StringVarP(&inventoryFile, "inventory-file", "", "", "Path to an inventory file on the host, all hosts will target the container")
 	fullCmd.Flags().BoolVarP(&remote, "remote", "m", false, "Run the test remotely to the container")
 	fullCmd.Flags().BoolVarP(&reportProvided, "report", "f", false, "Provide a report after completion")
 	fullCmd.Flags().StringVarP(&reportFilename, "report-output", "b", "report.yml", "Filename in current working directory to write a report to")
+	fullCmd.Flags().StringVarP(&reportJUnit, "report-junit", "", "", "Path of a file to write a JUnit XML report of the stages to")
+	fullCmd.Flags().StringVarP(&reportJSON, "report-json", "", "", "Path of a file to write a JSON report of the stages to")
+	fullCmd.Flags().StringVarP(&reportMarkdown, "report-markdown", "", "", "Path of a file to write a markdown summary of the stages to")
+	fullCmd.Flags().StringVarP(&reportSARIF, "report-sarif", "", "", "Path of a file to write the syntax errors and warnings to as SARIF")
+	fullCmd.Flags().StringVarP(&logDir, "log-dir", "", "", "Directory to write the output of each stage to, as <distribution>/<stage>.log")
+	fullCmd.Flags().BoolVarP(&noDiagnostics, "no-diagnostics", "", false, "Don't collect the logs and journal of the container when a stage fails")
+	fullCmd.Flags().BoolVarP(&prefixOutput, "prefix-output", "", false, "Prefix each line of output with the time and the distribution")
+	fullCmd.Flags().StringVarP(&notifyWebhook, "notify-webhook", "", "", "URL to POST a JSON summary of the run to when it completes")
+	fullCmd.Flags().BoolVarP(&noColor, "no-color", "", false, "Disable the colours of the summary table, which are disabled when not printing to a terminal")
+	fullCmd.Flags().BoolVarP(&platformsFromMeta, "platforms-from-meta", "", false, "Test every platform of galaxy_info.platforms in meta/main.yml on its closest distribution, instead of --distribution")
+	fullCmd.Flags().StringArrayVarP(&excludePlatforms, "exclude", "", []string{}, "Platform of meta/main.yml not to test with --platforms-from-meta, as PLATFORM, PLATFORM:VERSION or a distribution, ie EL:7, can be repeated")
+	fullCmd.Flags().BoolVarP(&failFast, "fail-fast", "", false, "Stop testing at the first distribution which fails")
+	fullCmd.Flags().BoolVarP(&tap, "tap", "", false, "Print the results of the stages as TAP version 13 on stdout")
+	fullCmd.Flags().BoolVarP(&github, "github", "", false, "Report failures and warnings as GitHub Actions annotations and a job summary (detected in Actions)")
 	fullCmd.Flags().StringVarP(&libraryPath, "library", "", "", "Path to library folder with modules.")
 
 	fullCmd.Flags().StringVarP(&initialise, "initialise", "a", "/bin/systemd", "The initialise command for the image")
-	fullCmd.Flags().StringVarP(&volume, "volume", "l", "/sys/fs/cgroup:/sys/fs/cgroup:ro", "The volume argument for the image")
+	fullCmd.Flags().StringArrayVarP(&volumes, "volume", "l", []string{}, "Additional volume of the container as HOST:CONTAINER[:ro], can be repeated (custom distributions mount the cgroups read-only unless a volume mounts /sys/fs/cgroup)")
 
 	fullCmd.Flags().StringVarP(&image, "image", "i", "", "The image reference to use.")
 	fullCmd.Flags().StringVarP(&user, "user", "u", "fubarhouse", "Selectively choose a compatible docker image from a specified user.")
-	fullCmd.Flags().StringVarP(&distro, "distribution", "t", "ubuntu1804", "Selectively choose a compatible docker image of a specified distribution.")
+	fullCmd.Flags().StringSliceVarP(&distros, "distribution", "t", []string{"ubuntu1804"}, "Selectively choose a compatible docker image of a specified distribution, several are tested in turn when repeated or comma-separated")
+	fullCmd.Flags().BoolVarP(&allDistributions, "all", "", false, "Test every distribution in turn, instead of --distribution")
+	fullCmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "Print the config of each distribution, with its overrides, instead of testing it")
+	fullCmd.Flags().IntVarP(&parallel, "parallel", "", 1, "Number of distributions to test at the same time, each printing its output once it is tested unless --prefix-output is given")
+	fullCmd.Flags().StringVarP(&distributionFamily, "family", "", "", "Test every distribution of the family in turn, ie ubuntu, or of the wider family, ie redhat")
 }
 
 func init() {
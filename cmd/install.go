@@ -34,20 +34,37 @@ var installCmd = &cobra.Command{
 	Short: "Run installation tasks for the mounted role",
 	Long:  `Run installation tasks for the mounted role (--name $NAME)`,
 	Run: func(cmd *cobra.Command, args []string) {
+		env, err := util.ParseEnv(ansibleEnv)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		// The servers of the distributions file are used unless any are given.
+		galaxyServers, galaxyServerTokens = util.GalaxyServers(galaxyServers)
+
 		config := util.AnsibleConfig{
-			HostPath:         source,
-			Inventory:        inventory,
-			RemotePath:       destination,
-			RequirementsFile: requirements,
-			PlaybookFile:     playbook,
-			Verbose:          verbose,
-			Remote:           remote,
-			Quiet:            quiet,
+			HostPath:           source,
+			Inventory:          inventory,
+			RemotePath:         destination,
+			RequirementsFile:   requirements,
+			ForceRequirements:  forceRequirements,
+			GalaxyServers:      galaxyServers,
+			GalaxyToken:        util.GalaxyToken(galaxyToken),
+			GalaxyServerTokens: galaxyServerTokens,
+			CollectionsPath:    collectionsPath,
+			CollectionsOffline: collectionsOffline,
+			AnsibleCfg:         ansibleCfg,
+			StdoutCallback:     stdoutCallback,
+			PlaybookFile:       playbook,
+			Env:                env,
+			Verbose:            verbose,
+			Remote:             remote,
+			Quiet:              quiet,
 		}
 
 		dist, e := util.GetDistribution(image, image, "/sbin/init", "/sys/fs/cgroup:/sys/fs/cgroup:ro", user, distro)
-		if e != nil && !quiet {
-			log.Fatalln("Incompatible distribution was inputted.")
+		if e != nil {
+			log.Fatalf("Incompatible distribution was inputted: %v", e)
 		}
 
 		dist.CID = containerID
@@ -59,8 +76,12 @@ var installCmd = &cobra.Command{
 
 			util.MapInventory(dist.CID, &config)
 			util.MapRequirements(&config)
+			util.MapAnsibleCfg(&config)
 
-			dist.RoleInstall(&config)
+			report := util.NewReport(&config)
+			if ok, _ := dist.RoleRequirements(&config, &report); !ok {
+				os.Exit(util.AnsibleRequirementsCode)
+			}
 
 		} else {
 			if !quiet {
@@ -75,8 +96,16 @@ func init() {
 	pwd, _ := os.Getwd()
 	installCmd.Flags().StringVarP(&containerID, "name", "n", containerID, "Container ID")
 	installCmd.Flags().StringVarP(&inventory, "inventory", "e", "", "Inventory file")
-	installCmd.Flags().StringVarP(&requirements, "requirements", "r", "", "Path to requirements file.")
+	installCmd.Flags().StringVarP(&requirements, "requirements", "r", "", "Path to requirements file, defaults to requirements.yml or tests/requirements.yml of the role")
+	installCmd.Flags().BoolVarP(&forceRequirements, "force-requirements", "", false, "Reinstall the requirements which are already installed")
+	installCmd.Flags().StringArrayVarP(&galaxyServers, "galaxy-server", "", []string{}, "URL of a Galaxy server the requirements are installed from, ie a private Automation Hub, can be repeated to try the servers in order")
+	installCmd.Flags().StringVarP(&galaxyToken, "galaxy-token", "", "", "Token of the Galaxy servers, defaults to $ANSIBLE_GALAXY_TOKEN")
+	installCmd.Flags().StringVarP(&collectionsPath, "collections-path", "", "", "Path the collections are installed into, exported as ANSIBLE_COLLECTIONS_PATH")
+	installCmd.Flags().StringVarP(&collectionsOffline, "collections-offline", "", "", "Directory of collection tarballs on the host which are installed instead of using Galaxy")
 	installCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Enable quiet mode")
+	installCmd.Flags().StringVarP(&ansibleCfg, "ansible-cfg", "", "", "Path to an ansible.cfg file to use for Ansible commands")
+	installCmd.Flags().StringVarP(&stdoutCallback, "stdout-callback", "", "", "Ansible stdout callback plugin to use (ie yaml, debug, json)")
+	installCmd.Flags().StringArrayVarP(&ansibleEnv, "ansible-env", "", []string{}, "Environment variables for Ansible commands as KEY=VALUE, can be repeated")
 	installCmd.Flags().StringVarP(&source, "source", "s", pwd, "Location of the role to test")
 	installCmd.MarkFlagRequired("name")
 }
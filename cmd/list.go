@@ -0,0 +1,82 @@
+// Copyright © 2018 Karl Hepworth Karl.Hepworth@gmail.com
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fubarhouse/ansible-role-tester/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the distributions which can be tested",
+	Long: `Lists the distributions which can be tested, which are the built-in
+distributions and those of the distributions file, with their image,
+init system and family.
+
+Distributions are selected with --distribution NAME and --user USER,
+or by one of their aliases, which are listed with the image they map to.
+Distributions past their end of life are marked as deprecated.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if listJSON {
+			data, err := util.DistributionsJSON(listFamily)
+			if err != nil {
+				log.Errorln(err)
+				os.Exit(util.ConfigCode)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		distributions := util.ListDistributions(listFamily)
+		if len(distributions) == 0 {
+			log.Warnf("There are no distributions of the %v family", listFamily)
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tALIASES\tUSER\tIMAGE\tFAMILY\tINIT\tEOL")
+		for _, dist := range distributions {
+			listed := util.NewJSONListDistribution(&dist)
+			eol := listed.EOL
+			if listed.Deprecated {
+				eol += " (deprecated)"
+			}
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", listed.Name, strings.Join(listed.Aliases, ","), listed.User, listed.Image, listed.Family, listed.Init, eol)
+		}
+		writer.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVarP(&listFamily, "family", "", "", "Only list the distributions of the family, ie ubuntu, or of the wider family, ie debian or redhat")
+	listCmd.Flags().BoolVarP(&listJSON, "json", "", false, "Write the distributions as JSON")
+}
@@ -0,0 +1,82 @@
+// Copyright © 2018 Karl Hepworth Karl.Hepworth@gmail.com
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/fubarhouse/ansible-role-tester/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Removes leftover containers, networks and images of the tests",
+	Long: `Removes the containers, networks and images which were created by
+ansible-role-tester and left behind, ie by interrupted runs.
+
+Only resources with the ansible-role-tester label are ever considered.
+Without --force, the resources which would be removed are listed.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		resources, err := util.PruneCandidates(pruneOlderThan)
+		if err != nil {
+			log.Errorln(err)
+			os.Exit(util.DockerRunCode)
+		}
+
+		if len(resources) == 0 && !quiet {
+			log.Println("There is nothing to remove")
+		}
+
+		failed := false
+		for _, resource := range resources {
+			age := time.Since(resource.Created).Round(time.Second)
+			if !pruneForce {
+				log.Printf("Would remove %v (created %v ago)", resource, age)
+				continue
+			}
+			if err := resource.Remove(); err != nil {
+				log.Errorf("Unable to remove %v: %v", resource, err)
+				failed = true
+			} else if !quiet {
+				log.Printf("Removed %v (created %v ago)", resource, age)
+			}
+		}
+
+		if len(resources) > 0 && !pruneForce && !quiet {
+			log.Println("Run with --force to remove them")
+		}
+		if failed {
+			os.Exit(util.DockerRunCode)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().BoolVarP(&pruneForce, "force", "", false, "Remove the resources instead of listing them")
+	pruneCmd.Flags().DurationVarP(&pruneOlderThan, "older-than", "", 0, "Only remove resources created at least this long ago, ie 2h")
+	pruneCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Enable quiet mode")
+}
@@ -22,7 +22,9 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/fubarhouse/ansible-role-tester/util"
 	"github.com/spf13/cobra"
 )
 
@@ -40,6 +42,9 @@ var (
 	// inventory is the input path to the inventory file.
 	inventory string
 
+	// inventoryFile is the path to an inventory file on the host.
+	inventoryFile string
+
 	// source is the location of the role to test.
 	source string
 
@@ -53,18 +58,114 @@ var (
 	// Path to the requirements file relative to source.
 	requirements string
 
+	// roleName is the name the role will be linked to in the roles path.
+	roleName string
+
+	// rolesPath is the roles path the role will be linked into.
+	rolesPath string
+
 	// extraRoles is an optional argument for binding a
 	// host folder with roles into the container
 	extraRoles string
 
+	// extraVars is a list of key=value pairs or @file references
+	// which will be passed to ansible-playbook as extra variables.
+	extraVars []string
+
+	// tags is a list of tags to limit the playbook run to.
+	tags []string
+
+	// skipTags is a list of tags to exclude from the playbook run.
+	skipTags []string
+
+	// limit is a host pattern to restrict the playbook run to.
+	limit string
+
+	// ansibleCfg is the path to an ansible.cfg file to use.
+	ansibleCfg string
+
+	// vaultPasswordFile is the path to a file containing the vault password.
+	vaultPasswordFile string
+
+	// forks is the number of parallel processes used by Ansible.
+	forks int
+
+	// become indicates privilege escalation should be used.
+	become = false
+
+	// becomeUser is the user to become during the playbook run.
+	becomeUser string
+
+	// becomeMethod is the privilege escalation method to use.
+	becomeMethod string
+
+	// execUser is the user Ansible runs as in the container.
+	execUser string
+
+	// createUser is a boolean indicating execUser is created with
+	// passwordless sudo when the container is created.
+	createUser = false
+
+	// ansibleEnv is a list of KEY=VALUE environment variables
+	// which will be set for every Ansible command.
+	ansibleEnv []string
+
+	// startAtTask is the name of the task to start the role run at.
+	startAtTask string
+
 	// playbook is the path to the playbook to execute inside of
 	// the 'tests' folder.
 	playbook string
 
+	// playbooks is a list of playbooks to run in order, which
+	// replaces playbook when provided.
+	playbooks []string
+
+	// preparePlaybook is the path to a playbook to run before the role.
+	preparePlaybook string
+
+	// cleanupPlaybook is the path to a playbook to run after the tests.
+	cleanupPlaybook string
+
+	// verifyPlaybook is the path to a playbook of assertions to
+	// run after the idempotence test.
+	verifyPlaybook string
+
+	// idempotencePasses is the number of idempotence test passes.
+	idempotencePasses = 1
+
+	// allowedChangedTasks is a list of tasks which may report changes
+	// during the idempotence test.
+	allowedChangedTasks []string
+
+	// idempotenceAllPlaybooks is a boolean indicating the idempotence
+	// test should run every playbook instead of only the first.
+	idempotenceAllPlaybooks = false
+
+	// idempotenceMaxChanged is the number of changed tasks which
+	// the idempotence test allows.
+	idempotenceMaxChanged = 0
+
+	// skipSyntax is a boolean indicating the syntax check should be skipped.
+	skipSyntax = false
+
+	// skipConverge is a boolean indicating the role run should be skipped.
+	skipConverge = false
+
+	// skipIdempotence is a boolean indicating the idempotence test
+	// should be skipped.
+	skipIdempotence = false
+
 	// libraryPath is an optional argument for binding a
 	// host folder with ansible modules into the container
 	libraryPath string
 
+	// modulePath is the path to custom modules inside the role.
+	modulePath string
+
+	// filterPluginsPath is the path to filter plugins inside the role.
+	filterPluginsPath string
+
 	// user is the optional argument which specifies the
 	// user associated to the selected distribution, which
 	// will be used to locate a Distribution with the same user.
@@ -97,24 +198,348 @@ var (
 	// will not work, but will automatically handle as necessary.
 	reportFilename string
 
+	// reportJUnit is the path of a file to write a JUnit XML report to.
+	reportJUnit string
+
+	// reportJSON is the path of a file to write a JSON report to.
+	reportJSON string
+
+	// reportMarkdown is the path of a file to write a markdown summary to.
+	reportMarkdown string
+
+	// reportSARIF is the path of a file to write a SARIF report to.
+	reportSARIF string
+
+	// logDir is a directory to write the output of each stage to.
+	logDir string
+
+	// prefixOutput is a boolean indicating the output lines should
+	// be prefixed with the time and the distribution.
+	prefixOutput = false
+
+	// notifyWebhook is a URL to POST a summary of the run to.
+	notifyWebhook string
+
+	// noColor is a boolean indicating the summary should not be coloured.
+	noColor = false
+
+	// distros are the distributions the full test runs on in turn.
+	distros []string
+
+	// allDistributions is a boolean indicating every distribution
+	// is tested in turn.
+	allDistributions = false
+
+	// distributionFamily is the family whose distributions are
+	// tested in turn, ie redhat.
+	distributionFamily string
+
+	// parallel is the number of distributions which are tested
+	// at the same time.
+	parallel = 1
+
+	// roleURL is the URL of a git repository of the role to test,
+	// which is cloned instead of testing --source.
+	roleURL string
+
+	// gitBranch, gitTag and gitCommit select what is cloned of the
+	// git repository of the role.
+	gitBranch string
+	gitTag    string
+	gitCommit string
+
+	// fullClone is a boolean indicating the whole history of the
+	// role is cloned instead of a shallow clone.
+	fullClone = false
+
+	// dryRun is a boolean indicating the config of each distribution
+	// is printed instead of testing it.
+	dryRun = false
+
+	// platformsFromMeta is a boolean indicating the platforms of
+	// meta/main.yml are tested instead of a distribution.
+	platformsFromMeta = false
+
+	// excludePlatforms are the platforms of meta/main.yml which are
+	// not tested, as PLATFORM, PLATFORM:VERSION or a distribution.
+	excludePlatforms []string
+
+	// failFast is a boolean indicating the tests should stop at
+	// the first distribution which fails.
+	failFast = false
+
+	// tap is a boolean indicating the results should be printed
+	// as a TAP stream on stdout instead of the report.
+	tap = false
+
+	// github is a boolean indicating the results should be reported as
+	// GitHub Actions workflow commands, which is detected in Actions.
+	github = false
+
 	// verbose is a boolean indicating all Ansible commands should
 	// be dockerRun with the --verbose flag.
 	verbose = false
 
-	// volume is the initialisation command for custom distributions
-	volume string
+	// volumes are the additional volumes of the container, which
+	// include the cgroups volume of custom distributions.
+	volumes []string
+
+	// checkMode is a boolean indicating the role should be run
+	// with --check before the real run.
+	checkMode = false
+
+	// diff is a boolean indicating Ansible should report the
+	// differences of changed files during the role runs.
+	diff = false
+
+	// ansibleBinary is the path to the ansible-playbook binary.
+	ansibleBinary string
+
+	// minAnsibleVersion is the oldest supported version of Ansible.
+	minAnsibleVersion string
+
+	// profileTasks is the number of the slowest tasks to report.
+	profileTasks = 0
+
+	// failOnIgnored is a boolean indicating the role run should fail
+	// when tasks failed with ignored errors.
+	failOnIgnored = false
+
+	// strict is a boolean indicating Ansible warnings fail the tests.
+	strict = false
+
+	// ignoreWarnings is a list of warnings to ignore in strict mode.
+	ignoreWarnings []string
+
+	// retries is the number of times a failed role run is retried.
+	retries = 0
+
+	// timeout is the longest time each stage is allowed to run for.
+	timeout time.Duration
+
+	// maxDuration is the longest time the stages may take together.
+	maxDuration time.Duration
+
+	// stageBudgets are the longest time each stage may take,
+	// in the format STAGE=DURATION.
+	stageBudgets []string
+
+	// privileged is a boolean indicating the container is privileged,
+	// which overrides the distribution only when the flag is given.
+	privileged = true
+
+	// noInit is a boolean indicating the container runs without
+	// an init system.
+	noInit = false
+
+	// initCommand is the command the container is started with
+	// instead of the init system of the distribution.
+	initCommand string
+
+	// memory is the memory limit of the container, ie 2g.
+	memory string
+
+	// cpus is the CPU limit of the container, ie 1.5.
+	cpus string
+
+	// network is the docker network of the container.
+	network string
+
+	// dockerArgs are extra arguments of docker run.
+	dockerArgs []string
+
+	// ansibleArgs are extra arguments of ansible-playbook.
+	ansibleArgs []string
+
+	// publish is a list of HOSTPORT:CONTAINERPORT[/PROTO] ports of
+	// the container which are published to the host.
+	publish []string
+
+	// dockerEnv is a list of KEY=VALUE environment variables of the
+	// container, where KEY uses the value of this environment.
+	dockerEnv []string
+
+	// dockerEnvFiles are files of environment variables of the container.
+	dockerEnvFiles []string
+
+	// reuse is a boolean indicating the container of a previous
+	// run is reused and kept after the tests.
+	reuse = false
+
+	// destroy is a boolean indicating a reused container is
+	// removed after the tests.
+	destroy = false
+
+	// stopTimeout is how long the container has to stop before it
+	// is killed.
+	stopTimeout time.Duration
+
+	// autoRemove is a boolean indicating the daemon removes the
+	// container once it stops.
+	autoRemove = false
+
+	// tmpfs are the tmpfs mounts of the container, instead of those
+	// of the distribution.
+	tmpfs []string
+
+	// copyRole is a boolean indicating the role is copied into the
+	// container instead of being mounted.
+	copyRole = false
+
+	// keepOnFailure is a boolean indicating the container is left
+	// running when the tests fail.
+	keepOnFailure = false
+
+	// keepAlways is a boolean indicating the container is left
+	// running after the tests.
+	keepAlways = false
+
+	// pullPolicy is when the image is pulled, which is always,
+	// missing or never, or empty for the policy of the distribution.
+	pullPolicy string
+
+	// platforms are the platforms of the containers, ie linux/arm64,
+	// or distribution=platform to override a single distribution.
+	platforms []string
+
+	// readyTimeout is the longest time to wait for the init process
+	// of the container to be ready.
+	readyTimeout time.Duration
+
+	// forceRequirements is a boolean indicating the requirements are
+	// reinstalled when they are already installed.
+	forceRequirements = false
+
+	// pythonRequirements is the requirements file of the Python
+	// libraries which are installed in the container.
+	pythonRequirements string
+
+	// pipFlags are the flags added to pip install.
+	pipFlags string
+
+	// galaxyServers are the URLs of the Galaxy servers, in the order
+	// they are tried.
+	galaxyServers []string
+
+	// galaxyToken is the token of the Galaxy servers.
+	galaxyToken string
+
+	// galaxyServerTokens are the tokens of the Galaxy servers of the
+	// distributions file which have one of their own, by URL.
+	galaxyServerTokens map[string]string
+
+	// depsFrom is the directory of the roles the role depends on.
+	depsFrom string
+
+	// collectionsPath is the path the collections are installed into.
+	collectionsPath string
+
+	// collectionsOffline is the directory of the collection tarballs
+	// which are installed instead of using Galaxy.
+	collectionsOffline string
+
+	// skipBootstrap is a boolean indicating the bootstrap commands
+	// of the distribution are not run.
+	skipBootstrap = false
+
+	// noEOL is a boolean indicating distributions past their end of
+	// life are not tested.
+	noEOL = false
+
+	// eolMirrors is a boolean indicating distributions past their end
+	// of life use the archive of their repositories.
+	eolMirrors = false
+
+	// installAnsible is a boolean indicating Ansible is installed in
+	// the container when the image doesn't include it.
+	installAnsible = false
+
+	// installAnsibleVersion is the version of Ansible which is
+	// installed with pip, ie 9.*.
+	installAnsibleVersion string
+
+	// noDiagnostics is a boolean indicating the logs and journal of
+	// the container are not collected when a stage fails.
+	noDiagnostics = false
+
+	// shellUser is the user of the shell in the container.
+	shellUser string
+
+	// pruneForce is a boolean indicating the resources are removed,
+	// instead of only listing them.
+	pruneForce = false
+
+	// pruneOlderThan is the minimum age of the resources to remove.
+	pruneOlderThan time.Duration
+
+	// registryUsername is the username of the registry of the image.
+	registryUsername string
+
+	// registryPassword is the password of the registry of the image.
+	registryPassword string
+
+	// dockerfile is the Dockerfile the image of the container is
+	// built from.
+	dockerfile string
+
+	// buildArgs are the build arguments of the Dockerfile.
+	buildArgs []string
+
+	// buildAlways is a boolean indicating the image is built even
+	// when it already exists.
+	buildAlways = false
+
+	// connection is the connection plugin used for remote runs.
+	connection string
+
+	// noFactCache is a boolean indicating facts should be gathered
+	// by every playbook run instead of being cached.
+	noFactCache = false
+
+	// stdoutCallback is the Ansible stdout callback plugin to use.
+	stdoutCallback string
+
+	// step is a boolean indicating the role run should prompt
+	// for confirmation before each task.
+	step = false
 
 	// custom is a boolean to indicate a custom distribution should be used.
 	custom = false
 
+	// dockerCLI is a boolean indicating docker commands should be run
+	// with the docker CLI instead of the Docker Engine API.
+	dockerCLI = false
+
+	// listFamily is the family of the distributions which are listed.
+	listFamily string
+
+	// listJSON is a boolean indicating the distributions are listed
+	// as JSON.
+	listJSON = false
+
+	// distributionsFile is the file of custom distributions, which is
+	// found with the role when it is not given.
+	distributionsFile string
+
 	// rootCmd represents the base command when called without any subcommands
 	rootCmd = &cobra.Command{
 		Use:   "ansible-test",
 		Short: "Run an Ansible role for testing purposes in an isolated environment.",
 		Long:  ``,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			util.UseDockerCLI(dockerCLI)
+			if err := util.LoadDistributions(distributionsFile, source); err != nil {
+				util.ConfigError("%v", err)
+			}
+		},
 	}
 )
 
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&dockerCLI, "docker-cli", "", false, "Run docker commands with the docker CLI instead of the Docker Engine API (deprecated, will be removed in the next release)")
+	rootCmd.PersistentFlags().StringVarP(&distributionsFile, "distributions-file", "", "", "File of custom distributions, which are added to the built-in distributions (default distributions.yml in or next to the role)")
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
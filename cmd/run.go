@@ -25,6 +25,7 @@ import (
 
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/fubarhouse/ansible-role-tester/util"
 	log "github.com/sirupsen/logrus"
@@ -45,17 +46,98 @@ func newRunCmd() *cobra.Command {
 Volume mount locations image and id are all configurable.
 `,
 		Run: func(cmd *cobra.Command, args []string) {
+			if noInit && initCommand != "" {
+				util.ConfigError("The --no-init flag cannot be combined with --init-command.")
+			}
+
+			if cmd.Flags().Changed("install-ansible-version") && strings.TrimSpace(installAnsibleVersion) == "" {
+				util.ConfigError("The --install-ansible-version flag requires a version.")
+			}
+
+			if pullPolicy != "" {
+				if err := util.CheckPullPolicy(pullPolicy); err != nil {
+					util.ConfigError("%v", err)
+				}
+			}
+
+			if err := util.CheckExecUser(execUser, createUser); err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			if err := util.CheckTmpfs(tmpfs); err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			if _, err := util.ParseBuildArgs(buildArgs); err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			containerPlatforms, err := util.ParsePlatforms(platforms)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			// Credentials are kept out of the config, and so the reports.
+			if err := util.SetRegistryAuth(registryUsername, registryPassword); err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			containerEnv, err := util.ParseDockerEnv(dockerEnv, dockerEnvFiles)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			ports, err := util.ParsePublish(publish)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			memoryLimit, err := util.ParseMemory(memory)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
+			cpuLimit, err := util.ParseCPUs(cpus)
+			if err != nil {
+				util.ConfigError("%v", err)
+			}
+
 			config = util.AnsibleConfig{
-				HostPath:         source,
-				Inventory:        inventory,
-				RemotePath:       destination,
-				ExtraRolesPath:   extraRoles,
-				LibraryPath:      libraryPath,
-				RequirementsFile: requirements,
-				PlaybookFile:     playbook,
-				Verbose:          verbose,
-				Remote:           remote,
-				Quiet:            quiet,
+				HostPath:              source,
+				Inventory:             inventory,
+				InventoryFile:         inventoryFile,
+				RemotePath:            destination,
+				ExtraRolesPath:        extraRoles,
+				LibraryPath:           libraryPath,
+				RequirementsFile:      requirements,
+				AnsibleCfg:            ansibleCfg,
+				PlaybookFile:          playbook,
+				VaultPasswordFile:     vaultPasswordFile,
+				Volumes:               volumes,
+				DockerEnv:             containerEnv,
+				Network:               network,
+				Memory:                memoryLimit,
+				CPUs:                  cpuLimit,
+				Publish:               ports,
+				DockerArgs:            dockerArgs,
+				PullPolicy:            pullPolicy,
+				ReadyTimeout:          readyTimeout,
+				SkipBootstrap:         skipBootstrap,
+				PythonRequirements:    pythonRequirements,
+				PipFlags:              pipFlags,
+				EOLMirrors:            eolMirrors,
+				InstallAnsible:        installAnsible || installAnsibleVersion != "",
+				InstallAnsibleVersion: installAnsibleVersion,
+				Dockerfile:            dockerfile,
+				BuildArgs:             buildArgs,
+				BuildAlways:           buildAlways,
+				Copy:                  copyRole,
+				Tmpfs:                 tmpfs,
+				ExecUser:              execUser,
+				CreateUser:            createUser,
+				Verbose:               verbose,
+				Remote:                remote,
+				Quiet:                 quiet,
 			}
 
 			var dist util.Distribution
@@ -64,7 +146,7 @@ Volume mount locations image and id are all configurable.
 				var e error
 				dist, e = util.GetDistribution(image, image, "/sbin/init", "/sys/fs/cgroup:/sys/fs/cgroup:ro", user, distro)
 				if e != nil {
-					log.Fatalln("Incompatible distribution was inputted.")
+					log.Fatalf("Incompatible distribution was inputted: %v", e)
 				}
 			} else {
 				dist = *util.NewCustomDistribution()
@@ -80,10 +162,19 @@ Volume mount locations image and id are all configurable.
 				util.CustomDistributionValueSet(&dist, "User", user)
 				util.CustomDistributionValueSet(&dist, "Distro", image)
 				util.CustomFamilyValueSet(&dist.Family, "Initialise", initialise)
-				util.CustomFamilyValueSet(&dist.Family, "Volume", volume)
+				util.CustomFamilyValueSet(&dist.Family, "Volume", util.CgroupVolume(volumes))
+			}
+
+			if noEOL {
+				if err := util.CheckEOL([]util.Distribution{dist}); err != nil {
+					util.ConfigError("%v", err)
+				}
 			}
 
 			dist.CID = containerID
+			dist.Override(privilegedOverride(cmd), noInit, initCommand)
+			config.Platform = util.PlatformFor(containerPlatforms, dist.Distro)
+			config.CgroupV2 = util.CgroupV2()
 
 			if !config.IsAnsibleRole() && !quiet {
 				log.Fatalf("Path %v is not recognized as an Ansible role.", config.HostPath)
@@ -99,10 +190,21 @@ Volume mount locations image and id are all configurable.
 			}
 
 			util.MapInventory(dist.CID, &config)
+			util.MapVaultPasswordFile(&config)
+			util.MapAnsibleCfg(&config)
+			util.MapInventoryFile(&config)
+			util.MapVolumes(&config)
+			util.MapPythonRequirements(&config)
 			// Our report variable is needed, but unused.
 			report = util.AnsibleReport{}
 
 			if !dist.DockerCheck() {
+				if config.Dockerfile != "" {
+					if err := dist.DockerBuild(&config, &report); err != nil {
+						log.Errorln(err)
+						return
+					}
+				}
 				report.Docker.Run = dist.DockerRun(&config, &report)
 			} else {
 				if !quiet {
@@ -124,18 +226,51 @@ Volume mount locations image and id are all configurable.
 
 func addRunFlags(runCmd *cobra.Command, dir string) {
 	runCmd.Flags().StringVarP(&containerID, "name", "n", containerID, "Container ID")
+	runCmd.Flags().StringVarP(&containerID, "container-name", "", containerID, "Name of the container (default art-<role>-<distribution>-<run id>)")
+	runCmd.Flags().StringVarP(&ansibleCfg, "ansible-cfg", "", "", "Path to an ansible.cfg file to use for Ansible commands")
 	runCmd.Flags().StringVarP(&source, "source", "s", dir, "Location of the role to test")
 	runCmd.Flags().StringVarP(&destination, "destination", "d", "", "Location which the role will be mounted to")
 	runCmd.Flags().StringVarP(&inventory, "inventory", "e", "", "Inventory file")
+	runCmd.Flags().StringVarP(&inventoryFile, "inventory-file", "", "", "Path to an inventory file on the host, all hosts will target the container")
 	runCmd.Flags().StringVarP(&extraRoles, "extra-roles", "x", "", "Path to roles folder with dependencies.")
 	runCmd.Flags().BoolVarP(&custom, "custom", "c", false, "Provide my own custom distribution.")
 	runCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Enable quiet mode")
 	runCmd.Flags().BoolVarP(&remote, "remote", "m", false, "Run the test remotely to the container")
+	runCmd.Flags().StringVarP(&vaultPasswordFile, "vault-password-file", "", "", "Path to a file containing the vault password")
 	runCmd.Flags().StringVarP(&libraryPath, "library", "", "", "Path to library folder with modules.")
 
 	runCmd.Flags().StringVarP(&initialise, "initialise", "a", "/bin/systemd", "The initialise command for the image")
-	runCmd.Flags().StringVarP(&volume, "volume", "l", "/sys/fs/cgroup:/sys/fs/cgroup:ro", "The volume argument for the image")
+	runCmd.Flags().BoolVarP(&privileged, "privileged", "", true, "Run the container privileged, use --privileged=false where privileged containers are forbidden (default is the setting of the distribution)")
+	runCmd.Flags().BoolVarP(&noInit, "no-init", "", false, "Run the container without an init system, for roles which don't need systemd")
+	runCmd.Flags().StringVarP(&initCommand, "init-command", "", "", "Command the container is started with instead of the init system of the distribution")
+	runCmd.Flags().StringVarP(&memory, "memory", "", "", "Memory limit of the container, ie 512m or 2g (default no limit)")
+	runCmd.Flags().StringVarP(&cpus, "cpus", "", "", "CPU limit of the container, ie 1.5 (default no limit)")
+	runCmd.Flags().StringVarP(&network, "network", "", "", "Docker network of the container, ie host or a user-defined network which is created when it doesn't exist")
+	runCmd.Flags().StringArrayVarP(&dockerArgs, "docker-arg", "", []string{}, "Extra argument of docker run, added after the arguments of the tool, can be repeated")
+	runCmd.Flags().StringArrayVarP(&publish, "publish", "", []string{}, "Publish a port of the container to the host as HOSTPORT:CONTAINERPORT[/PROTO], where a host port of 0 is assigned by docker, can be repeated")
+	runCmd.Flags().StringArrayVarP(&dockerEnv, "docker-env", "", []string{}, "Environment variable of the container as KEY=VALUE, or KEY to use the value of this environment, can be repeated")
+	runCmd.Flags().StringArrayVarP(&dockerEnvFiles, "docker-env-file", "", []string{}, "File of environment variables of the container, one KEY=VALUE per line, can be repeated")
+	runCmd.Flags().StringVarP(&execUser, "exec-user", "", "", "User Ansible runs as in the container instead of root, which implies --become (--user selects the image)")
+	runCmd.Flags().BoolVarP(&createUser, "create-user", "", false, "Create the --exec-user in the container with passwordless sudo")
+	runCmd.Flags().StringArrayVarP(&tmpfs, "tmpfs", "", []string{}, "Tmpfs mount of the container as PATH[:OPTIONS] instead of those of the distribution, ie /run for systemd, or none, can be repeated")
+	runCmd.Flags().BoolVarP(&copyRole, "copy", "", false, "Copy the role into the container instead of mounting it, leaving out the files of its .artignore")
+	runCmd.Flags().StringArrayVarP(&volumes, "volume", "l", []string{}, "Additional volume of the container as HOST:CONTAINER[:ro], can be repeated (custom distributions mount the cgroups read-only unless a volume mounts /sys/fs/cgroup)")
 
+	runCmd.Flags().StringArrayVarP(&platforms, "platform", "", []string{}, "Platform of the container, ie linux/arm64, or DISTRIBUTION=PLATFORM to override the platform of a distribution, can be repeated")
+	runCmd.Flags().StringVarP(&pullPolicy, "pull", "", "", "When to pull the image: always, missing or never (default is the policy of the distribution, or missing)")
+	runCmd.Flags().StringVarP(&registryUsername, "registry-username", "", "", "Username of the registry of the image, instead of the docker config")
+	runCmd.Flags().StringVarP(&registryPassword, "registry-password", "", "", "Password of the registry of the image (REGISTRY_AUTH, the base64 of username:password, avoids showing it in the process list)")
+	runCmd.Flags().DurationVarP(&readyTimeout, "ready-timeout", "", time.Minute, "Maximum time to wait for the init process of the container to be ready, ie systemd, where 0 disables the check")
+	runCmd.Flags().BoolVarP(&noEOL, "no-eol", "", false, "Fail instead of testing distributions which are past their end of life")
+	runCmd.Flags().BoolVarP(&eolMirrors, "eol-mirrors", "", false, "Install packages from the archive of the repositories of distributions past their end of life, ie vault.centos.org")
+	runCmd.Flags().StringVarP(&pythonRequirements, "python-requirements", "", "", "Requirements file of the Python libraries the modules need, which are installed in the container with pip, defaults to tests/requirements.txt of the role")
+	runCmd.Flags().StringVarP(&pipFlags, "pip-flags", "", "", "Flags added to pip install for the Python requirements, ie --no-cache-dir")
+	runCmd.Flags().BoolVarP(&skipBootstrap, "skip-bootstrap", "", false, "Don't run the bootstrap commands of the distribution once the container is ready, ie installing Python")
+	runCmd.Flags().BoolVarP(&installAnsible, "install-ansible", "", false, "Install Ansible in the container with the package manager of the distribution when the image doesn't include it")
+	runCmd.Flags().StringVarP(&installAnsibleVersion, "install-ansible-version", "", "", "Version of Ansible to install with pip when the image doesn't include it, ie 9.* or >=8,<10, implies --install-ansible")
+	runCmd.Flags().StringVarP(&dockerfile, "dockerfile", "", "", "Build the image of the container from this Dockerfile, in the context of its directory")
+	runCmd.Flags().StringArrayVarP(&buildArgs, "build-arg", "", []string{}, "Build argument of the Dockerfile as KEY=VALUE, or KEY to use the value of this environment, can be repeated")
+	runCmd.Flags().BoolVarP(&buildAlways, "build-always", "", false, "Build the image from the Dockerfile even when it already exists")
 	runCmd.Flags().StringVarP(&image, "image", "i", "", "The image reference to use.")
 	runCmd.Flags().StringVarP(&user, "user", "u", "fubarhouse", "Selectively choose a compatible docker image from a specified user.")
 	runCmd.Flags().StringVarP(&distro, "distribution", "t", "ubuntu1804", "Selectively choose a compatible docker image of a specified distribution.")
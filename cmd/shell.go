@@ -21,6 +21,9 @@
 package cmd
 
 import (
+	"os"
+	"time"
+
 	"github.com/fubarhouse/ansible-role-tester/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -28,30 +31,77 @@ import (
 
 // shellCmd represents the shell command
 var shellCmd = &cobra.Command{
-	Use:   "shell",
+	Use:   "shell [distribution]",
 	Short: "Shells into a container",
-	Long:  `Shell into a container after creation.`,
+	Long: `Shell into the most recent running container of the role, which is
+created when there is none. The distribution selects the container of
+a single distribution, ie ubuntu1804.
+
+Created containers are left running, remove them with destroy or prune.
+`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		arguments := []string{
-			"exec",
-			"-it",
-			containerID,
-			"bash",
+		config := util.AnsibleConfig{
+			HostPath:     source,
+			RemotePath:   destination,
+			ReadyTimeout: time.Minute,
+			Quiet:        quiet,
+		}
+		if config.RemotePath == "" {
+			config.RemotePath = "/etc/ansible/roles/role_under_test"
 		}
 
-		dist, _ := util.GetDistribution(image, image, "/sbin/init", "/sys/fs/cgroup:/sys/fs/cgroup:ro", user, distro)
-		dist.CID = containerID
+		distribution := ""
+		if len(args) > 0 {
+			distribution = args[0]
+		}
 
-		if dist.DockerCheck() {
-			util.DockerExec(arguments, true)
+		var dist util.Distribution
+		if containerID == "" {
+			containerID = util.RoleContainer(&config, distribution)
+		}
+
+		if containerID != "" {
+			dist.CID = containerID
+			if !dist.DockerCheck() {
+				log.Warnf("Container %v is not currently running", dist.CID)
+				os.Exit(util.DockerRunCode)
+			}
 		} else {
-			log.Warnf("Container %v is not currently running", dist.CID)
+			if distribution == "" {
+				distribution = distro
+			}
+			var err error
+			dist, err = util.GetDistribution("", "", "/sbin/init", "/sys/fs/cgroup:/sys/fs/cgroup:ro", "fubarhouse", distribution)
+			if err != nil {
+				log.Errorf("Incompatible distribution %v was inputted: %v", distribution, err)
+				os.Exit(util.ConfigCode)
+			}
+
+			util.MapVolumes(&config)
+			config.CgroupV2 = util.CgroupV2()
+			report := util.NewReport(&config)
+			if !dist.DockerRun(&config, &report) {
+				os.Exit(util.DockerRunCode)
+			}
+			if !quiet {
+				log.Printf("Created %v, remove it with: ansible-role-tester destroy --name %v", dist.CID, dist.CID)
+			}
+		}
+
+		if err := dist.DockerShell(&config, shellUser); err != nil {
+			os.Exit(util.DockerRunCode)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(shellCmd)
-	shellCmd.Flags().StringVarP(&containerID, "name", "n", containerID, "Container ID")
-	shellCmd.MarkFlagRequired("name")
+	pwd, _ := os.Getwd()
+	shellCmd.Flags().StringVarP(&containerID, "name", "n", containerID, "Container ID, instead of the most recent container of the role")
+	shellCmd.Flags().StringVarP(&source, "source", "s", pwd, "Location of the role")
+	shellCmd.Flags().StringVarP(&destination, "destination", "d", "", "Location which the role is mounted to")
+	shellCmd.Flags().StringVarP(&distro, "distribution", "t", "ubuntu1804", "Distribution of the container which is created when the role has none")
+	shellCmd.Flags().StringVarP(&shellUser, "user", "u", "", "User of the shell (default the user of the image)")
+	shellCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Enable quiet mode")
 }
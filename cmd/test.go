@@ -21,7 +21,9 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/fubarhouse/ansible-role-tester/util"
 	log "github.com/sirupsen/logrus"
@@ -35,23 +37,118 @@ var testCmd = &cobra.Command{
 	Long: `Execute tests against an existing container
 
 If container does not exist it will be created, however
-containers won't be removed after completion.`,
+containers won't be removed after completion.
+` + util.ExitCodeHelp,
 	Run: func(cmd *cobra.Command, args []string) {
+		// Exit after the container has been cleaned up by the
+		// deferred functions, which run before this one.
+		exitCode := util.OKCode
+		defer func() {
+			util.Exit(exitCode)
+		}()
+		util.HandleSignals()
+
+		aggregate := util.NewAggregateReport(failFast)
+
+		// Keep stdout for the TAP stream and the result lines.
+		if tap || quiet {
+			log.SetOutput(os.Stderr)
+		}
+
+		if step && quiet {
+			util.ConfigError("The --step flag cannot be combined with --quiet.")
+		}
+
+		if startAtTask != "" && !quiet {
+			log.Warnln("--start-at-task only applies to the role run, the idempotence test will run the entire playbook and may report changes.")
+		}
+
+		if cmd.Flags().Changed("limit") && strings.TrimSpace(limit) == "" {
+			util.ConfigError("The --limit flag requires a non-empty host pattern.")
+		}
+
+		vars, varsFiles, err := util.ParseExtraVars(extraVars)
+		if err != nil {
+			util.ConfigError("%v", err)
+		}
+
+		env, err := util.ParseEnv(ansibleEnv)
+		if err != nil {
+			util.ConfigError("%v", err)
+		}
+
+		budgets, err := util.ParseBudgets(stageBudgets)
+		if err != nil {
+			util.ConfigError("%v", err)
+		}
+
 		config := util.AnsibleConfig{
-			HostPath:         source,
-			Inventory:        inventory,
-			RemotePath:       destination,
-			RequirementsFile: requirements,
-			PlaybookFile:     playbook,
-			Verbose:          verbose,
-			Remote:           remote,
-			Quiet:            quiet,
+			HostPath:                source,
+			Inventory:               inventory,
+			InventoryFile:           inventoryFile,
+			RoleName:                roleName,
+			RolesPath:               rolesPath,
+			RemotePath:              destination,
+			ModulePath:              modulePath,
+			FilterPluginsPath:       filterPluginsPath,
+			RequirementsFile:        requirements,
+			AnsibleCfg:              ansibleCfg,
+			PlaybookFile:            playbook,
+			PlaybookFiles:           playbooks,
+			PreparePlaybook:         preparePlaybook,
+			CleanupPlaybook:         cleanupPlaybook,
+			VerifyPlaybook:          verifyPlaybook,
+			IdempotencePasses:       idempotencePasses,
+			AllowedChangedTasks:     allowedChangedTasks,
+			IdempotenceAllPlaybooks: idempotenceAllPlaybooks,
+			IdempotenceMaxChanged:   idempotenceMaxChanged,
+			SkipSyntax:              skipSyntax,
+			SkipConverge:            skipConverge,
+			SkipIdempotence:         skipIdempotence,
+			VaultPasswordFile:       vaultPasswordFile,
+			ExtraVars:               vars,
+			ExtraVarsFiles:          varsFiles,
+			Tags:                    tags,
+			SkipTags:                skipTags,
+			Limit:                   limit,
+			Forks:                   forks,
+			Become:                  become,
+			BecomeUser:              becomeUser,
+			BecomeMethod:            becomeMethod,
+			ExecUser:                execUser,
+			StartAtTask:             startAtTask,
+			Step:                    step,
+			Diff:                    diff,
+			StdoutCallback:          stdoutCallback,
+			AnsibleBinary:           ansibleBinary,
+			MinAnsibleVersion:       minAnsibleVersion,
+			CollectWarnings:         reportSARIF != "",
+			GitHub:                  github || util.GitHubActions(),
+			PrefixOutput:            prefixOutput,
+			LogDir:                  logDir,
+			NoDiagnostics:           noDiagnostics,
+			ProfileTasks:            profileTasks,
+			FailOnIgnored:           failOnIgnored,
+			Strict:                  strict,
+			IgnoreWarnings:          ignoreWarnings,
+			Retries:                 retries,
+			Timeout:                 timeout,
+			MaxDuration:             maxDuration,
+			StageBudgets:            budgets,
+			AnsibleArgs:             ansibleArgs,
+			Connection:              connection,
+			FactCache:               !noFactCache,
+			Env:                     env,
+			Verbose:                 verbose,
+			Remote:                  remote,
+			Quiet:                   quiet,
 		}
 
 		dist, _ := util.GetDistribution(image, image, "/sbin/init", "/sys/fs/cgroup:/sys/fs/cgroup:ro", user, distro)
 		report := util.NewReport(&config)
 
 		dist.CID = containerID
+		report.Ansible.Distribution = dist
 
 		if dist.DockerCheck() {
 
@@ -60,7 +157,8 @@ containers won't be removed after completion.`,
 				for _, host := range hosts {
 					if host == "localhost" {
 						log.Errorln("remote runs should be run directly, not through this tool")
-						os.Exit(1)
+						exitCode = util.ConfigCode
+						return
 					}
 				}
 			}
@@ -68,35 +166,51 @@ containers won't be removed after completion.`,
 			util.MapPlaybook(&config)
 			util.MapInventory(dist.CID, &config)
 			util.MapRequirements(&config)
+			util.MapVaultPasswordFile(&config)
+			util.MapAnsibleCfg(&config)
+			util.MapInventoryFile(&config)
+			util.MapAnsibleBinary(&config)
 
-			if !remote {
-				report.Ansible.Syntax = dist.RoleSyntaxCheck(&config)
-				if report.Ansible.Syntax {
-					report.Ansible.Run.Result, report.Ansible.Run.Time = dist.RoleTest(&config)
-				}
-				if report.Ansible.Run.Result {
-					report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time = dist.IdempotenceTest(&config)
-				}
-			} else {
-				report.Ansible.Syntax = dist.RoleSyntaxCheckRemote(&config)
-				if report.Ansible.Syntax {
-					report.Ansible.Run.Result, report.Ansible.Run.Time = dist.RoleTestRemote(&config)
-				}
-				if report.Ansible.Run.Result {
-					report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time = dist.IdempotenceTestRemote(&config)
-				}
-				hosts, _ := dist.AnsibleHosts(&config, &report)
-				for _, host := range hosts {
-					if host == "localhost" {
-						log.Errorln("remote runs should be run directly, not through this tool")
-						os.Exit(1)
-					}
+			report.Docker.Version, _ = util.DockerVersion()
+			report.CollectMetadata(&config, &dist)
+			if config.MinAnsibleVersion != "" {
+				if err := util.CheckAnsibleVersion(report.Ansible.Version, config.MinAnsibleVersion); err != nil {
+					log.Errorln(err)
+					report.Ansible.Config = config
+					aggregate.Add(report)
+					writeReports(aggregate)
+					printSummary(aggregate)
+					exitCode = util.AnsibleVersionCode
+					return
 				}
 			}
+
+			_, unlink := dist.RoleLink(&config)
+			defer unlink()
+
+			clearFacts := dist.FactCache(&config, &report)
+			defer clearFacts()
+
+			report.RunStages(&config, dist.Stages(&config, &report))
+
+			if report.Passed("syntax", report.Ansible.Syntax) && report.Ansible.Cleanup.Enabled && !report.Ansible.Interrupted {
+				report.Ansible.Cleanup.Result, report.Ansible.Cleanup.Time = dist.RoleCleanup(&config)
+			}
+
+			dist.CollectDiagnostics(&config, &report)
+			report.Ansible.Config = config
+			aggregate.Add(report)
+			writeReports(aggregate)
+			if tap {
+				fmt.Print(report.TAP())
+			}
+			printSummary(aggregate)
+			exitCode = aggregate.ExitCode()
 		} else {
 			if !quiet {
 				log.Warnf("Container %v is not currently running", dist.CID)
 			}
+			exitCode = util.DockerRunCode
 		}
 	},
 }
@@ -106,12 +220,69 @@ func init() {
 	pwd, _ := os.Getwd()
 	testCmd.Flags().StringVarP(&containerID, "name", "n", containerID, "Container ID")
 	testCmd.Flags().StringVarP(&inventory, "inventory", "e", "", "Inventory file")
+	testCmd.Flags().StringVarP(&inventoryFile, "inventory-file", "", "", "Path to an inventory file on the host, all hosts will target the container")
 	testCmd.Flags().StringVarP(&destination, "destination", "d", "", "Location which the role was mounted to")
+	testCmd.Flags().StringVarP(&stdoutCallback, "stdout-callback", "", "", "Ansible stdout callback plugin to use (ie yaml, debug, json)")
+	testCmd.Flags().StringVarP(&ansibleBinary, "ansible-binary", "", "", "Path to the ansible-playbook binary for remote runs, instead of $PATH")
+	testCmd.Flags().StringVarP(&minAnsibleVersion, "min-ansible-version", "", "", "Minimum version of Ansible to test with, ie 2.9")
+	testCmd.Flags().BoolVarP(&strict, "strict", "", false, "Fail the syntax check and role run on Ansible warnings and deprecation warnings")
+	testCmd.Flags().BoolVarP(&failOnIgnored, "fail-on-ignored", "", false, "Fail the role run when tasks fail with ignore_errors")
+	testCmd.Flags().IntVarP(&profileTasks, "profile-tasks", "", 0, "Number of the slowest tasks of the role run to report, using the profile_tasks callback")
+	testCmd.Flags().StringArrayVarP(&ignoreWarnings, "ignore-warning", "", util.DefaultIgnoredWarnings, "Text of a warning to ignore in strict mode, can be repeated")
+	testCmd.Flags().IntVarP(&retries, "retries", "", 0, "Number of times to retry a failed role run, idempotence is never retried")
+	testCmd.Flags().DurationVarP(&timeout, "timeout", "", 0, "Maximum time each stage may run for before it is killed, ie 30m (default no timeout)")
+	testCmd.Flags().DurationVarP(&maxDuration, "max-duration", "", 0, "Maximum time the stages may take together, the run fails once they complete when exceeded (default no limit)")
+	testCmd.Flags().StringArrayVarP(&stageBudgets, "stage-budget", "", []string{}, "Maximum time a stage may take as STAGE=DURATION, ie converge=5m, the run fails once the stages complete when exceeded, can be repeated")
+	testCmd.Flags().StringVarP(&connection, "connection", "", "", "Connection plugin for remote runs, ie docker or community.docker.docker (detected by default)")
+	testCmd.Flags().BoolVarP(&noFactCache, "no-fact-cache", "", false, "Gather facts in every playbook run instead of caching them")
+	testCmd.Flags().BoolVarP(&diff, "diff", "", false, "Show the differences of changed files during the role runs")
 	testCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose mode for Ansible commands.")
 	testCmd.Flags().StringVarP(&playbook, "playbook", "p", "playbook.yml", "The filename of the playbook")
+	testCmd.Flags().StringSliceVarP(&playbooks, "playbooks", "", []string{}, "Comma-separated list of playbooks to run in order, replaces --playbook")
+	testCmd.Flags().StringVarP(&preparePlaybook, "prepare-playbook", "", "", "The filename of a playbook to prepare the container before the role runs")
+	testCmd.Flags().StringVarP(&cleanupPlaybook, "cleanup-playbook", "", "", "The filename of a playbook to clean up the container after the tests")
+	testCmd.Flags().StringVarP(&verifyPlaybook, "verify-playbook", "", "tests/verify.yml", "The filename of a playbook of assertions to run after the idempotence test, skipped if missing")
+	testCmd.Flags().IntVarP(&idempotencePasses, "idempotence-passes", "", 1, "Number of idempotence passes, only the final pass must report no changes")
+	testCmd.Flags().IntVarP(&idempotenceMaxChanged, "idempotence-max-changed", "", 0, "Number of changed tasks across all hosts the idempotence test allows")
+	testCmd.Flags().StringArrayVarP(&allowedChangedTasks, "allow-changed-task", "", []string{}, "Name or glob pattern of a task which may report changes during the idempotence test, can be repeated")
+	testCmd.Flags().BoolVarP(&idempotenceAllPlaybooks, "idempotence-all-playbooks", "", false, "Run every playbook in the idempotence test instead of only the first")
+	testCmd.Flags().BoolVarP(&skipSyntax, "skip-syntax", "", false, "Skip the syntax check stage")
+	testCmd.Flags().BoolVarP(&skipConverge, "skip-converge", "", false, "Skip the converge stage which runs the role, along with prepare and check mode")
+	testCmd.Flags().BoolVarP(&skipIdempotence, "skip-idempotence", "", false, "Skip the idempotence test stage")
+	testCmd.Flags().StringSliceVarP(&tags, "tags", "", []string{}, "Only run plays and tasks tagged with these values")
+	testCmd.Flags().StringSliceVarP(&skipTags, "skip-tags", "", []string{}, "Only run plays and tasks whose tags do not match these values")
+	testCmd.Flags().StringVarP(&vaultPasswordFile, "vault-password-file", "", "", "Path to a file containing the vault password")
+	testCmd.Flags().BoolVarP(&become, "become", "", false, "Run the playbook with privilege escalation")
+	testCmd.Flags().StringVarP(&becomeUser, "become-user", "", "", "The user to become, implies --become")
+	testCmd.Flags().StringVarP(&becomeMethod, "become-method", "", "", "The privilege escalation method to use")
+	testCmd.Flags().StringVarP(&execUser, "exec-user", "", "", "User Ansible runs as in the container instead of root, which implies --become (--user selects the image)")
+	testCmd.Flags().IntVarP(&forks, "forks", "", 0, "Number of parallel processes used by Ansible")
+	testCmd.Flags().BoolVarP(&step, "step", "", false, "Confirm each task interactively during the role run")
+	testCmd.Flags().StringVarP(&startAtTask, "start-at-task", "", "", "Start the role run at the task matching this name")
+	testCmd.Flags().StringVarP(&limit, "limit", "", "", "Limit the playbook run to the specified host pattern")
+	testCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "", []string{}, "Extra variables as key=value or @file, can be repeated")
 	testCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Enable quiet mode")
+	testCmd.Flags().StringVarP(&ansibleCfg, "ansible-cfg", "", "", "Path to an ansible.cfg file to use for Ansible commands")
+	testCmd.Flags().StringArrayVarP(&ansibleArgs, "ansible-arg", "", []string{}, "Extra argument of ansible-playbook for the syntax check, role run and idempotence test, added after the arguments of the tool, can be repeated")
+	testCmd.Flags().StringArrayVarP(&ansibleEnv, "ansible-env", "", []string{}, "Environment variables for Ansible commands as KEY=VALUE, can be repeated")
+	testCmd.Flags().StringVarP(&modulePath, "module-path", "", "", "Path to custom modules inside the role, ie library")
+	testCmd.Flags().StringVarP(&filterPluginsPath, "filter-plugins-path", "", "", "Path to filter plugins inside the role, ie filter_plugins")
+	testCmd.Flags().StringVarP(&roleName, "role-name", "", "", "Name to link the role as in the roles path, defaults to the galaxy name")
+	testCmd.Flags().StringVarP(&rolesPath, "roles-path", "", "", "Roles path to link the role into")
 	testCmd.Flags().StringVarP(&source, "source", "s", pwd, "Location of the role to test")
 	testCmd.Flags().BoolVarP(&remote, "remote", "m", false, "Run the test remotely to the container")
+	testCmd.Flags().StringVarP(&reportJUnit, "report-junit", "", "", "Path of a file to write a JUnit XML report of the stages to")
+	testCmd.Flags().StringVarP(&reportJSON, "report-json", "", "", "Path of a file to write a JSON report of the stages to")
+	testCmd.Flags().StringVarP(&reportMarkdown, "report-markdown", "", "", "Path of a file to write a markdown summary of the stages to")
+	testCmd.Flags().StringVarP(&reportSARIF, "report-sarif", "", "", "Path of a file to write the syntax errors and warnings to as SARIF")
+	testCmd.Flags().StringVarP(&logDir, "log-dir", "", "", "Directory to write the output of each stage to, as <distribution>/<stage>.log")
+	testCmd.Flags().BoolVarP(&noDiagnostics, "no-diagnostics", "", false, "Don't collect the logs and journal of the container when a stage fails")
+	testCmd.Flags().BoolVarP(&prefixOutput, "prefix-output", "", false, "Prefix each line of output with the time and the distribution")
+	testCmd.Flags().StringVarP(&notifyWebhook, "notify-webhook", "", "", "URL to POST a JSON summary of the run to when it completes")
+	testCmd.Flags().BoolVarP(&noColor, "no-color", "", false, "Disable the colours of the summary table, which are disabled when not printing to a terminal")
+	testCmd.Flags().BoolVarP(&failFast, "fail-fast", "", false, "Stop testing at the first distribution which fails")
+	testCmd.Flags().BoolVarP(&tap, "tap", "", false, "Print the results of the stages as TAP version 13 on stdout")
+	testCmd.Flags().BoolVarP(&github, "github", "", false, "Report failures and warnings as GitHub Actions annotations and a job summary (detected in Actions)")
 
 	testCmd.MarkFlagRequired("name")
 }
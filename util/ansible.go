@@ -2,10 +2,14 @@ package util
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -26,7 +30,7 @@ func (dist *Distribution) AnsibleHosts(config *AnsibleConfig, report *AnsibleRep
 		"--list-hosts",
 	}
 
-	out, err := AnsiblePlaybook(args, false)
+	out, err := config.ansiblePlaybook(args, buildAnsibleEnv(config), false)
 
 	hosts := []string{}
 
@@ -62,41 +66,80 @@ func (dist *Distribution) AnsibleHosts(config *AnsibleConfig, report *AnsibleRep
 
 // IdempotenceTestRemote will run an Ansible playbook once and check the
 // output for any changed or failed tasks as reported by Ansible.
-func (dist *Distribution) IdempotenceTestRemote(config *AnsibleConfig) (bool, time.Duration) {
+func (dist *Distribution) IdempotenceTestRemote(config *AnsibleConfig, report *AnsibleReport) (bool, time.Duration) {
 
 	// Test role idempotence.
 	if !config.Quiet {
 		log.Infoln("Testing role idempotence...")
 	}
 
-	// Adjust the playbook path.
-	if strings.HasPrefix(config.PlaybookFile, "/") {
-		config.PlaybookFile = fmt.Sprintf("/%v", config.PlaybookFile)
-	} else {
-		strings.Replace(config.PlaybookFile, config.RemotePath, "./", -1)
-	}
+	// Build the inventory, which resolves all hosts to the container.
+	inventory, cleanup := dist.buildInventoryArgs(config)
+	defer cleanup()
 
-	args := []string{
-		config.PlaybookFile,
-		"-i",
-		dist.CID + ",",
-		"-c",
-		"docker",
+	// The playbooks are run together, so the recap covers all of them.
+	args := config.remotePlaybooks(config.idempotencePlaybooks())
+	args = append(args, "-c", connectionPlugin(config))
+	args = append(args, inventory...)
+
+	// Add diff if configured
+	if config.Diff {
+		args = append(args, "--diff")
 	}
 
+	// Add the arguments common to every playbook run.
+	args = append(args, buildAnsibleArgs(config)...)
+
 	// Add verbose if configured
 	if config.Verbose {
 		args = append(args, "-vvvv")
 	}
 
-	var idempotence = false
+	// Add the extra arguments after every other argument.
+	args = withAnsibleArgs(config, "ansible-playbook", args)
+
+	// Roles may need more than one pass to settle, only the
+	// result of the final pass is used.
+	passes := config.IdempotencePasses
+	if passes < 1 {
+		passes = 1
+	}
+
+	var out string
+	var err error
 	now := time.Now()
-	if !config.Quiet {
-		out, _ := AnsiblePlaybook(args, true)
-		idempotence = IdempotenceResult(out)
-	} else {
-		out, _ := AnsiblePlaybook(args, false)
-		idempotence = IdempotenceResult(out)
+	for pass := 1; pass <= passes; pass++ {
+		if passes > 1 && !config.Quiet {
+			log.Infof("Idempotence pass %v of %v", pass, passes)
+		}
+
+		// Record the run which will reuse the cached facts.
+		report.factCacheReused(config)
+
+		if !config.Quiet {
+			out, err = config.ansiblePlaybook(args, buildAnsibleEnv(config), true)
+		} else {
+			out, err = config.ansiblePlaybook(args, buildAnsibleEnv(config), false)
+		}
+		if err == ErrTimeout {
+			report.timedOut("idempotence", out)
+			break
+		}
+		if err == ErrInterrupted {
+			break
+		}
+		report.Ansible.Idempotence.Changed = append(report.Ansible.Idempotence.Changed, config.changedCount(out))
+	}
+	idempotence := err != ErrTimeout && err != ErrInterrupted && config.idempotenceResult(out, report)
+	report.checkUnreachable("idempotence", report.Ansible.Idempotence.Stats)
+	report.recordOutput(config, "idempotence", out)
+	if !idempotence {
+		report.recordFailedIdempotence(config, out)
+	}
+
+	// Keep the output so the diff can be written to the report.
+	if config.Diff {
+		report.Ansible.Idempotence.Output = out
 	}
 
 	if !config.Quiet {
@@ -107,79 +150,565 @@ func (dist *Distribution) IdempotenceTestRemote(config *AnsibleConfig) (bool, ti
 
 }
 
-// RoleTestRemote will execute the specified playbook outside the
-// container once. It will assemble a request to  pass into the
-// Docker execution function DockerRun.
-func (dist *Distribution) RoleTestRemote(config *AnsibleConfig) (bool, time.Duration) {
+// RoleTestRemote will execute the specified playbooks outside the
+// container once, in order. It will assemble a request to pass into
+// the Docker execution function DockerRun. The run stops at the first
+// playbook which fails.
+func (dist *Distribution) RoleTestRemote(config *AnsibleConfig, report *AnsibleReport) (bool, time.Duration) {
 
 	// Test role.
 	if !config.Quiet {
 		log.Infoln("Running the role...")
 	}
 
-	// Adjust the playbook path.
-	if strings.HasPrefix(config.PlaybookFile, "/") {
-		config.PlaybookFile = fmt.Sprintf("/%v", config.PlaybookFile)
-	} else {
-		strings.Replace(config.PlaybookFile, config.RemotePath, "./", -1)
-		//config.PlaybookFile = fmt.Sprintf("./%v", config.PlaybookFile)
+	// Build the inventory, which resolves all hosts to the container.
+	inventory, cleanup := dist.buildInventoryArgs(config)
+	defer cleanup()
+
+	now := time.Now()
+	for _, playbook := range config.remotePlaybooks(config.playbooks()) {
+		result, duration := report.retryRun(config, playbook, func() (bool, time.Duration) {
+			return dist.roleTestPlaybookRemote(config, report, playbook, inventory)
+		})
+		report.Ansible.Run.Playbooks = append(report.Ansible.Run.Playbooks, PlaybookReport{
+			File:   playbook,
+			Result: result,
+			Time:   duration,
+		})
+		if !result {
+			return false, time.Since(now)
+		}
+	}
+
+	if !config.Quiet {
+		log.Infof("Role ran in %v", time.Since(now))
 	}
+	return true, time.Since(now)
+}
+
+// remotePlaybooks will return the paths of the playbooks of a remote
+// run, where playbooks below the remote path are relative to it. The
+// config is left untouched, so every run starts from the same paths.
+func (config *AnsibleConfig) remotePlaybooks(playbooks []string) []string {
+	paths := []string{}
+	for _, playbook := range playbooks {
+		if !strings.HasPrefix(playbook, "/") {
+			playbook = strings.Replace(playbook, config.RemotePath, "./", -1)
+		}
+		paths = append(paths, playbook)
+	}
+	return paths
+}
+
+// roleTestPlaybookRemote will execute a single playbook outside the container.
+func (dist *Distribution) roleTestPlaybookRemote(config *AnsibleConfig, report *AnsibleReport, playbook string, inventory []string) (bool, time.Duration) {
 
 	args := []string{
-		fmt.Sprintf("%v/%v", config.RemotePath, config.PlaybookFile),
-		"-i",
-		dist.CID + ",",
+		fmt.Sprintf("%v/%v", config.RemotePath, playbook),
 		"-c",
-		"docker",
+		connectionPlugin(config),
 	}
+	args = append(args, inventory...)
+
+	// Add diff if configured
+	if config.Diff {
+		args = append(args, "--diff")
+	}
+
+	// Add the arguments for the role run.
+	args = append(args, buildRunArgs(config)...)
+
+	// Add the arguments common to every playbook run.
+	args = append(args, buildAnsibleArgs(config)...)
 
 	// Add verbose if configured
 	if config.Verbose {
 		args = append(args, "-vvvv")
 	}
 
+	// Add the extra arguments after every other argument.
+	args = withAnsibleArgs(config, "ansible-playbook", args)
+
+	// Record the run which will reuse the cached facts.
+	report.factCacheReused(config)
+
+	var out string
+	var err error
 	now := time.Now()
 	if !config.Quiet {
-		if _, err := AnsiblePlaybook(args, true); err != nil {
-			log.Errorln(err)
+		out, err = config.ansiblePlaybook(args, buildAnsibleEnv(config.profiled()), true)
+	} else {
+		out, err = config.ansiblePlaybook(args, buildAnsibleEnv(config.profiled()), false)
+	}
+	report.recordOutput(config, "converge", out)
+	report.recordTaskTimings(config, out)
+	if err == ErrTimeout {
+		report.timedOut("run", out)
+	}
+	if err != nil {
+		// Unreachable hosts exit with an error, but are still recorded.
+		stats, _ := config.playStats(out)
+		report.checkUnreachable("run", stats)
+		log.Errorln(err)
+		return false, time.Since(now)
+	}
+
+	// Warnings fail the role run in strict mode.
+	if !report.checkWarnings(config, out) {
+		return false, time.Since(now)
+	}
+
+	// The recap reports failed and unreachable hosts, which
+	// is only required with the json callback.
+	stats, err := config.playStats(out)
+	if err != nil && config.StdoutCallback == "json" {
+		log.Errorln(err)
+		return false, time.Since(now)
+	}
+	if err == nil {
+		report.Ansible.Run.Stats = report.Ansible.Run.Stats.Add(stats)
+		if !report.checkUnreachable("run", stats) || config.failedStats(stats) {
 			return false, time.Since(now)
 		}
+	}
+
+	return true, time.Since(now)
+}
+
+// RoleVerifyRemote will run the verify playbook outside the container
+// after the idempotence test, any failed task fails the tests.
+func (dist *Distribution) RoleVerifyRemote(config *AnsibleConfig, report *AnsibleReport) (bool, time.Duration) {
+
+	report.Ansible.Verify.Enabled = true
+
+	if !config.Quiet {
+		log.Infoln("Verifying the role...")
+	}
+
+	// Build the inventory, which resolves all hosts to the container.
+	inventory, cleanup := dist.buildInventoryArgs(config)
+	defer cleanup()
+
+	args := []string{
+		fmt.Sprintf("%v/%v", config.RemotePath, config.VerifyPlaybook),
+		"-c",
+		connectionPlugin(config),
+	}
+	args = append(args, inventory...)
+
+	// Add the arguments common to every playbook run.
+	args = append(args, buildAnsibleArgs(config)...)
+
+	// Add verbose if configured
+	if config.Verbose {
+		args = append(args, "-vvvv")
+	}
+
+	var out string
+	var err error
+	now := time.Now()
+	if !config.Quiet {
+		out, err = config.ansiblePlaybook(args, buildAnsibleEnv(config), true)
 	} else {
-		if _, err := AnsiblePlaybook(args, false); err != nil {
-			log.Errorln(err)
+		out, err = config.ansiblePlaybook(args, buildAnsibleEnv(config), false)
+	}
+	report.recordOutput(config, "verify", out)
+	if err == ErrTimeout {
+		report.timedOut("verify", out)
+	}
+	if err != nil {
+		// Unreachable hosts exit with an error, but are still recorded.
+		stats, _ := config.playStats(out)
+		report.checkUnreachable("verify", stats)
+		log.Errorln("Verify: FAIL")
+		return false, time.Since(now)
+	}
+
+	// The recap reports failed and unreachable hosts, which
+	// is only required with the json callback.
+	stats, err := config.playStats(out)
+	if err != nil && config.StdoutCallback == "json" {
+		log.Errorln(err)
+		return false, time.Since(now)
+	}
+	if err == nil {
+		report.Ansible.Verify.Stats = stats
+		if !report.checkUnreachable("verify", stats) || config.failedStats(stats) {
+			log.Errorln("Verify: FAIL")
 			return false, time.Since(now)
 		}
 	}
+
 	if !config.Quiet {
-		log.Infof("Role ran in %v", time.Since(now))
+		log.Infoln("Verify: PASS")
+		log.Infof("Role was verified in %v", time.Since(now))
 	}
 	return true, time.Since(now)
 }
 
+// CheckTestRemote will execute the specified playbook outside the
+// container once in check mode, reporting the changes which would
+// be made without applying them. Tasks which don't support check
+// mode are skipped by Ansible and are not treated as failures.
+func (dist *Distribution) CheckTestRemote(config *AnsibleConfig) (bool, time.Duration) {
+
+	// Test role in check mode.
+	if !config.Quiet {
+		log.Infoln("Running the role in check mode...")
+	}
+
+	// Build the inventory, which resolves all hosts to the container.
+	inventory, cleanup := dist.buildInventoryArgs(config)
+	defer cleanup()
+
+	args := []string{
+		fmt.Sprintf("%v/%v", config.RemotePath, config.PlaybookFile),
+		"-c",
+		connectionPlugin(config),
+		"--check",
+		"--diff",
+	}
+	args = append(args, inventory...)
+
+	// Add the arguments common to every playbook run.
+	args = append(args, buildAnsibleArgs(config)...)
+
+	// Add verbose if configured
+	if config.Verbose {
+		args = append(args, "-vvvv")
+	}
+
+	now := time.Now()
+	if _, err := config.ansiblePlaybook(args, buildAnsibleEnv(config), !config.Quiet); err != nil {
+		log.Errorln(err)
+		return false, time.Since(now)
+	}
+	if !config.Quiet {
+		log.Infof("Check mode ran in %v", time.Since(now))
+	}
+	return true, time.Since(now)
+}
+
+// ParseExtraVars will separate the values of the --extra-vars flag into
+// a map of variables and a list of variable files. Values are expected
+// to be in the format key=value, or @path for a variable file.
+func ParseExtraVars(values []string) (map[string]string, []string, error) {
+
+	vars := map[string]string{}
+	files := []string{}
+
+	for _, value := range values {
+		if strings.HasPrefix(value, "@") {
+			files = append(files, strings.TrimPrefix(value, "@"))
+			continue
+		}
+		pair := strings.SplitN(value, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			return vars, files, fmt.Errorf("invalid extra variable '%v', expected key=value or @file", value)
+		}
+		vars[pair[0]] = pair[1]
+	}
+
+	return vars, files, nil
+}
+
+// buildAnsibleArgs returns a list of arguments for ansible-playbook which
+// are shared by every stage. Arguments are passed directly to exec.Cmd,
+// so each value must be a single entry and is never split by a shell.
+func buildAnsibleArgs(config *AnsibleConfig) []string {
+	args := []string{}
+
+	// Variables are encoded as JSON so values containing
+	// spaces or quotes will arrive to Ansible intact.
+	if len(config.ExtraVars) > 0 {
+		if vars, err := json.Marshal(config.ExtraVars); err == nil {
+			args = append(args, "--extra-vars", string(vars))
+		}
+	}
+
+	// Variable files inside the container are relative to the role.
+	for _, file := range config.ExtraVarsFiles {
+		if !config.Remote && !strings.HasPrefix(file, "/") {
+			file = fmt.Sprintf("%v/%v", config.RemotePath, strings.TrimPrefix(file, "./"))
+		}
+		args = append(args, "--extra-vars", "@"+file)
+	}
+
+	if len(config.Tags) > 0 {
+		args = append(args, "--tags", strings.Join(config.Tags, ","))
+	}
+
+	if len(config.SkipTags) > 0 {
+		args = append(args, "--skip-tags", strings.Join(config.SkipTags, ","))
+	}
+
+	if config.Limit != "" {
+		args = append(args, "--limit", config.Limit)
+	}
+
+	if config.Forks > 0 {
+		args = append(args, "--forks", strconv.Itoa(config.Forks))
+	}
+
+	// The docker connection runs as the user when Ansible is remote.
+	if config.ExecUser != "" && config.Remote {
+		args = append(args, "--user", config.ExecUser)
+	}
+
+	// A become user has no effect without --become, so it is implied,
+	// as it is for unprivileged users.
+	if config.Become || config.BecomeUser != "" || config.ExecUser != "" {
+		args = append(args, "--become")
+	}
+
+	if config.BecomeUser != "" {
+		args = append(args, "--become-user", config.BecomeUser)
+	}
+
+	if config.BecomeMethod != "" {
+		args = append(args, "--become-method", config.BecomeMethod)
+	}
+
+	if config.VaultPasswordFile != "" {
+		if config.Remote {
+			args = append(args, "--vault-password-file", config.VaultPasswordFile)
+		} else {
+			args = append(args, "--vault-password-file", vaultPasswordPath)
+		}
+	}
+
+	return args
+}
+
+// withAnsibleArgs will append the extra arguments of ansible-playbook to
+// the arguments of the command, after every argument of the tool, where
+// the command is ansible-playbook.
+// The command line is shown in verbose mode.
+func withAnsibleArgs(config *AnsibleConfig, command string, args []string) []string {
+	args = append(args, config.AnsibleArgs...)
+	if config.Verbose && !config.Quiet {
+		log.Infof("Running %v", commandLine(command, args))
+	}
+	return args
+}
+
+// withAnsibleExecArgs will append the extra arguments of ansible-playbook
+// to the command run in the container, like withAnsibleArgs.
+func withAnsibleExecArgs(config *AnsibleConfig, exec execCommand) execCommand {
+	exec.Cmd = append(exec.Cmd, config.AnsibleArgs...)
+	if config.Verbose && !config.Quiet {
+		log.Infof("Running %v", commandLine("docker", exec.args()))
+	}
+	return exec
+}
+
+// commandLine will return the command and its arguments as they would
+// be typed in a shell, where arguments are quoted when needed.
+func commandLine(command string, args []string) string {
+	words := []string{command}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+			arg = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+		words = append(words, arg)
+	}
+	return strings.Join(words, " ")
+}
+
+// buildRunArgs returns a list of arguments for ansible-playbook which
+// only apply to the role run, and not to the syntax check or idempotence
+// test. Task names are passed as a single argument, so spaces and colons
+// are preserved without any quoting.
+func buildRunArgs(config *AnsibleConfig) []string {
+	args := []string{}
+
+	if config.StartAtTask != "" {
+		args = append(args, fmt.Sprintf("--start-at-task=%v", config.StartAtTask))
+	}
+
+	if config.Step {
+		args = append(args, "--step")
+	}
+
+	return args
+}
+
+// buildAnsibleEnv returns the environment variables which should be
+// set for every Ansible command. Variables with an empty value should
+// be unset rather than set to an empty string.
+func buildAnsibleEnv(config *AnsibleConfig) map[string]string {
+	env := map[string]string{}
+
+	if config.AnsibleCfg != "" {
+		if config.Remote {
+			env["ANSIBLE_CONFIG"] = config.AnsibleCfg
+		} else {
+			env["ANSIBLE_CONFIG"] = ansibleCfgPath
+		}
+	}
+
+	if config.StdoutCallback != "" {
+		env["ANSIBLE_STDOUT_CALLBACK"] = config.StdoutCallback
+	}
+
+	// Keep the default roles path so other roles can still be found.
+	if config.RolesPath != "" {
+		if config.Remote && os.Getenv("ANSIBLE_ROLES_PATH") != "" {
+			env["ANSIBLE_ROLES_PATH"] = config.RolesPath + ":" + os.Getenv("ANSIBLE_ROLES_PATH")
+		} else {
+			env["ANSIBLE_ROLES_PATH"] = config.RolesPath + ":" + defaultAnsibleRolesPath
+		}
+	}
+
+	if config.CollectionsPath != "" {
+		if config.Remote && os.Getenv("ANSIBLE_COLLECTIONS_PATH") != "" {
+			env["ANSIBLE_COLLECTIONS_PATH"] = config.CollectionsPath + ":" + os.Getenv("ANSIBLE_COLLECTIONS_PATH")
+		} else {
+			env["ANSIBLE_COLLECTIONS_PATH"] = config.CollectionsPath + ":" + defaultAnsibleCollectionsPath
+		}
+	}
+
+	if config.ModulePath != "" {
+		env["ANSIBLE_LIBRARY"] = rolePath(config, config.ModulePath)
+	}
+
+	if config.FilterPluginsPath != "" {
+		env["ANSIBLE_FILTER_PLUGINS"] = rolePath(config, config.FilterPluginsPath)
+	}
+
+	// Facts are only gathered when they are missing from the cache.
+	if config.FactCachePath != "" {
+		env["ANSIBLE_GATHERING"] = "smart"
+		env["ANSIBLE_CACHE_PLUGIN"] = "jsonfile"
+		env["ANSIBLE_CACHE_PLUGIN_CONNECTION"] = config.FactCachePath
+	}
+
+	for key, value := range config.galaxyServerEnv() {
+		env[key] = value
+	}
+
+	// Variables from the configuration take precedence.
+	for key, value := range config.Env {
+		env[key] = value
+	}
+
+	return env
+}
+
+// rolePath will resolve a path inside the role to the location Ansible
+// will find it. Relative paths are resolved against HostPath, and paths
+// are rewritten to the RemotePath location when run inside the container.
+func rolePath(config *AnsibleConfig, path string) string {
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(config.HostPath, path)
+	}
+
+	if !config.Remote && strings.HasPrefix(path, config.HostPath) {
+		path = config.RemotePath + strings.TrimPrefix(path, config.HostPath)
+	}
+
+	return path
+}
+
+// mergeEnv will merge a map of environment variables over a list of
+// variables in the format KEY=VALUE, as returned by os.Environ().
+// Variables in the map with an empty value are removed from the result.
+func mergeEnv(base []string, env map[string]string) []string {
+	merged := []string{}
+
+	for _, variable := range base {
+		key := strings.SplitN(variable, "=", 2)[0]
+		if _, ok := env[key]; !ok {
+			merged = append(merged, variable)
+		}
+	}
+
+	keys := []string{}
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if env[key] != "" {
+			merged = append(merged, fmt.Sprintf("%v=%v", key, env[key]))
+		}
+	}
+
+	return merged
+}
+
+// ParseEnv will convert a list of values in the format KEY=VALUE into a
+// map of environment variables. A value of KEY= will unset the variable.
+func ParseEnv(values []string) (map[string]string, error) {
+
+	env := map[string]string{}
+
+	for _, value := range values {
+		pair := strings.SplitN(value, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			return env, fmt.Errorf("invalid environment variable '%v', expected KEY=VALUE", value)
+		}
+		env[pair[0]] = pair[1]
+	}
+
+	return env, nil
+}
+
 // AnsiblePlaybook will execute a command to the ansible-playbook
 // binary and use the input args as arguments for that process.
+// Environment variables in env are merged over the environment
+// of the process, and variables with an empty value are unset.
 // You can request output be printed using the bool stdout.
-func AnsiblePlaybook(args []string, stdout bool) (string, error) {
+func AnsiblePlaybook(args []string, env map[string]string, stdout bool) (string, error) {
+	return defaultOutput.ansiblePlaybook(context.Background(), args, env, stdout)
+}
 
-	// If we haven't found Ansible yet, we should look for it.
-	if ansibleplaybook == "" {
-		a, e := exec.LookPath("ansible-playbook")
-		if e != nil {
-			log.Errorln("executable 'ansible-playbook' was not found in $PATH.")
-		}
-		ansibleplaybook = a
+// ansiblePlaybook will run ansible-playbook like AnsiblePlaybook,
+// writing its output to the output of the distribution, which is
+// stopped once the timeout of the stage is reached.
+func (config *AnsibleConfig) ansiblePlaybook(args []string, env map[string]string, stdout bool) (string, error) {
+	return config.output().ansiblePlaybook(config.stageContext(), args, env, stdout)
+}
+
+// ansiblePlaybook will run ansible-playbook like AnsiblePlaybook,
+// writing its output to the output.
+func (output *Output) ansiblePlaybook(ctx context.Context, args []string, env map[string]string, stdout bool) (string, error) {
+	return output.ansibleCommand(ctx, ansiblePlaybookPath(), args, env, stdout)
+}
+
+// ansibleGalaxy will run ansible-galaxy on the host machine, which is
+// found next to ansible-playbook, writing its output to the output of
+// the distribution.
+func (config *AnsibleConfig) ansibleGalaxy(args []string, stdout bool) (string, error) {
+	bin, err := ansibleTool("ansible-galaxy")
+	if err != nil {
+		return "", err
 	}
+	return config.output().ansibleCommand(config.stageContext(), bin, args, buildAnsibleEnv(config), stdout)
+}
+
+// ansibleCommand will run the Ansible binary on the host machine,
+// writing its output to the output. The command is stopped once ctx is
+// done or the run is interrupted.
+func (output *Output) ansibleCommand(ctx context.Context, bin string, args []string, env map[string]string, stdout bool) (string, error) {
 
 	// Generate the command, based on input.
 	cmd := exec.Cmd{}
-	cmd.Path = ansibleplaybook
-	cmd.Args = []string{ansibleplaybook}
+	cmd.Path = bin
+	cmd.Args = []string{bin}
 
 	// Add our arguments to the command.
 	cmd.Args = append(cmd.Args, args...)
 
+	// Add our environment to the command.
+	if len(env) > 0 {
+		cmd.Env = mergeEnv(os.Environ(), env)
+	}
+
 	// If configured, print to os.Stdout.
-	if stdout {
+	if stdout && output.interactive() {
 		cmd.Stdout = os.Stdout
 		cmd.Stdin = os.Stdin
 		cmd.Stderr = os.Stderr
@@ -187,20 +716,19 @@ func AnsiblePlaybook(args []string, stdout bool) (string, error) {
 
 	// Create a buffer for the output.
 	var out bytes.Buffer
-	multi := io.MultiWriter(&out)
+	multi, done := output.writer(&out, stdout)
+	defer done()
 
-	//if stdout && !noOutput {
-	if stdout {
-		multi = io.MultiWriter(&out, os.Stdout)
-	}
-
-	// Assign the output to the writer.
+	// Assign the output to the writer, warnings are printed to stderr.
 	cmd.Stdout = multi
+	cmd.Stderr = multi
 
 	// Check the errors, return as needed.
 	var wg sync.WaitGroup
 	wg.Add(1)
-	if err := cmd.Run(); err != nil {
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+	if err := runCommand(ctx, &cmd); err != nil {
 		log.Errorln(err)
 		return out.String(), err
 	}
@@ -213,42 +741,58 @@ func AnsiblePlaybook(args []string, stdout bool) (string, error) {
 // RoleSyntaxCheckRemote will run a syntax check of the specified container.
 // This helps with pure isolation of the syntax to separate it from other
 // potential Ansible versions.
-func (dist *Distribution) RoleSyntaxCheckRemote(config *AnsibleConfig) bool {
+func (dist *Distribution) RoleSyntaxCheckRemote(config *AnsibleConfig, report *AnsibleReport) bool {
 
 	// Ansible syntax check.
 	if !config.Quiet {
 		log.Infoln("Checking role syntax...")
 	}
 
-	args := []string{
-		config.PlaybookFile,
-		"-i",
-		dist.CID + ",",
-		"-c",
-		"docker",
-		"--syntax-check",
-	}
+	// Build the inventory, which resolves all hosts to the container.
+	inventory, cleanup := dist.buildInventoryArgs(config)
+	defer cleanup()
+
+	// Check every playbook which will be run.
+	args := append([]string{}, config.playbooks()...)
+	args = append(args, "-c", connectionPlugin(config), "--syntax-check")
+	args = append(args, inventory...)
+
+	// Add the arguments common to every playbook run.
+	args = append(args, buildAnsibleArgs(config)...)
 
 	// Add verbose if configured
 	if config.Verbose {
 		args = append(args, "-vvvv")
 	}
 
+	// Add the extra arguments after every other argument.
+	args = withAnsibleArgs(config, "ansible-playbook", args)
+
+	var out string
+	var err error
 	if !config.Quiet {
-		_, err := AnsiblePlaybook(args, true)
-		if err != nil {
+		out, err = config.ansiblePlaybook(args, buildAnsibleEnv(config), true)
+	} else {
+		out, err = config.ansiblePlaybook(args, buildAnsibleEnv(config), false)
+	}
+	report.recordOutput(config, "syntax", out)
+	if err != nil {
+		if !config.Quiet {
 			log.Errorln("Syntax check: FAIL")
-			return false
 		} else {
-			log.Infoln("Syntax check: PASS")
-			return true
-		}
-	} else {
-		_, err := AnsiblePlaybook(args, false)
-		if err != nil {
 			log.Errorln(err)
-			return false
 		}
+		return false
+	}
+
+	// Warnings fail the syntax check in strict mode.
+	if !report.checkWarnings(config, out) {
+		log.Errorln("Syntax check: FAIL")
+		return false
+	}
+
+	if !config.Quiet {
+		log.Infoln("Syntax check: PASS")
 	}
 	return true
 }
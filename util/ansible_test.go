@@ -0,0 +1,330 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBuildAnsibleArgs(t *testing.T) {
+
+	Convey("Setup", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Extra variables", func() {
+			Convey("A map is passed as a single JSON argument", func() {
+				config := AnsibleConfig{
+					ExtraVars: map[string]string{
+						"greeting": `say "hello world"`,
+						"user":     "it's me",
+					},
+				}
+				args := buildAnsibleArgs(&config)
+				So(args, ShouldResemble, []string{
+					"--extra-vars",
+					`{"greeting":"say \"hello world\"","user":"it's me"}`,
+				})
+			})
+
+			Convey("Files are prefixed with @", func() {
+				config := AnsibleConfig{
+					Remote:         true,
+					ExtraVarsFiles: []string{"tests/vars.yml", "/tmp/other vars.yml"},
+				}
+				args := buildAnsibleArgs(&config)
+				So(args, ShouldResemble, []string{
+					"--extra-vars", "@tests/vars.yml",
+					"--extra-vars", "@/tmp/other vars.yml",
+				})
+			})
+
+			Convey("Relative files are mapped into the container", func() {
+				config := AnsibleConfig{
+					RemotePath:     "/etc/ansible/roles/role_under_test",
+					ExtraVarsFiles: []string{"./tests/vars.yml"},
+				}
+				args := buildAnsibleArgs(&config)
+				So(args, ShouldResemble, []string{
+					"--extra-vars", "@/etc/ansible/roles/role_under_test/tests/vars.yml",
+				})
+			})
+
+			Convey("No variables adds no arguments", func() {
+				config := AnsibleConfig{}
+				So(buildAnsibleArgs(&config), ShouldBeEmpty)
+			})
+		})
+
+		Convey("Tags", func() {
+			Convey("Tags and skipped tags are joined", func() {
+				config := AnsibleConfig{
+					Tags:     []string{"a", "b"},
+					SkipTags: []string{"c"},
+				}
+				args := buildAnsibleArgs(&config)
+				So(args, ShouldResemble, []string{
+					"--tags", "a,b",
+					"--skip-tags", "c",
+				})
+			})
+
+			Convey("Empty tags add no arguments", func() {
+				config := AnsibleConfig{
+					Tags:     []string{},
+					SkipTags: []string{},
+				}
+				So(buildAnsibleArgs(&config), ShouldBeEmpty)
+			})
+		})
+
+		Convey("Become", func() {
+			Convey("All become options are added", func() {
+				config := AnsibleConfig{
+					Become:       true,
+					BecomeUser:   "deploy",
+					BecomeMethod: "su",
+				}
+				args := buildAnsibleArgs(&config)
+				So(args, ShouldResemble, []string{
+					"--become",
+					"--become-user", "deploy",
+					"--become-method", "su",
+				})
+			})
+
+			Convey("A become user implies become", func() {
+				config := AnsibleConfig{BecomeUser: "deploy"}
+				args := buildAnsibleArgs(&config)
+				So(args, ShouldResemble, []string{"--become", "--become-user", "deploy"})
+			})
+		})
+
+		Convey("Forks", func() {
+			Convey("Forks are added when set", func() {
+				config := AnsibleConfig{Forks: 10}
+				So(buildAnsibleArgs(&config), ShouldResemble, []string{"--forks", "10"})
+			})
+
+			Convey("Forks are not added when zero", func() {
+				config := AnsibleConfig{Forks: 0}
+				So(buildAnsibleArgs(&config), ShouldNotContain, "--forks")
+			})
+		})
+
+		Convey("Limit", func() {
+			config := AnsibleConfig{Limit: "webservers:&staging"}
+			args := buildAnsibleArgs(&config)
+			So(args, ShouldResemble, []string{"--limit", "webservers:&staging"})
+		})
+	})
+}
+
+func TestParseExtraVars(t *testing.T) {
+
+	Convey("Parsing --extra-vars values", t, func() {
+		vars, files, err := ParseExtraVars([]string{"a=b", "c=d=e", "@vars.yml"})
+		So(err, ShouldBeNil)
+		So(vars, ShouldResemble, map[string]string{"a": "b", "c": "d=e"})
+		So(files, ShouldResemble, []string{"vars.yml"})
+
+		_, _, err = ParseExtraVars([]string{"novalue"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestAnsibleEnv(t *testing.T) {
+
+	Convey("Environment variables", t, func() {
+		config := AnsibleConfig{
+			Env: map[string]string{
+				"ANSIBLE_STDOUT_CALLBACK":   "yaml",
+				"ANSIBLE_HOST_KEY_CHECKING": "",
+			},
+		}
+
+		Convey("Variables are merged over the host environment", func() {
+			env := mergeEnv([]string{
+				"PATH=/usr/bin",
+				"ANSIBLE_STDOUT_CALLBACK=default",
+				"ANSIBLE_HOST_KEY_CHECKING=True",
+			}, buildAnsibleEnv(&config))
+			So(env, ShouldResemble, []string{
+				"PATH=/usr/bin",
+				"ANSIBLE_STDOUT_CALLBACK=yaml",
+			})
+		})
+
+		Convey("Role plugin paths are resolved", func() {
+			config := AnsibleConfig{
+				HostPath:          "/home/user/role",
+				RemotePath:        "/etc/ansible/roles/role_under_test",
+				ModulePath:        "library",
+				FilterPluginsPath: "/home/user/role/filter_plugins",
+			}
+
+			Convey("Inside of the container", func() {
+				env := buildAnsibleEnv(&config)
+				So(env["ANSIBLE_LIBRARY"], ShouldEqual, "/etc/ansible/roles/role_under_test/library")
+				So(env["ANSIBLE_FILTER_PLUGINS"], ShouldEqual, "/etc/ansible/roles/role_under_test/filter_plugins")
+			})
+
+			Convey("On the host", func() {
+				config.Remote = true
+				env := buildAnsibleEnv(&config)
+				So(env["ANSIBLE_LIBRARY"], ShouldEqual, "/home/user/role/library")
+				So(env["ANSIBLE_FILTER_PLUGINS"], ShouldEqual, "/home/user/role/filter_plugins")
+			})
+		})
+
+		Convey("The fact cache is configured when prepared", func() {
+			config := AnsibleConfig{FactCache: true}
+			So(buildAnsibleEnv(&config)["ANSIBLE_CACHE_PLUGIN"], ShouldBeEmpty)
+
+			config.FactCachePath = factCachePath
+			env := buildAnsibleEnv(&config)
+			So(env["ANSIBLE_GATHERING"], ShouldEqual, "smart")
+			So(env["ANSIBLE_CACHE_PLUGIN"], ShouldEqual, "jsonfile")
+			So(env["ANSIBLE_CACHE_PLUGIN_CONNECTION"], ShouldEqual, factCachePath)
+
+			Convey("Only reuses after the first save time", func() {
+				report := AnsibleReport{}
+				report.Ansible.FactCache.Time = time.Second
+				report.factCacheReused(&config)
+				So(report.Ansible.FactCache.Saved, ShouldEqual, time.Duration(0))
+				report.factCacheReused(&config)
+				So(report.Ansible.FactCache.Saved, ShouldEqual, time.Second)
+			})
+		})
+
+		Convey("Variables are exported to docker exec", func() {
+			dist := Distribution{CID: "test"}
+			args := buildExecCommand(&dist, &config).args()
+			So(args, ShouldResemble, []string{
+				"exec",
+				"--tty",
+				"--env", "ANSIBLE_STDOUT_CALLBACK=yaml",
+				"test",
+				"env", "-u", "ANSIBLE_HOST_KEY_CHECKING",
+			})
+		})
+	})
+}
+
+func TestBuildRunArgs(t *testing.T) {
+
+	Convey("Starting at a task", t, func() {
+		config := AnsibleConfig{StartAtTask: "nginx : Configure vhost: default site"}
+		dist := Distribution{CID: "test"}
+
+		args := append(buildExecCommand(&dist, &config).args(), "ansible-playbook")
+		args = append(args, buildRunArgs(&config)...)
+
+		So(args, ShouldResemble, []string{
+			"exec",
+			"--tty",
+			"test",
+			"ansible-playbook",
+			"--start-at-task=nginx : Configure vhost: default site",
+		})
+
+		Convey("Other stages start from the beginning", func() {
+			So(buildAnsibleArgs(&config), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestAnsibleVersion(t *testing.T) {
+
+	Convey("Parsing the Ansible version", t, func() {
+		version, err := parseAnsibleVersion("ansible-playbook [core 2.16.3]\n  config file = None\n")
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, "2.16.3")
+
+		version, err = parseAnsibleVersion("ansible-playbook 2.9.27\n  python version = 3.8.10\n")
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, "2.9.27")
+
+		_, err = parseAnsibleVersion("command not found")
+		So(err, ShouldNotBeNil)
+
+		Convey("Comparing to the minimum version", func() {
+			So(CheckAnsibleVersion("2.16.3", "2.9"), ShouldBeNil)
+			So(CheckAnsibleVersion("2.9.0", "2.9"), ShouldBeNil)
+			So(CheckAnsibleVersion("2.8.20", "2.9"), ShouldNotBeNil)
+			So(CheckAnsibleVersion("2.16.3", "two"), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestExtraArgs(t *testing.T) {
+
+	Convey("Passing extra arguments through to the commands", t, func() {
+
+		Convey("Ansible arguments are added last as separate arguments", func() {
+			config := AnsibleConfig{AnsibleArgs: []string{"--flush-cache", "--extra-vars=greeting='hello world'"}}
+			args := withAnsibleArgs(&config, "ansible-playbook", []string{"site.yml", "-vvvv"})
+			So(args, ShouldResemble, []string{"site.yml", "-vvvv", "--flush-cache", "--extra-vars=greeting='hello world'"})
+		})
+
+		Convey("Docker arguments are added before the image", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: cgroupVolume}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", DockerArgs: []string{"--cap-add", "NET_ADMIN"}}
+			args := buildRunCommand(&dist, &config, &AnsibleReport{}).args()
+			So(args[len(args)-4:], ShouldResemble, []string{"--cap-add", "NET_ADMIN", "image", "/bin/systemd"})
+		})
+
+		Convey("Command lines are quoted like a shell", func() {
+			So(commandLine("ansible-playbook", []string{"site.yml", "--extra-vars", `{"greeting": "it's"}`, ""}), ShouldEqual,
+				`ansible-playbook site.yml --extra-vars '{"greeting": "it'\''s"}' ''`)
+		})
+	})
+}
+
+func TestRemotePlaybooks(t *testing.T) {
+
+	Convey("Running the playbooks from the host", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		dir, _ := ioutil.TempDir("", "remote")
+		defer os.RemoveAll(dir)
+
+		previous := ansibleplaybook
+		defer func() { ansibleplaybook = previous }()
+		ansibleplaybook = writeFixture(dir, "ansible-playbook", `
+#!/bin/sh
+echo "$1" >> "$(dirname "$0")/playbooks"
+echo "PLAY RECAP *********************************************************************"
+echo "test                       : ok=1    changed=0    unreachable=0    failed=0"
+`)
+		os.Chmod(ansibleplaybook, 0755)
+
+		Convey("The playbook of the config is the same for every run", func() {
+			dist := Ubuntu1804
+			dist.CID = "test"
+			config := AnsibleConfig{Quiet: true, Remote: true, RemotePath: dir, PlaybookFile: "/srv/role/tests/playbook.yml"}
+			report := AnsibleReport{}
+
+			for run := 0; run < 2; run++ {
+				result, _ := dist.RoleTestRemote(&config, &report)
+				So(result, ShouldBeTrue)
+				result, _ = dist.IdempotenceTestRemote(&config, &report)
+				So(result, ShouldBeTrue)
+			}
+			So(config.PlaybookFile, ShouldEqual, "/srv/role/tests/playbook.yml")
+
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "playbooks"))
+			playbooks := strings.Fields(string(data))
+			So(playbooks, ShouldHaveLength, 4)
+			So(playbooks[2:], ShouldResemble, playbooks[:2])
+			So(playbooks[1], ShouldEqual, "/srv/role/tests/playbook.yml")
+		})
+	})
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// bootstrapCommands will return the bootstrap commands of the
+// distribution, which are those of its family unless it has its own.
+func (dist *Distribution) bootstrapCommands() []string {
+	if dist.Bootstrap != nil {
+		return dist.Bootstrap
+	}
+	return dist.Family.Bootstrap
+}
+
+// DockerBootstrap will run the bootstrap commands of the distribution in
+// the container once it is ready, in order, which prepare images which
+// don't include everything the tests need, ie Python or Ansible. The
+// first command which fails stops the bootstrap, and its output is
+// returned in the error. With EOLMirrors, distributions past their end
+// of life are first pointed to the archive of their repositories.
+func (dist *Distribution) DockerBootstrap(config *AnsibleConfig) error {
+
+	commands := dist.bootstrapCommands()
+	if mirror := dist.eolMirrorCommand(); mirror != "" && config.EOLMirrors {
+		commands = append([]string{mirror}, commands...)
+	}
+	if config.SkipBootstrap || len(commands) == 0 {
+		return nil
+	}
+
+	if !config.Quiet {
+		log.Printf("Bootstrapping %v", dist.CID)
+	}
+	for _, command := range commands {
+		if _, err := dist.runScript(config, command); err != nil {
+			return fmt.Errorf("unable to bootstrap %v, %v", dist.CID, err)
+		}
+	}
+	return nil
+}
+
+// runScript will run the shell command in the container as root,
+// returning its output, and the end of its output in the error when
+// it fails.
+func (dist *Distribution) runScript(config *AnsibleConfig, command string) (string, error) {
+
+	// Errors are written to the output, so they are kept.
+	out, err := config.dockerExec(dist.containerExec("sh", "-c", "exec 2>&1; "+command), config.Verbose && !config.Quiet)
+	if err != nil {
+		lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+		if len(lines) > outputLines {
+			lines = lines[len(lines)-outputLines:]
+		}
+		return out, fmt.Errorf("%v failed: %v\n%v", command, err, strings.Join(lines, "\n"))
+	}
+	return out, nil
+}
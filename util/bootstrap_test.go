@@ -0,0 +1,93 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBootstrap(t *testing.T) {
+
+	Convey("Bootstrapping the container once it is ready", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("The commands of the family are the defaults", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+			So(dist.DockerBootstrap(&AnsibleConfig{Quiet: true}), ShouldBeNil)
+			So(fake.commands, ShouldHaveLength, 1)
+			So(fake.commands[0][4], ShouldContainSubstring, "apt-get install -y python3")
+
+			So(CentOS7.bootstrapCommands()[0], ShouldContainSubstring, "yum install -y python3")
+			So(JeffRockyLinux9.bootstrapCommands()[0], ShouldContainSubstring, "dnf install -y python3")
+			So(OpenSUSELeap15.bootstrapCommands()[0], ShouldContainSubstring, "zypper")
+		})
+
+		Convey("The commands of the distribution run in order", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Alpine3
+			dist.CID = "test"
+			dist.Bootstrap = []string{"apk add --no-cache python3", "apk add --no-cache ansible"}
+			So(dist.DockerBootstrap(&AnsibleConfig{Quiet: true}), ShouldBeNil)
+			So(fake.commands, ShouldResemble, [][]string{
+				{"exec", "test", "sh", "-c", "exec 2>&1; apk add --no-cache python3"},
+				{"exec", "test", "sh", "-c", "exec 2>&1; apk add --no-cache ansible"},
+			})
+
+			fake.commands = nil
+			dist.Bootstrap = []string{}
+			So(dist.DockerBootstrap(&AnsibleConfig{Quiet: true}), ShouldBeNil)
+			So(fake.commands, ShouldBeEmpty)
+		})
+
+		Convey("A failing command stops the bootstrap with its output", func() {
+			command := "sh -c exec 2>&1; apk add --no-cache python3"
+			fake := &fakeEngine{
+				outputs: map[string][]string{command: {"ERROR: unable to select packages"}},
+				failing: map[string]bool{command: true},
+			}
+			engine = fake
+			dist := Alpine3
+			dist.CID = "test"
+			dist.Bootstrap = []string{"apk add --no-cache python3", "apk add --no-cache ansible"}
+
+			err := dist.DockerBootstrap(&AnsibleConfig{Quiet: true})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "apk add --no-cache python3 failed")
+			So(err.Error(), ShouldContainSubstring, "ERROR: unable to select packages")
+			So(fake.commands, ShouldHaveLength, 1)
+		})
+
+		Convey("The commands are given in the distributions file", func() {
+			builtin := Distributions
+			defer func() { Distributions = builtin }()
+
+			dir, _ := ioutil.TempDir("", "bootstrap")
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, DistributionsFile)
+			ioutil.WriteFile(file, []byte(`{
+  "distributions": [
+    {"name": "internal8", "image": "registry.example.com/base/centos:8", "family": "centos", "bootstrap": ["dnf install -y sudo"]},
+    {"name": "internal9", "image": "registry.example.com/base/centos:9", "family": "centos", "bootstrap": []}
+  ]
+}`), 0644)
+			So(LoadDistributions(file, dir), ShouldBeNil)
+
+			dist, err := GetDistribution("", "", "", "", "", "internal8")
+			So(err, ShouldBeNil)
+			So(dist.bootstrapCommands(), ShouldResemble, []string{"dnf install -y sudo"})
+
+			dist, err = GetDistribution("", "", "", "", "", "internal9")
+			So(err, ShouldBeNil)
+			So(dist.bootstrapCommands(), ShouldBeEmpty)
+		})
+	})
+}
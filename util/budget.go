@@ -0,0 +1,77 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// budgetTotal is the name of the overall budget of the stages.
+const budgetTotal = "total"
+
+// budgetStages are the stages which can have a duration budget.
+var budgetStages = []string{"requirements", "syntax", "prepare", "check", "converge", "idempotence", "verify"}
+
+// ParseBudgets will convert a list of values in the format STAGE=DURATION
+// into the duration budget of each stage, ie converge=5m.
+func ParseBudgets(values []string) (map[string]time.Duration, error) {
+
+	budgets := map[string]time.Duration{}
+
+	for _, value := range values {
+		pair := strings.SplitN(value, "=", 2)
+		if len(pair) != 2 {
+			return budgets, fmt.Errorf("invalid stage budget '%v', expected STAGE=DURATION", value)
+		}
+
+		known := false
+		for _, stage := range budgetStages {
+			if pair[0] == stage {
+				known = true
+			}
+		}
+		if !known {
+			return budgets, fmt.Errorf("invalid stage budget '%v', the stage must be one of %v", value, strings.Join(budgetStages, ", "))
+		}
+
+		budget, err := time.ParseDuration(pair[1])
+		if err != nil || budget <= 0 {
+			return budgets, fmt.Errorf("invalid stage budget '%v', expected a duration such as 5m", value)
+		}
+		budgets[pair[0]] = budget
+	}
+
+	return budgets, nil
+}
+
+// checkBudgets will record the stages which took longer than their
+// duration budget, and the total when the stages together took longer
+// than the maximum duration. The stages are not stopped, but the run
+// fails once they complete.
+func (report *AnsibleReport) checkBudgets(config *AnsibleConfig) {
+
+	for _, stage := range report.stageResults() {
+		budget, ok := config.StageBudgets[stage.name]
+		if ok && report.Ran(stage.name) && stage.time > budget {
+			log.Errorf("The %v stage took %v, which exceeds its budget of %v", stage.name, stage.time, budget)
+			report.Ansible.BudgetExceeded = append(report.Ansible.BudgetExceeded, stage.name)
+		}
+	}
+
+	if config.MaxDuration > 0 && report.duration() > config.MaxDuration {
+		log.Errorf("The stages took %v, which exceeds the maximum duration of %v", report.duration(), config.MaxDuration)
+		report.Ansible.BudgetExceeded = append(report.Ansible.BudgetExceeded, budgetTotal)
+	}
+}
+
+// overBudget will identify if the stage, or the total, exceeded its budget.
+func (report *AnsibleReport) overBudget(stage string) bool {
+	for _, exceeded := range report.Ansible.BudgetExceeded {
+		if exceeded == stage {
+			return true
+		}
+	}
+	return false
+}
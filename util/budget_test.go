@@ -0,0 +1,73 @@
+package util
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBudgets(t *testing.T) {
+
+	Convey("Duration budgets of the stages", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Budgets are parsed per stage", func() {
+			budgets, err := ParseBudgets([]string{"converge=5m", "idempotence=90s"})
+			So(err, ShouldBeNil)
+			So(budgets, ShouldResemble, map[string]time.Duration{"converge": 5 * time.Minute, "idempotence": 90 * time.Second})
+		})
+
+		Convey("Invalid budgets are errors", func() {
+			for _, value := range []string{"converge", "convrge=5m", "converge=soon", "converge=0s"} {
+				_, err := ParseBudgets([]string{value})
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Stages over their budget fail once they complete", func() {
+			config := AnsibleConfig{StageBudgets: map[string]time.Duration{"converge": time.Minute, "idempotence": time.Minute}}
+			report := AnsibleReport{}
+			report.Ansible.Config = config
+			result := report.RunStages(&config, []Stage{
+				{Name: "syntax", Run: func() bool {
+					report.Ansible.Syntax = true
+					return true
+				}},
+				{Name: "converge", Run: func() bool {
+					report.Ansible.Run.Result, report.Ansible.Run.Time = true, 2*time.Minute
+					return true
+				}},
+				{Name: "idempotence", Run: func() bool {
+					report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time = true, 30*time.Second
+					return true
+				}},
+			})
+			So(result, ShouldBeTrue)
+			So(report.Ansible.BudgetExceeded, ShouldResemble, []string{"converge"})
+			So(report.ExitCode(), ShouldEqual, DurationBudgetCode)
+			So(report.NewJSONReport().Passed, ShouldBeFalse)
+			So(report.NewJSONReport().Stages[1].Budget, ShouldEqual, 60.0)
+		})
+
+		Convey("The stages together can exceed the maximum duration", func() {
+			config := AnsibleConfig{MaxDuration: 2 * time.Minute}
+			report := AnsibleReport{}
+			report.Ansible.Stages = []string{"syntax", "converge", "idempotence"}
+			report.Ansible.Run.Time = 90 * time.Second
+			report.Ansible.Idempotence.Time = 40 * time.Second
+			report.checkBudgets(&config)
+			So(report.Ansible.BudgetExceeded, ShouldResemble, []string{"total"})
+		})
+
+		Convey("Failed stages take precedence over the budget", func() {
+			report := AnsibleReport{}
+			report.Ansible.Syntax = true
+			report.Ansible.BudgetExceeded = []string{"total"}
+			So(report.ExitCode(), ShouldEqual, AnsibleRunCode)
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// buildRepository is the repository of the images which are built
+// from a Dockerfile.
+const buildRepository = "ansible-role-tester"
+
+// BuildTag will return the tag of the image built from the Dockerfile,
+// which is derived from the contents of the Dockerfile, the build
+// arguments and the platform so that the same image is only built once.
+func BuildTag(dockerfile string, buildArgs []string, platform string) (string, error) {
+
+	data, err := ioutil.ReadFile(dockerfile)
+	if err != nil {
+		return "", err
+	}
+
+	args := append([]string{}, buildArgs...)
+	sort.Strings(args)
+
+	hash := sha256.New()
+	hash.Write(data)
+	for _, arg := range args {
+		hash.Write([]byte("\x00" + arg))
+	}
+	if platform != "" {
+		hash.Write([]byte("\x00\x00" + platform))
+	}
+
+	return fmt.Sprintf("%v:%v", buildRepository, hex.EncodeToString(hash.Sum(nil))[:12]), nil
+}
+
+// ParseBuildArgs will validate a list of build arguments in the format
+// KEY=VALUE, or KEY to use the value of this environment like docker.
+func ParseBuildArgs(values []string) ([]string, error) {
+	for _, value := range values {
+		key := strings.SplitN(value, "=", 2)[0]
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid build argument '%v', expected KEY=VALUE or KEY", value)
+		}
+	}
+	return values, nil
+}
+
+// DockerBuild will build the image of the Dockerfile of the config and
+// use it as the image of the distribution. The build is skipped when
+// the image already exists, unless BuildAlways is set. The context of
+// the build is the directory of the Dockerfile.
+func (dist *Distribution) DockerBuild(config *AnsibleConfig, report *AnsibleReport) error {
+
+	tag, err := BuildTag(config.Dockerfile, config.BuildArgs, config.Platform)
+	if err != nil {
+		return err
+	}
+	dist.Container = tag
+	report.Docker.Dockerfile = config.Dockerfile
+
+	if !config.BuildAlways && dist.imagePresent() {
+		if !config.Quiet {
+			log.Printf("Using %v built from %v", tag, config.Dockerfile)
+		}
+		return nil
+	}
+
+	build := buildCommand{
+		Dockerfile: config.Dockerfile,
+		Tag:        tag,
+		Labels:     []string{fmt.Sprintf("%v=true", containerLabel)},
+		BuildArgs:  config.BuildArgs,
+		Platform:   config.Platform,
+		Context:    filepath.Dir(config.Dockerfile),
+	}
+
+	if !config.Quiet {
+		log.Printf("Building %v from %v", tag, config.Dockerfile)
+		if config.Verbose {
+			log.Infof("Running %v", commandLine("docker", build.args()))
+		}
+	}
+	if _, err := config.dockerExec(build, !config.Quiet); err != nil {
+		return fmt.Errorf("unable to build %v: %v", config.Dockerfile, err)
+	}
+	report.Docker.Built = true
+
+	return nil
+}
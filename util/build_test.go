@@ -0,0 +1,96 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerBuild(t *testing.T) {
+
+	Convey("Building images from a Dockerfile", t, func() {
+
+		dir, _ := ioutil.TempDir("", "dockerfile")
+		defer os.RemoveAll(dir)
+		dockerfile := filepath.Join(dir, "Dockerfile")
+		ioutil.WriteFile(dockerfile, []byte("FROM ubuntu:18.04\n"), 0644)
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Tags are derived from the Dockerfile and build arguments", func() {
+			tag, err := BuildTag(dockerfile, []string{"A=1", "B=2"}, "")
+			So(err, ShouldBeNil)
+			So(tag, ShouldStartWith, "ansible-role-tester:")
+			So(len(tag), ShouldEqual, len("ansible-role-tester:")+12)
+
+			same, _ := BuildTag(dockerfile, []string{"B=2", "A=1"}, "")
+			So(same, ShouldEqual, tag)
+
+			other, _ := BuildTag(dockerfile, []string{"A=1", "B=3"}, "")
+			So(other, ShouldNotEqual, tag)
+
+			arm, _ := BuildTag(dockerfile, []string{"A=1", "B=2"}, "linux/arm64")
+			So(arm, ShouldNotEqual, tag)
+
+			_, err = BuildTag(filepath.Join(dir, "missing"), nil, "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Build arguments need a key", func() {
+			_, err := ParseBuildArgs([]string{"A=1", "HOME"})
+			So(err, ShouldBeNil)
+			_, err = ParseBuildArgs([]string{"=1"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Missing images are built and used by the distribution", func() {
+			tag, _ := BuildTag(dockerfile, []string{"A=1"}, "")
+			fake := &fakeEngine{missing: map[string]bool{tag: true}}
+			engine = fake
+			dist := Distribution{Container: "fubarhouse/docker-ansible:bionic"}
+			report := AnsibleReport{}
+
+			So(dist.DockerBuild(&AnsibleConfig{Dockerfile: dockerfile, BuildArgs: []string{"A=1"}, Quiet: true}, &report), ShouldBeNil)
+			So(dist.Container, ShouldEqual, tag)
+			So(fake.commands[1], ShouldResemble, []string{"build", "--file=" + dockerfile, "--tag=" + tag, "--label=ansible-role-tester=true", "--build-arg=A=1", dir})
+			So(report.Docker.Dockerfile, ShouldEqual, dockerfile)
+			So(report.Docker.Built, ShouldBeTrue)
+		})
+
+		Convey("Existing images are not rebuilt", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{}
+			report := AnsibleReport{}
+
+			So(dist.DockerBuild(&AnsibleConfig{Dockerfile: dockerfile, Quiet: true}, &report), ShouldBeNil)
+			So(len(fake.commands), ShouldEqual, 1)
+			So(report.Docker.Built, ShouldBeFalse)
+		})
+
+		Convey("Existing images are rebuilt with BuildAlways", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{}
+			report := AnsibleReport{}
+
+			So(dist.DockerBuild(&AnsibleConfig{Dockerfile: dockerfile, BuildAlways: true, Quiet: true}, &report), ShouldBeNil)
+			So(fake.commands[0][0], ShouldEqual, "build")
+			So(report.Docker.Built, ShouldBeTrue)
+		})
+
+		Convey("Built images are not pulled", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{Container: "ansible-role-tester:000000000000"}
+			report := AnsibleReport{}
+
+			So(dist.DockerPull(&AnsibleConfig{Dockerfile: dockerfile, PullPolicy: PullAlways, Quiet: true}, &report), ShouldBeNil)
+			So(fake.commands, ShouldBeEmpty)
+		})
+	})
+}
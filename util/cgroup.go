@@ -0,0 +1,39 @@
+package util
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// cgroupControllers only exists on hosts with the unified cgroup
+// hierarchy, which is cgroup v2.
+var cgroupControllers = "/sys/fs/cgroup/cgroup.controllers"
+
+// CgroupV2 will return true when the docker daemon uses cgroup v2,
+// which is found from the cgroups of this host, or from the daemon
+// itself when it is remote.
+func CgroupV2() bool {
+	if RemoteDaemon() {
+		out, err := dockerExec(cgroupCommand{}, false)
+		return err == nil && strings.TrimSpace(out) == "2"
+	}
+	_, err := os.Stat(cgroupControllers)
+	return err == nil
+}
+
+// cgroupSetup will return the volume of the family and the cgroup
+// namespace of the container, which is empty for the default of docker.
+// With cgroup v2, systemd needs the cgroups mounted read-write in the
+// cgroup namespace of the host, and /run as tmpfs, which tmpfsMounts
+// adds. Families which don't mount the cgroups, and every family with
+// cgroup v1, are run as they are.
+func cgroupSetup(volume string, v2 bool) (string, string) {
+
+	parts := strings.Split(volume, ":")
+	if !v2 || len(parts) < 2 || path.Clean(parts[1]) != "/sys/fs/cgroup" {
+		return volume, ""
+	}
+
+	return parts[0] + ":" + parts[1] + ":rw", "host"
+}
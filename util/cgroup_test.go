@@ -0,0 +1,71 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCgroup(t *testing.T) {
+
+	Convey("Running systemd containers for the cgroup version of the host", t, func() {
+
+		Convey("Hosts with cgroup.controllers use cgroup v2", func() {
+			previous := cgroupControllers
+			defer func() { cgroupControllers = previous }()
+
+			dir, _ := ioutil.TempDir("", "cgroup")
+			defer os.RemoveAll(dir)
+			cgroupControllers = filepath.Join(dir, "cgroup.controllers")
+			So(CgroupV2(), ShouldBeFalse)
+
+			ioutil.WriteFile(cgroupControllers, []byte("cpuset cpu io memory pids\n"), 0644)
+			So(CgroupV2(), ShouldBeTrue)
+		})
+
+		Convey("Remote daemons report their cgroup version", func() {
+			previous := engine
+			defer func() { engine = previous }()
+			os.Setenv("DOCKER_HOST", "tcp://docker.example.com:2376")
+			defer os.Unsetenv("DOCKER_HOST")
+
+			scripted := &scriptedEngine{outputs: map[string]string{"info --format {{.CgroupVersion}}": "2"}}
+			engine = scripted
+			So(CgroupV2(), ShouldBeTrue)
+			So(scripted.commands[0], ShouldResemble, []string{"info", "--format", "{{.CgroupVersion}}"})
+
+			scripted.outputs["info --format {{.CgroupVersion}}"] = "1"
+			So(CgroupV2(), ShouldBeFalse)
+		})
+
+		Convey("The cgroups are mounted read-only with cgroup v1", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test"}
+			args := buildRunCommand(&dist, &config, &AnsibleReport{}).args()
+			So(args, ShouldContain, "--volume=/sys/fs/cgroup:/sys/fs/cgroup:ro")
+			So(args, ShouldNotContain, "--cgroupns=host")
+			So(args, ShouldNotContain, "--tmpfs=/run")
+		})
+
+		Convey("The cgroups are mounted read-write with cgroup v2", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", CgroupV2: true}
+			report := AnsibleReport{}
+			args := buildRunCommand(&dist, &config, &report).args()
+			So(args, ShouldContain, "--volume=/sys/fs/cgroup:/sys/fs/cgroup:rw")
+			So(args, ShouldContain, "--cgroupns=host")
+			So(args, ShouldContain, "--tmpfs=/run")
+			So(args, ShouldContain, "--tmpfs=/run/lock")
+			So(report.Docker.Volumes, ShouldContain, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+		})
+
+		Convey("Families without the cgroups are unchanged with cgroup v2", func() {
+			volume, args := cgroupSetup("/tmp:/tmp", true)
+			So(volume, ShouldEqual, "/tmp:/tmp")
+			So(args, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// collectionsFile is the requirements file of the collections of a
+// role, which is used when the requirements file has no collections.
+var collectionsFile = filepath.Join("collections", "requirements.yml")
+
+// requirementsSections will identify if the requirements file has roles
+// and collections. Files in the older format are a list of roles.
+func requirementsSections(file string) (bool, bool) {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return true, false
+	}
+
+	sections := struct {
+		Roles       []interface{} `yaml:"roles"`
+		Collections []interface{} `yaml:"collections"`
+	}{}
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return true, false
+	}
+
+	return len(sections.Roles) > 0, len(sections.Collections) > 0
+}
+
+// detectCollections will return the path of the requirements file of
+// the collections of the role, or an empty string when it has none.
+func detectCollections(path string) string {
+	if _, err := os.Stat(filepath.Join(path, collectionsFile)); err == nil {
+		return filepath.Join(path, collectionsFile)
+	}
+	return ""
+}
+
+// installsCollections will identify if collections are installed before
+// the role is tested, from a requirements file or the offline directory.
+func (config *AnsibleConfig) installsCollections() bool {
+	return config.CollectionsFile != "" || config.CollectionsOffline != ""
+}
+
+// galaxyCollectionsPath will return the path the collections are
+// installed into, which Ansible searches by default unless it is
+// configured. Remote runs without a path use the path of the host.
+func (config *AnsibleConfig) galaxyCollectionsPath() string {
+	if config.CollectionsPath == "" && !config.Remote {
+		return collectionsPath
+	}
+	return config.CollectionsPath
+}
+
+// CollectionInstall will install the collections into the collections
+// path with ansible-galaxy, inside of the container or on the host when
+// the run is remote. The collections are installed from the tarballs
+// of the offline directory instead of Galaxy when it is configured. The
+// output of ansible-galaxy is returned, and an error is returned when
+// any of the collections can't be resolved.
+func (dist *Distribution) CollectionInstall(config *AnsibleConfig) (string, error) {
+
+	args := []string{"collection", "install"}
+	if config.CollectionsOffline != "" {
+		tarballs, err := filepath.Glob(filepath.Join(config.CollectionsOffline, "*.tar.gz"))
+		if err != nil {
+			return "", err
+		}
+		if len(tarballs) == 0 {
+			return "", fmt.Errorf("no collection tarballs were found in %v", config.CollectionsOffline)
+		}
+		if !config.Quiet {
+			log.Printf("Installing collections from %v\n", config.CollectionsOffline)
+		}
+		args = append(args, "--offline")
+		for _, tarball := range tarballs {
+			if !config.Remote {
+				tarball = offlineCollectionsPath + "/" + filepath.Base(tarball)
+			}
+			args = append(args, tarball)
+		}
+	} else {
+		if !config.Quiet {
+			log.Printf("Installing collections from %v\n", config.CollectionsFile)
+		}
+		args = append(args, "-r", config.CollectionsFile)
+	}
+
+	if path := config.galaxyCollectionsPath(); path != "" {
+		args = append(args, "-p", path)
+	}
+
+	// Replace the collections which are already installed.
+	if config.ForceRequirements {
+		args = append(args, "--force")
+	}
+
+	// Add verbose if configured
+	if config.Verbose {
+		args = append(args, "-vvvv")
+	}
+
+	return dist.ansibleGalaxy(config, args)
+}
@@ -0,0 +1,99 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollections(t *testing.T) {
+
+	Convey("Installing the collections required by the role", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		remote := "/etc/ansible/roles/role_under_test"
+
+		Convey("The collections section of the requirements file is found", func() {
+			dir, _ := ioutil.TempDir("", "collections")
+			defer os.RemoveAll(dir)
+			ioutil.WriteFile(filepath.Join(dir, "requirements.yml"), []byte(`{"roles": [{"name": "geerlingguy.java"}], "collections": [{"name": "community.general"}]}`), 0644)
+			config := AnsibleConfig{HostPath: dir, RemotePath: remote}
+			MapRequirements(&config)
+			So(config.RequirementsFile, ShouldEqual, remote+"/requirements.yml")
+			So(config.CollectionsFile, ShouldEqual, remote+"/requirements.yml")
+
+			ioutil.WriteFile(filepath.Join(dir, "requirements.yml"), []byte(`{"collections": [{"name": "ansible.posix"}]}`), 0644)
+			config = AnsibleConfig{HostPath: dir, RemotePath: remote}
+			MapRequirements(&config)
+			So(config.RequirementsFile, ShouldEqual, "")
+			So(config.CollectionsFile, ShouldEqual, remote+"/requirements.yml")
+		})
+
+		Convey("The collections are found in collections/requirements.yml", func() {
+			dir, _ := ioutil.TempDir("", "collections")
+			defer os.RemoveAll(dir)
+			os.MkdirAll(filepath.Join(dir, "collections"), 0755)
+			ioutil.WriteFile(filepath.Join(dir, "collections", "requirements.yml"), []byte(`{"collections": [{"name": "ansible.posix"}]}`), 0644)
+			config := AnsibleConfig{HostPath: dir, RemotePath: remote}
+			MapRequirements(&config)
+			So(config.RequirementsFile, ShouldEqual, "")
+			So(config.CollectionsFile, ShouldEqual, remote+"/collections/requirements.yml")
+			So(config.installsCollections(), ShouldBeTrue)
+		})
+
+		Convey("The collections are installed into the collections path", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+
+			_, err := dist.CollectionInstall(&AnsibleConfig{Quiet: true, CollectionsFile: remote + "/requirements.yml"})
+			So(err, ShouldBeNil)
+			So(fake.commands[0], ShouldResemble, []string{"exec", "--tty", "test", "ansible-galaxy", "collection", "install", "-r", remote + "/requirements.yml", "-p", "/usr/share/ansible/collections"})
+
+			config := AnsibleConfig{CollectionsPath: "/opt/collections"}
+			So(buildAnsibleEnv(&config)["ANSIBLE_COLLECTIONS_PATH"], ShouldEqual, "/opt/collections:"+defaultAnsibleCollectionsPath)
+		})
+
+		Convey("Offline runs install the tarballs of the directory", func() {
+			dir, _ := ioutil.TempDir("", "collections")
+			defer os.RemoveAll(dir)
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+
+			config := AnsibleConfig{Quiet: true, CollectionsOffline: dir}
+			_, err := dist.CollectionInstall(&config)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "no collection tarballs were found")
+
+			ioutil.WriteFile(filepath.Join(dir, "ansible-posix-1.5.4.tar.gz"), []byte{}, 0644)
+			_, err = dist.CollectionInstall(&config)
+			So(err, ShouldBeNil)
+			So(fake.commands[0][3:], ShouldResemble, []string{"ansible-galaxy", "collection", "install", "--offline", offlineCollectionsPath + "/ansible-posix-1.5.4.tar.gz", "-p", "/usr/share/ansible/collections"})
+		})
+
+		Convey("The collections are installed in the requirements stage", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+			config := AnsibleConfig{Quiet: true, CollectionsFile: remote + "/collections/requirements.yml"}
+			report := AnsibleReport{}
+
+			So(report.RunStages(&config, dist.Stages(&config, &report)[:1]), ShouldBeTrue)
+			So(report.Ansible.Stages, ShouldResemble, []string{"requirements"})
+			So(fake.commands, ShouldHaveLength, 1)
+			So(fake.commands[0][4], ShouldEqual, "collection")
+		})
+	})
+}
@@ -0,0 +1,421 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dockerCommand is a docker command of the tests, which the engines
+// run. The arguments are those of the docker CLI, which runs them
+// with --docker-cli, the Docker Engine API runs the command itself.
+type dockerCommand interface {
+	args() []string
+}
+
+// cliCommand is a command of the docker CLI with any arguments, which
+// only the docker CLI runs.
+type cliCommand []string
+
+// args will return the arguments of the docker CLI.
+func (command cliCommand) args() []string {
+	return command
+}
+
+// versionCommand writes the version of the Docker server.
+type versionCommand struct{}
+
+// args will return the arguments of the docker CLI.
+func (versionCommand) args() []string {
+	return []string{"version", "--format", "{{.Server.Version}}"}
+}
+
+// cgroupCommand writes the cgroup version of the Docker daemon.
+type cgroupCommand struct{}
+
+// args will return the arguments of the docker CLI.
+func (cgroupCommand) args() []string {
+	return []string{"info", "--format", "{{.CgroupVersion}}"}
+}
+
+// psCommand writes the names of the containers which match the
+// filters, ie "label=x" or "status=running", one per line. Stopped
+// containers are only listed with All.
+type psCommand struct {
+	All     bool
+	Filters []string
+}
+
+// args will return the arguments of the docker CLI.
+func (command psCommand) args() []string {
+	args := []string{"ps"}
+	if command.All {
+		args = append(args, "-a")
+	}
+	for _, filter := range command.Filters {
+		args = append(args, "--filter", filter)
+	}
+	return append(args, "--format", "{{.Names}}")
+}
+
+// imagesCommand writes the short ids of the images which match the
+// filters, one per line.
+type imagesCommand struct {
+	Filters []string
+}
+
+// args will return the arguments of the docker CLI.
+func (command imagesCommand) args() []string {
+	args := []string{"images"}
+	for _, filter := range command.Filters {
+		args = append(args, "--filter", filter)
+	}
+	return append(args, "--format", "{{.ID}}")
+}
+
+// networkLsCommand writes the names of the networks which match the
+// filters, one per line.
+type networkLsCommand struct {
+	Filters []string
+}
+
+// args will return the arguments of the docker CLI.
+func (command networkLsCommand) args() []string {
+	args := []string{"network", "ls"}
+	for _, filter := range command.Filters {
+		args = append(args, "--filter", filter)
+	}
+	return append(args, "--format", "{{.Name}}")
+}
+
+// inspectField is a field which is inspected, as the template of the
+// docker CLI which writes it.
+type inspectField string
+
+const (
+
+	// fieldID is the id of an image.
+	fieldID inspectField = "{{.Id}}"
+
+	// fieldName is the name of a network.
+	fieldName inspectField = "{{.Name}}"
+
+	// fieldImage is the id of the image of a container.
+	fieldImage inspectField = "{{.Image}}"
+
+	// fieldCreated is the creation time of a container, image or
+	// network, as a JSON string.
+	fieldCreated inspectField = "{{json .Created}}"
+
+	// fieldDigests are the repository digests of an image.
+	fieldDigests inspectField = `{{join .RepoDigests " "}}`
+
+	// fieldPlatform is the platform of an image, ie linux/arm64.
+	fieldPlatform inspectField = "{{.Os}}/{{.Architecture}}"
+)
+
+// inspectCommand writes the field of the container, image or network,
+// which is the Kind of the resource.
+type inspectCommand struct {
+	Kind  string
+	Field inspectField
+	Name  string
+}
+
+// args will return the arguments of the docker CLI.
+func (command inspectCommand) args() []string {
+	if command.Kind == "network" {
+		return []string{"network", "inspect", "--format", string(command.Field), command.Name}
+	}
+	return []string{"inspect", "--type", command.Kind, "--format", string(command.Field), command.Name}
+}
+
+// runContainer creates and starts a container in the background, which
+// writes the id of the container. The image is pulled when it is
+// missing. Extra are further arguments of the docker CLI, which are
+// given by the user with --docker-arg.
+type runContainer struct {
+	Name       string
+	Labels     []string
+	Cgroupns   string
+	Tmpfs      []string
+	Volumes    []string
+	Network    string
+	Memory     int64
+	CPUs       float64
+	Publish    []string
+	Env        []string
+	Privileged bool
+	Platform   string
+	AutoRemove bool
+	Extra      []string
+	Image      string
+	Cmd        []string
+}
+
+// args will return the arguments of the docker CLI, where the extra
+// arguments are added last, the image must follow the options.
+func (command runContainer) args() []string {
+	args := []string{"run", "--detach", fmt.Sprintf("--name=%v", command.Name)}
+	for _, label := range command.Labels {
+		args = append(args, fmt.Sprintf("--label=%v", label))
+	}
+	if command.Cgroupns != "" {
+		args = append(args, fmt.Sprintf("--cgroupns=%v", command.Cgroupns))
+	}
+	for _, mount := range command.Tmpfs {
+		args = append(args, fmt.Sprintf("--tmpfs=%v", mount))
+	}
+	for _, volume := range command.Volumes {
+		args = append(args, fmt.Sprintf("--volume=%v", volume))
+	}
+	if command.Network != "" {
+		args = append(args, fmt.Sprintf("--network=%v", command.Network))
+	}
+	if command.Memory > 0 {
+		args = append(args, fmt.Sprintf("--memory=%v", command.Memory))
+	}
+	if command.CPUs > 0 {
+		args = append(args, fmt.Sprintf("--cpus=%v", command.CPUs))
+	}
+	for _, port := range command.Publish {
+		args = append(args, fmt.Sprintf("--publish=%v", port))
+	}
+	for _, env := range command.Env {
+		args = append(args, fmt.Sprintf("--env=%v", env))
+	}
+	if command.Privileged {
+		args = append(args, "--privileged")
+	}
+	if command.Platform != "" {
+		args = append(args, fmt.Sprintf("--platform=%v", command.Platform))
+	}
+	if command.AutoRemove {
+		args = append(args, "--rm")
+	}
+	args = append(args, command.Extra...)
+	args = append(args, command.Image)
+	return append(args, command.Cmd...)
+}
+
+// execCommand runs a command in a container and writes its output.
+// Variables of Env without a value are read from the secrets, or from
+// this environment like docker.
+type execCommand struct {
+	Container   string
+	Cmd         []string
+	TTY         bool
+	Interactive bool
+	User        string
+	Workdir     string
+	Env         []string
+
+	// secrets are the values of the variables of Env which are given
+	// by name, which are never part of the arguments.
+	secrets map[string]string
+}
+
+// args will return the arguments of the docker CLI.
+func (command execCommand) args() []string {
+	args := []string{"exec"}
+	if command.TTY {
+		args = append(args, "--tty")
+	}
+	if command.Interactive {
+		args = append(args, "--interactive")
+	}
+	if command.User != "" {
+		args = append(args, "--user", command.User)
+	}
+	if command.Workdir != "" {
+		args = append(args, "--workdir", command.Workdir)
+	}
+	for _, env := range command.Env {
+		args = append(args, "--env", env)
+	}
+	args = append(args, command.Container)
+	return append(args, command.Cmd...)
+}
+
+// env will return the environment of the command, where variables
+// without a value are read from the secrets or this environment, and
+// are left out when neither has them.
+func (command execCommand) env() []string {
+	env := []string{}
+	for _, variable := range command.Env {
+		if strings.Contains(variable, "=") {
+			env = append(env, variable)
+		} else if value, ok := command.secrets[variable]; ok {
+			env = append(env, variable+"="+value)
+		} else if value, ok := os.LookupEnv(variable); ok {
+			env = append(env, variable+"="+value)
+		}
+	}
+	return env
+}
+
+// containerExec will return the command which runs cmd in the
+// container of the distribution, without a terminal.
+func (dist *Distribution) containerExec(cmd ...string) execCommand {
+	return execCommand{Container: dist.CID, Cmd: cmd}
+}
+
+// networkCreateCommand creates a network with the labels.
+type networkCreateCommand struct {
+	Name   string
+	Labels []string
+}
+
+// args will return the arguments of the docker CLI.
+func (command networkCreateCommand) args() []string {
+	args := []string{"network", "create"}
+	for _, label := range command.Labels {
+		args = append(args, fmt.Sprintf("--label=%v", label))
+	}
+	return append(args, command.Name)
+}
+
+// networkRmCommand removes a network.
+type networkRmCommand struct {
+	Name string
+}
+
+// args will return the arguments of the docker CLI.
+func (command networkRmCommand) args() []string {
+	return []string{"network", "rm", command.Name}
+}
+
+// portCommand writes the published ports of a container, one per
+// line, ie "80/tcp -> 0.0.0.0:32768".
+type portCommand struct {
+	Container string
+}
+
+// args will return the arguments of the docker CLI.
+func (command portCommand) args() []string {
+	return []string{"port", command.Container}
+}
+
+// startCommand starts a stopped container.
+type startCommand struct {
+	Container string
+}
+
+// args will return the arguments of the docker CLI.
+func (command startCommand) args() []string {
+	return []string{"start", command.Container}
+}
+
+// stopCommand stops a container, where the init system is given the
+// seconds of Timeout to shut down, or the default of docker when it is
+// zero, before docker kills it.
+type stopCommand struct {
+	Container string
+	Timeout   int
+}
+
+// args will return the arguments of the docker CLI.
+func (command stopCommand) args() []string {
+	if command.Timeout > 0 {
+		return []string{"stop", "--time", fmt.Sprint(command.Timeout), command.Container}
+	}
+	return []string{"stop", command.Container}
+}
+
+// killCommand kills a container.
+type killCommand struct {
+	Container string
+}
+
+// args will return the arguments of the docker CLI.
+func (command killCommand) args() []string {
+	return []string{"kill", command.Container}
+}
+
+// rmCommand removes a container, which is killed first with Force.
+type rmCommand struct {
+	Container string
+	Force     bool
+}
+
+// args will return the arguments of the docker CLI.
+func (command rmCommand) args() []string {
+	if command.Force {
+		return []string{"rm", "--force", command.Container}
+	}
+	return []string{"rm", command.Container}
+}
+
+// rmiCommand removes an image.
+type rmiCommand struct {
+	Image string
+}
+
+// args will return the arguments of the docker CLI.
+func (command rmiCommand) args() []string {
+	return []string{"rmi", command.Image}
+}
+
+// cpCommand copies a path of this host to the destination in a
+// container, whose parent directory must exist.
+type cpCommand struct {
+	Source      string
+	Container   string
+	Destination string
+}
+
+// args will return the arguments of the docker CLI.
+func (command cpCommand) args() []string {
+	return []string{"cp", command.Source, fmt.Sprintf("%v:%v", command.Container, command.Destination)}
+}
+
+// buildCommand builds an image from the Dockerfile in the context,
+// which writes the output of the build.
+type buildCommand struct {
+	Dockerfile string
+	Tag        string
+	Labels     []string
+	BuildArgs  []string
+	Platform   string
+	Context    string
+}
+
+// args will return the arguments of the docker CLI.
+func (command buildCommand) args() []string {
+	args := []string{"build", "--file=" + command.Dockerfile, "--tag=" + command.Tag}
+	for _, label := range command.Labels {
+		args = append(args, "--label="+label)
+	}
+	for _, arg := range command.BuildArgs {
+		args = append(args, "--build-arg="+arg)
+	}
+	if command.Platform != "" {
+		args = append(args, "--platform="+command.Platform)
+	}
+	return append(args, command.Context)
+}
+
+// pullCommand pulls an image, which writes the progress of the pull.
+type pullCommand struct {
+	Image    string
+	Platform string
+}
+
+// args will return the arguments of the docker CLI.
+func (command pullCommand) args() []string {
+	if command.Platform != "" {
+		return []string{"pull", "--platform", command.Platform, command.Image}
+	}
+	return []string{"pull", command.Image}
+}
+
+// logsCommand writes the last lines of the logs of a container.
+type logsCommand struct {
+	Container string
+	Tail      int
+}
+
+// args will return the arguments of the docker CLI.
+func (command logsCommand) args() []string {
+	return []string{"logs", "--tail", fmt.Sprint(command.Tail), command.Container}
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"os/exec"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+
+	// defaultConnection is the connection plugin used to run
+	// playbooks from the host against the container.
+	defaultConnection = "docker"
+
+	// communityConnection is the docker connection plugin from the
+	// community.docker collection, which replaced the builtin plugin.
+	communityConnection = "community.docker.docker"
+
+	// detectedConnection is the connection plugin which was found by
+	// detectConnection, it is only detected once per process.
+	detectedConnection   string
+	detectConnectionOnce sync.Once
+)
+
+// connectionPlugin returns the connection plugin to use for playbooks
+// which are run from the host. The configured plugin is used when it is
+// set, otherwise the available plugin is detected.
+func connectionPlugin(config *AnsibleConfig) string {
+	if config.Connection != "" {
+		return config.Connection
+	}
+
+	detectConnectionOnce.Do(func() {
+		detectedConnection = detectConnection(config.Quiet)
+	})
+
+	return detectedConnection
+}
+
+// detectConnection will check if the builtin docker connection plugin
+// is available using ansible-doc, and fall back to the community plugin
+// when it has been removed from the installed version of Ansible.
+func detectConnection(quiet bool) string {
+
+	bin, err := ansibleTool("ansible-doc")
+	if err != nil {
+		return defaultConnection
+	}
+
+	if exec.Command(bin, "-t", "connection", defaultConnection).Run() == nil {
+		return defaultConnection
+	}
+
+	if exec.Command(bin, "-t", "connection", communityConnection).Run() == nil {
+		if !quiet {
+			log.Infof("Connection plugin %v was not found, using %v", defaultConnection, communityConnection)
+		}
+		return communityConnection
+	}
+
+	log.Warnf("Connection plugins %v and %v were not found", defaultConnection, communityConnection)
+	return defaultConnection
+}
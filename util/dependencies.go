@@ -0,0 +1,252 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// dependenciesPath is the location the requirements file of the
+// dependencies of meta/main.yml is written to inside of the container.
+const dependenciesPath = "/tmp/ansible-role-tester-dependencies.yml"
+
+// MetaDependency is a dependency of meta/main.yml of a role, in the
+// form of a requirement of ansible-galaxy.
+type MetaDependency struct {
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Src     string `json:"src,omitempty" yaml:"src,omitempty"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	Scm     string `json:"scm,omitempty" yaml:"scm,omitempty"`
+}
+
+// role will return the name the dependency is referenced by, which is
+// the name of the directory it is installed into.
+func (dep MetaDependency) role() string {
+	if dep.Name != "" {
+		return dep.Name
+	}
+	return dep.Src
+}
+
+// parseMetaDependency will convert a dependency of meta/main.yml to a
+// requirement, where a dependency is the name of a role, the older
+// src,version,name form, or a map with the role, src, version, name
+// and scm of the role.
+func parseMetaDependency(entry interface{}) (MetaDependency, error) {
+
+	if name, ok := entry.(string); ok {
+		parts := strings.Split(name, ",")
+		dep := MetaDependency{Src: strings.TrimSpace(parts[0])}
+		if len(parts) > 1 {
+			dep.Version = strings.TrimSpace(parts[1])
+		}
+		if len(parts) > 2 {
+			dep.Name = strings.TrimSpace(parts[2])
+		}
+		return dep, nil
+	}
+
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return MetaDependency{}, err
+	}
+	fields := struct {
+		Role    string `yaml:"role"`
+		Name    string `yaml:"name"`
+		Src     string `yaml:"src"`
+		Version string `yaml:"version"`
+		Scm     string `yaml:"scm"`
+	}{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return MetaDependency{}, err
+	}
+
+	dep := MetaDependency{Src: fields.Src, Version: fields.Version, Scm: fields.Scm, Name: fields.Name}
+	if dep.Src == "" {
+		dep.Src = fields.Role
+		if dep.Src == "" {
+			dep.Src, dep.Name = dep.Name, ""
+		}
+	}
+	if dep.Src == "" {
+		return MetaDependency{}, fmt.Errorf("dependency %v has no role, name or src", strings.TrimSpace(string(data)))
+	}
+	return dep, nil
+}
+
+// ReadMetaDependencies will return the dependencies of meta/main.yml of
+// the role, which has none when the file is missing.
+func ReadMetaDependencies(role string) ([]MetaDependency, error) {
+
+	file := filepath.Join(role, "meta", "main.yml")
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return []MetaDependency{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	meta := struct {
+		Dependencies []interface{} `yaml:"dependencies"`
+	}{}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", file, err)
+	}
+
+	deps := []MetaDependency{}
+	for _, entry := range meta.Dependencies {
+		dep, err := parseMetaDependency(entry)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %v: %v", file, err)
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// localDependency will return the path of the role in the directory of
+// the local roles, which is found by the name of the role or the name
+// without its namespace, or an empty string when it isn't there.
+func localDependency(dir string, dep MetaDependency) string {
+	if dir == "" {
+		return ""
+	}
+	name := dep.role()
+	for _, candidate := range []string{name, name[strings.LastIndex(name, ".")+1:]} {
+		path := filepath.Join(dir, candidate)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// resolveDependencies will return the dependencies of the role which are
+// installed from Galaxy, and the paths of the dependencies found in the
+// directory of the local roles by their name. The dependencies of local
+// roles are resolved too, as Galaxy won't find them.
+func resolveDependencies(role, dir string) ([]MetaDependency, map[string]string, error) {
+
+	galaxy := []MetaDependency{}
+	local := map[string]string{}
+	seen := map[string]bool{}
+
+	roles := []string{role}
+	for len(roles) > 0 {
+		deps, err := ReadMetaDependencies(roles[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		roles = roles[1:]
+
+		for _, dep := range deps {
+			if seen[dep.role()] {
+				continue
+			}
+			seen[dep.role()] = true
+			if path := localDependency(dir, dep); path != "" {
+				local[dep.role()] = path
+				roles = append(roles, path)
+			} else {
+				galaxy = append(galaxy, dep)
+			}
+		}
+	}
+
+	return galaxy, local, nil
+}
+
+// installsDependencies will identify if the dependencies of meta/main.yml
+// are installed before the role is tested.
+func (config *AnsibleConfig) installsDependencies() bool {
+	return len(config.Dependencies) > 0 || len(config.LocalDependencies) > 0
+}
+
+// dependencyVolumes will return the volumes which mount the local
+// dependencies into the roles path of the container.
+func (config *AnsibleConfig) dependencyVolumes() []string {
+	names := []string{}
+	for name := range config.LocalDependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	volumes := []string{}
+	for _, name := range names {
+		volumes = append(volumes, fmt.Sprintf("%s:%v/%v:ro", config.LocalDependencies[name], config.galaxyRolesPath(), name))
+	}
+	return volumes
+}
+
+// DependencyInstall will install the dependencies of meta/main.yml into
+// the roles path. The dependencies from Galaxy are installed with
+// ansible-galaxy, and the local dependencies, which are mounted into the
+// container, are linked into the roles path for remote runs. The output
+// of ansible-galaxy is returned, and an error is returned when any of
+// the dependencies can't be resolved.
+func (dist *Distribution) DependencyInstall(config *AnsibleConfig) (string, error) {
+
+	if config.Remote {
+		for name, path := range config.LocalDependencies {
+			link := filepath.Join(config.RolesPath, name)
+			if _, err := os.Lstat(link); os.IsNotExist(err) {
+				if err := os.Symlink(path, link); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+
+	if len(config.Dependencies) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(config.Dependencies)
+	if err != nil {
+		return "", err
+	}
+	if !config.Quiet {
+		log.Printf("Installing the dependencies of meta/main.yml: %v\n", string(data))
+	}
+
+	args := []string{"install", "-r", dependenciesPath}
+	if path := config.galaxyRolesPath(); path != "" {
+		args = append(args, "-p", path)
+	}
+
+	// Replace the roles which are already installed.
+	if config.ForceRequirements {
+		args = append(args, "--force")
+	}
+
+	// Add verbose if configured
+	if config.Verbose {
+		args = append(args, "-vvvv")
+	}
+
+	if !config.Remote {
+		// The requirements are written inside of the container first.
+		script := fmt.Sprintf(`printf '%%s\n' "$0" > %v && exec ansible-galaxy "$@"`, dependenciesPath)
+		command := append([]string{"sh", "-c", script, string(data)}, args...)
+		return config.dockerExec(buildExecCommand(dist, config, command...), !config.Quiet)
+	}
+
+	file, err := ioutil.TempFile("", "ansible-role-tester-dependencies")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return "", err
+	}
+	file.Close()
+	args[2] = file.Name()
+	return config.ansibleGalaxy(args, !config.Quiet)
+}
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetaDependencies(t *testing.T) {
+
+	Convey("Installing the dependencies of meta/main.yml", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Every form of dependency is read", func() {
+			dir, _ := ioutil.TempDir("", "dependencies")
+			defer os.RemoveAll(dir)
+			writeFixture(dir, "meta/main.yml", `
+dependencies:
+  - geerlingguy.java
+  - git+https://github.com/acme/ansible-role-base.git,v1.0,base
+  - role: acme.common
+    vars:
+      common_user: deploy
+  - src: https://github.com/acme/ansible-role-nginx
+    version: 1.2.0
+    name: nginx
+    scm: git
+  - name: geerlingguy.php
+`)
+
+			deps, err := ReadMetaDependencies(dir)
+			So(err, ShouldBeNil)
+			So(deps, ShouldResemble, []MetaDependency{
+				{Src: "geerlingguy.java"},
+				{Src: "git+https://github.com/acme/ansible-role-base.git", Version: "v1.0", Name: "base"},
+				{Src: "acme.common"},
+				{Src: "https://github.com/acme/ansible-role-nginx", Version: "1.2.0", Name: "nginx", Scm: "git"},
+				{Src: "geerlingguy.php"},
+			})
+
+			writeFixture(dir, "meta/main.yml", `
+dependencies:
+  - vars:
+      common_user: deploy
+`)
+			_, err = ReadMetaDependencies(dir)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "has no role, name or src")
+
+			deps, err = ReadMetaDependencies(filepath.Join(dir, "missing"))
+			So(err, ShouldBeNil)
+			So(deps, ShouldBeEmpty)
+		})
+
+		Convey("Local dependencies are found in the directory of the roles", func() {
+			dir, _ := ioutil.TempDir("", "dependencies")
+			defer os.RemoveAll(dir)
+			writeFixture(dir, "web/meta/main.yml", `
+dependencies:
+  - acme.common
+  - geerlingguy.java
+`)
+			writeFixture(dir, "common/meta/main.yml", `
+dependencies:
+  - role: geerlingguy.mysql
+  - geerlingguy.java
+`)
+
+			galaxy, local, err := resolveDependencies(filepath.Join(dir, "web"), dir)
+			So(err, ShouldBeNil)
+			So(galaxy, ShouldResemble, []MetaDependency{{Src: "geerlingguy.java"}, {Src: "geerlingguy.mysql"}})
+			So(local, ShouldResemble, map[string]string{"acme.common": filepath.Join(dir, "common")})
+
+			galaxy, local, err = resolveDependencies(filepath.Join(dir, "web"), "")
+			So(err, ShouldBeNil)
+			So(galaxy, ShouldHaveLength, 2)
+			So(local, ShouldBeEmpty)
+
+			config := AnsibleConfig{LocalDependencies: map[string]string{"acme.common": "/srv/roles/common"}}
+			So(config.dependencyVolumes(), ShouldResemble, []string{"/srv/roles/common:/etc/ansible/roles/acme.common:ro"})
+		})
+
+		Convey("The dependencies from Galaxy are installed in the container", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+			config := AnsibleConfig{Quiet: true, Dependencies: []MetaDependency{{Src: "geerlingguy.java", Version: "2.3.0"}}}
+
+			_, err := dist.DependencyInstall(&config)
+			So(err, ShouldBeNil)
+			So(fake.commands, ShouldHaveLength, 1)
+			So(fake.commands[0][6], ShouldEqual, `[{"src":"geerlingguy.java","version":"2.3.0"}]`)
+			So(fake.commands[0][7:], ShouldResemble, []string{"install", "-r", dependenciesPath, "-p", "/etc/ansible/roles"})
+
+			report := AnsibleReport{}
+			stages := dist.Stages(&config, &report)
+			So(stages[0].Name, ShouldEqual, "requirements")
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// diagnosticsTimeout is the longest time each diagnostic command may
+// take, so a wedged container can't hang the failure path.
+var diagnosticsTimeout = 10 * time.Second
+
+// diagnosticCommand will run the docker command for up to the
+// diagnostics timeout. Commands which take longer are stopped.
+func diagnosticCommand(command dockerCommand) (string, bool) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	out, err := defaultOutput.dockerExec(ctx, command, nil, false)
+	if err == ErrTimeout {
+		log.Warnf("docker %v did not complete within %v", strings.Join(command.args(), " "), diagnosticsTimeout)
+		return "", false
+	}
+	return out, err == nil || out != ""
+}
+
+// CollectDiagnostics will collect the logs of the container and, for
+// systemd, its journal when a stage failed, which explain failures such
+// as services which wouldn't start. The diagnostics are added to the
+// report and written to the log directory, or logged without one.
+// Collecting them is best effort and is disabled with NoDiagnostics.
+func (dist *Distribution) CollectDiagnostics(config *AnsibleConfig, report *AnsibleReport) {
+
+	switch report.ExitCode() {
+	case OKCode, DurationBudgetCode, InterruptedCode:
+		return
+	}
+	if config.NoDiagnostics || dist.CID == "" {
+		return
+	}
+
+	if !config.Quiet {
+		log.Printf("Collecting the diagnostics of %v", dist.CID)
+	}
+
+	diagnostics := map[string]string{}
+	if out, ok := diagnosticCommand(logsCommand{Container: dist.CID, Tail: 500}); ok {
+		diagnostics["container"] = out
+	}
+	if dist.initProcess() == "systemd" {
+		if out, ok := diagnosticCommand(dist.containerExec("journalctl", "-xe", "--no-pager")); ok {
+			diagnostics["journal"] = out
+		}
+	}
+	report.Ansible.Diagnostics = diagnostics
+
+	for name, out := range diagnostics {
+		if config.LogDir == "" {
+			if !config.Quiet {
+				log.Errorf("Diagnostics (%v) of %v:\n%v", name, dist.CID, out)
+			}
+			continue
+		}
+
+		dir, err := report.logDir(config)
+		if err != nil {
+			log.Errorln(err)
+			return
+		}
+		filename := filepath.Join(dir, "diagnostics-"+name+".log")
+		if err := ioutil.WriteFile(filename, []byte(out), 0644); err != nil {
+			log.Errorf("could not write the diagnostics %v: %v", filename, err)
+			continue
+		}
+		if report.Ansible.Logs == nil {
+			report.Ansible.Logs = map[string]string{}
+		}
+		report.Ansible.Logs["diagnostics-"+name] = filename
+		if !config.Quiet {
+			log.Printf("The diagnostics (%v) were written to %v", name, filename)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// wedgedEngine never completes the docker commands, like a container
+// which is wedged.
+type wedgedEngine struct{}
+
+// Command will block until the command is stopped.
+func (wedgedEngine) Command(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error {
+	<-ctx.Done()
+	return contextError(ctx, ctx.Err())
+}
+
+func TestCollectDiagnostics(t *testing.T) {
+
+	Convey("Collecting the diagnostics of failed stages", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		failed := func() AnsibleReport {
+			report := AnsibleReport{}
+			report.Ansible.Syntax = true
+			report.Ansible.Run.Result = false
+			return report
+		}
+		scripted := func() *scriptedEngine {
+			return &scriptedEngine{outputs: map[string]string{
+				"logs --tail 500 test":                "Welcome to Ubuntu 18.04",
+				"exec test cat /proc/1/comm":          "systemd",
+				"exec test journalctl -xe --no-pager": "nginx.service: Failed with result 'exit-code'.",
+			}}
+		}
+
+		Convey("Nothing is collected when the stages passed", func() {
+			fake := scripted()
+			engine = fake
+			dist := Distribution{CID: "test"}
+			report := AnsibleReport{}
+			report.Ansible.Syntax = true
+			report.Ansible.Run.Result = true
+			report.Ansible.Idempotence.Result = true
+
+			dist.CollectDiagnostics(&AnsibleConfig{Quiet: true}, &report)
+			So(fake.commands, ShouldBeEmpty)
+		})
+
+		Convey("The logs and journal are collected when a stage failed", func() {
+			engine = scripted()
+			dist := Distribution{CID: "test"}
+			report := failed()
+
+			dist.CollectDiagnostics(&AnsibleConfig{Quiet: true}, &report)
+			So(report.Ansible.Diagnostics["container"], ShouldContainSubstring, "Welcome to Ubuntu")
+			So(report.Ansible.Diagnostics["journal"], ShouldContainSubstring, "nginx.service")
+			So(report.NewJSONReport().Diagnostics, ShouldResemble, report.Ansible.Diagnostics)
+		})
+
+		Convey("The journal is only collected with systemd", func() {
+			fake := scripted()
+			fake.outputs["exec test cat /proc/1/comm"] = "tail"
+			engine = fake
+			dist := Distribution{CID: "test"}
+			report := failed()
+
+			dist.CollectDiagnostics(&AnsibleConfig{Quiet: true}, &report)
+			_, journal := report.Ansible.Diagnostics["journal"]
+			So(journal, ShouldBeFalse)
+		})
+
+		Convey("Diagnostics are written to the log directory", func() {
+			dir, _ := ioutil.TempDir("", "logs")
+			defer os.RemoveAll(dir)
+			engine = scripted()
+			dist := Distribution{CID: "test"}
+			report := failed()
+			report.Ansible.Distribution = Distribution{Distro: "ubuntu1804"}
+
+			dist.CollectDiagnostics(&AnsibleConfig{LogDir: dir, Quiet: true}, &report)
+			filename := filepath.Join(dir, "ubuntu1804", "diagnostics-journal.log")
+			So(report.Ansible.Logs["diagnostics-journal"], ShouldEqual, filename)
+			data, _ := ioutil.ReadFile(filename)
+			So(string(data), ShouldContainSubstring, "nginx.service")
+		})
+
+		Convey("Diagnostics can be disabled", func() {
+			fake := scripted()
+			engine = fake
+			dist := Distribution{CID: "test"}
+			report := failed()
+
+			dist.CollectDiagnostics(&AnsibleConfig{NoDiagnostics: true, Quiet: true}, &report)
+			So(fake.commands, ShouldBeEmpty)
+		})
+
+		Convey("Wedged containers don't hang the failure path", func() {
+			timeout := diagnosticsTimeout
+			diagnosticsTimeout = 10 * time.Millisecond
+			defer func() { diagnosticsTimeout = timeout }()
+
+			engine = wedgedEngine{}
+			now := time.Now()
+			_, ok := diagnosticCommand(logsCommand{Container: "test"})
+			So(ok, ShouldBeFalse)
+			So(time.Since(now), ShouldBeLessThan, time.Second)
+		})
+	})
+}
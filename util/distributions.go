@@ -2,7 +2,10 @@ package util
 
 import (
 	"errors"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	"fmt"
 	"reflect"
@@ -37,6 +40,94 @@ type Distribution struct {
 
 	// Family associated to this distribution.
 	Family Family
+
+	// Tmpfs are the paths which are mounted as tmpfs in the container,
+	// as PATH[:OPTIONS], which systemd needs for /run.
+	Tmpfs []string
+
+	// Volumes are the volumes the image needs besides the volume of
+	// the family, as HOST:CONTAINER[:ro].
+	Volumes []string
+
+	// EOL is the end of life of the release upstream, after which the
+	// distribution is deprecated. Zero is unknown.
+	EOL time.Time
+
+	// PullPolicy is when the image is pulled unless the config has a
+	// policy, ie always for rolling releases. Empty is missing.
+	PullPolicy string
+
+	// Bootstrap are the commands which prepare the container once it
+	// is ready, which are those of the family when nil.
+	Bootstrap []string
+}
+
+// endOfLife will return the date of an end of life, as YYYY-MM-DD.
+func endOfLife(date string) time.Time {
+	eol, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		panic(err)
+	}
+	return eol
+}
+
+// Deprecated will return true when the distribution is past its end
+// of life.
+func (dist *Distribution) Deprecated() bool {
+	return !dist.EOL.IsZero() && time.Now().After(dist.EOL)
+}
+
+// InitSystem will return the init system of the distribution, which is
+// systemd, none for containers without an init system, or the name of
+// the init command otherwise.
+func (dist *Distribution) InitSystem() string {
+	switch {
+	case dist.Family.Initialise == NoInitCommand:
+		return "none"
+	case strings.Contains(dist.Family.Initialise, "systemd") || len(dist.Tmpfs) > 0:
+		return "systemd"
+	}
+	return path.Base(strings.Fields(dist.Family.Initialise + " init")[0])
+}
+
+// Matches will return true when the family is named family, or is part
+// of the wider family, ie redhat.
+func (family *Family) Matches(name string) bool {
+	return strings.EqualFold(family.Name, name) || strings.EqualFold(family.Group, name)
+}
+
+// ListDistributions will return the distributions of the family, or
+// every distribution when the family is empty, which are the built-in
+// distributions and those of the distributions file.
+func ListDistributions(family string) []Distribution {
+	distributions := []Distribution{}
+	for _, dist := range Distributions {
+		if family == "" || dist.Family.Matches(family) {
+			distributions = append(distributions, dist)
+		}
+	}
+	return distributions
+}
+
+// SystemdTmpfs are the tmpfs mounts of distributions with systemd.
+var SystemdTmpfs = []string{"/run", "/run/lock"}
+
+// NoInitCommand is the command of containers without an init system,
+// which keeps the container running until it is stopped.
+const NoInitCommand = "tail -f /dev/null"
+
+// Override will override the privileged and init settings of the
+// distribution for a single run, where privileged is only applied
+// when it is not nil and an empty init command keeps the default.
+func (dist *Distribution) Override(privileged *bool, noInit bool, initCommand string) {
+	if privileged != nil {
+		dist.Privileged = *privileged
+	}
+	if noInit {
+		dist.Family.Initialise = NoInitCommand
+	} else if initCommand != "" {
+		dist.Family.Initialise = initCommand
+	}
 }
 
 // Family is a set of characteristics describing a family of linux distributions.
@@ -45,6 +136,14 @@ type Family struct {
 	Name       string
 	Initialise string
 	Volume     string
+
+	// Group is the wider family the distributions are filtered by,
+	// ie redhat or debian.
+	Group string
+
+	// Bootstrap are the shell commands which prepare the containers
+	// of the family once they are ready, ie to install Ansible.
+	Bootstrap []string
 }
 
 // CentOS Family Distribution Identifier
@@ -52,6 +151,10 @@ var CentOS = Family{
 	"CentOS",
 	"/sbin/init",
 	"/sys/fs/cgroup:/sys/fs/cgroup:ro",
+	"redhat",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || dnf install -y python3 || yum install -y python3",
+	},
 }
 
 // Debian Family Distribution Identifier
@@ -59,6 +162,23 @@ var Debian = Family{
 	"Debian",
 	"/bin/systemd",
 	"/sys/fs/cgroup:/sys/fs/cgroup:ro",
+	"debian",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || (apt-get update && apt-get install -y python3)",
+	},
+}
+
+// DebianSystemd Family Distribution Identifier, which starts systemd
+// directly and mounts the cgroups read-write for cgroup v2, as of
+// Debian 12.
+var DebianSystemd = Family{
+	"Debian",
+	"/lib/systemd/systemd",
+	"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+	"debian",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || (apt-get update && apt-get install -y python3)",
+	},
 }
 
 // Fedora Family Distribution Identifier
@@ -66,6 +186,10 @@ var Fedora = Family{
 	"Fedora",
 	"/usr/lib/systemd/systemd",
 	"/sys/fs/cgroup:/sys/fs/cgroup:ro",
+	"redhat",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || dnf install -y python3 || yum install -y python3",
+	},
 }
 
 // Ubuntu Family Distribution Identifier
@@ -73,6 +197,96 @@ var Ubuntu = Family{
 	"Ubuntu",
 	"/sbin/init",
 	"/sys/fs/cgroup:/sys/fs/cgroup:ro",
+	"debian",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || (apt-get update && apt-get install -y python3)",
+	},
+}
+
+// UbuntuSystemd Family Distribution Identifier, which starts systemd
+// directly and mounts the cgroups read-write for cgroup v2, as of
+// Ubuntu 24.04.
+var UbuntuSystemd = Family{
+	"Ubuntu",
+	"/lib/systemd/systemd",
+	"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+	"debian",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || (apt-get update && apt-get install -y python3)",
+	},
+}
+
+// RockyLinux Family Distribution Identifier, which mounts the cgroups
+// read-write for the systemd of EL9.
+var RockyLinux = Family{
+	"RockyLinux",
+	"/usr/lib/systemd/systemd",
+	"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+	"redhat",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || dnf install -y python3 || yum install -y python3",
+	},
+}
+
+// AlmaLinux Family Distribution Identifier, which mounts the cgroups
+// read-write for the systemd of EL9.
+var AlmaLinux = Family{
+	"AlmaLinux",
+	"/usr/sbin/init",
+	"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+	"redhat",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || dnf install -y python3 || yum install -y python3",
+	},
+}
+
+// Alpine Family Distribution Identifier, which has no init system and
+// installs Ansible with apk when the image doesn't include it.
+var Alpine = Family{
+	"Alpine",
+	NoInitCommand,
+	"",
+	"alpine",
+	[]string{
+		"command -v ansible-playbook >/dev/null || apk add --no-cache ansible",
+	},
+}
+
+// ArchLinux Family Distribution Identifier, which installs Ansible with
+// pacman when the image doesn't include it.
+var ArchLinux = Family{
+	"ArchLinux",
+	"/usr/lib/systemd/systemd",
+	"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+	"archlinux",
+	[]string{
+		"command -v ansible-playbook >/dev/null || pacman -Sy --noconfirm ansible python",
+	},
+}
+
+// AmazonLinux Family Distribution Identifier, which mounts the cgroups
+// read-write for the systemd of Amazon Linux 2023.
+var AmazonLinux = Family{
+	"AmazonLinux",
+	"/usr/lib/systemd/systemd",
+	"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+	"redhat",
+	[]string{
+		"command -v python3 >/dev/null || command -v python >/dev/null || dnf install -y python3 || yum install -y python3",
+	},
+}
+
+// OpenSUSE Family Distribution Identifier, which boots the systemd of the
+// dokken images and installs Python and Ansible with zypper when the image
+// doesn't include them.
+var OpenSUSE = Family{
+	"openSUSE",
+	"/usr/lib/systemd/systemd",
+	"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+	"suse",
+	[]string{
+		"command -v python3 >/dev/null && command -v ansible-playbook >/dev/null || zypper -n install python3 ansible",
+	},
 }
 
 // CentOS6 Distribution declaration
@@ -84,6 +298,11 @@ var CentOS6 = Distribution{
 	"fubarhouse",
 	"centos6",
 	CentOS,
+	nil,
+	nil,
+	endOfLife("2020-11-30"),
+	"",
+	nil,
 }
 
 // CentOS7 Distribution declaration
@@ -95,6 +314,11 @@ var CentOS7 = Distribution{
 	"fubarhouse",
 	"centos7",
 	CentOS,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2024-06-30"),
+	"",
+	nil,
 }
 
 // DebianWheezy Distribution declaration
@@ -106,6 +330,11 @@ var DebianWheezy = Distribution{
 	"fubarhouse",
 	"debian7",
 	Debian,
+	nil,
+	nil,
+	endOfLife("2018-05-31"),
+	"",
+	nil,
 }
 
 // DebianJessie Distribution declaration
@@ -117,6 +346,11 @@ var DebianJessie = Distribution{
 	"fubarhouse",
 	"debian8",
 	Debian,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2020-06-30"),
+	"",
+	nil,
 }
 
 // DebianStretch Distribution declaration
@@ -128,6 +362,11 @@ var DebianStretch = Distribution{
 	"fubarhouse",
 	"debian9",
 	Debian,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2022-06-30"),
+	"",
+	nil,
 }
 
 // DebianBuster Distribution declaration
@@ -139,6 +378,11 @@ var DebianBuster = Distribution{
 	"fubarhouse",
 	"debian10",
 	Debian,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2024-06-30"),
+	"",
+	nil,
 }
 
 // Fedora24 Distribution declaration
@@ -150,6 +394,11 @@ var Fedora24 = Distribution{
 	"fubarhouse",
 	"fedora24",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2017-08-08"),
+	"",
+	nil,
 }
 
 // Fedora25 Distribution declaration
@@ -161,6 +410,11 @@ var Fedora25 = Distribution{
 	"fubarhouse",
 	"fedora25",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2017-12-12"),
+	"",
+	nil,
 }
 
 // Fedora26 Distribution declaration
@@ -172,6 +426,11 @@ var Fedora26 = Distribution{
 	"fubarhouse",
 	"fedora26",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2018-05-29"),
+	"",
+	nil,
 }
 
 // Fedora27 Distribution declaration
@@ -183,6 +442,11 @@ var Fedora27 = Distribution{
 	"fubarhouse",
 	"fedora27",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2018-11-30"),
+	"",
+	nil,
 }
 
 // Fedora28 Distribution declaration
@@ -194,6 +458,11 @@ var Fedora28 = Distribution{
 	"fubarhouse",
 	"fedora28",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2019-05-28"),
+	"",
+	nil,
 }
 
 // Fedora29 Distribution declaration
@@ -205,6 +474,11 @@ var Fedora29 = Distribution{
 	"fubarhouse",
 	"fedora29",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2019-11-26"),
+	"",
+	nil,
 }
 
 // Fedora30 Distribution declaration
@@ -216,6 +490,11 @@ var Fedora30 = Distribution{
 	"fubarhouse",
 	"fedora30",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2020-05-26"),
+	"",
+	nil,
 }
 
 // Fedora31 Distribution declaration
@@ -227,6 +506,11 @@ var Fedora31 = Distribution{
 	"fubarhouse",
 	"fedora31",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2020-11-24"),
+	"",
+	nil,
 }
 
 // Ubuntu1204 Distribution declaration
@@ -238,6 +522,11 @@ var Ubuntu1204 = Distribution{
 	"fubarhouse",
 	"ubuntu1204",
 	Ubuntu,
+	nil,
+	nil,
+	endOfLife("2017-04-28"),
+	"",
+	nil,
 }
 
 // Ubuntu1210 Distribution declaration
@@ -249,6 +538,11 @@ var Ubuntu1210 = Distribution{
 	"fubarhouse",
 	"ubuntu1210",
 	Ubuntu,
+	nil,
+	nil,
+	endOfLife("2014-05-16"),
+	"",
+	nil,
 }
 
 // Ubuntu1304 Distribution declaration
@@ -260,6 +554,11 @@ var Ubuntu1304 = Distribution{
 	"fubarhouse",
 	"ubuntu1304",
 	Ubuntu,
+	nil,
+	nil,
+	endOfLife("2014-01-27"),
+	"",
+	nil,
 }
 
 // Ubuntu1310 Distribution declaration
@@ -271,6 +570,11 @@ var Ubuntu1310 = Distribution{
 	"fubarhouse",
 	"ubuntu1310",
 	Ubuntu,
+	nil,
+	nil,
+	endOfLife("2014-07-17"),
+	"",
+	nil,
 }
 
 // Ubuntu1404 Distribution declaration
@@ -282,6 +586,11 @@ var Ubuntu1404 = Distribution{
 	"fubarhouse",
 	"ubuntu1404",
 	Ubuntu,
+	nil,
+	nil,
+	endOfLife("2019-04-25"),
+	"",
+	nil,
 }
 
 // Ubuntu1410 Distribution declaration
@@ -293,6 +602,11 @@ var Ubuntu1410 = Distribution{
 	"fubarhouse",
 	"ubuntu1410",
 	Ubuntu,
+	nil,
+	nil,
+	endOfLife("2015-07-23"),
+	"",
+	nil,
 }
 
 // Ubuntu1504 Distribution declaration
@@ -304,6 +618,11 @@ var Ubuntu1504 = Distribution{
 	"fubarhouse",
 	"ubuntu1504",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2016-02-04"),
+	"",
+	nil,
 }
 
 // Ubuntu1510 Distribution declaration
@@ -315,6 +634,11 @@ var Ubuntu1510 = Distribution{
 	"fubarhouse",
 	"ubuntu1510",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2016-07-28"),
+	"",
+	nil,
 }
 
 // Ubuntu1604 Distribution declaration
@@ -326,6 +650,11 @@ var Ubuntu1604 = Distribution{
 	"fubarhouse",
 	"ubuntu1604",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2021-04-30"),
+	"",
+	nil,
 }
 
 // Ubuntu1610 Distribution declaration
@@ -337,6 +666,11 @@ var Ubuntu1610 = Distribution{
 	"fubarhouse",
 	"ubuntu1610",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2017-07-20"),
+	"",
+	nil,
 }
 
 // Ubuntu1704 Distribution declaration
@@ -348,6 +682,11 @@ var Ubuntu1704 = Distribution{
 	"fubarhouse",
 	"ubuntu1704",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2018-01-13"),
+	"",
+	nil,
 }
 
 // Ubuntu1710 Distribution declaration
@@ -359,6 +698,11 @@ var Ubuntu1710 = Distribution{
 	"fubarhouse",
 	"ubuntu1710",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2018-07-19"),
+	"",
+	nil,
 }
 
 // Ubuntu1804 Distribution declaration
@@ -370,6 +714,11 @@ var Ubuntu1804 = Distribution{
 	"fubarhouse",
 	"ubuntu1804",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2023-05-31"),
+	"",
+	nil,
 }
 
 // Ubuntu1810 Distribution declaration
@@ -381,6 +730,11 @@ var Ubuntu1810 = Distribution{
 	"fubarhouse",
 	"ubuntu1810",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2019-07-18"),
+	"",
+	nil,
 }
 
 // Ubuntu1904 Distribution declaration
@@ -392,6 +746,11 @@ var Ubuntu1904 = Distribution{
 	"fubarhouse",
 	"ubuntu1904",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2020-01-23"),
+	"",
+	nil,
 }
 
 // Ubuntu2004 Distribution declaration
@@ -403,6 +762,11 @@ var Ubuntu2004 = Distribution{
 	"fubarhouse",
 	"ubuntu2004",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2025-05-31"),
+	"",
+	nil,
 }
 
 // JeffCentOS6 Distribution declaration
@@ -414,6 +778,11 @@ var JeffCentOS6 = Distribution{
 	"geerlingguy",
 	"centos6",
 	CentOS,
+	nil,
+	nil,
+	endOfLife("2020-11-30"),
+	"",
+	nil,
 }
 
 // JeffCentOS7 Distribution declaration
@@ -425,6 +794,11 @@ var JeffCentOS7 = Distribution{
 	"geerlingguy",
 	"centos7",
 	CentOS,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2024-06-30"),
+	"",
+	nil,
 }
 
 // JeffUbuntu1204 Distribution declaration
@@ -436,6 +810,11 @@ var JeffUbuntu1204 = Distribution{
 	"geerlingguy",
 	"ubuntu1204",
 	Ubuntu,
+	nil,
+	nil,
+	endOfLife("2017-04-28"),
+	"",
+	nil,
 }
 
 // JeffUbuntu1404 Distribution declaration
@@ -447,6 +826,11 @@ var JeffUbuntu1404 = Distribution{
 	"geerlingguy",
 	"ubuntu1404",
 	Ubuntu,
+	nil,
+	nil,
+	endOfLife("2019-04-25"),
+	"",
+	nil,
 }
 
 // JeffUbuntu1604 Distribution declaration
@@ -458,6 +842,11 @@ var JeffUbuntu1604 = Distribution{
 	"geerlingguy",
 	"ubuntu1604",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2021-04-30"),
+	"",
+	nil,
 }
 
 // JeffUbuntu1804 Distribution declaration
@@ -469,6 +858,43 @@ var JeffUbuntu1804 = Distribution{
 	"geerlingguy",
 	"ubuntu1804",
 	Ubuntu,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2023-05-31"),
+	"",
+	nil,
+}
+
+// JeffUbuntu2204 Distribution declaration
+var JeffUbuntu2204 = Distribution{
+	"",
+	"ubuntu2204",
+	true,
+	"geerlingguy/docker-ubuntu2204-ansible:latest",
+	"geerlingguy",
+	"ubuntu2204",
+	UbuntuSystemd,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2027-04-30"),
+	"",
+	nil,
+}
+
+// JeffUbuntu2404 Distribution declaration
+var JeffUbuntu2404 = Distribution{
+	"",
+	"ubuntu2404",
+	true,
+	"geerlingguy/docker-ubuntu2404-ansible:latest",
+	"geerlingguy",
+	"ubuntu2404",
+	UbuntuSystemd,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2029-05-31"),
+	"",
+	nil,
 }
 
 // JeffDebian8 Distribution declaration
@@ -480,6 +906,11 @@ var JeffDebian8 = Distribution{
 	"geerlingguy",
 	"debian8",
 	Debian,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2020-06-30"),
+	"",
+	nil,
 }
 
 // JeffDebian9 Distribution declaration
@@ -491,6 +922,27 @@ var JeffDebian9 = Distribution{
 	"geerlingguy",
 	"debian9",
 	Debian,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2022-06-30"),
+	"",
+	nil,
+}
+
+// JeffDebian12 Distribution declaration
+var JeffDebian12 = Distribution{
+	"",
+	"debian12",
+	true,
+	"geerlingguy/docker-debian12-ansible:latest",
+	"geerlingguy",
+	"debian12",
+	DebianSystemd,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2028-06-30"),
+	"",
+	nil,
 }
 
 // JeffFedora24 Distribution declaration
@@ -502,6 +954,11 @@ var JeffFedora24 = Distribution{
 	"geerlingguy",
 	"fedora24",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2017-08-08"),
+	"",
+	nil,
 }
 
 // JeffFedora27 Distribution declaration
@@ -513,6 +970,215 @@ var JeffFedora27 = Distribution{
 	"geerlingguy",
 	"fedora27",
 	Fedora,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2018-11-30"),
+	"",
+	nil,
+}
+
+// JeffRockyLinux8 Distribution declaration
+var JeffRockyLinux8 = Distribution{
+	"",
+	"rockylinux8",
+	true,
+	"geerlingguy/docker-rockylinux8-ansible:latest",
+	"geerlingguy",
+	"rockylinux8",
+	RockyLinux,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2029-05-31"),
+	"",
+	nil,
+}
+
+// JeffRockyLinux9 Distribution declaration
+var JeffRockyLinux9 = Distribution{
+	"",
+	"rockylinux9",
+	true,
+	"geerlingguy/docker-rockylinux9-ansible:latest",
+	"geerlingguy",
+	"rockylinux9",
+	RockyLinux,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2032-05-31"),
+	"",
+	nil,
+}
+
+// AlmaLinux8 Distribution declaration
+var AlmaLinux8 = Distribution{
+	"",
+	"almalinux8",
+	true,
+	"almalinux/8-init:latest",
+	"almalinux",
+	"almalinux8",
+	AlmaLinux,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2029-03-01"),
+	"",
+	nil,
+}
+
+// AlmaLinux9 Distribution declaration
+var AlmaLinux9 = Distribution{
+	"",
+	"almalinux9",
+	true,
+	"almalinux/9-init:latest",
+	"almalinux",
+	"almalinux9",
+	AlmaLinux,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2032-05-31"),
+	"",
+	nil,
+}
+
+// Alpine3 Distribution declaration
+var Alpine3 = Distribution{
+	"",
+	"alpine3",
+	false,
+	"alpine:3",
+	"library",
+	"alpine3",
+	Alpine,
+	nil,
+	nil,
+	time.Time{},
+	"",
+	nil,
+}
+
+// ArchLinuxRolling Distribution declaration, which is a rolling release, so
+// the image is always pulled.
+var ArchLinuxRolling = Distribution{
+	"",
+	"archlinux",
+	true,
+	"carlodepieri/docker-archlinux-systemd:latest",
+	"carlodepieri",
+	"archlinux",
+	ArchLinux,
+	SystemdTmpfs,
+	nil,
+	time.Time{},
+	PullAlways,
+	nil,
+}
+
+// AmazonLinux2 Distribution declaration
+var AmazonLinux2 = Distribution{
+	"",
+	"amazonlinux2",
+	true,
+	"geerlingguy/docker-amazonlinux2-ansible:latest",
+	"geerlingguy",
+	"amazonlinux2",
+	AmazonLinux,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2026-06-30"),
+	"",
+	nil,
+}
+
+// AmazonLinux2023 Distribution declaration
+var AmazonLinux2023 = Distribution{
+	"",
+	"amazonlinux2023",
+	true,
+	"geerlingguy/docker-amazonlinux2023-ansible:latest",
+	"geerlingguy",
+	"amazonlinux2023",
+	AmazonLinux,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2029-06-30"),
+	"",
+	nil,
+}
+
+// OpenSUSELeap15 Distribution declaration
+var OpenSUSELeap15 = Distribution{
+	"",
+	"opensuse15",
+	true,
+	"dokken/opensuse-leap-15:latest",
+	"dokken",
+	"opensuse15",
+	OpenSUSE,
+	SystemdTmpfs,
+	nil,
+	endOfLife("2026-04-30"),
+	"",
+	nil,
+}
+
+// OpenSUSETumbleweed Distribution declaration, which is a rolling
+// release, so the image is always pulled.
+var OpenSUSETumbleweed = Distribution{
+	"",
+	"tumbleweed",
+	true,
+	"dokken/opensuse-tumbleweed:latest",
+	"dokken",
+	"tumbleweed",
+	OpenSUSE,
+	SystemdTmpfs,
+	nil,
+	time.Time{},
+	PullAlways,
+	nil,
+}
+
+// DistributionAliases are the short names of distributions, which are
+// accepted instead of their names.
+var DistributionAliases = map[string]string{
+	"al2":           "amazonlinux2",
+	"al2023":        "amazonlinux2023",
+	"alma8":         "almalinux8",
+	"alma9":         "almalinux9",
+	"arch":          "archlinux",
+	"debian-latest": "debian12",
+	"bookworm":      "debian12",
+	"buster":        "debian10",
+	"el8":           "rockylinux8",
+	"el9":           "rockylinux9",
+	"focal":         "ubuntu2004",
+	"jammy":         "ubuntu2204",
+	"noble":         "ubuntu2404",
+	"ubuntu-latest": "ubuntu2404",
+	"rocky8":        "rockylinux8",
+	"rocky9":        "rockylinux9",
+}
+
+// AliasesOf will return the aliases of the distribution, in order.
+func AliasesOf(distro string) []string {
+	aliases := []string{}
+	for alias, name := range DistributionAliases {
+		if name == distro {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// ResolveAlias will return the name of the distribution of an alias,
+// or the name itself when it is not an alias.
+func ResolveAlias(name string) string {
+	if distro, ok := DistributionAliases[strings.ToLower(name)]; ok {
+		return distro
+	}
+	return name
 }
 
 // Distributions is a slice of all distributions listed above.
@@ -553,10 +1219,23 @@ var Distributions = []Distribution{
 	JeffUbuntu1404,
 	JeffUbuntu1604,
 	JeffUbuntu1804,
+	JeffUbuntu2204,
+	JeffUbuntu2404,
 	JeffDebian8,
 	JeffDebian9,
+	JeffDebian12,
 	JeffFedora24,
 	JeffFedora27,
+	JeffRockyLinux8,
+	JeffRockyLinux9,
+	AlmaLinux8,
+	AlmaLinux9,
+	Alpine3,
+	ArchLinuxRolling,
+	AmazonLinux2,
+	AmazonLinux2023,
+	OpenSUSELeap15,
+	OpenSUSETumbleweed,
 }
 
 // NewCustomDistribution will return an empty distribution.
@@ -615,30 +1294,52 @@ func CustomFamilyValueGet(family *Family, key string) (string, error) {
 // GetDistribution will get the distribution object to allow dynamic
 // loading of different distributions. A suitable struct will be compiled
 // from the inputs and returned with an error if the specified container
-// cannot be found.
+// cannot be found. Aliases of the distributions are accepted, and names
+// are matched regardless of case, dots and dashes, ie centos-7.
 func GetDistribution(container, target, init, volume, user, distro string) (Distribution, error) {
 
+	names := []string{}
+	for _, dist := range ListDistributions("") {
+		names = append(names, dist.Distro)
+	}
+	match, suggestions := MatchDistribution(distro, names, DistributionAliases)
+	if match != "" {
+		distro = match
+	}
+
 	// We will search for the exact container.
-	for _, dist := range Distributions {
+	for _, dist := range ListDistributions("") {
 		// Check for explicit matches using image.
 		if dist.Container == container {
 			return dist, nil
 		}
-		// Check for explicit matches for user and distro.
-		if dist.User == user && dist.Distro == distro {
+		// Check for explicit matches for user and distro, where
+		// distributions without a user match any user.
+		if (dist.User == user || dist.User == "") && dist.Distro == distro {
 			return dist, nil
 		}
 	}
 
-	c, _ := DockerExec([]string{
-		"images",
-		container,
-	}, false)
+	// Distributions which only one user provides are found without
+	// the user.
+	matches := []Distribution{}
+	for _, dist := range ListDistributions("") {
+		if dist.Distro == distro {
+			matches = append(matches, dist)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
 
-	if !strings.Contains(c, container) {
+	if _, err := dockerExec(inspectCommand{Kind: "image", Field: fieldID, Name: container}, false); err != nil {
 		log.Errorf("no valid image was found for '%v'\n", container)
 	}
 
+	if len(suggestions) > 0 {
+		return Distribution{},
+			fmt.Errorf("could not find matching distribution %v, did you mean: %v?", distro, strings.Join(suggestions, ", "))
+	}
 	return Distribution{},
 		errors.New("could not find matching distribution")
 }
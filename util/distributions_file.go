@@ -0,0 +1,249 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DistributionsFile is the file of custom distributions which is found
+// in the role or next to it.
+const DistributionsFile = "distributions.yml"
+
+// Families are the families of the distributions, by name.
+var Families = []Family{CentOS, Debian, Fedora, Ubuntu, RockyLinux, AlmaLinux, Alpine, ArchLinux, AmazonLinux, OpenSUSE}
+
+// distributionEntry is a distribution of the distributions file.
+type distributionEntry struct {
+	Name       string    `yaml:"name"`
+	Image      string    `yaml:"image"`
+	User       string    `yaml:"user"`
+	Privileged *bool     `yaml:"privileged"`
+	Init       string    `yaml:"init"`
+	Volumes    []string  `yaml:"volumes"`
+	Family     string    `yaml:"family"`
+	Tmpfs      *[]string `yaml:"tmpfs"`
+	EOL        string    `yaml:"eol"`
+	Pull       string    `yaml:"pull"`
+	Bootstrap  *[]string `yaml:"bootstrap"`
+}
+
+// FindDistributionsFile will return the distributions file of the role,
+// which is in the role or the directory containing it, or an empty
+// string when there is none.
+func FindDistributionsFile(role string) string {
+	if role == "" {
+		role, _ = os.Getwd()
+	}
+	for _, dir := range []string{role, filepath.Dir(role)} {
+		file := filepath.Join(dir, DistributionsFile)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file
+		}
+	}
+	return ""
+}
+
+// entryLine will return the line of the name of the nth entry with the
+// name in the distributions file, or zero when it can't be found.
+func entryLine(data []byte, name string, nth int) int {
+	if name == "" {
+		return 0
+	}
+	pattern := regexp.MustCompile(`^\s*(-\s*)?["']?name["']?\s*:\s*["']?` + regexp.QuoteMeta(name) + `["']?\s*,?\s*$`)
+	for i, line := range strings.Split(string(data), "\n") {
+		if pattern.MatchString(line) {
+			if nth == 0 {
+				return i + 1
+			}
+			nth--
+		}
+	}
+	return 0
+}
+
+// ReadDistributions will read the distributions of the distributions
+// file. Every invalid entry is listed in the error with its line.
+func ReadDistributions(file string) ([]Distribution, error) {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	document := struct {
+		Distributions []distributionEntry `yaml:"distributions"`
+	}{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", file, err)
+	}
+
+	distributions := []Distribution{}
+	problems := []string{}
+	seen := map[string]int{}
+	for i, entry := range document.Distributions {
+
+		line := entryLine(data, entry.Name, seen[entry.Name])
+		where := fmt.Sprintf("%v: entry %v", file, i+1)
+		if line > 0 {
+			where = fmt.Sprintf("%v:%v: entry %v", file, line, i+1)
+		}
+		if entry.Name != "" {
+			where += fmt.Sprintf(" (%v)", entry.Name)
+		}
+		seen[entry.Name]++
+
+		dist, err := entry.distribution()
+		if err == nil && seen[entry.Name] > 1 {
+			err = fmt.Errorf("duplicate name %v", entry.Name)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", where, err))
+			continue
+		}
+		distributions = append(distributions, dist)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid distributions:\n\t%v", strings.Join(problems, "\n\t"))
+	}
+	return distributions, nil
+}
+
+// distribution will return the distribution of the entry, where the
+// family provides the init command and the cgroup volume unless they
+// are given.
+func (entry *distributionEntry) distribution() (Distribution, error) {
+
+	if entry.Name == "" {
+		return Distribution{}, fmt.Errorf("missing name")
+	}
+	if entry.Image == "" {
+		return Distribution{}, fmt.Errorf("missing image")
+	}
+
+	family := Family{Name: entry.Family, Volume: cgroupVolume}
+	if entry.Family != "" {
+		found := false
+		for _, known := range Families {
+			if strings.EqualFold(known.Name, entry.Family) {
+				family, found = known, true
+			}
+		}
+		if !found {
+			return Distribution{}, fmt.Errorf("unknown family %v", entry.Family)
+		}
+	} else if entry.Init == "" {
+		return Distribution{}, fmt.Errorf("missing family or init")
+	}
+	if entry.Init != "" {
+		family.Initialise = entry.Init
+	}
+
+	// The cgroup volume replaces the volume of the family.
+	volumes := []string{}
+	for _, volume := range entry.Volumes {
+		if parts := strings.Split(volume, ":"); len(parts) < 2 {
+			return Distribution{}, fmt.Errorf("invalid volume %v, expected HOST:CONTAINER[:ro]", volume)
+		}
+		if CgroupVolume([]string{volume}) == volume {
+			family.Volume = volume
+		} else {
+			volumes = append(volumes, volume)
+		}
+	}
+
+	dist := Distribution{
+		Name:       entry.Name,
+		Privileged: entry.Privileged == nil || *entry.Privileged,
+		Container:  entry.Image,
+		User:       entry.User,
+		Distro:     entry.Name,
+		Family:     family,
+		Tmpfs:      SystemdTmpfs,
+		Volumes:    volumes,
+	}
+	if entry.Tmpfs != nil {
+		dist.Tmpfs = *entry.Tmpfs
+		if err := CheckTmpfs(dist.Tmpfs); err != nil {
+			return Distribution{}, err
+		}
+	}
+	if family.Initialise == NoInitCommand {
+		dist.Tmpfs = nil
+	}
+	if entry.EOL != "" {
+		eol, err := time.Parse("2006-01-02", entry.EOL)
+		if err != nil {
+			return Distribution{}, fmt.Errorf("invalid eol %v, expected YYYY-MM-DD", entry.EOL)
+		}
+		dist.EOL = eol
+	}
+	if entry.Pull != "" {
+		if err := CheckPullPolicy(entry.Pull); err != nil {
+			return Distribution{}, err
+		}
+		dist.PullPolicy = entry.Pull
+	}
+	if entry.Bootstrap != nil {
+		dist.Bootstrap = *entry.Bootstrap
+	}
+	return dist, nil
+}
+
+// MergeDistributions will add the distributions to the built-in
+// distributions, replacing those with the same name.
+func MergeDistributions(custom []Distribution) {
+
+	merged := []Distribution{}
+	for _, dist := range Distributions {
+		replaced := false
+		for _, replacement := range custom {
+			if replacement.Distro == dist.Distro && (replacement.User == "" || replacement.User == dist.User) {
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, dist)
+		}
+	}
+	Distributions = append(merged, custom...)
+}
+
+// LoadDistributions will merge the distributions of the file with the
+// built-in distributions, where the file is found with the role when
+// it is not given. The overrides of the file are set once its
+// distributions are known, and its Galaxy servers are set.
+func LoadDistributions(file, role string) error {
+
+	if file == "" {
+		if file = FindDistributionsFile(role); file == "" {
+			return nil
+		}
+	}
+
+	custom, err := ReadDistributions(file)
+	if err != nil {
+		return err
+	}
+	MergeDistributions(custom)
+
+	overrides, err := ReadOverrides(file)
+	if err != nil {
+		return err
+	}
+	SetOverrides(overrides)
+
+	servers, err := ReadGalaxyServers(file)
+	if err != nil {
+		return err
+	}
+	SetGalaxyServers(servers)
+	return nil
+}
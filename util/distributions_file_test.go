@@ -0,0 +1,104 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDistributionsFile(t *testing.T) {
+
+	Convey("Loading custom distributions from a file", t, func() {
+
+		builtin := Distributions
+		defer func() { Distributions = builtin }()
+
+		dir, _ := ioutil.TempDir("", "distributions")
+		defer os.RemoveAll(dir)
+		role := filepath.Join(dir, "role")
+		os.MkdirAll(role, 0755)
+		file := filepath.Join(dir, DistributionsFile)
+
+		Convey("The file is found next to the role", func() {
+			So(FindDistributionsFile(role), ShouldEqual, "")
+			writeFixture(dir, DistributionsFile, `
+distributions: []
+`)
+			So(FindDistributionsFile(role), ShouldEqual, file)
+		})
+
+		Convey("Entries are merged with the built-in distributions", func() {
+			writeFixture(dir, DistributionsFile, `
+distributions:
+  - name: internal8
+    image: registry.example.com/base/centos:8
+    family: centos
+    pull: always
+    volumes:
+      - /sys/fs/cgroup:/sys/fs/cgroup:rw
+      - /srv/cache:/srv/cache
+  - name: ubuntu1804
+    image: registry.example.com/base/ubuntu:18.04
+    user: fubarhouse
+    privileged: false
+    init: /lib/systemd/systemd
+`)
+			So(LoadDistributions("", role), ShouldBeNil)
+
+			dist, err := GetDistribution("", "", "", "", "anyone", "internal8")
+			So(err, ShouldBeNil)
+			So(dist.Container, ShouldEqual, "registry.example.com/base/centos:8")
+			So(dist.Privileged, ShouldBeTrue)
+			So(dist.Family.Initialise, ShouldEqual, CentOS.Initialise)
+			So(dist.Family.Volume, ShouldEqual, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+			So(dist.Volumes, ShouldResemble, []string{"/srv/cache:/srv/cache"})
+			So(dist.Tmpfs, ShouldResemble, SystemdTmpfs)
+			So(dist.PullPolicy, ShouldEqual, PullAlways)
+
+			dist, err = GetDistribution("", "", "", "", "fubarhouse", "ubuntu1804")
+			So(err, ShouldBeNil)
+			So(dist.Container, ShouldEqual, "registry.example.com/base/ubuntu:18.04")
+			So(dist.Privileged, ShouldBeFalse)
+			So(dist.Family.Volume, ShouldEqual, cgroupVolume)
+
+			dist, err = GetDistribution("", "", "", "", "geerlingguy", "ubuntu1804")
+			So(err, ShouldBeNil)
+			So(dist.Container, ShouldEqual, JeffUbuntu1804.Container)
+		})
+
+		Convey("Invalid entries are listed with their line", func() {
+			writeFixture(dir, DistributionsFile, `
+distributions:
+  - name: internal8
+    family: centos
+  - name: internal9
+    image: registry.example.com/base/centos:9
+    family: centos
+  - name: internal9
+    image: registry.example.com/base/centos:9
+    family: solaris
+  - name: internal9
+    image: registry.example.com/base/centos:9
+    init: /sbin/init
+  - name: internal10
+    image: registry.example.com/base/centos:10
+    family: centos
+    pull: sometimes
+`)
+			_, err := ReadDistributions(file)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, file+":2: entry 1 (internal8): missing image")
+			So(err.Error(), ShouldContainSubstring, file+":7: entry 3 (internal9): unknown family solaris")
+			So(err.Error(), ShouldContainSubstring, file+":10: entry 4 (internal9): duplicate name internal9")
+			So(err.Error(), ShouldContainSubstring, "entry 5 (internal10): invalid pull policy 'sometimes'")
+			So(err.Error(), ShouldNotContainSubstring, "entry 2")
+
+			Distributions = builtin
+			So(LoadDistributions(file, role), ShouldNotBeNil)
+			So(len(Distributions), ShouldEqual, len(builtin))
+		})
+	})
+}
@@ -0,0 +1,230 @@
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// imageReference matches the references of the images of the
+// distributions, which are REPOSITORY:TAG.
+var imageReference = regexp.MustCompile(`^([a-z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*:[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+func TestDistributions(t *testing.T) {
+
+	Convey("Declaring the distributions", t, func() {
+
+		Convey("Every distribution has a valid image and is unique", func() {
+			seen := map[string]bool{}
+			for _, dist := range Distributions {
+				So(dist.Container, ShouldNotBeEmpty)
+				So(imageReference.MatchString(dist.Container), ShouldBeTrue)
+				So(dist.Family.Initialise, ShouldNotBeEmpty)
+				So(seen[dist.User+"/"+dist.Distro], ShouldBeFalse)
+				seen[dist.User+"/"+dist.Distro] = true
+			}
+		})
+
+		Convey("Rocky Linux runs systemd in the redhat family", func() {
+			for _, name := range []string{"rockylinux8", "rockylinux9"} {
+				dist, err := GetDistribution("", "", "", "", "fubarhouse", name)
+				So(err, ShouldBeNil)
+				So(dist.Distro, ShouldEqual, name)
+				So(dist.Container, ShouldEqual, "geerlingguy/docker-"+name+"-ansible:latest")
+				So(dist.Privileged, ShouldBeTrue)
+				So(dist.InitSystem(), ShouldEqual, "systemd")
+				So(dist.Family.Volume, ShouldEqual, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+				So(dist.Tmpfs, ShouldResemble, SystemdTmpfs)
+				So(dist.Deprecated(), ShouldBeFalse)
+				So(ListDistributions("redhat"), ShouldContain, dist)
+			}
+		})
+
+		Convey("AlmaLinux runs systemd in the redhat family", func() {
+			for _, name := range []string{"almalinux8", "almalinux9"} {
+				dist, err := GetDistribution("", "", "", "", "fubarhouse", name)
+				So(err, ShouldBeNil)
+				So(dist.Distro, ShouldEqual, name)
+				So(dist.User, ShouldEqual, "almalinux")
+				So(dist.InitSystem(), ShouldEqual, "systemd")
+				So(ListDistributions("redhat"), ShouldContain, dist)
+			}
+		})
+
+		Convey("Amazon Linux runs systemd in the redhat family", func() {
+			for alias, name := range map[string]string{"al2": "amazonlinux2", "AL2023": "amazonlinux2023"} {
+				dist, err := GetDistribution("", "", "", "", "fubarhouse", alias)
+				So(err, ShouldBeNil)
+				So(dist.Distro, ShouldEqual, name)
+				So(dist.InitSystem(), ShouldEqual, "systemd")
+				So(dist.Family.Volume, ShouldEqual, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+				So(ListDistributions("redhat"), ShouldContain, dist)
+				So(ListDistributions("amazonlinux"), ShouldContain, dist)
+			}
+			So(AliasesOf("amazonlinux2023"), ShouldResemble, []string{"al2023"})
+			So(AliasesOf("ubuntu1804"), ShouldBeEmpty)
+		})
+
+		Convey("openSUSE is bootstrapped with zypper in the suse family", func() {
+			for _, name := range []string{"opensuse15", "tumbleweed"} {
+				dist, err := GetDistribution("", "", "", "", "fubarhouse", name)
+				So(err, ShouldBeNil)
+				So(dist.Family.Group, ShouldEqual, "suse")
+				So(dist.InitSystem(), ShouldEqual, "systemd")
+				So(dist.Privileged, ShouldBeTrue)
+				So(dist.Family.Volume, ShouldEqual, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+				So(dist.Tmpfs, ShouldResemble, SystemdTmpfs)
+				So(ListDistributions("suse"), ShouldContain, dist)
+				So(ListDistributions("opensuse"), ShouldContain, dist)
+				So(ListDistributions("redhat"), ShouldNotContain, dist)
+			}
+			So(OpenSUSE.Bootstrap[0], ShouldContainSubstring, "zypper -n install python3 ansible")
+			So(OpenSUSETumbleweed.PullPolicy, ShouldEqual, PullAlways)
+		})
+
+		Convey("Debian 12 and Ubuntu 24.04 are the latest releases", func() {
+			for alias, name := range map[string]string{"debian-latest": "debian12", "ubuntu-latest": "ubuntu2404"} {
+				dist, err := GetDistribution("", "", "", "", "fubarhouse", alias)
+				So(err, ShouldBeNil)
+				So(dist.Distro, ShouldEqual, name)
+				So(dist.Family.Initialise, ShouldEqual, "/lib/systemd/systemd")
+				So(dist.Family.Volume, ShouldEqual, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+				So(dist.Deprecated(), ShouldBeFalse)
+				So(ListDistributions("debian"), ShouldContain, dist)
+			}
+		})
+
+		Convey("Releases past their end of life are deprecated with a warning", func() {
+			for _, dist := range []Distribution{JeffDebian8, JeffDebian9, JeffUbuntu1404, JeffUbuntu1604} {
+				So(dist.Deprecated(), ShouldBeTrue)
+			}
+
+			var out bytes.Buffer
+			log.SetOutput(&out)
+			defer log.SetOutput(ioutil.Discard)
+			JeffDebian8.warnDeprecated(&AnsibleConfig{})
+			So(out.String(), ShouldContainSubstring, "debian8 reached its end of life on 2020-06-30 and is deprecated")
+
+			out.Reset()
+			JeffDebian8.warnDeprecated(&AnsibleConfig{Quiet: true})
+			JeffDebian12.warnDeprecated(&AnsibleConfig{})
+			So(out.String(), ShouldBeEmpty)
+		})
+
+		Convey("Distributions are found by their aliases", func() {
+			dist, err := GetDistribution("", "", "", "", "fubarhouse", "alma9")
+			So(err, ShouldBeNil)
+			So(dist.Container, ShouldEqual, AlmaLinux9.Container)
+			dist, err = GetDistribution("", "", "", "", "fubarhouse", "Rocky8")
+			So(err, ShouldBeNil)
+			So(dist.Container, ShouldEqual, JeffRockyLinux8.Container)
+			for alias, name := range DistributionAliases {
+				_, err := GetDistribution("", "", "", "", "", name)
+				So(err, ShouldBeNil)
+				So(ResolveAlias(alias), ShouldEqual, name)
+			}
+		})
+
+		Convey("EL9 mounts the cgroups for cgroup v2", func() {
+			for _, dist := range []Distribution{JeffRockyLinux9, AlmaLinux9} {
+				dist.CID = "test"
+				config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", CgroupV2: true}
+				args := buildRunCommand(&dist, &config, &AnsibleReport{}).args()
+				So(args, ShouldContain, "--cgroupns=host")
+				So(args, ShouldContain, "--volume=/sys/fs/cgroup:/sys/fs/cgroup:rw")
+				So(args, ShouldContain, "--tmpfs=/run")
+			}
+		})
+
+		Convey("Distributions of several users need the user", func() {
+			dist, err := GetDistribution("", "", "", "", "geerlingguy", "ubuntu1804")
+			So(err, ShouldBeNil)
+			So(dist.Container, ShouldEqual, JeffUbuntu1804.Container)
+			dist, err = GetDistribution("", "", "", "", "fubarhouse", "ubuntu1804")
+			So(err, ShouldBeNil)
+			So(dist.Container, ShouldEqual, Ubuntu1804.Container)
+		})
+	})
+}
+
+func TestAlpine(t *testing.T) {
+
+	Convey("Running Alpine without systemd", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Alpine has no init system or cgroups", func() {
+			dist, err := GetDistribution("", "", "", "", "fubarhouse", "alpine3")
+			So(err, ShouldBeNil)
+			So(dist.InitSystem(), ShouldEqual, "none")
+			So(dist.Privileged, ShouldBeFalse)
+			So(ListDistributions("alpine"), ShouldResemble, []Distribution{Alpine3})
+
+			dist.CID = "test"
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", CgroupV2: true}
+			report := AnsibleReport{}
+			args := buildRunCommand(&dist, &config, &report).args()
+			So(args, ShouldNotContain, "--volume=")
+			So(args, ShouldNotContain, "--privileged")
+			So(args, ShouldNotContain, "--cgroupns=host")
+			So(report.Docker.Tmpfs, ShouldBeEmpty)
+			So(report.Docker.Volumes[0], ShouldEqual, "/home/user/web:/etc/ansible/roles/role_under_test")
+			So(args, ShouldContain, "alpine:3")
+		})
+
+		Convey("Ansible is installed by the bootstrap", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Alpine3
+			dist.CID = "test"
+			So(dist.DockerBootstrap(&AnsibleConfig{Quiet: true}), ShouldBeNil)
+			So(fake.commands, ShouldResemble, [][]string{{"exec", "test", "sh", "-c", "exec 2>&1; " + Alpine.Bootstrap[0]}})
+			So(Alpine.Bootstrap[0], ShouldContainSubstring, "apk add --no-cache ansible")
+
+			fake.commands = nil
+			So(dist.DockerBootstrap(&AnsibleConfig{Quiet: true, SkipBootstrap: true}), ShouldBeNil)
+			So(fake.commands, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestArchLinux(t *testing.T) {
+
+	Convey("Running Arch Linux as a rolling release", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Arch Linux runs systemd in its own family", func() {
+			dist, err := GetDistribution("", "", "", "", "fubarhouse", "arch")
+			So(err, ShouldBeNil)
+			So(dist.Distro, ShouldEqual, "archlinux")
+			So(dist.InitSystem(), ShouldEqual, "systemd")
+			So(dist.Privileged, ShouldBeTrue)
+			So(ListDistributions("archlinux"), ShouldResemble, []Distribution{ArchLinuxRolling})
+			So(ArchLinux.Bootstrap[0], ShouldContainSubstring, "pacman -Sy --noconfirm ansible python")
+		})
+
+		Convey("The image is always pulled unless the config has a policy", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := ArchLinuxRolling
+			report := AnsibleReport{}
+
+			So(dist.DockerPull(&AnsibleConfig{Quiet: true}, &report), ShouldBeNil)
+			So(fake.commands, ShouldResemble, [][]string{{"pull", dist.Container}})
+			So(report.Docker.PullPolicy, ShouldEqual, PullAlways)
+
+			fake.commands = nil
+			report = AnsibleReport{}
+			So(dist.DockerPull(&AnsibleConfig{PullPolicy: PullMissing, Quiet: true}, &report), ShouldBeNil)
+			So(fake.commands, ShouldResemble, [][]string{{"inspect", "--type", "image", "--format", "{{.Id}}", dist.Container}})
+			So(report.Docker.PullPolicy, ShouldEqual, PullMissing)
+		})
+	})
+}
@@ -2,76 +2,80 @@ package util
 
 import (
 	"bytes"
-	"io"
+	"context"
+	"io/ioutil"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"fmt"
-	"sync"
-	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// DockerExec will execute a command to the docker binary
-// and use the input args as arguments for that process.
+// DockerExec will execute a docker command, where args are the
+// arguments of the docker CLI, which runs it.
 // You can request output be printed using the bool stdout.
 func DockerExec(args []string, stdout bool) (string, error) {
+	return dockerExec(cliCommand(args), stdout)
+}
 
-	// Generate the command, based on input.
-	cmd := exec.Cmd{}
-	cmd.Path = docker
-	cmd.Args = []string{docker}
+// dockerExec will run the docker command with the engine, which is the
+// Docker Engine API unless the docker CLI is requested.
+func dockerExec(command dockerCommand, stdout bool) (string, error) {
+	return defaultOutput.dockerExec(context.Background(), command, nil, stdout)
+}
 
-	// Add our arguments to the command.
-	cmd.Args = append(cmd.Args, args...)
+// dockerExec will run the docker command like dockerExec, writing its
+// output to the output of the distribution. The secrets of the config
+// are given to the commands it executes in the container, and commands
+// of a stage are stopped once the timeout of the stage is reached.
+func (config *AnsibleConfig) dockerExec(command dockerCommand, stdout bool) (string, error) {
+	return config.output().dockerExec(config.stageContext(), command, config.galaxySecrets(), stdout)
+}
 
-	// If configured, print to os.Stdout.
-	if stdout {
-		cmd.Stdout = os.Stdout
-		cmd.Stdin = os.Stdin
-		cmd.Stderr = os.Stderr
+// dockerExec will run the docker command like dockerExec, writing its
+// output to the output. Only commands of the interactive output can
+// use the terminal. The secrets are only given to the commands which
+// are executed in a container, whose variables of their names read them.
+// The command is stopped once ctx is done or the run is interrupted.
+func (output *Output) dockerExec(ctx context.Context, command dockerCommand, secrets map[string]string, stdout bool) (string, error) {
+
+	if exec, ok := command.(execCommand); ok && len(secrets) > 0 {
+		exec.secrets = secrets
+		command = exec
 	}
 
 	// Create a buffer for the output.
 	var out bytes.Buffer
-	multi := io.MultiWriter(&out)
-
-	//if stdout && !noOutput {
-	if stdout {
-		multi = io.MultiWriter(&out, os.Stdout)
-	}
-
-	// Assign the output to the writer.
-	cmd.Stdout = multi
+	multi, done := output.writer(&out, stdout)
+	defer done()
 
 	// Check the errors, return as needed.
-	var wg sync.WaitGroup
-	wg.Add(1)
-	if err := cmd.Run(); err != nil {
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+	if err := engine.Command(ctx, command, stdout && output.interactive(), multi); err != nil {
 		log.Errorln(err)
 		return out.String(), err
 	}
-	wg.Done()
 
 	// Return out output as a string.
 	return out.String(), nil
 }
 
+// DockerVersion will return the version of the Docker server.
+func DockerVersion() (string, error) {
+	out, err := dockerExec(versionCommand{}, false)
+	return strings.TrimSpace(out), err
+}
+
 // DockerCheck checks if the specified container is running.
 func (dist *Distribution) DockerCheck() bool {
 	// Users should not be able to re-dockerRun containers with the same name...
 	if dist.CID != "" {
-		out, err := DockerExec([]string{
-			"ps",
-			"-a",
-			"-f",
-			"status=running",
-			"--format",
-			"'{{.Names}}'",
-		}, false)
+		out, err := dockerExec(psCommand{Filters: []string{"status=running"}}, false)
 
 		if err != nil {
 			return false
@@ -85,43 +89,120 @@ func (dist *Distribution) DockerCheck() bool {
 	return false
 }
 
-// buildDockerArgs returns a list of arguments for the docker daemon. Note that the order
-// matters here, and beware of trailing whitespaces.
-func buildDockerArgs(dist *Distribution, config *AnsibleConfig, report *AnsibleReport) []string {
-	dockerArgs := []string{
-		"run",
-		"--detach",
-		fmt.Sprintf("--name=%v", dist.CID),
+// containerLabel is the label of the containers which are created by
+// ansible-role-tester, so leftover containers can be found and removed.
+const containerLabel = "ansible-role-tester"
+
+// buildRunCommand returns the command which runs the container of the
+// distribution. Note that the order of the volumes matters here.
+func buildRunCommand(dist *Distribution, config *AnsibleConfig, report *AnsibleReport) runContainer {
+	run := runContainer{
+		Name:   dist.CID,
+		Labels: []string{fmt.Sprintf("%v=true", containerLabel)},
 	}
 
-	// Basic volumes, assumed default.
-	report.Docker.Volumes = append(report.Docker.Volumes, dist.Family.Volume)
-	report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%v:%v", config.HostPath, config.RemotePath))
+	// Containers are labelled with their owner, so they can be found
+	// even when this run lost track of them.
+	run.Labels = append(run.Labels, ownershipLabels(dist, config)...)
 
-	// If we're dealing with commands inside the container directly,
-	// it would be practical to mount into the proper namespace
-	// so we can call the roles by name in the playbook, rather
-	// than the role_under_test convention.
-	if !config.Remote {
-		roleDir := filepath.Base(config.HostPath)
-		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:/etc/ansible/roles/%v", config.HostPath, roleDir))
+	// Containers which may be kept are labelled so they can be found.
+	if config.KeepAlways {
+		run.Labels = append(run.Labels, fmt.Sprintf("%v.keep=always", containerLabel))
+	} else if config.KeepOnFailure {
+		run.Labels = append(run.Labels, fmt.Sprintf("%v.keep=failure", containerLabel))
+	}
+
+	// Reusable containers are found by the role and distribution.
+	if config.Reuse {
+		run.Labels = append(run.Labels, reuseLabels(dist, config)...)
+	}
+
+	// Basic volumes, assumed default. The cgroups are mounted for
+	// the cgroup version of the host.
+	familyVolume, cgroupns := cgroupSetup(dist.Family.Volume, config.CgroupV2)
+	run.Cgroupns = cgroupns
+
+	// Systemd needs /run as tmpfs, which distributions declare.
+	report.Docker.Tmpfs = tmpfsMounts(dist, config)
+	run.Tmpfs = report.Docker.Tmpfs
+	report.Docker.Volumes = append(report.Docker.Volumes, familyVolume)
+
+	// The role is copied into the container by DockerSync instead
+	// when it is not mounted.
+	report.Docker.Transfer = config.transfer()
+	if !config.Copy {
+		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%v:%v", config.HostPath, config.RemotePath))
+
+		// If we're dealing with commands inside the container directly,
+		// it would be practical to mount into the proper namespace
+		// so we can call the roles by name in the playbook, rather
+		// than the role_under_test convention.
+		if !config.Remote {
+			roleDir := filepath.Base(config.HostPath)
+			report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:/etc/ansible/roles/%v", config.HostPath, roleDir))
+		}
 	}
 
 	if config.ExtraRolesPath != "" {
 		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:%v", config.ExtraRolesPath, "/root/.ansible/roles"))
 	}
 
+	// The modules run in the container for remote runs too.
+	if config.PythonRequirements != "" {
+		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:%v:ro", config.PythonRequirements, pythonRequirementsPath))
+	}
+
+	if config.CollectionsOffline != "" && !config.Remote {
+		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:%v:ro", config.CollectionsOffline, offlineCollectionsPath))
+	}
+
 	if config.LibraryPath != "" {
 		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:%v", config.LibraryPath, "/root/.ansible/plugins/modules"))
 	}
 
+	if config.AnsibleCfg != "" && !config.Remote {
+		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:%v:ro", config.AnsibleCfg, ansibleCfgPath))
+	}
+
+	if config.InventoryFile != "" && !config.Remote {
+		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:%v:ro", config.InventoryFile, inventoryPath))
+	}
+
+	if config.VaultPasswordFile != "" && !config.Remote {
+		report.Docker.Volumes = append(report.Docker.Volumes, fmt.Sprintf("%s:%v:ro", config.VaultPasswordFile, vaultPasswordPath))
+	}
+
+	if !config.Remote {
+		report.Docker.Volumes = append(report.Docker.Volumes, config.dependencyVolumes()...)
+	}
+
+	report.Docker.Volumes = append(report.Docker.Volumes, dist.Volumes...)
+	report.Docker.Volumes = append(report.Docker.Volumes, config.Volumes...)
+
+	// A remote daemon can't mount the paths of this host, so they
+	// are copied into the container once it is running instead.
+	if RemoteDaemon() {
+		for _, Volume := range report.Docker.Volumes[1:] {
+			if Volume != dist.Family.Volume {
+				Volume = strings.TrimSuffix(strings.TrimSuffix(Volume, ":ro"), ":rw")
+				report.Docker.Copies = append(report.Docker.Copies, Volume)
+			}
+		}
+		report.Docker.Volumes = report.Docker.Volumes[:1]
+	}
+
+	// Families without a cgroup volume, ie Alpine, don't mount it.
+	if report.Docker.Volumes[0] == "" {
+		report.Docker.Volumes = report.Docker.Volumes[1:]
+	}
+
 	// Mount the volumes!
 	VolumeMap := map[string]string{}
 	for i, Volume := range report.Docker.Volumes {
 		if VolumeMap[Volume] != Volume {
 			// The Volume entry was not found in the map.
 			VolumeMap[Volume] = Volume
-			dockerArgs = append(dockerArgs, fmt.Sprintf("--volume=%v", Volume))
+			run.Volumes = append(run.Volumes, Volume)
 		} else {
 			// The volume entry was found in the map.
 			// We need to update our slice to reflect this duplication.
@@ -129,14 +210,120 @@ func buildDockerArgs(dist *Distribution, config *AnsibleConfig, report *AnsibleR
 		}
 	}
 
-	if dist.Privileged {
-		dockerArgs = append(dockerArgs, fmt.Sprint("--privileged"))
+	run.Network = config.Network
+
+	report.Docker.Memory = config.Memory
+	report.Docker.CPUs = config.CPUs
+	run.Memory = config.Memory
+	run.CPUs = config.CPUs
+	run.Publish = config.Publish
+	run.Env = config.DockerEnv
+	run.Privileged = dist.Privileged
+	run.Platform = config.Platform
+
+	// The daemon removes the container once it stops, unless it may
+	// be kept.
+	run.AutoRemove = config.AutoRemove && !config.keepsContainer()
+
+	// Extra arguments are added last, the image must follow the options.
+	run.Extra = extraDockerArgs(config)
+
+	run.Image = dist.Container
+	run.Cmd = strings.Fields(dist.Family.Initialise)
+	return run
+}
+
+// ParseDockerEnv will convert the environment variables of the env files
+// and the list of values into the environment of the container, where
+// values override the files. Variables are in the format KEY=VALUE, or
+// KEY to use the value of this environment, which is left out when it
+// is not set. Blank lines and lines starting with # are ignored in files.
+func ParseDockerEnv(values []string, files []string) ([]string, error) {
+
+	lines := []string{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, values...)
+
+	env := []string{}
+	index := map[string]int{}
+	for _, line := range lines {
+		pair := strings.SplitN(line, "=", 2)
+		if pair[0] == "" || strings.ContainsAny(pair[0], " \t") {
+			return nil, fmt.Errorf("invalid docker environment variable '%v', expected KEY=VALUE or KEY", line)
+		}
+		if len(pair) == 1 {
+			value, ok := os.LookupEnv(pair[0])
+			if !ok {
+				continue
+			}
+			pair = append(pair, value)
+		}
+
+		variable := pair[0] + "=" + pair[1]
+		if i, ok := index[pair[0]]; ok {
+			env[i] = variable
+		} else {
+			index[pair[0]] = len(env)
+			env = append(env, variable)
+		}
+	}
+
+	return env, nil
+}
+
+// buildExecCommand returns the command which executes cmd inside of
+// the container, including any environment variables needed by Ansible.
+func buildExecCommand(dist *Distribution, config *AnsibleConfig, cmd ...string) execCommand {
+	exec := execCommand{
+		Container: dist.CID,
+		TTY:       true,
+
+		// Step mode needs to read confirmations from the terminal.
+		Interactive: config.Step,
+		User:        config.ExecUser,
+	}
+
+	env := buildAnsibleEnv(config)
+	keys := []string{}
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	unset := []string{}
+	for _, key := range keys {
+		if env[key] == "" {
+			unset = append(unset, key)
+		} else if galaxySecret(key) {
+			// The value is given to docker by dockerExec.
+			exec.Env = append(exec.Env, key)
+		} else {
+			exec.Env = append(exec.Env, fmt.Sprintf("%v=%v", key, env[key]))
+		}
+	}
+
+	// Docker can't unset variables, so the command is wrapped with env.
+	if len(unset) > 0 {
+		exec.Cmd = append(exec.Cmd, "env")
+		for _, key := range unset {
+			exec.Cmd = append(exec.Cmd, "-u", key)
+		}
 	}
-	dockerArgs = append(dockerArgs, []string{
-		dist.Container,
-		dist.Family.Initialise,
-	}...)
-	return dockerArgs
+
+	exec.Cmd = append(exec.Cmd, cmd...)
+	return exec
 }
 
 // DockerRun will launch a new container (containerID) using
@@ -144,16 +331,84 @@ func buildDockerArgs(dist *Distribution, config *AnsibleConfig, report *AnsibleR
 func (dist *Distribution) DockerRun(config *AnsibleConfig, report *AnsibleReport) bool {
 
 	if dist.CID == "" {
-		dist.CID = fmt.Sprint(time.Now().Unix())
+		dist.CID = ContainerName(dist, config)
 	}
 
 	if !dist.DockerCheck() {
+		dist.warnDeprecated(config)
+
+		report.Docker.Platform = config.Platform
+		if err := CheckPlatform(config.Platform); err != nil {
+			log.Errorln(err)
+			return false
+		}
+
+		if err := dist.DockerPull(config, report); err != nil {
+			log.Errorln(err)
+			return false
+		}
+
+		if err := dist.DockerNetwork(config, report); err != nil {
+			log.Errorln(err)
+			return false
+		}
+
+		if err := checkPorts(config.Publish); err != nil {
+			log.Errorln(err)
+			return false
+		}
+
 		if !config.Quiet {
-			log.Printf("Running %v", dist.CID)
+			log.Printf("Running %v (privileged: %v, init: %v)", dist.CID, dist.Privileged, dist.Family.Initialise)
+			if _, cgroupns := cgroupSetup(dist.Family.Volume, config.CgroupV2); cgroupns != "" {
+				log.Printf("The host uses cgroup v2, the cgroups are mounted read-write with --cgroupns=%v and /run as tmpfs", cgroupns)
+			} else if !config.CgroupV2 {
+				log.Debugln("The host uses cgroup v1")
+			}
+			config.logRemoval(dist)
 		}
 
-		if _, err := DockerExec(buildDockerArgs(dist, config, report), !config.Quiet); err != nil {
+		report.warnUnreadable(config)
+		run := buildRunCommand(dist, config, report)
+		if config.Verbose && !config.Quiet {
+			log.Infof("Running %v", commandLine("docker", run.args()))
+		}
+		out, err := config.dockerExec(run, !config.Quiet)
+
+		// Limits the kernel can't apply are only warned about by docker.
+		for _, warning := range dockerWarnings(out) {
+			log.Warnf("docker: %v", warning)
+			report.Docker.Warnings = append(report.Docker.Warnings, warning)
+		}
+
+		if err != nil {
+			log.Errorln(err)
+			if config.Platform != "" {
+				log.Errorf("Containers of %v may need qemu registered with binfmt_misc, install it with:\n\tdocker run --privileged --rm tonistiigi/binfmt --install %v", config.Platform, platformArchitecture(config.Platform))
+			}
+		} else if !dist.DockerCopy(config, report) {
+			return false
+		} else if config.Copy && !dist.DockerSync(config, report) {
+			return false
+		} else if err := dist.DockerReady(config); err != nil {
+			log.Errorln(err)
+			return false
+		} else if err := dist.DockerBootstrap(config); err != nil {
+			log.Errorln(err)
+			return false
+		} else if err := dist.DockerCreateUser(config); err != nil {
+			log.Errorln(err)
+			return false
+		} else if err := dist.DockerEnsureAnsible(config, report); err != nil {
 			log.Errorln(err)
+			return false
+		} else if err := dist.DockerPythonRequirements(config, report); err != nil {
+			log.Errorln(err)
+			return false
+		} else if len(config.Publish) > 0 {
+			if err := dist.DockerPorts(report); err != nil {
+				log.Errorln(err)
+			}
 		}
 
 	} else {
@@ -166,6 +421,69 @@ func (dist *Distribution) DockerRun(config *AnsibleConfig, report *AnsibleReport
 
 }
 
+// DockerCopy will copy the paths which could not be mounted into the
+// container, which is the case for remote docker daemons.
+func (dist *Distribution) DockerCopy(config *AnsibleConfig, report *AnsibleReport) bool {
+
+	for _, target := range report.Docker.Copies {
+		paths := strings.SplitN(target, ":", 2)
+		if len(paths) != 2 {
+			log.Errorf("invalid copy '%v'", target)
+			return false
+		}
+
+		if !config.Quiet {
+			log.Printf("Copying %v to %v", paths[0], paths[1])
+		}
+
+		// The docker CLI requires the parent directory to exist.
+		if _, err := config.dockerExec(dist.containerExec("mkdir", "-p", path.Dir(paths[1])), false); err != nil {
+			return false
+		}
+		if _, err := config.dockerExec(cpCommand{Source: paths[0], Container: dist.CID, Destination: paths[1]}, false); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// KeepContainer will return true when the container should be left
+// running after the tests, which is always with KeepAlways or when the
+// tests failed with KeepOnFailure.
+func (report *AnsibleReport) KeepContainer(config *AnsibleConfig) bool {
+	return config.KeepAlways || (config.KeepOnFailure && report.ExitCode() != OKCode)
+}
+
+// DockerCleanup will stop and remove the container after the tests,
+// unless it should be kept, in which case the command to debug it is
+// shown instead. Reused containers are kept unless Destroy is set.
+// A network which was created for the container is removed with it.
+// The outcome is recorded in the report.
+func (dist *Distribution) DockerCleanup(config *AnsibleConfig, report *AnsibleReport) {
+
+	if config.Reuse && !config.Destroy {
+		report.Docker.Kept = true
+		if !config.Quiet {
+			log.Infof("Container %v was kept for reuse, remove it with --destroy", dist.CID)
+		}
+		return
+	}
+
+	if report.KeepContainer(config) {
+		report.Docker.Kept = true
+		log.Warnf("Container %v was left running, debug it with:\n\tdocker exec -it %v bash", dist.CID, dist.CID)
+		return
+	}
+
+	dist.DockerStop(config, report)
+	dist.DockerRemoveRun(config)
+	if !dist.DockerCheck() {
+		report.Docker.Kill = true
+		dist.DockerNetworkRemove(config, report)
+	}
+}
+
 // DockerKill will stop the container and remove it.
 func (dist *Distribution) DockerKill(quiet bool) bool {
 
@@ -176,20 +494,14 @@ func (dist *Distribution) DockerKill(quiet bool) bool {
 			if !quiet {
 				log.Printf("Stopping %v\n", dist.CID)
 			}
-			if _, err := DockerExec([]string{
-				"stop",
-				dist.CID,
-			}, false); err != nil {
+			if _, err := dockerExec(stopCommand{Container: dist.CID}, false); err != nil {
 				log.Errorln(err)
 			}
 
 			if !quiet {
 				log.Printf("Removing %v\n", dist.CID)
 			}
-			if _, err := DockerExec([]string{
-				"rm",
-				dist.CID,
-			}, false); err != nil {
+			if _, err := dockerExec(rmCommand{Container: dist.CID}, false); err != nil {
 				log.Errorln(err)
 			}
 		} else {
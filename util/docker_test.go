@@ -0,0 +1,158 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerCleanup(t *testing.T) {
+
+	Convey("Cleaning up the container after the tests", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		failed := func() AnsibleReport {
+			report := AnsibleReport{}
+			report.Ansible.Syntax = true
+			report.Ansible.Run.Result = false
+			return report
+		}
+
+		Convey("Containers are kept when the tests fail with KeepOnFailure", func() {
+			config := AnsibleConfig{KeepOnFailure: true}
+			report := failed()
+			So(report.ExitCode(), ShouldNotEqual, OKCode)
+			So(report.KeepContainer(&config), ShouldBeTrue)
+			So(report.KeepContainer(&AnsibleConfig{}), ShouldBeFalse)
+			So(report.KeepContainer(&AnsibleConfig{KeepAlways: true}), ShouldBeTrue)
+		})
+
+		Convey("Kept containers are not removed and are recorded", func() {
+			fake := &fakeEngine{running: []string{"test"}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+			report := failed()
+
+			dist.DockerCleanup(&AnsibleConfig{KeepOnFailure: true, Quiet: true}, &report)
+			So(report.Docker.Kept, ShouldBeTrue)
+			So(report.Docker.Kill, ShouldBeFalse)
+			So(report.NewJSONReport().ContainerKept, ShouldBeTrue)
+			for _, command := range fake.commands {
+				So(command[0], ShouldNotEqual, "stop")
+			}
+		})
+
+		Convey("Containers are removed otherwise", func() {
+			fake := &fakeEngine{running: []string{"test"}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+			report := failed()
+
+			dist.DockerCleanup(&AnsibleConfig{Quiet: true}, &report)
+			So(report.Docker.Kept, ShouldBeFalse)
+			So(fake.commands, ShouldContain, []string{"stop", "test"})
+			So(fake.commands, ShouldContain, []string{"rm", "test"})
+		})
+
+		Convey("Containers which may be kept are labelled", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", KeepOnFailure: true}
+			args := buildRunCommand(&dist, &config, &AnsibleReport{}).args()
+			So(args, ShouldContain, "--label=ansible-role-tester=true")
+			So(args, ShouldContain, "--label=ansible-role-tester.keep=failure")
+		})
+	})
+}
+
+func TestParseDockerEnv(t *testing.T) {
+
+	Convey("Parsing the environment of the container", t, func() {
+
+		Convey("Values with = and spaces are kept", func() {
+			env, err := ParseDockerEnv([]string{"URL=https://example.com/?a=b", "GREETING=hello world"}, nil)
+			So(err, ShouldBeNil)
+			So(env, ShouldResemble, []string{"URL=https://example.com/?a=b", "GREETING=hello world"})
+		})
+
+		Convey("Keys without a value use this environment", func() {
+			os.Setenv("ART_TEST_TOKEN", "secret value")
+			defer os.Unsetenv("ART_TEST_TOKEN")
+			os.Unsetenv("ART_TEST_UNSET")
+
+			env, err := ParseDockerEnv([]string{"ART_TEST_TOKEN", "ART_TEST_UNSET"}, nil)
+			So(err, ShouldBeNil)
+			So(env, ShouldResemble, []string{"ART_TEST_TOKEN=secret value"})
+		})
+
+		Convey("Env files are read and overridden by values", func() {
+			dir, _ := ioutil.TempDir("", "env")
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, "test.env")
+			ioutil.WriteFile(file, []byte("# credentials\nUSER=admin\n\nPASSWORD=a b=c\n"), 0644)
+
+			env, err := ParseDockerEnv([]string{"USER=root"}, []string{file})
+			So(err, ShouldBeNil)
+			So(env, ShouldResemble, []string{"USER=root", "PASSWORD=a b=c"})
+
+			_, err = ParseDockerEnv(nil, []string{filepath.Join(dir, "missing.env")})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Invalid variables are rejected", func() {
+			_, err := ParseDockerEnv([]string{"=value"}, nil)
+			So(err, ShouldNotBeNil)
+			_, err = ParseDockerEnv([]string{"MY KEY=value"}, nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("The environment is set when the container is created", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: cgroupVolume}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", DockerEnv: []string{"GREETING=hello world"}}
+			So(buildRunCommand(&dist, &config, &AnsibleReport{}).args(), ShouldContain, "--env=GREETING=hello world")
+		})
+	})
+}
+
+func TestDistributionOverride(t *testing.T) {
+
+	Convey("Overriding the settings of the distribution", t, func() {
+
+		Convey("Settings are kept without overrides", func() {
+			dist := CentOS7
+			dist.Override(nil, false, "")
+			So(dist.Privileged, ShouldBeTrue)
+			So(dist.Family.Initialise, ShouldEqual, "/sbin/init")
+		})
+
+		Convey("Privileged and init are overridden for the run", func() {
+			privileged := false
+			dist := CentOS7
+			dist.Override(&privileged, false, "/usr/lib/systemd/systemd --system")
+			So(dist.Privileged, ShouldBeFalse)
+			So(dist.Family.Initialise, ShouldEqual, "/usr/lib/systemd/systemd --system")
+			So(CentOS7.Privileged, ShouldBeTrue)
+			So(CentOS7.Family.Initialise, ShouldEqual, "/sbin/init")
+
+			dist.CID = "test"
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test"}
+			args := buildRunCommand(&dist, &config, &AnsibleReport{}).args()
+			So(args, ShouldNotContain, "--privileged")
+			So(args[len(args)-3:], ShouldResemble, []string{"fubarhouse/docker-ansible:centos-7", "/usr/lib/systemd/systemd", "--system"})
+		})
+
+		Convey("Containers run without init", func() {
+			dist := CentOS7
+			dist.Override(nil, true, "")
+			So(dist.Family.Initialise, ShouldEqual, NoInitCommand)
+
+			report := AnsibleReport{}
+			report.Ansible.Distribution = dist
+			So(report.NewJSONReport().Distribution.InitCommand, ShouldEqual, NoInitCommand)
+		})
+	})
+}
@@ -0,0 +1,872 @@
+package util
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// Engine runs the docker commands of the tests with either the docker
+// CLI or the Docker Engine API. Commands are stopped once the context
+// is done.
+type Engine interface {
+	Command(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error
+}
+
+const (
+
+	// execMarker is the variable which marks the processes of the
+	// commands executed in containers, so they can be killed when the
+	// command is stopped, which docker leaves running.
+	execMarker = "ANSIBLE_ROLE_TESTER_EXEC"
+)
+
+var (
+
+	// engine runs the docker commands, which is the Docker Engine
+	// API unless the docker CLI is requested.
+	engine Engine = newEngine(dockerHost())
+
+	// execCount is the number of commands which were marked.
+	execCount int64
+
+	// reapTimeout is the longest time killing the processes of a
+	// command which was stopped may take.
+	reapTimeout = 10 * time.Second
+)
+
+// engineCommand will run the docker command with the engine, which is
+// stopped when the run is interrupted.
+func engineCommand(command dockerCommand, stdout bool, out io.Writer) error {
+	ctx, cancel := commandContext(context.Background())
+	defer cancel()
+	return engine.Command(ctx, command, stdout, out)
+}
+
+// UseDockerCLI will run the docker commands with the docker CLI
+// instead of the Docker Engine API when cli is true.
+func UseDockerCLI(cli bool) {
+	if cli {
+		engine = cliEngine{}
+	} else {
+		engine = newEngine(dockerHost())
+	}
+}
+
+// dockerHost will return the address of the Docker daemon, which
+// is DOCKER_HOST when it is set, or the local socket otherwise.
+func dockerHost() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	return client.DefaultDockerHost
+}
+
+// dockerAddress will return the network and address of the Docker
+// daemon at host, which is empty for daemons which can't be dialled,
+// such as ssh:// daemons which only the docker CLI can reach.
+func dockerAddress(host string) (string, string) {
+	address, err := url.Parse(host)
+	if err != nil {
+		return "", ""
+	}
+	switch address.Scheme {
+	case "unix":
+		return "unix", address.Path
+	case "tcp":
+		return "tcp", address.Host
+	}
+	return "", ""
+}
+
+// RemoteDaemon will return true when the Docker daemon is not running
+// on this host, in which case the paths of this host can't be mounted
+// into the containers and are copied into them instead.
+func RemoteDaemon() bool {
+	address, err := url.Parse(dockerHost())
+	if err != nil || address.Scheme == "unix" || address.Scheme == "npipe" {
+		return false
+	}
+	switch address.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return false
+	}
+	return true
+}
+
+// newEngine will return the Engine for the Docker daemon at host. The
+// daemons of ssh:// addresses are run with the docker CLI, which
+// honours DOCKER_HOST itself. The client of the Docker SDK reads the
+// certificates of DOCKER_TLS_VERIFY and DOCKER_CERT_PATH like the CLI.
+func newEngine(host string) Engine {
+	if network, _ := dockerAddress(host); network == "" {
+		return cliEngine{}
+	}
+	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithHost(host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		log.Warnf("unable to use the Docker Engine API, the docker CLI is used instead: %v", err)
+		return cliEngine{}
+	}
+	return &apiEngine{client: docker}
+}
+
+// contextError will return ErrTimeout or ErrInterrupted when the
+// command failed because its context is done, or err otherwise.
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return ErrTimeout
+	case context.Canceled:
+		return ErrInterrupted
+	}
+	return err
+}
+
+// runExec will run the docker command with run. Docker leaves the
+// processes of a command executed in a container running when the
+// command is stopped, so they are marked in their environment and are
+// killed once the context is done.
+func runExec(ctx context.Context, command dockerCommand, stdout bool, out io.Writer, run func(context.Context, dockerCommand, bool, io.Writer) error) error {
+
+	exec, ok := command.(execCommand)
+	if !ok || ctx.Done() == nil {
+		return run(ctx, command, stdout, out)
+	}
+
+	marker := fmt.Sprintf("%v=%v-%v", execMarker, os.Getpid(), atomic.AddInt64(&execCount, 1))
+	exec.Env = append(append([]string{}, exec.Env...), marker)
+	err := run(ctx, exec, stdout, out)
+	if ctx.Err() == nil {
+		return err
+	}
+
+	reap, cancel := context.WithTimeout(context.Background(), reapTimeout)
+	defer cancel()
+	script := fmt.Sprintf(`for p in /proc/[0-9]*; do tr '\0' '\n' < $p/environ 2>/dev/null | grep -qx '%v' && kill -9 ${p#/proc/} 2>/dev/null; done; true`, marker)
+	if err := run(reap, execCommand{Container: exec.Container, Cmd: []string{"sh", "-c", script}}, false, ioutil.Discard); err != nil {
+		log.Warnf("unable to kill the processes of the stopped command in %v: %v", exec.Container, err)
+	}
+	return err
+}
+
+// cliEngine runs the docker commands with the docker CLI.
+type cliEngine struct{}
+
+// Command will run the docker CLI with the arguments of the command,
+// where the output is written to out. The terminal is attached when
+// stdout is true.
+func (cli cliEngine) Command(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error {
+	return runExec(ctx, command, stdout, out, cli.run)
+}
+
+// run will run the docker CLI with the arguments of the command, where
+// the secrets of commands executed in a container are only in the
+// environment of the docker CLI.
+func (cliEngine) run(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error {
+
+	if docker == "" {
+		return errors.New("executable 'docker' was not found in $PATH")
+	}
+
+	cmd := exec.Command(docker, command.args()...)
+	if stdout {
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+	}
+	cmd.Stdout = out
+	if exec, ok := command.(execCommand); ok && len(exec.secrets) > 0 {
+		cmd.Env = mergeEnv(os.Environ(), exec.secrets)
+	}
+
+	// Explicit credentials are given to pulls as a docker config
+	// of their own, so they are never stored or shown.
+	if pull, ok := command.(pullCommand); ok && registryLogin != nil {
+		dir, err := ioutil.TempDir("", "docker-config")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		auth, _ := registryAuth(pull.Image)
+		if err := auth.dockerConfig(dir); err != nil {
+			return err
+		}
+		cmd.Env = append(os.Environ(), "DOCKER_CONFIG="+dir)
+	}
+
+	return runCommand(ctx, cmd)
+}
+
+// apiEngine runs the docker commands with the Docker Engine API, using
+// the client of the Docker SDK, which negotiates the version of the API
+// with the daemon.
+type apiEngine struct {
+	client client.APIClient
+}
+
+// Command will run the docker command with the Docker Engine API, where
+// the output is written to out. Commands of the docker CLI, and the
+// containers with the flags of --docker-arg, which are flags of the
+// docker CLI, are run with the docker CLI.
+func (api *apiEngine) Command(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error {
+	switch command := command.(type) {
+	case cliCommand:
+		return cliEngine{}.Command(ctx, command, stdout, out)
+	case runContainer:
+		if len(command.Extra) > 0 {
+			log.Debugf("Running %v with the docker CLI for the flags of --docker-arg", command.Name)
+			return cliEngine{}.Command(ctx, command, stdout, out)
+		}
+	}
+	return runExec(ctx, command, stdout, out, api.run)
+}
+
+// run will run the docker command with the Docker Engine API, where the
+// output is written like the docker CLI.
+func (api *apiEngine) run(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error {
+
+	var err error
+	switch command := command.(type) {
+	case versionCommand:
+		var version types.Version
+		if version, err = api.client.ServerVersion(ctx); err == nil {
+			fmt.Fprintln(out, version.Version)
+		}
+	case cgroupCommand:
+		err = api.cgroup(ctx, out)
+	case psCommand:
+		err = api.ps(ctx, command, out)
+	case imagesCommand:
+		err = api.images(ctx, command, out)
+	case networkLsCommand:
+		err = api.networks(ctx, command, out)
+	case inspectCommand:
+		err = api.inspect(ctx, command, out)
+	case runContainer:
+		err = api.runContainer(ctx, command, out)
+	case execCommand:
+		err = api.exec(ctx, command, stdout, out)
+	case networkCreateCommand:
+		var created types.NetworkCreateResponse
+		if created, err = api.client.NetworkCreate(ctx, command.Name, types.NetworkCreate{Labels: labelMap(command.Labels)}); err == nil {
+			fmt.Fprintln(out, created.ID)
+		}
+	case networkRmCommand:
+		if err = api.client.NetworkRemove(ctx, command.Name); err == nil {
+			fmt.Fprintln(out, command.Name)
+		}
+	case portCommand:
+		err = api.port(ctx, command, out)
+	case startCommand:
+		if err = api.client.ContainerStart(ctx, command.Container, container.StartOptions{}); err == nil {
+			fmt.Fprintln(out, command.Container)
+		}
+	case stopCommand:
+		options := container.StopOptions{}
+		if command.Timeout > 0 {
+			options.Timeout = &command.Timeout
+		}
+		if err = api.client.ContainerStop(ctx, command.Container, options); err == nil {
+			fmt.Fprintln(out, command.Container)
+		}
+	case killCommand:
+		if err = api.client.ContainerKill(ctx, command.Container, ""); err == nil {
+			fmt.Fprintln(out, command.Container)
+		}
+	case rmCommand:
+		if err = api.client.ContainerRemove(ctx, command.Container, container.RemoveOptions{Force: command.Force}); err == nil {
+			fmt.Fprintln(out, command.Container)
+		}
+	case rmiCommand:
+		_, err = api.client.ImageRemove(ctx, command.Image, image.RemoveOptions{})
+	case cpCommand:
+		err = api.copy(ctx, command)
+	case buildCommand:
+		err = api.build(ctx, command, out)
+	case pullCommand:
+		err = api.pull(ctx, command, out)
+	case logsCommand:
+		err = api.logs(ctx, command, out)
+	default:
+		err = fmt.Errorf("docker %v is not supported by the Docker Engine API", strings.Join(command.args(), " "))
+	}
+
+	return contextError(ctx, err)
+}
+
+// listFilters will return the filters of a list, ie "label=x".
+func listFilters(values []string) filters.Args {
+	args := filters.NewArgs()
+	for _, value := range values {
+		pair := strings.SplitN(value, "=", 2)
+		if len(pair) == 2 {
+			args.Add(pair[0], pair[1])
+		} else {
+			args.Add(pair[0], "")
+		}
+	}
+	return args
+}
+
+// labelMap will return the labels in the format key=value as a map.
+func labelMap(labels []string) map[string]string {
+	result := map[string]string{}
+	for _, label := range labels {
+		pair := strings.SplitN(label, "=", 2)
+		result[pair[0]] = ""
+		if len(pair) == 2 {
+			result[pair[0]] = pair[1]
+		}
+	}
+	return result
+}
+
+// cgroup will write the cgroup version of the Docker daemon, where
+// daemons which don't report it use cgroup v1.
+func (api *apiEngine) cgroup(ctx context.Context, out io.Writer) error {
+	info, err := api.client.Info(ctx)
+	if err != nil {
+		return err
+	}
+	if info.CgroupVersion == "" {
+		info.CgroupVersion = "1"
+	}
+	fmt.Fprintln(out, info.CgroupVersion)
+	return nil
+}
+
+// ps will write the names of the containers, one per line.
+func (api *apiEngine) ps(ctx context.Context, command psCommand, out io.Writer) error {
+	containers, err := api.client.ContainerList(ctx, container.ListOptions{All: command.All, Filters: listFilters(command.Filters)})
+	if err != nil {
+		return err
+	}
+	for _, container := range containers {
+		for _, name := range container.Names {
+			fmt.Fprintln(out, strings.TrimPrefix(name, "/"))
+		}
+	}
+	return nil
+}
+
+// images will write the short ids of the images, one per line.
+func (api *apiEngine) images(ctx context.Context, command imagesCommand, out io.Writer) error {
+	images, err := api.client.ImageList(ctx, image.ListOptions{Filters: listFilters(command.Filters)})
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		id := strings.TrimPrefix(image.ID, "sha256:")
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		fmt.Fprintln(out, id)
+	}
+	return nil
+}
+
+// networks will write the names of the networks, one per line.
+func (api *apiEngine) networks(ctx context.Context, command networkLsCommand, out io.Writer) error {
+	networks, err := api.client.NetworkList(ctx, types.NetworkListOptions{Filters: listFilters(command.Filters)})
+	if err != nil {
+		return err
+	}
+	for _, network := range networks {
+		fmt.Fprintln(out, network.Name)
+	}
+	return nil
+}
+
+// inspect will write the field of the container, image or network,
+// where the creation time is written as a JSON string.
+func (api *apiEngine) inspect(ctx context.Context, command inspectCommand, out io.Writer) error {
+
+	fields := map[inspectField]interface{}{}
+	switch command.Kind {
+	case "container":
+		result, err := api.client.ContainerInspect(ctx, command.Name)
+		if err != nil {
+			return err
+		}
+		fields[fieldImage] = result.Image
+		fields[fieldCreated] = result.Created
+	case "image":
+		result, _, err := api.client.ImageInspectWithRaw(ctx, command.Name)
+		if err != nil {
+			return err
+		}
+		fields[fieldID] = result.ID
+		fields[fieldCreated] = result.Created
+		fields[fieldDigests] = strings.Join(result.RepoDigests, " ")
+		fields[fieldPlatform] = result.Os + "/" + result.Architecture
+	case "network":
+		result, err := api.client.NetworkInspect(ctx, command.Name, types.NetworkInspectOptions{})
+		if err != nil {
+			return err
+		}
+		fields[fieldName] = result.Name
+		fields[fieldCreated] = result.Created
+	}
+
+	value, ok := fields[command.Field]
+	if !ok {
+		return fmt.Errorf("unable to inspect %v of the %v %v", command.Field, command.Kind, command.Name)
+	}
+	if command.Field == fieldCreated {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		value = string(encoded)
+	}
+	fmt.Fprintln(out, value)
+	return nil
+}
+
+// runContainer will create and start a container, pulling the image if
+// it is missing, and will write the id of the container. Warnings of the
+// daemon are written like the CLI, ie "WARNING: ...".
+func (api *apiEngine) runContainer(ctx context.Context, command runContainer, out io.Writer) error {
+
+	exposed, bindings, err := nat.ParsePortSpecs(command.Publish)
+	if err != nil {
+		return err
+	}
+
+	tmpfs := map[string]string{}
+	for _, mount := range command.Tmpfs {
+		pair := strings.SplitN(mount, ":", 2)
+		tmpfs[pair[0]] = ""
+		if len(pair) == 2 {
+			tmpfs[pair[0]] = pair[1]
+		}
+	}
+
+	config := &container.Config{
+		Image:        command.Image,
+		Cmd:          command.Cmd,
+		Env:          command.Env,
+		Labels:       labelMap(command.Labels),
+		ExposedPorts: exposed,
+	}
+	hostConfig := &container.HostConfig{
+		Binds:        command.Volumes,
+		PortBindings: bindings,
+		NetworkMode:  container.NetworkMode(command.Network),
+		Privileged:   command.Privileged,
+		AutoRemove:   command.AutoRemove,
+		CgroupnsMode: container.CgroupnsMode(command.Cgroupns),
+		Tmpfs:        tmpfs,
+		Resources: container.Resources{
+			Memory:   command.Memory,
+			NanoCPUs: int64(command.CPUs * 1e9),
+		},
+	}
+
+	var platform *ocispec.Platform
+	if command.Platform != "" {
+		parts := strings.SplitN(command.Platform, "/", 3)
+		platform = &ocispec.Platform{OS: parts[0]}
+		if len(parts) > 1 {
+			platform.Architecture = parts[1]
+		}
+		if len(parts) > 2 {
+			platform.Variant = parts[2]
+		}
+	}
+
+	created, err := api.client.ContainerCreate(ctx, config, hostConfig, nil, platform, command.Name)
+	if client.IsErrNotFound(err) {
+		if err = api.pull(ctx, pullCommand{Image: command.Image, Platform: command.Platform}, ioutil.Discard); err == nil {
+			created, err = api.client.ContainerCreate(ctx, config, hostConfig, nil, platform, command.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range created.Warnings {
+		fmt.Fprintf(out, "WARNING: %v\n", warning)
+	}
+
+	if err := api.client.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, created.ID)
+	return nil
+}
+
+// pull will pull the image and write its progress, without the
+// progress bars. The credentials of the registry are sent when there
+// are any.
+func (api *apiEngine) pull(ctx context.Context, command pullCommand, out io.Writer) error {
+
+	options := image.PullOptions{Platform: command.Platform}
+	auth, err := registryAuth(command.Image)
+	if err != nil {
+		return err
+	}
+	if auth != nil {
+		if options.RegistryAuth, err = auth.header(); err != nil {
+			return err
+		}
+	}
+
+	progress, err := api.client.ImagePull(ctx, command.Image, options)
+	if err != nil {
+		return err
+	}
+	defer progress.Close()
+
+	// The progress of the pull is streamed, which includes any error.
+	decoder := json.NewDecoder(progress)
+	for {
+		message := struct {
+			ID       string `json:"id"`
+			Status   string `json:"status"`
+			Progress string `json:"progress"`
+			Error    string `json:"error"`
+		}{}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		if message.Status != "" && message.Progress == "" {
+			if message.ID != "" {
+				fmt.Fprintf(out, "%v: ", message.ID)
+			}
+			fmt.Fprintln(out, message.Status)
+		}
+	}
+}
+
+// build will build an image from a Dockerfile and write its output,
+// where the context is sent as a tar stream.
+func (api *apiEngine) build(ctx context.Context, command buildCommand, out io.Writer) error {
+
+	// The Dockerfile is named relative to the build context.
+	dockerfile, err := filepath.Rel(command.Context, command.Dockerfile)
+	if err != nil || strings.HasPrefix(dockerfile, "..") {
+		return fmt.Errorf("the Dockerfile %v is not in the context %v", command.Dockerfile, command.Context)
+	}
+
+	// Like docker, an argument without a value is read from the environment.
+	buildArgs := map[string]*string{}
+	for _, arg := range command.BuildArgs {
+		pair := strings.SplitN(arg, "=", 2)
+		if len(pair) == 2 {
+			buildArgs[pair[0]] = &pair[1]
+		} else if value, ok := os.LookupEnv(pair[0]); ok {
+			buildArgs[pair[0]] = &value
+		}
+	}
+
+	var archive bytes.Buffer
+	if err := tarPath(&archive, command.Context, ""); err != nil {
+		return err
+	}
+
+	resp, err := api.client.ImageBuild(ctx, &archive, types.ImageBuildOptions{
+		Tags:       []string{command.Tag},
+		Dockerfile: filepath.ToSlash(dockerfile),
+		Labels:     labelMap(command.Labels),
+		BuildArgs:  buildArgs,
+		Platform:   command.Platform,
+		Remove:     true,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The output of the build is streamed, which includes any error.
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		message := struct {
+			Stream string `json:"stream"`
+			Error  string `json:"error"`
+		}{}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		fmt.Fprint(out, message.Stream)
+	}
+}
+
+// hijackedStream is the stream of an interactive command, which its
+// input is written to.
+type hijackedStream struct {
+	*types.HijackedResponse
+}
+
+// Write will write the input to the command.
+func (stream hijackedStream) Write(data []byte) (int, error) {
+	return stream.Conn.Write(data)
+}
+
+// exec will run a command in a container and write its output.
+// Interactive commands read the terminal, which is in raw mode while
+// they run. Commands which exit with a non-zero status fail.
+func (api *apiEngine) exec(ctx context.Context, command execCommand, stdout bool, out io.Writer) error {
+
+	created, err := api.client.ContainerExecCreate(ctx, command.Container, types.ExecConfig{
+		User:         command.User,
+		Tty:          command.TTY,
+		AttachStdin:  command.Interactive,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          command.env(),
+		WorkingDir:   command.Workdir,
+		Cmd:          command.Cmd,
+	})
+	if err != nil {
+		return err
+	}
+
+	attached, err := api.client.ContainerExecAttach(ctx, created.ID, types.ExecStartCheck{Tty: command.TTY})
+	if err != nil {
+		return err
+	}
+	defer attached.Close()
+
+	// The hijacked connection isn't closed with the context.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attached.Close()
+		case <-finished:
+		}
+	}()
+
+	// The terminal is sent to the command like the docker CLI,
+	// which stops once the output of the command has ended.
+	if command.Interactive {
+		if command.TTY && Terminal(os.Stdin) {
+			if restore, err := makeRaw(os.Stdin); err == nil {
+				defer restore()
+			}
+			if height, width, err := terminalSize(os.Stdout); err == nil && height > 0 && width > 0 {
+				api.client.ContainerExecResize(ctx, created.ID, container.ResizeOptions{Height: uint(height), Width: uint(width)})
+			}
+		}
+		go forwardInput(hijackedStream{&attached}, finished)
+	}
+
+	// Without a terminal stdout and stderr are multiplexed, where
+	// stderr is only shown like the CLI when the output is shown.
+	if command.TTY {
+		_, err = io.Copy(out, attached.Reader)
+	} else {
+		var stderr io.Writer = ioutil.Discard
+		if stdout {
+			stderr = os.Stderr
+		}
+		_, err = stdcopy.StdCopy(out, stderr, attached.Reader)
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := api.client.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("exit status %v", result.ExitCode)
+	}
+	return nil
+}
+
+// port will write the published ports of the container, one per line,
+// ie "80/tcp -> 0.0.0.0:32768".
+func (api *apiEngine) port(ctx context.Context, command portCommand, out io.Writer) error {
+
+	result, err := api.client.ContainerInspect(ctx, command.Container)
+	if err != nil {
+		return err
+	}
+	if result.NetworkSettings == nil {
+		return nil
+	}
+
+	ports := []string{}
+	for port := range result.NetworkSettings.Ports {
+		ports = append(ports, string(port))
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		for _, binding := range result.NetworkSettings.Ports[nat.Port(port)] {
+			fmt.Fprintf(out, "%v -> %v\n", port, net.JoinHostPort(binding.HostIP, binding.HostPort))
+		}
+	}
+	return nil
+}
+
+// copy will copy a path of this host into a container as a tar stream.
+func (api *apiEngine) copy(ctx context.Context, command cpCommand) error {
+
+	if !path.IsAbs(command.Destination) {
+		return fmt.Errorf("the destination %v in %v is not absolute", command.Destination, command.Container)
+	}
+
+	var archive bytes.Buffer
+	if err := tarPath(&archive, command.Source, strings.TrimPrefix(path.Clean(command.Destination), "/")); err != nil {
+		return err
+	}
+	if err := api.client.CopyToContainer(ctx, command.Container, "/", &archive, types.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("unable to copy %v: %v", command.Source, err)
+	}
+	return nil
+}
+
+// logs will write the last lines of the logs of the container, where
+// the output and errors of the container are both written to out.
+func (api *apiEngine) logs(ctx context.Context, command logsCommand, out io.Writer) error {
+	logs, err := api.client.ContainerLogs(ctx, command.Container, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       fmt.Sprint(command.Tail),
+	})
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	_, err = stdcopy.StdCopy(out, out, logs)
+	return err
+}
+
+var (
+
+	// terminalOnce starts reading the terminal once.
+	terminalOnce sync.Once
+
+	// terminalData is the input of the terminal, which is only read
+	// while an interactive command runs, so no input is lost between
+	// the commands. It is closed once the input has ended.
+	terminalData chan []byte
+)
+
+// forwardInput will write the input of the terminal to the stream of
+// the interactive command until it has finished.
+func forwardInput(stream io.Writer, finished <-chan struct{}) {
+
+	terminalOnce.Do(func() {
+		terminalData = make(chan []byte)
+		go func() {
+			for {
+				data := make([]byte, 1024)
+				n, err := os.Stdin.Read(data)
+				if n > 0 {
+					terminalData <- data[:n]
+				}
+				if err != nil {
+					close(terminalData)
+					return
+				}
+			}
+		}()
+	})
+
+	for {
+		select {
+		case data, ok := <-terminalData:
+			if !ok {
+				// The command gets the end of its input.
+				if closer, ok := stream.(interface{ CloseWrite() error }); ok {
+					closer.CloseWrite()
+				}
+				return
+			}
+			if _, err := stream.Write(data); err != nil {
+				return
+			}
+		case <-finished:
+			return
+		}
+	}
+}
+
+// tarPath will write the file or directory at source to a tar stream,
+// where the files are named relative to name.
+func tarPath(w io.Writer, source, name string) error {
+
+	writer := tar.NewWriter(w)
+	err := filepath.Walk(source, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel(source, file)
+		header.Name = path.Join(name, filepath.ToSlash(relative))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(writer, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,586 @@
+package util
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeDockerAPI will return a server which answers the requests of the
+// Docker Engine API with the handlers, keyed by "METHOD /path", and the
+// engine which sends its requests to the server.
+func fakeDockerAPI(handlers map[string]http.HandlerFunc) (*httptest.Server, *apiEngine) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/v1.41")
+		if handler, ok := handlers[key]; ok {
+			handler(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message": "page not found: %v"}`, key)
+	}))
+	docker, err := client.NewClientWithOpts(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithVersion("1.41"))
+	if err != nil {
+		panic(err)
+	}
+	return server, &apiEngine{client: docker}
+}
+
+// frame will return the data as a frame of a multiplexed stream.
+func frame(stream byte, data string) []byte {
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+// upgrade will take over the connection of a command which is attached
+// to, like the daemon, and write the output of the command to it.
+func upgrade(w http.ResponseWriter, output ...[]byte) net.Conn {
+	conn, buf, _ := w.(http.Hijacker).Hijack()
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	for _, data := range output {
+		buf.Write(data)
+	}
+	buf.Flush()
+	return conn
+}
+
+func TestAPIEngine(t *testing.T) {
+
+	Convey("Running docker commands with the Docker Engine API", t, func() {
+
+		ctx := context.Background()
+
+		Convey("The server version is found", func() {
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"GET /version": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"Version": "20.10.7"}`)
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, versionCommand{}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "20.10.7\n")
+		})
+
+		Convey("Running containers are listed by name", func() {
+			filters := ""
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"GET /containers/json": func(w http.ResponseWriter, r *http.Request) {
+					filters = r.URL.Query().Get("filters")
+					fmt.Fprint(w, `[{"Names": ["/test"]}, {"Names": ["/other"]}]`)
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, psCommand{Filters: []string{"status=running"}}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "test\nother\n")
+			So(filters, ShouldEqual, `{"status":{"running":true}}`)
+		})
+
+		Convey("Containers are created with the volumes and privileges", func() {
+			requests := []string{}
+			var body map[string]interface{}
+			created := false
+			name, pulled := "", ""
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"POST /containers/create": func(w http.ResponseWriter, r *http.Request) {
+					requests = append(requests, "create")
+					if !created {
+						w.WriteHeader(http.StatusNotFound)
+						fmt.Fprint(w, `{"message": "No such image: fubarhouse/docker-ansible:bionic"}`)
+						return
+					}
+					name = r.URL.Query().Get("name")
+					json.NewDecoder(r.Body).Decode(&body)
+					fmt.Fprint(w, `{"Id": "0123456789ab"}`)
+				},
+				"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+					requests = append(requests, "pull")
+					pulled = r.URL.Query().Get("fromImage") + ":" + r.URL.Query().Get("tag")
+					created = true
+					fmt.Fprint(w, `{"status": "Pulling from fubarhouse/docker-ansible"}`)
+				},
+				"POST /containers/0123456789ab/start": func(w http.ResponseWriter, r *http.Request) {
+					requests = append(requests, "start")
+					w.WriteHeader(http.StatusNoContent)
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, runContainer{
+				Name:       "test",
+				Labels:     []string{"ansible-role-tester=true"},
+				Volumes:    []string{"/sys/fs/cgroup:/sys/fs/cgroup:ro", "/home/user/web:/etc/ansible/roles/role_under_test"},
+				Publish:    []string{"0:80/tcp"},
+				Privileged: true,
+				Image:      "fubarhouse/docker-ansible:bionic",
+				Cmd:        []string{"/bin/systemd"},
+			}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "0123456789ab\n")
+			So(name, ShouldEqual, "test")
+			So(pulled, ShouldEqual, "fubarhouse/docker-ansible:bionic")
+			So(requests, ShouldResemble, []string{"create", "pull", "create", "start"})
+			So(body["Image"], ShouldEqual, "fubarhouse/docker-ansible:bionic")
+			So(body["Cmd"], ShouldResemble, []interface{}{"/bin/systemd"})
+			So(body["Labels"], ShouldResemble, map[string]interface{}{"ansible-role-tester": "true"})
+			hostConfig := body["HostConfig"].(map[string]interface{})
+			So(hostConfig["Binds"], ShouldResemble, []interface{}{"/sys/fs/cgroup:/sys/fs/cgroup:ro", "/home/user/web:/etc/ansible/roles/role_under_test"})
+			So(hostConfig["Privileged"], ShouldBeTrue)
+			So(hostConfig["PortBindings"], ShouldResemble, map[string]interface{}{"80/tcp": []interface{}{map[string]interface{}{"HostIp": "", "HostPort": "0"}}})
+		})
+
+		Convey("Flags of --docker-arg are given to the docker CLI", func() {
+			dir, _ := ioutil.TempDir("", "docker")
+			defer os.RemoveAll(dir)
+			script := filepath.Join(dir, "docker")
+			ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$@\"\n"), 0755)
+
+			path := docker
+			docker = script
+			defer func() { docker = path }()
+
+			requested := false
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"POST /containers/create": func(w http.ResponseWriter, r *http.Request) {
+					requested = true
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, runContainer{Name: "test", Extra: []string{"--cap-add=SYS_ADMIN", "--shm-size=64m"}, Image: "debian:12"}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "run --detach --name=test --cap-add=SYS_ADMIN --shm-size=64m debian:12\n")
+			So(requested, ShouldBeFalse)
+		})
+
+		Convey("Commands are run in the container", func() {
+			var body map[string]interface{}
+			exitCode := 0
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"POST /containers/test/exec": func(w http.ResponseWriter, r *http.Request) {
+					json.NewDecoder(r.Body).Decode(&body)
+					fmt.Fprint(w, `{"Id": "exec1"}`)
+				},
+				"POST /exec/exec1/start": func(w http.ResponseWriter, r *http.Request) {
+					upgrade(w, frame(1, "PLAY RECAP\n"), frame(2, "[WARNING]: ignored\n"), frame(1, "ok=1\n")).Close()
+				},
+				"GET /exec/exec1/json": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, `{"ExitCode": %v}`, exitCode)
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, execCommand{Container: "test", Env: []string{"ANSIBLE_FORCE_COLOR=1"}, Cmd: []string{"ansible-playbook", "site.yml"}}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "PLAY RECAP\nok=1\n")
+			So(body["Cmd"], ShouldResemble, []interface{}{"ansible-playbook", "site.yml"})
+			So(body["Env"], ShouldResemble, []interface{}{"ANSIBLE_FORCE_COLOR=1"})
+			So(body["Tty"], ShouldBeFalse)
+
+			exitCode = 2
+			So(api.Command(ctx, execCommand{Container: "test", Cmd: []string{"false"}}, false, &out).Error(), ShouldEqual, "exit status 2")
+		})
+
+		Convey("The processes of stopped commands are killed in the container", func() {
+			bodies := make(chan map[string]interface{}, 2)
+			execs := 0
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"POST /containers/test/exec": func(w http.ResponseWriter, r *http.Request) {
+					var body map[string]interface{}
+					json.NewDecoder(r.Body).Decode(&body)
+					bodies <- body
+					execs++
+					fmt.Fprintf(w, `{"Id": "exec%v"}`, execs)
+				},
+				"POST /exec/exec1/start": func(w http.ResponseWriter, r *http.Request) {
+					conn := upgrade(w)
+					defer conn.Close()
+					ioutil.ReadAll(conn)
+				},
+				"POST /exec/exec2/start": func(w http.ResponseWriter, r *http.Request) {
+					upgrade(w).Close()
+				},
+				"GET /exec/exec2/json": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"ExitCode": 0}`)
+				},
+			})
+			defer server.Close()
+
+			timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+			var out bytes.Buffer
+			So(api.Command(timeout, execCommand{Container: "test", Cmd: []string{"sleep", "600"}}, false, &out), ShouldEqual, ErrTimeout)
+
+			env := (<-bodies)["Env"].([]interface{})
+			So(env, ShouldHaveLength, 1)
+			marker := env[0].(string)
+			So(marker, ShouldStartWith, execMarker+"=")
+			reaper := (<-bodies)["Cmd"].([]interface{})
+			So(reaper[:2], ShouldResemble, []interface{}{"sh", "-c"})
+			So(reaper[2], ShouldContainSubstring, "grep -qx '"+marker+"'")
+			So(reaper[2], ShouldContainSubstring, "kill -9")
+		})
+
+		Convey("Paths are copied into containers as a tar stream", func() {
+			dir, _ := ioutil.TempDir("", "role")
+			defer os.RemoveAll(dir)
+			os.MkdirAll(filepath.Join(dir, "tasks"), 0755)
+			ioutil.WriteFile(filepath.Join(dir, "tasks", "main.yml"), []byte("---\n"), 0644)
+
+			destination := ""
+			files := map[string]string{}
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"PUT /containers/test/archive": func(w http.ResponseWriter, r *http.Request) {
+					destination = r.URL.Query().Get("path")
+					archive := tar.NewReader(r.Body)
+					for {
+						header, err := archive.Next()
+						if err != nil {
+							break
+						}
+						data, _ := ioutil.ReadAll(archive)
+						files[header.Name] = string(data)
+					}
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, cpCommand{Source: dir, Container: "test", Destination: "/etc/ansible/roles/role_under_test"}, false, &out), ShouldBeNil)
+			So(destination, ShouldEqual, "/")
+			So(files, ShouldResemble, map[string]string{
+				"etc/ansible/roles/role_under_test/":               "",
+				"etc/ansible/roles/role_under_test/tasks/":         "",
+				"etc/ansible/roles/role_under_test/tasks/main.yml": "---\n",
+			})
+		})
+
+		Convey("Images are pulled with their progress", func() {
+			query := ""
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+					query = r.URL.RawQuery
+					fmt.Fprintln(w, `{"status": "Pulling from fubarhouse/docker-ansible", "id": "bionic"}`)
+					fmt.Fprintln(w, `{"status": "Downloading", "progress": "[==>  ]", "id": "a1b2"}`)
+					fmt.Fprintln(w, `{"status": "Pull complete", "id": "a1b2"}`)
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, pullCommand{Image: "fubarhouse/docker-ansible:bionic"}, false, &out), ShouldBeNil)
+			So(query, ShouldEqual, "fromImage=fubarhouse%2Fdocker-ansible&tag=bionic")
+			So(out.String(), ShouldEqual, "bionic: Pulling from fubarhouse/docker-ansible\na1b2: Pull complete\n")
+		})
+
+		Convey("Labelled networks and images are listed for pruning", func() {
+			filters := []string{}
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"GET /networks": func(w http.ResponseWriter, r *http.Request) {
+					filters = append(filters, r.URL.Query().Get("filters"))
+					fmt.Fprint(w, `[{"Name": "scenario"}]`)
+				},
+				"GET /images/json": func(w http.ResponseWriter, r *http.Request) {
+					filters = append(filters, r.URL.Query().Get("filters"))
+					fmt.Fprint(w, `[{"Id": "sha256:0123456789abcdef"}]`)
+				},
+				"GET /networks/scenario": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"Name": "scenario", "Created": "2019-01-02T03:04:05.000000006Z"}`)
+				},
+			})
+			defer server.Close()
+
+			labels := []string{"label=ansible-role-tester=true"}
+			var out bytes.Buffer
+			So(api.Command(ctx, networkLsCommand{Filters: labels}, false, &out), ShouldBeNil)
+			So(api.Command(ctx, imagesCommand{Filters: labels}, false, &out), ShouldBeNil)
+			So(api.Command(ctx, inspectCommand{Kind: "network", Field: fieldCreated, Name: "scenario"}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "scenario\n0123456789ab\n\"2019-01-02T03:04:05.000000006Z\"\n")
+			So(filters, ShouldResemble, []string{`{"label":{"ansible-role-tester=true":true}}`, `{"label":{"ansible-role-tester=true":true}}`})
+		})
+
+		Convey("Published ports of containers are listed", func() {
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"GET /containers/test/json": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"NetworkSettings": {"Ports": {"80/tcp": [{"HostIp": "0.0.0.0", "HostPort": "32768"}], "443/tcp": null}}}`)
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, portCommand{Container: "test"}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "80/tcp -> 0.0.0.0:32768\n")
+		})
+
+		Convey("The logs of containers are written", func() {
+			tail := ""
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"GET /containers/test/logs": func(w http.ResponseWriter, r *http.Request) {
+					tail = r.URL.Query().Get("tail")
+					w.Write(frame(1, "Welcome to Ubuntu 18.04\n"))
+					w.Write(frame(2, "Failed to start nginx\n"))
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, logsCommand{Container: "test", Tail: 500}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "Welcome to Ubuntu 18.04\nFailed to start nginx\n")
+			So(tail, ShouldEqual, "500")
+		})
+
+		Convey("Errors of the daemon are returned", func() {
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{})
+			defer server.Close()
+
+			var out bytes.Buffer
+			err := api.Command(ctx, stopCommand{Container: "test"}, false, &out)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "page not found: POST /containers/test/stop")
+		})
+
+		Convey("Commands which time out are stopped", func() {
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"GET /version": func(w http.ResponseWriter, r *http.Request) {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(time.Second):
+					}
+				},
+			})
+			defer server.Close()
+
+			timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+			var out bytes.Buffer
+			So(api.Command(timeout, versionCommand{}, false, &out), ShouldEqual, ErrTimeout)
+		})
+
+		Convey("Containers and images are of the platform", func() {
+			queries := []string{}
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+					queries = append(queries, r.URL.Query().Get("platform"))
+				},
+				"POST /containers/create": func(w http.ResponseWriter, r *http.Request) {
+					queries = append(queries, r.URL.Query().Get("platform"))
+					fmt.Fprint(w, `{"Id": "0123456789ab"}`)
+				},
+				"POST /containers/0123456789ab/start": func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNoContent)
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, pullCommand{Image: "debian:12", Platform: "linux/arm64"}, false, &out), ShouldBeNil)
+			So(api.Command(ctx, runContainer{Name: "test", Platform: "linux/arm64", Image: "debian:12"}, false, &out), ShouldBeNil)
+			So(queries, ShouldResemble, []string{"linux/arm64", "linux/arm64"})
+		})
+
+		Convey("Interactive commands read the terminal", func() {
+			var body map[string]interface{}
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"POST /containers/test/exec": func(w http.ResponseWriter, r *http.Request) {
+					json.NewDecoder(r.Body).Decode(&body)
+					fmt.Fprint(w, `{"Id": "exec1"}`)
+				},
+				"POST /exec/exec1/start": func(w http.ResponseWriter, r *http.Request) {
+					upgrade(w, []byte("root@test:/etc/ansible/roles/role_under_test# ")).Close()
+				},
+				"GET /exec/exec1/json": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"ExitCode": 0}`)
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(ctx, execCommand{Container: "test", Cmd: []string{"bash"}, TTY: true, Interactive: true, Workdir: "/etc/ansible/roles/role_under_test"}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "root@test:/etc/ansible/roles/role_under_test# ")
+			So(body["AttachStdin"], ShouldBeTrue)
+			So(body["Tty"], ShouldBeTrue)
+			So(body["WorkingDir"], ShouldEqual, "/etc/ansible/roles/role_under_test")
+		})
+	})
+}
+
+// fakeEngine runs the docker commands without a Docker daemon, where
+// the commands are recorded, the containers which were run are listed
+// by ps, the labelled containers are listed by ps with a label filter,
+// every image is present unless it is missing, only the networks which
+// exist can be inspected and commands which are executed write their
+// outputs in turn, repeating the last one. Containers which are stopped
+// or killed are no longer running, unless they are stubborn when they
+// are stopped.
+type fakeEngine struct {
+	commands [][]string
+	running  []string
+	stubborn map[string]bool
+	labelled []string
+	missing  map[string]bool
+	networks map[string]bool
+	outputs  map[string][]string
+	failing  map[string]bool
+}
+
+// Command will record the docker command.
+func (fake *fakeEngine) Command(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error {
+	args := command.args()
+	fake.commands = append(fake.commands, args)
+	switch args[0] {
+	case "run":
+		fake.running = append(fake.running, strings.TrimPrefix(args[2], "--name="))
+	case "start":
+		fake.running = append(fake.running, args[1])
+	case "stop", "kill":
+		name := args[len(args)-1]
+		if args[0] == "stop" && fake.stubborn[name] {
+			break
+		}
+		for i, running := range fake.running {
+			if running == name {
+				fake.running = append(fake.running[:i], fake.running[i+1:]...)
+				break
+			}
+		}
+	case "exec":
+		command := strings.Join(args[2:], " ")
+		if outputs := fake.outputs[command]; len(outputs) > 0 {
+			fmt.Fprintln(out, outputs[0])
+			if len(outputs) > 1 {
+				fake.outputs[command] = outputs[1:]
+			}
+		}
+		if fake.failing[command] {
+			return fmt.Errorf("exit status 1")
+		}
+
+		// Images include Ansible unless the output says otherwise.
+		if _, ok := fake.outputs[command]; !ok && strings.HasSuffix(command, "ansible-playbook --version") {
+			fmt.Fprintln(out, "ansible-playbook [core 2.15.0]")
+		}
+	case "ps":
+		if strings.Contains(strings.Join(args, " "), "label=") {
+			fmt.Fprintln(out, strings.Join(fake.labelled, "\n"))
+		} else {
+			fmt.Fprintln(out, strings.Join(fake.running, "\n"))
+		}
+	case "pull":
+		delete(fake.missing, args[1])
+	case "build":
+		delete(fake.missing, strings.TrimPrefix(args[2], "--tag="))
+	case "inspect":
+		if image := args[len(args)-1]; args[2] == "image" && fake.missing[image] {
+			return fmt.Errorf("no such image: %v", image)
+		}
+	case "network":
+		name := args[len(args)-1]
+		switch args[1] {
+		case "inspect":
+			if !fake.networks[name] {
+				return fmt.Errorf("network %v not found", name)
+			}
+		case "create":
+			if fake.networks == nil {
+				fake.networks = map[string]bool{}
+			}
+			fake.networks[name] = true
+		case "rm":
+			delete(fake.networks, name)
+		}
+	}
+	return nil
+}
+
+func TestRemoteDaemon(t *testing.T) {
+
+	Convey("Detecting remote docker daemons", t, func() {
+
+		host := os.Getenv("DOCKER_HOST")
+		defer os.Setenv("DOCKER_HOST", host)
+
+		Convey("Local daemons are not remote", func() {
+			for _, address := range []string{"", "unix:///var/run/docker.sock", "tcp://127.0.0.1:2375", "tcp://localhost:2375"} {
+				os.Setenv("DOCKER_HOST", address)
+				So(RemoteDaemon(), ShouldBeFalse)
+			}
+		})
+
+		Convey("Daemons of other hosts are remote", func() {
+			for _, address := range []string{"tcp://builder:2375", "ssh://user@builder"} {
+				os.Setenv("DOCKER_HOST", address)
+				So(RemoteDaemon(), ShouldBeTrue)
+			}
+		})
+
+		Convey("The engine is chosen by the address", func() {
+			_, api := newEngine("unix:///tmp/docker.sock").(*apiEngine)
+			So(api, ShouldBeTrue)
+			So(newEngine("tcp://builder:2375").(*apiEngine).client.DaemonHost(), ShouldEqual, "tcp://builder:2375")
+			_, cli := newEngine("ssh://user@builder").(cliEngine)
+			So(cli, ShouldBeTrue)
+		})
+
+		Convey("The role is copied into containers of remote daemons", func() {
+			os.Setenv("DOCKER_HOST", "tcp://builder:2375")
+
+			fake := &fakeEngine{}
+			previous := engine
+			engine = fake
+			defer func() { engine = previous }()
+
+			dist := Distribution{CID: "test", Container: "fubarhouse/docker-ansible:bionic", Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", Quiet: true}
+			report := AnsibleReport{}
+
+			So(dist.DockerRun(&config, &report), ShouldBeTrue)
+			So(report.Docker.Volumes, ShouldResemble, []string{"/sys/fs/cgroup:/sys/fs/cgroup:ro"})
+			So(fake.commands[2], ShouldResemble, []string{"run", "--detach", "--name=test", "--label=ansible-role-tester=true", "--label=art.role=web", "--label=art.distribution=", "--label=art.run-id=" + RunID, "--volume=/sys/fs/cgroup:/sys/fs/cgroup:ro", "fubarhouse/docker-ansible:bionic", "/bin/systemd"})
+			So(fake.commands[3:7], ShouldResemble, [][]string{
+				{"exec", "test", "mkdir", "-p", "/etc/ansible/roles"},
+				{"cp", "/home/user/web", "test:/etc/ansible/roles/role_under_test"},
+				{"exec", "test", "mkdir", "-p", "/etc/ansible/roles"},
+				{"cp", "/home/user/web", "test:/etc/ansible/roles/web"},
+			})
+		})
+
+		Convey("The role is mounted into containers of local daemons", func() {
+			os.Setenv("DOCKER_HOST", "")
+
+			fake := &fakeEngine{}
+			previous := engine
+			engine = fake
+			defer func() { engine = previous }()
+
+			dist := Distribution{CID: "test", Container: "fubarhouse/docker-ansible:bionic", Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", Quiet: true}
+			report := AnsibleReport{}
+
+			So(dist.DockerRun(&config, &report), ShouldBeTrue)
+			So(report.Docker.Copies, ShouldBeEmpty)
+			So(fake.commands[2], ShouldContain, "--volume=/home/user/web:/etc/ansible/roles/role_under_test")
+			So(len(fake.commands), ShouldEqual, 5)
+			So(fake.commands[3], ShouldResemble, []string{"exec", "--tty", "test", "ansible-playbook", "--version"})
+		})
+	})
+}
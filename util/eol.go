@@ -0,0 +1,58 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// eolMirrors are the commands which point the package manager of the
+// releases of a family which are past their end of life to the archive
+// of their repositories, by the name of the family. Ubuntu releases are
+// left out as they stay on archive.ubuntu.com for some time.
+var eolMirrors = map[string]string{
+	"CentOS": `sed -i -e 's/^mirrorlist=/#mirrorlist=/' -e 's|^#\?baseurl=http://mirror.centos.org|baseurl=http://vault.centos.org|' /etc/yum.repos.d/CentOS-*.repo`,
+	"Debian": `sed -i -e '/-updates/d' -e 's|[a-z]*.debian.org/debian-security|archive.debian.org/debian-security|' -e 's|security.debian.org|archive.debian.org/debian-security|' -e 's|[a-z]*.debian.org/debian |archive.debian.org/debian |' /etc/apt/sources.list && echo 'Acquire::Check-Valid-Until "false";' > /etc/apt/apt.conf.d/99archive`,
+}
+
+// eolMirrorCommand will return the command which points the package
+// manager to the archive of the repositories of the distribution, or an
+// empty string when it isn't past its end of life or has no archive.
+func (dist *Distribution) eolMirrorCommand() string {
+	if !dist.Deprecated() {
+		return ""
+	}
+	return eolMirrors[dist.Family.Name]
+}
+
+// warnDeprecated will warn that the distribution is past its end of
+// life, which is still tested, as its package repositories may be gone.
+func (dist *Distribution) warnDeprecated(config *AnsibleConfig) {
+	if !dist.Deprecated() || config.Quiet {
+		return
+	}
+	banner := strings.Repeat("*", 72)
+	log.Warnln(banner)
+	log.Warnf("%v reached its end of life on %v and is deprecated", dist.Distro, dist.EOL.Format("2006-01-02"))
+	log.Warnln("Its package repositories may no longer exist, so installing packages can fail.")
+	if dist.eolMirrorCommand() != "" && !config.EOLMirrors {
+		log.Warnln("Use --eol-mirrors to install packages from the archive of its repositories.")
+	}
+	log.Warnln(banner)
+}
+
+// CheckEOL will return an error naming the distributions which are past
+// their end of life, for runs which don't test them.
+func CheckEOL(distributions []Distribution) error {
+	deprecated := []string{}
+	for _, dist := range distributions {
+		if dist.Deprecated() {
+			deprecated = append(deprecated, fmt.Sprintf("%v (%v)", dist.Distro, dist.EOL.Format("2006-01-02")))
+		}
+	}
+	if len(deprecated) > 0 {
+		return fmt.Errorf("distributions past their end of life can't be tested with --no-eol: %v", strings.Join(deprecated, ", "))
+	}
+	return nil
+}
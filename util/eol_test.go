@@ -0,0 +1,62 @@
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEOL(t *testing.T) {
+
+	Convey("Testing distributions past their end of life", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Runs with --no-eol fail on deprecated distributions", func() {
+			So(CheckEOL([]Distribution{JeffDebian12, JeffUbuntu2404}), ShouldBeNil)
+
+			err := CheckEOL([]Distribution{JeffDebian12, CentOS7, JeffDebian8})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "distributions past their end of life can't be tested with --no-eol: centos7 (2024-06-30), debian8 (2020-06-30)")
+		})
+
+		Convey("The repositories of CentOS and Debian are archived", func() {
+			So(CentOS7.eolMirrorCommand(), ShouldContainSubstring, "vault.centos.org")
+			So(JeffDebian8.eolMirrorCommand(), ShouldContainSubstring, "archive.debian.org")
+			So(JeffUbuntu1604.eolMirrorCommand(), ShouldEqual, "")
+			So(JeffDebian12.eolMirrorCommand(), ShouldEqual, "")
+		})
+
+		Convey("The mirrors are changed before the bootstrap with --eol-mirrors", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := CentOS7
+			dist.CID = "test"
+			So(dist.DockerBootstrap(&AnsibleConfig{Quiet: true, EOLMirrors: true}), ShouldBeNil)
+			So(fake.commands, ShouldHaveLength, 2)
+			So(fake.commands[0][4], ShouldEqual, "exec 2>&1; "+eolMirrors["CentOS"])
+
+			fake.commands = nil
+			So(dist.DockerBootstrap(&AnsibleConfig{Quiet: true}), ShouldBeNil)
+			So(fake.commands, ShouldHaveLength, 1)
+		})
+
+		Convey("The warning suggests the archived mirrors", func() {
+			var out bytes.Buffer
+			log.SetOutput(&out)
+			defer log.SetOutput(ioutil.Discard)
+
+			CentOS7.warnDeprecated(&AnsibleConfig{})
+			So(out.String(), ShouldContainSubstring, "centos7 reached its end of life on 2024-06-30 and is deprecated")
+			So(out.String(), ShouldContainSubstring, "--eol-mirrors")
+
+			out.Reset()
+			CentOS7.warnDeprecated(&AnsibleConfig{EOLMirrors: true})
+			So(out.String(), ShouldNotContainSubstring, "--eol-mirrors")
+		})
+	})
+}
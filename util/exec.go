@@ -0,0 +1,83 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+var (
+
+	// ErrTimeout is returned when a command was killed because its
+	// stage did not complete within the configured timeout.
+	ErrTimeout = errors.New("the command did not complete within the timeout")
+
+	// interruptGrace is how long a command in the foreground is given
+	// to exit once the interrupt was forwarded to it.
+	interruptGrace = 5 * time.Second
+)
+
+// foreground will return true when the command reads the terminal, ie
+// with --step or the pause module, which it can only do while it is in
+// the foreground process group of the terminal.
+func foreground(cmd *exec.Cmd) bool {
+	file, ok := cmd.Stdin.(*os.File)
+	return ok && Terminal(file)
+}
+
+// runCommand will run the command and wait for it to complete. When the
+// context can be done, the command is started in its own process group
+// so the whole group (ie docker exec and its children) can be killed
+// when the timeout of the stage is reached or the run is interrupted, in which case
+// ErrTimeout or ErrInterrupted is returned. Commands which read the
+// terminal stay in the foreground process group instead, and the
+// interrupt is forwarded to them.
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+
+	if ctx.Done() == nil {
+		return cmd.Run()
+	}
+
+	interactive := foreground(cmd)
+	if !interactive {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// A negative pid signals every process in the group.
+	signal := func(sig syscall.Signal) {
+		if interactive {
+			cmd.Process.Signal(sig)
+		} else {
+			syscall.Kill(-cmd.Process.Pid, sig)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	if interactive && ctx.Err() == context.Canceled {
+		signal(syscall.SIGINT)
+		select {
+		case <-done:
+			return ErrInterrupted
+		case <-time.After(interruptGrace):
+		}
+	}
+	signal(syscall.SIGKILL)
+	<-done
+	return contextError(ctx, ctx.Err())
+}
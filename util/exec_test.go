@@ -0,0 +1,81 @@
+package util
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// deadlineEngine records whether each docker command had a deadline.
+type deadlineEngine struct {
+	deadlines []bool
+}
+
+// Command will record the deadline of the docker command.
+func (engine *deadlineEngine) Command(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error {
+	_, ok := ctx.Deadline()
+	engine.deadlines = append(engine.deadlines, ok)
+	return nil
+}
+
+func TestStageTimeout(t *testing.T) {
+
+	Convey("Running the commands of a stage with a timeout", t, func() {
+		config := AnsibleConfig{Timeout: 200 * time.Millisecond, Quiet: true}
+		report := AnsibleReport{}
+		sh := func(script string) error {
+			_, err := config.output().ansibleCommand(config.stageContext(), "/bin/sh", []string{"-c", script}, nil, false)
+			return err
+		}
+
+		Convey("Commands which complete in time succeed", func() {
+			So(report.RunStages(&config, []Stage{{Name: "converge", Run: func() bool { return sh("true") == nil }}}), ShouldBeTrue)
+		})
+
+		Convey("The process group is killed on timeout", func() {
+			var err error
+			now := time.Now()
+			report.RunStages(&config, []Stage{{Name: "converge", Run: func() bool { err = sh("sleep 10 & wait"); return err == nil }}})
+			So(err, ShouldEqual, ErrTimeout)
+			So(time.Since(now), ShouldBeLessThan, 5*time.Second)
+		})
+
+		Convey("The commands of a stage share its timeout", func() {
+			errs := []error{}
+			report.RunStages(&config, []Stage{{Name: "converge", Run: func() bool {
+				errs = append(errs, sh("sleep 0.15"), sh("sleep 0.15"))
+				return false
+			}}})
+			So(errs, ShouldResemble, []error{nil, ErrTimeout})
+		})
+
+		Convey("Each stage has a timeout of its own", func() {
+			errs := []error{}
+			run := func() bool {
+				errs = append(errs, sh("sleep 0.15"))
+				return true
+			}
+			So(report.RunStages(&config, []Stage{{Name: "converge", Run: run}, {Name: "idempotence", Run: run}}), ShouldBeTrue)
+			So(errs, ShouldResemble, []error{nil, nil})
+		})
+
+		Convey("Only the docker commands of the stage have the timeout", func() {
+			fake := &deadlineEngine{}
+			previous := engine
+			engine = fake
+			defer func() { engine = previous }()
+
+			dist := Distribution{CID: "test"}
+			report.RunStages(&config, []Stage{{Name: "converge", Run: func() bool {
+				config.dockerExec(dist.containerExec("true"), false)
+				dockerExec(psCommand{}, false)
+				return true
+			}}})
+			config.dockerExec(dist.containerExec("true"), false)
+			So(fake.deadlines, ShouldResemble, []bool{true, false, false})
+		})
+	})
+}
@@ -1,11 +1,121 @@
 package util
 
-// This file contains a full reference of all exit codes.
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// This file contains a full reference of all exit codes, which is
+// the contract with callers of the commands. Each failure exits with
+// the code of the first stage which failed.
 var (
 	OKCode                 = 0
-	DockerRunCode          = 2
-	AnsibleSyntaxCode      = 10
-	AnsibleRunCode         = 11
-	AnsibleIdempotenceCode = 12
-	NotARoleCode           = 20
+	AnsibleSyntaxCode      = 2
+	AnsibleRunCode         = 3
+	AnsibleIdempotenceCode = 4
+	AnsibleVerifyCode      = 5
+	DockerRunCode          = 6
+	ConfigCode             = 7
+	DurationBudgetCode     = 8
+	AnsibleTimeoutCode     = 124
+	InterruptedCode        = 130
+
+	// Building the image is part of the container setup.
+	DockerBuildCode = DockerRunCode
+
+	// The requirements, prepare and check mode stages are part of
+	// the converge.
+	AnsibleRequirementsCode = AnsibleRunCode
+	AnsiblePrepareCode      = AnsibleRunCode
+	AnsibleCheckCode        = AnsibleRunCode
+
+	// An unsupported Ansible version or a path which is not a
+	// role are both configuration errors.
+	AnsibleVersionCode = ConfigCode
+	NotARoleCode       = ConfigCode
 )
+
+// ExitCodeHelp documents the exit codes in the help of the commands.
+var ExitCodeHelp = fmt.Sprintf(`
+Exit codes:
+  %v	success
+  %v	syntax check failed
+  %v	converge failed, including the requirements, the prepare playbook and check mode
+  %v	idempotence test failed
+  %v	verify playbook failed
+  %v	container or docker setup failed
+  %v	configuration error
+  %v	a stage or the whole run exceeded its duration budget
+  %v	a stage did not complete within the timeout
+  %v	the run was interrupted, ie by Ctrl-C
+`, OKCode, AnsibleSyntaxCode, AnsibleRunCode, AnsibleIdempotenceCode, AnsibleVerifyCode, DockerRunCode, ConfigCode, DurationBudgetCode, AnsibleTimeoutCode, InterruptedCode)
+
+var (
+
+	// exitLock guards exitHooks.
+	exitLock sync.Mutex
+
+	// exitHooks are run before the tool exits with Exit.
+	exitHooks []func()
+)
+
+// AtExit will run the hook before the tool exits with Exit, which
+// includes configuration errors and a second interrupt, ie to remove
+// temporary files which deferred functions would miss.
+func AtExit(hook func()) {
+	exitLock.Lock()
+	defer exitLock.Unlock()
+	exitHooks = append(exitHooks, hook)
+}
+
+// Exit will run the hooks of AtExit, the last one first, and exit
+// with the exit code.
+func Exit(code int) {
+	exitLock.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitLock.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+	os.Exit(code)
+}
+
+// ConfigError will log the error and exit with the exit code of
+// a configuration error, such as an invalid flag or missing file.
+func ConfigError(format string, args ...interface{}) {
+	log.Errorf(format, args...)
+	Exit(ConfigCode)
+}
+
+// ExitCode will return the exit code of the first stage which failed,
+// where skipped stages are not failures.
+func (report *AnsibleReport) ExitCode() int {
+	switch {
+	case report.Ansible.Interrupted:
+		return InterruptedCode
+	case report.Ansible.Timeout.Stage != "":
+		return AnsibleTimeoutCode
+	case report.Ansible.Requirements.Enabled && !report.Passed("requirements", report.Ansible.Requirements.Result):
+		return AnsibleRequirementsCode
+	case !report.Passed("syntax", report.Ansible.Syntax):
+		return AnsibleSyntaxCode
+	case !report.Prepared():
+		return AnsiblePrepareCode
+	case report.Ansible.Check.Enabled && !report.Passed("check", report.Ansible.Check.Result):
+		return AnsibleCheckCode
+	case !report.Passed("converge", report.Ansible.Run.Result):
+		return AnsibleRunCode
+	case !report.Passed("idempotence", report.Ansible.Idempotence.Result):
+		return AnsibleIdempotenceCode
+	case report.Ansible.Verify.Enabled && !report.Ansible.Verify.Result:
+		return AnsibleVerifyCode
+	case len(report.Ansible.BudgetExceeded) > 0:
+		return DurationBudgetCode
+	}
+	return OKCode
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExitCode(t *testing.T) {
+
+	Convey("The exit code of a report", t, func() {
+
+		Convey("Passing stages exit with success", func() {
+			report := AnsibleReport{}
+			report.Ansible.Syntax = true
+			report.Ansible.Run.Result = true
+			report.Ansible.Idempotence.Result = true
+			So(report.ExitCode(), ShouldEqual, OKCode)
+		})
+
+		Convey("The first failed stage is the exit code", func() {
+			report := AnsibleReport{}
+			report.Ansible.Syntax = true
+			So(report.ExitCode(), ShouldEqual, AnsibleRunCode)
+		})
+
+		Convey("Skipped stages are not failures", func() {
+			report := AnsibleReport{}
+			report.Ansible.Skipped = []string{"syntax", "converge"}
+			So(report.ExitCode(), ShouldEqual, AnsibleIdempotenceCode)
+		})
+
+		Convey("Timeouts take precedence over the failed stage", func() {
+			report := AnsibleReport{}
+			report.Ansible.Syntax = true
+			report.timedOut("run", "")
+			So(report.ExitCode(), ShouldEqual, AnsibleTimeoutCode)
+		})
+	})
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FactCache will prepare a jsonfile fact cache and gather the facts of
+// the role hosts into it once, so the role and idempotence runs don't
+// have to gather facts again. The time taken to gather the facts is
+// recorded in the report. The returned function removes the cache and
+// should be called when the tests are complete.
+func (dist *Distribution) FactCache(config *AnsibleConfig, report *AnsibleReport) func() {
+
+	if !config.FactCache {
+		return func() {}
+	}
+
+	cleanup := func() {}
+	if config.Remote {
+		// Ansible runs on the host, so the cache must be on the host.
+		dir, err := ioutil.TempDir("", "ansible-role-tester-facts")
+		if err != nil {
+			log.Errorln(err)
+			return cleanup
+		}
+		config.FactCachePath = dir
+		cleanup = func() {
+			os.RemoveAll(dir)
+			config.FactCachePath = ""
+		}
+	} else {
+		config.FactCachePath = factCachePath
+		cleanup = func() {
+			if dist.DockerCheck() {
+				config.dockerExec(dist.containerExec("rm", "-rf", factCachePath), false)
+			}
+			config.FactCachePath = ""
+		}
+	}
+	report.Ansible.FactCache.Enabled = true
+
+	if !config.Quiet {
+		log.Infoln("Gathering facts...")
+	}
+
+	pattern := "all"
+	if len(report.Ansible.Hosts) > 0 {
+		pattern = strings.Join(report.Ansible.Hosts, ",")
+	}
+
+	var err error
+	now := time.Now()
+	if config.Remote {
+		inventory, cleanupInventory := dist.buildInventoryArgs(config)
+		defer cleanupInventory()
+
+		args := append([]string{pattern, "-c", connectionPlugin(config)}, inventory...)
+		args = append(args, factCacheArgs(config)...)
+		err = ansibleAdHoc(args, buildAnsibleEnv(config))
+	} else {
+		exec := buildExecCommand(dist, config, "ansible", pattern)
+		if config.Inventory != "" {
+			exec.Cmd = append(exec.Cmd, fmt.Sprintf("-i=%v", config.Inventory))
+		}
+		if config.Inventory == inventoryPath {
+			exec.Cmd = append(exec.Cmd, "--connection=local")
+		}
+		exec.Cmd = append(exec.Cmd, factCacheArgs(config)...)
+		_, err = config.dockerExec(exec, false)
+	}
+
+	// The role runs will gather any facts which are missing instead.
+	if err != nil {
+		if !config.Quiet {
+			log.Warnf("facts could not be cached: %v", err)
+		}
+		return cleanup
+	}
+
+	report.Ansible.FactCache.Time = time.Since(now)
+	if !config.Quiet {
+		log.Infof("Facts were gathered in %v", report.Ansible.FactCache.Time)
+	}
+
+	return cleanup
+}
+
+// factCacheArgs returns the arguments to gather facts with the setup module.
+func factCacheArgs(config *AnsibleConfig) []string {
+	args := []string{"-m", "setup"}
+	if config.Limit != "" {
+		args = append(args, "--limit", config.Limit)
+	}
+	return args
+}
+
+// factCacheReused will record a playbook run which reused the cached
+// facts. Gathering the facts into the cache costs the same as a single
+// run gathering them, so every reuse after the first saves that time.
+func (report *AnsibleReport) factCacheReused(config *AnsibleConfig) {
+	if config.FactCachePath == "" || report.Ansible.FactCache.Time == 0 {
+		return
+	}
+	report.Ansible.FactCache.Reused++
+	if report.Ansible.FactCache.Reused > 1 {
+		report.Ansible.FactCache.Saved += report.Ansible.FactCache.Time
+	}
+}
+
+// ansibleAdHoc will execute an ad-hoc command with the ansible binary
+// on the host machine, which is found next to ansible-playbook.
+func ansibleAdHoc(args []string, env map[string]string) error {
+
+	bin, err := ansibleTool("ansible")
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(bin, args...)
+	if len(env) > 0 {
+		cmd.Env = mergeEnv(os.Environ(), env)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %v", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeFixture will write the file of a test, ie meta/main.yml, below
+// the directory, creating its parents, and will return its path. The
+// leading newline of the content is left out.
+func writeFixture(dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	os.MkdirAll(filepath.Dir(file), 0755)
+	ioutil.WriteFile(file, []byte(strings.TrimPrefix(content, "\n")), 0644)
+	return file
+}
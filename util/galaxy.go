@@ -0,0 +1,135 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// GalaxyServer is a Galaxy server of the distributions file. Its token
+// is read from the variable named by token_env, so the file never holds
+// it, or is the token of the Galaxy servers when it has none.
+type GalaxyServer struct {
+	URL      string `yaml:"url"`
+	TokenEnv string `yaml:"token_env"`
+}
+
+// galaxyServers are the Galaxy servers of the distributions file, which
+// are used when none are given.
+var galaxyServers = []GalaxyServer{}
+
+// ReadGalaxyServers will read the Galaxy servers of the distributions
+// file, which are tried in order.
+func ReadGalaxyServers(file string) ([]GalaxyServer, error) {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	document := struct {
+		GalaxyServers []GalaxyServer `yaml:"galaxy_servers"`
+	}{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", file, err)
+	}
+
+	for i, server := range document.GalaxyServers {
+		if server.URL == "" {
+			return nil, fmt.Errorf("%v: Galaxy server %v has no url", file, i+1)
+		}
+	}
+	return document.GalaxyServers, nil
+}
+
+// SetGalaxyServers will set the Galaxy servers which are used when
+// none are given.
+func SetGalaxyServers(servers []GalaxyServer) {
+	galaxyServers = servers
+}
+
+// GalaxyServers will return the URLs of the Galaxy servers, which are
+// those given or otherwise those of the distributions file, and the
+// tokens of the servers which have a token of their own.
+func GalaxyServers(servers []string) ([]string, map[string]string) {
+
+	tokens := map[string]string{}
+	if len(servers) > 0 {
+		return servers, tokens
+	}
+
+	urls := []string{}
+	for _, server := range galaxyServers {
+		urls = append(urls, server.URL)
+		if server.TokenEnv != "" {
+			tokens[server.URL] = os.Getenv(server.TokenEnv)
+		}
+	}
+	return urls, tokens
+}
+
+// galaxyServerEnv will return the variables which configure the Galaxy
+// servers the requirements are installed from, which are tried in
+// order. The token is used for every server without a token of its own.
+func (config *AnsibleConfig) galaxyServerEnv() map[string]string {
+
+	env := map[string]string{}
+	if len(config.GalaxyServers) == 0 {
+		return env
+	}
+
+	// Roles are installed from the first server.
+	env["ANSIBLE_GALAXY_SERVER"] = config.GalaxyServers[0]
+
+	names := []string{}
+	for i, server := range config.GalaxyServers {
+		name := fmt.Sprintf("server%v", i+1)
+		names = append(names, name)
+		key := "ANSIBLE_GALAXY_SERVER_" + strings.ToUpper(name)
+		env[key+"_URL"] = server
+		token, ok := config.GalaxyServerTokens[server]
+		if !ok {
+			token = config.GalaxyToken
+		}
+		if token != "" {
+			env[key+"_TOKEN"] = token
+		}
+	}
+	env["ANSIBLE_GALAXY_SERVER_LIST"] = strings.Join(names, ",")
+
+	return env
+}
+
+// galaxyTokenEnv is the variable the token of the Galaxy servers is
+// read from when it isn't given.
+const galaxyTokenEnv = "ANSIBLE_GALAXY_TOKEN"
+
+// GalaxyToken will return the token of the Galaxy servers, which is
+// read from ANSIBLE_GALAXY_TOKEN when it isn't given.
+func GalaxyToken(token string) string {
+	if token != "" {
+		return token
+	}
+	return os.Getenv(galaxyTokenEnv)
+}
+
+// galaxySecret will identify if the variable is the token of a Galaxy
+// server, which is never put on the command line.
+func galaxySecret(key string) bool {
+	return strings.HasPrefix(key, "ANSIBLE_GALAXY_SERVER_") && strings.HasSuffix(key, "_TOKEN")
+}
+
+// galaxySecrets will return the tokens of the Galaxy servers, which
+// docker exec is only given the names of.
+func (config *AnsibleConfig) galaxySecrets() map[string]string {
+	secrets := map[string]string{}
+	for key, value := range config.galaxyServerEnv() {
+		if galaxySecret(key) {
+			secrets[key] = value
+		}
+	}
+	return secrets
+}
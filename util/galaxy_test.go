@@ -0,0 +1,116 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGalaxyServers(t *testing.T) {
+
+	Convey("Installing the requirements from private Galaxy servers", t, func() {
+
+		token := "0123456789abcdef"
+		config := AnsibleConfig{
+			GalaxyServers: []string{"https://hub.example.com/api/galaxy/", "https://galaxy.ansible.com/"},
+			GalaxyToken:   token,
+		}
+
+		Convey("The servers are tried in order", func() {
+			env := buildAnsibleEnv(&config)
+			So(env["ANSIBLE_GALAXY_SERVER_LIST"], ShouldEqual, "server1,server2")
+			So(env["ANSIBLE_GALAXY_SERVER"], ShouldEqual, "https://hub.example.com/api/galaxy/")
+			So(env["ANSIBLE_GALAXY_SERVER_SERVER2_URL"], ShouldEqual, "https://galaxy.ansible.com/")
+			So(env["ANSIBLE_GALAXY_SERVER_SERVER1_TOKEN"], ShouldEqual, token)
+
+			So(buildAnsibleEnv(&AnsibleConfig{GalaxyToken: token}), ShouldBeEmpty)
+		})
+
+		Convey("The token is never on the command line", func() {
+			dist := Ubuntu1804
+			dist.CID = "test"
+			args := buildExecCommand(&dist, &config).args()
+			So(args, ShouldContain, "ANSIBLE_GALAXY_SERVER_SERVER1_TOKEN")
+			So(args, ShouldContain, "ANSIBLE_GALAXY_SERVER_SERVER1_URL=https://hub.example.com/api/galaxy/")
+			So(strings.Join(args, " "), ShouldNotContainSubstring, token)
+			So(os.Getenv("ANSIBLE_GALAXY_SERVER_SERVER1_TOKEN"), ShouldEqual, "")
+			So(config.galaxySecrets(), ShouldResemble, map[string]string{
+				"ANSIBLE_GALAXY_SERVER_SERVER1_TOKEN": token,
+				"ANSIBLE_GALAXY_SERVER_SERVER2_TOKEN": token,
+			})
+		})
+
+		Convey("The token is only in the environment of docker", func() {
+			dir, _ := ioutil.TempDir("", "galaxy")
+			defer os.RemoveAll(dir)
+			script := filepath.Join(dir, "docker")
+			ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$ANSIBLE_GALAXY_SERVER_SERVER1_TOKEN\"\n"), 0755)
+
+			path := docker
+			docker = script
+			defer func() { docker = path }()
+
+			var out bytes.Buffer
+			So(cliEngine{}.Command(context.Background(), execCommand{Container: "test", Env: []string{"ANSIBLE_GALAXY_SERVER_SERVER1_TOKEN"}, secrets: config.galaxySecrets()}, false, &out), ShouldBeNil)
+			So(out.String(), ShouldEqual, token+"\n")
+		})
+
+		Convey("The token is left out of the reports", func() {
+			data, err := json.Marshal(config)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldNotContainSubstring, token)
+		})
+
+		Convey("The servers are read from the distributions file", func() {
+			dir, _ := ioutil.TempDir("", "galaxy")
+			defer os.RemoveAll(dir)
+			file := writeFixture(dir, DistributionsFile, `
+galaxy_servers:
+  - url: https://hub.example.com/api/galaxy/
+    token_env: HUB_TOKEN
+  - url: https://galaxy.ansible.com/
+`)
+			os.Setenv("HUB_TOKEN", "hub")
+			defer os.Unsetenv("HUB_TOKEN")
+			defer SetGalaxyServers(nil)
+
+			servers, err := ReadGalaxyServers(file)
+			So(err, ShouldBeNil)
+			SetGalaxyServers(servers)
+
+			urls, tokens := GalaxyServers(nil)
+			So(urls, ShouldResemble, []string{"https://hub.example.com/api/galaxy/", "https://galaxy.ansible.com/"})
+			So(tokens, ShouldResemble, map[string]string{"https://hub.example.com/api/galaxy/": "hub"})
+
+			// Only the servers without a token of their own use the token.
+			env := buildAnsibleEnv(&AnsibleConfig{GalaxyServers: urls, GalaxyServerTokens: tokens, GalaxyToken: token})
+			So(env["ANSIBLE_GALAXY_SERVER_SERVER1_TOKEN"], ShouldEqual, "hub")
+			So(env["ANSIBLE_GALAXY_SERVER_SERVER2_TOKEN"], ShouldEqual, token)
+
+			// The servers which are given replace them.
+			urls, _ = GalaxyServers([]string{"https://other.example.com/"})
+			So(urls, ShouldResemble, []string{"https://other.example.com/"})
+
+			writeFixture(dir, DistributionsFile, `
+galaxy_servers:
+  - token_env: HUB_TOKEN
+`)
+			_, err = ReadGalaxyServers(file)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("The token is read from the environment", func() {
+			os.Setenv(galaxyTokenEnv, token)
+			defer os.Unsetenv(galaxyTokenEnv)
+			So(GalaxyToken(""), ShouldEqual, token)
+			So(GalaxyToken("other"), ShouldEqual, "other")
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package util
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RolePrepare will run the prepare playbook against the container
+// before the role is run, which is used to create test fixtures
+// such as users, packages or services the role expects.
+func (dist *Distribution) RolePrepare(config *AnsibleConfig) (bool, time.Duration) {
+
+	if !config.Quiet {
+		log.Infoln("Preparing the container...")
+	}
+
+	now := time.Now()
+	if !dist.hookPlaybook(config, config.PreparePlaybook) {
+		log.Errorln("Prepare: FAIL")
+		return false, time.Since(now)
+	}
+
+	if !config.Quiet {
+		log.Infof("Container was prepared in %v", time.Since(now))
+	}
+	return true, time.Since(now)
+}
+
+// RoleCleanup will run the cleanup playbook against the container
+// after the idempotence test. A failed cleanup does not fail the
+// tests, so it is only reported as a warning.
+func (dist *Distribution) RoleCleanup(config *AnsibleConfig) (bool, time.Duration) {
+
+	if !config.Quiet {
+		log.Infoln("Cleaning up the container...")
+	}
+
+	now := time.Now()
+	if !dist.hookPlaybook(config, config.CleanupPlaybook) {
+		log.Warnln("Cleanup: FAIL")
+		return false, time.Since(now)
+	}
+
+	if !config.Quiet {
+		log.Infof("Container was cleaned up in %v", time.Since(now))
+	}
+	return true, time.Since(now)
+}
+
+// hookPlaybook will run a playbook against the container, from inside
+// of the container or from the host when the run is remote.
+func (dist *Distribution) hookPlaybook(config *AnsibleConfig, playbook string) bool {
+
+	var args []string
+	if config.Remote {
+		// Build the inventory, which resolves all hosts to the container.
+		inventory, cleanup := dist.buildInventoryArgs(config)
+		defer cleanup()
+
+		args = []string{
+			fmt.Sprintf("%v/%v", config.RemotePath, playbook),
+			"-c",
+			connectionPlugin(config),
+		}
+		args = append(args, inventory...)
+	} else {
+		args = []string{
+			"ansible-playbook",
+			fmt.Sprintf("%v/%v", config.RemotePath, playbook),
+		}
+
+		// Add inventory file if configured
+		if config.Inventory != "" {
+			args = append(args, fmt.Sprintf("-i=%v", config.Inventory))
+		}
+
+		// Hosts in the mounted inventory file all refer to this container.
+		if config.Inventory == inventoryPath {
+			args = append(args, "--connection=local")
+		}
+	}
+
+	// Add the arguments common to every playbook run.
+	args = append(args, buildAnsibleArgs(config)...)
+
+	// Add verbose if configured
+	if config.Verbose {
+		args = append(args, "-vvvv")
+	}
+
+	var err error
+	if config.Remote {
+		_, err = config.ansiblePlaybook(args, buildAnsibleEnv(config), !config.Quiet)
+	} else {
+		_, err = config.dockerExec(buildExecCommand(dist, config, args...), !config.Quiet)
+	}
+	if err != nil {
+		log.Errorln(err)
+		return false
+	}
+
+	return true
+}
@@ -2,10 +2,8 @@ package util
 
 import (
 	"fmt"
+	"sort"
 	"strings"
-
-	"errors"
-	"strconv"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -13,39 +11,88 @@ import (
 
 // IdempotenceTest will run an Ansible playbook once and check the
 // output for any changed or failed tasks as reported by Ansible.
-func (dist *Distribution) IdempotenceTest(config *AnsibleConfig) (bool, time.Duration) {
+func (dist *Distribution) IdempotenceTest(config *AnsibleConfig, report *AnsibleReport) (bool, time.Duration) {
 
 	// Test role idempotence.
 	if !config.Quiet {
 		log.Infoln("Testing role idempotence...")
 	}
 
-	args := []string{
-		"exec",
-		"--tty",
-		dist.CID,
-		"ansible-playbook",
-		fmt.Sprintf("%v/%v", config.RemotePath, config.PlaybookFile),
+	exec := buildExecCommand(dist, config, "ansible-playbook")
+
+	// The playbooks are run together, so the recap covers all of them.
+	for _, playbook := range config.idempotencePlaybooks() {
+		exec.Cmd = append(exec.Cmd, fmt.Sprintf("%v/%v", config.RemotePath, playbook))
 	}
 
 	// Add inventory file if configured
 	if config.Inventory != "" {
-		args = append(args, fmt.Sprintf("-i=%v", config.Inventory))
+		exec.Cmd = append(exec.Cmd, fmt.Sprintf("-i=%v", config.Inventory))
+	}
+
+	// Hosts in the mounted inventory file all refer to this container.
+	if config.Inventory == inventoryPath {
+		exec.Cmd = append(exec.Cmd, "--connection=local")
+	}
+
+	// Add diff if configured
+	if config.Diff {
+		exec.Cmd = append(exec.Cmd, "--diff")
 	}
 
+	// Add the arguments common to every playbook run.
+	exec.Cmd = append(exec.Cmd, buildAnsibleArgs(config)...)
+
 	// Add verbose if configured
 	if config.Verbose {
-		args = append(args, "-vvvv")
+		exec.Cmd = append(exec.Cmd, "-vvvv")
+	}
+
+	// Add the extra arguments after every other argument.
+	exec = withAnsibleExecArgs(config, exec)
+
+	// Roles may need more than one pass to settle, only the
+	// result of the final pass is used.
+	passes := config.IdempotencePasses
+	if passes < 1 {
+		passes = 1
 	}
 
-	var idempotence = false
+	var out string
+	var err error
 	now := time.Now()
-	if !config.Quiet {
-		out, _ := DockerExec(args, true)
-		idempotence = IdempotenceResult(out)
-	} else {
-		out, _ := DockerExec(args, false)
-		idempotence = IdempotenceResult(out)
+	for pass := 1; pass <= passes; pass++ {
+		if passes > 1 && !config.Quiet {
+			log.Infof("Idempotence pass %v of %v", pass, passes)
+		}
+
+		// Record the run which will reuse the cached facts.
+		report.factCacheReused(config)
+
+		if !config.Quiet {
+			out, err = config.dockerExec(exec, true)
+		} else {
+			out, err = config.dockerExec(exec, false)
+		}
+		if err == ErrTimeout {
+			report.timedOut("idempotence", out)
+			break
+		}
+		if err == ErrInterrupted {
+			break
+		}
+		report.Ansible.Idempotence.Changed = append(report.Ansible.Idempotence.Changed, config.changedCount(out))
+	}
+	idempotence := err != ErrTimeout && err != ErrInterrupted && config.idempotenceResult(out, report)
+	report.checkUnreachable("idempotence", report.Ansible.Idempotence.Stats)
+	report.recordOutput(config, "idempotence", out)
+	if !idempotence {
+		report.recordFailedIdempotence(config, out)
+	}
+
+	// Keep the output so the diff can be written to the report.
+	if config.Diff {
+		report.Ansible.Idempotence.Output = out
 	}
 
 	if !config.Quiet {
@@ -66,42 +113,168 @@ func PrintIdempotenceResult(start time.Time, idempotence bool) {
 	}
 }
 
-// IdempotenceResult will get the result of an idempotence test
-// which is the full output of a role, and it will identify each
-// of the applicable checks for idempotence. In this case, we
-// simply need the values of changed and failed and some basic logic.
-func IdempotenceResult(output string) bool {
-
-	lines := strings.Split(output, "\n")
-
-	var changed int64
-	var failed int64
-	error := errors.New("empty error")
-
-	for _, line := range lines {
-		if strings.Contains(line, "ok=") && strings.Contains(line, "changed=") {
-			f := strings.Split(line, "=")
-			if strings.Contains(line, "changed=") {
-				changed, error = strconv.ParseInt(strings.Split(f[2], " ")[0], 0, 0)
-			}
-			if strings.Contains(line, "failed=") {
-				failed, error = strconv.ParseInt(strings.Split(f[4], " ")[0], 0, 0)
-			}
+// idempotenceResult will get the result of an idempotence test using
+// the parser which matches the configured stdout callback. The stats
+// of the json callback are stored in the report.
+func (config *AnsibleConfig) idempotenceResult(output string, report *AnsibleReport) bool {
+	if len(config.AllowedChangedTasks) > 0 {
+		return config.allowedIdempotenceResult(output, report)
+	}
+
+	if config.IdempotenceMaxChanged > 0 {
+		return config.thresholdIdempotenceResult(output, report)
+	}
+
+	if config.StdoutCallback != "json" {
+		result, stats := IdempotenceResult(output)
+		report.Ansible.Idempotence.Stats = stats
+		return result
+	}
+
+	stats, err := ParsePlayStats(output)
+	if err != nil {
+		log.Errorln(err)
+		return false
+	}
+	report.Ansible.Idempotence.Stats = stats
+
+	return idempotentHosts(stats)
+}
+
+// allowedIdempotenceResult will get the result of an idempotence test
+// where some tasks are allowed to report changes. Every changed task is
+// found in the output, and the test fails if any of them is not allowed,
+// unless the changes of those tasks across all hosts are within the
+// threshold of --idempotence-max-changed. The allowed tasks which
+// changed are listed in the report.
+func (config *AnsibleConfig) allowedIdempotenceResult(output string, report *AnsibleReport) bool {
+
+	stats, err := config.playStats(output)
+	if err != nil {
+		log.Errorln(err)
+		return false
+	}
+	report.Ansible.Idempotence.Stats = stats
+
+	if stats.Failed() {
+		return false
+	}
+
+	unexpected := []string{}
+	for _, task := range config.changedTasks(output) {
+		if config.allowedChange(task) {
+			report.Ansible.Idempotence.AllowedChanges = append(report.Ansible.Idempotence.AllowedChanges, task)
+		} else {
+			unexpected = append(unexpected, task)
+		}
+	}
+
+	if len(report.Ansible.Idempotence.AllowedChanges) > 0 && !config.Quiet {
+		log.Warnf("Ignored changes from allowed tasks: %v", strings.Join(report.Ansible.Idempotence.AllowedChanges, ", "))
+	}
+
+	changed := 0
+	for _, change := range config.taskChanges(output) {
+		if !config.allowedChange(change.Task) {
+			changed++
+		}
+	}
+
+	// Changes which can't be traced to a task are never allowed.
+	if stats.Changed() && len(report.Ansible.Idempotence.AllowedChanges) == 0 && len(unexpected) == 0 {
+		changed = stats.TotalChanged()
+	}
+
+	if changed > config.IdempotenceMaxChanged {
+		if len(unexpected) > 0 {
+			log.Errorf("Tasks reported changes: %v", strings.Join(unexpected, ", "))
+		}
+		if config.IdempotenceMaxChanged > 0 {
+			log.Errorf("changed=%v (threshold %v): FAIL", changed, config.IdempotenceMaxChanged)
 		}
+		return false
+	}
+
+	if config.IdempotenceMaxChanged > 0 && !config.Quiet {
+		log.Infof("changed=%v (threshold %v): PASS", changed, config.IdempotenceMaxChanged)
 	}
+	return true
+}
+
+// thresholdIdempotenceResult will get the result of an idempotence test
+// where the total number of changed tasks across all hosts may be up to
+// the configured threshold. Failed or unreachable hosts always fail.
+func (config *AnsibleConfig) thresholdIdempotenceResult(output string, report *AnsibleReport) bool {
 
-	if error != nil {
-		log.Errorln(error)
+	stats, err := config.playStats(output)
+	if err != nil {
+		log.Errorln(err)
 		return false
 	}
+	report.Ansible.Idempotence.Stats = stats
 
-	if failed > 0 {
+	if stats.Failed() {
 		return false
 	}
 
-	if changed > 0 {
+	changed := stats.TotalChanged()
+	if changed > config.IdempotenceMaxChanged {
+		log.Errorf("changed=%v (threshold %v): FAIL", changed, config.IdempotenceMaxChanged)
 		return false
 	}
 
+	if !config.Quiet {
+		log.Infof("changed=%v (threshold %v): PASS", changed, config.IdempotenceMaxChanged)
+	}
 	return true
 }
+
+// changedCount will return the number of changed tasks across all
+// hosts in the output of a playbook, or -1 if it can't be found.
+func (config *AnsibleConfig) changedCount(output string) int {
+
+	stats, err := config.playStats(output)
+	if err != nil {
+		return -1
+	}
+
+	return stats.TotalChanged()
+}
+
+// IdempotenceResult will get the result of an idempotence test
+// which is the full output of a role, using the play recap of each
+// host. Every host must report no changed, failed or unreachable
+// tasks, and the stats of each host are returned alongside the result
+// so the hosts which broke idempotence can be reported.
+func IdempotenceResult(output string) (bool, PlayStats) {
+
+	stats, err := ParseRecap(output)
+	if err != nil {
+		log.Errorln(err)
+		return false, stats
+	}
+
+	return idempotentHosts(stats), stats
+}
+
+// idempotentHosts will identify if every host is idempotent, and
+// will log the counts of each host which is not.
+func idempotentHosts(stats PlayStats) bool {
+
+	hosts := []string{}
+	for name := range stats {
+		hosts = append(hosts, name)
+	}
+	sort.Strings(hosts)
+
+	result := true
+	for _, name := range hosts {
+		host := stats[name]
+		if host.Changed > 0 || host.Failed > 0 || host.Unreachable > 0 {
+			log.Errorf("Host %v is not idempotent: changed=%v failed=%v unreachable=%v", name, host.Changed, host.Failed, host.Unreachable)
+			result = false
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,156 @@
+package util
+
+import (
+	"io/ioutil"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONIdempotenceResult(t *testing.T) {
+
+	Convey("Parsing the json stdout callback", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		config := AnsibleConfig{StdoutCallback: "json"}
+		report := AnsibleReport{}
+
+		Convey("Unchanged hosts pass", func() {
+			out := `[WARNING]: provided hosts list is empty
+{"plays": [], "stats": {"test": {"changed": 0, "failures": 0, "ok": 4, "skipped": 1, "unreachable": 0}}}`
+			So(config.idempotenceResult(out, &report), ShouldBeTrue)
+		})
+
+		Convey("Changed hosts fail idempotence", func() {
+			out := `{"plays": [], "stats": {"test": {"changed": 2, "failures": 0, "ok": 4, "unreachable": 0}}}`
+			So(config.idempotenceResult(out, &report), ShouldBeFalse)
+		})
+
+		Convey("Failed hosts fail idempotence", func() {
+			out := `{"plays": [], "stats": {"test": {"changed": 0, "failures": 1, "ok": 4, "unreachable": 0}}}`
+			So(config.idempotenceResult(out, &report), ShouldBeFalse)
+		})
+
+		Convey("Output without json is a failure", func() {
+			So(config.idempotenceResult("ERROR! the playbook could not be found", &report), ShouldBeFalse)
+		})
+	})
+}
+
+func TestChangedCount(t *testing.T) {
+
+	Convey("Counting changed tasks", t, func() {
+		config := AnsibleConfig{}
+
+		Convey("Changes are summed across hosts in the recap", func() {
+			out := `PLAY RECAP *********************************************************************
+web                        : ok=5    changed=2    unreachable=0    failed=0
+db                         : ok=3    changed=1    unreachable=0    failed=0`
+			So(config.changedCount(out), ShouldEqual, 3)
+		})
+
+		Convey("Missing recaps are unknown", func() {
+			So(config.changedCount("ERROR! the playbook could not be found"), ShouldEqual, -1)
+		})
+	})
+}
+
+func TestIdempotenceMaxChanged(t *testing.T) {
+
+	Convey("Allowing a number of changed tasks", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		out := `PLAY RECAP *********************************************************************
+web                        : ok=5    changed=2    unreachable=0    failed=0
+db                         : ok=3    changed=1    unreachable=0    failed=0`
+
+		Convey("Changes up to the threshold pass", func() {
+			config := AnsibleConfig{IdempotenceMaxChanged: 3}
+			report := AnsibleReport{}
+			So(config.idempotenceResult(out, &report), ShouldBeTrue)
+		})
+
+		Convey("Changes over the threshold fail", func() {
+			config := AnsibleConfig{IdempotenceMaxChanged: 2}
+			report := AnsibleReport{}
+			So(config.idempotenceResult(out, &report), ShouldBeFalse)
+		})
+
+		Convey("Unreachable hosts fail regardless of the threshold", func() {
+			config := AnsibleConfig{IdempotenceMaxChanged: 5}
+			report := AnsibleReport{}
+			unreachable := `PLAY RECAP *********************************************************************
+web                        : ok=1    changed=0    unreachable=1    failed=0`
+			So(config.idempotenceResult(unreachable, &report), ShouldBeFalse)
+		})
+	})
+}
+
+func TestIdempotenceResult(t *testing.T) {
+
+	Convey("Parsing the play recap of each host", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Every host must be unchanged", func() {
+			out := `PLAY RECAP *********************************************************************
+web                        : ok=5    changed=0    unreachable=0    failed=0
+db                         : ok=3    changed=1    unreachable=0    failed=0
+cache                      : ok=3    changed=0    unreachable=0    failed=0`
+			result, stats := IdempotenceResult(out)
+			So(result, ShouldBeFalse)
+			So(stats, ShouldHaveLength, 3)
+			So(stats["db"].Changed, ShouldEqual, 1)
+			So(stats["web"].Changed, ShouldEqual, 0)
+		})
+
+		Convey("Unchanged hosts pass", func() {
+			out := `PLAY RECAP *********************************************************************
+web                        : ok=5    changed=0    unreachable=0    failed=0
+db                         : ok=3    changed=0    unreachable=0    failed=0`
+			result, _ := IdempotenceResult(out)
+			So(result, ShouldBeTrue)
+		})
+
+		Convey("Output without a recap is a failure", func() {
+			result, _ := IdempotenceResult("ERROR! the playbook could not be found")
+			So(result, ShouldBeFalse)
+		})
+	})
+}
+
+func TestUnreachableHosts(t *testing.T) {
+
+	Convey("Hosts which were unreachable", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		out := `PLAY RECAP *********************************************************************
+web                        : ok=4    changed=0    unreachable=0    failed=0
+db                         : ok=1    changed=0    unreachable=1    failed=0`
+
+		Convey("Unreachable hosts fail idempotence without changes", func() {
+			result, stats := IdempotenceResult(out)
+			So(result, ShouldBeFalse)
+			So(stats.Unreachable(), ShouldResemble, []string{"db"})
+		})
+
+		Convey("Unreachable hosts are recorded in the report", func() {
+			config := AnsibleConfig{}
+			report := AnsibleReport{}
+			stats, err := config.playStats(out)
+			So(err, ShouldBeNil)
+			So(report.checkUnreachable("run", stats), ShouldBeFalse)
+			So(report.Ansible.Unreachable["run"], ShouldResemble, []string{"db"})
+		})
+
+		Convey("Reachable hosts are not recorded", func() {
+			report := AnsibleReport{}
+			So(report.checkUnreachable("run", PlayStats{"web": {Ok: 4}}), ShouldBeTrue)
+			So(report.Ansible.Unreachable, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,103 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ansiblePackages are the commands which install Ansible with the
+// package manager of each wider family of distributions.
+var ansiblePackages = map[string]string{
+	"debian":    "apt-get update && apt-get install -y ansible",
+	"redhat":    "dnf install -y ansible-core || yum install -y ansible",
+	"alpine":    "apk add --no-cache ansible",
+	"archlinux": "pacman -Sy --noconfirm ansible",
+	"suse":      "zypper -n install ansible",
+}
+
+// pipPackages are the commands which install pip with the package
+// manager of each wider family of distributions.
+var pipPackages = map[string]string{
+	"debian":    "apt-get update && apt-get install -y python3-pip",
+	"redhat":    "dnf install -y python3-pip || yum install -y python3-pip",
+	"alpine":    "apk add --no-cache py3-pip",
+	"archlinux": "pacman -Sy --noconfirm python-pip",
+	"suse":      "zypper -n install python3-pip",
+}
+
+// ansibleRequirement will return the pip requirement of the version of
+// Ansible, where a version without an operator is pinned, ie 9.* is
+// ansible==9.*.
+func ansibleRequirement(version string) string {
+	version = strings.TrimSpace(version)
+	if strings.IndexAny(version, "=<>!~") == 0 {
+		return "ansible" + version
+	}
+	return "ansible==" + version
+}
+
+// ansibleInstallCommands will return the commands which install Ansible
+// in the container, which use the package manager of the family unless
+// a version is given, which is installed with pip. Distributions of
+// unknown families are installed with pip when it is there.
+func (dist *Distribution) ansibleInstallCommands(version string) []string {
+
+	group := strings.ToLower(dist.Family.Group)
+	if command, ok := ansiblePackages[group]; ok && version == "" {
+		return []string{command}
+	}
+
+	requirement := "ansible"
+	if version != "" {
+		requirement = ansibleRequirement(version)
+	}
+	commands := []string{}
+	if command, ok := pipPackages[group]; ok {
+		commands = append(commands, "python3 -m pip --version >/dev/null 2>&1 || ("+command+")")
+	}
+
+	// Newer images refuse to install packages outside of a virtualenv.
+	pip := fmt.Sprintf("python3 -m pip install '%v'", requirement)
+	return append(commands, pip+" || "+strings.Replace(pip, "install", "install --break-system-packages", 1))
+}
+
+// DockerEnsureAnsible will check ansible-playbook is in the container,
+// which runs the role unless the run is remote. When it is missing it
+// is installed with InstallAnsible, and the tests can't run otherwise.
+// The version of Ansible is written to the report.
+func (dist *Distribution) DockerEnsureAnsible(config *AnsibleConfig, report *AnsibleReport) error {
+
+	if config.Remote {
+		return nil
+	}
+
+	if version, err := dist.AnsibleVersion(config); err == nil {
+		report.Ansible.Version = version
+		return nil
+	}
+	if !config.InstallAnsible {
+		return fmt.Errorf("ansible-playbook was not found in the image %v, use --install-ansible to install it", dist.Container)
+	}
+
+	if !config.Quiet {
+		log.Printf("Installing Ansible in %v", dist.CID)
+	}
+	for _, command := range dist.ansibleInstallCommands(config.InstallAnsibleVersion) {
+		if _, err := dist.runScript(config, command); err != nil {
+			return fmt.Errorf("unable to install Ansible in the image %v, %v", dist.Container, err)
+		}
+	}
+
+	version, err := dist.AnsibleVersion(config)
+	if err != nil {
+		return fmt.Errorf("ansible-playbook was not found in the image %v after installing Ansible: %v", dist.Container, err)
+	}
+	if !config.Quiet {
+		log.Printf("Installed Ansible %v in %v", version, dist.CID)
+	}
+	report.Ansible.Version = version
+	report.Ansible.Installed = true
+	return nil
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEnsureAnsible(t *testing.T) {
+
+	Convey("Checking Ansible is in the container", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		probe := "test ansible-playbook --version"
+
+		Convey("The version of Ansible in the image is reported", func() {
+			fake := &fakeEngine{outputs: map[string][]string{probe: {"ansible-playbook 2.9.27"}}}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+			report := AnsibleReport{}
+			So(dist.DockerEnsureAnsible(&AnsibleConfig{Quiet: true}, &report), ShouldBeNil)
+			So(report.Ansible.Version, ShouldEqual, "2.9.27")
+			So(report.Ansible.Installed, ShouldBeFalse)
+			So(fake.commands, ShouldHaveLength, 1)
+		})
+
+		Convey("Images without Ansible fail naming the image", func() {
+			fake := &fakeEngine{
+				outputs: map[string][]string{probe: {"sh: ansible-playbook: not found"}},
+				failing: map[string]bool{probe: true},
+			}
+			engine = fake
+			dist := Alpine3
+			dist.CID = "test"
+			err := dist.DockerEnsureAnsible(&AnsibleConfig{Quiet: true}, &AnsibleReport{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "ansible-playbook was not found in the image alpine:3, use --install-ansible to install it")
+		})
+
+		Convey("Ansible is installed with the package manager of the family", func() {
+			fake := &fakeEngine{outputs: map[string][]string{probe: {"sh: ansible-playbook: not found", "ansible-playbook [core 2.16.3]"}}}
+			engine = fake
+			dist := JeffDebian12
+			dist.CID = "test"
+			report := AnsibleReport{}
+			So(dist.DockerEnsureAnsible(&AnsibleConfig{Quiet: true, InstallAnsible: true}, &report), ShouldBeNil)
+			So(fake.commands[1], ShouldResemble, []string{"exec", "test", "sh", "-c", "exec 2>&1; apt-get update && apt-get install -y ansible"})
+			So(fake.commands, ShouldHaveLength, 3)
+			So(report.Ansible.Version, ShouldEqual, "2.16.3")
+			So(report.Ansible.Installed, ShouldBeTrue)
+			So(report.NewJSONReport().AnsibleInstalled, ShouldBeTrue)
+		})
+
+		Convey("Remote runs use the Ansible of the host", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Alpine3
+			So(dist.DockerEnsureAnsible(&AnsibleConfig{Quiet: true, Remote: true}, &AnsibleReport{}), ShouldBeNil)
+			So(fake.commands, ShouldBeEmpty)
+		})
+
+		Convey("A version of Ansible is installed with pip", func() {
+			dist := JeffRockyLinux9
+			So(dist.ansibleInstallCommands("9.*"), ShouldResemble, []string{
+				"python3 -m pip --version >/dev/null 2>&1 || (dnf install -y python3-pip || yum install -y python3-pip)",
+				"python3 -m pip install 'ansible==9.*' || python3 -m pip install --break-system-packages 'ansible==9.*'",
+			})
+			So(ansibleRequirement(">=8,<10"), ShouldEqual, "ansible>=8,<10")
+			So(ansibleRequirement("2.9.27"), ShouldEqual, "ansible==2.9.27")
+
+			custom := Distribution{}
+			So(custom.ansibleInstallCommands(""), ShouldResemble, []string{
+				"python3 -m pip install 'ansible' || python3 -m pip install --break-system-packages 'ansible'",
+			})
+		})
+	})
+}
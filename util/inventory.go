@@ -0,0 +1,120 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// inventoryHosts will return the names of all hosts in the given
+// inventory file as reported by ansible-inventory.
+func inventoryHosts(inventory string) ([]string, error) {
+
+	bin, err := ansibleTool("ansible-inventory")
+	if err != nil {
+		return []string{}, err
+	}
+
+	out, err := exec.Command(bin, "-i", inventory, "--list").Output()
+	if err != nil {
+		return []string{}, err
+	}
+
+	groups := map[string]json.RawMessage{}
+	if err := json.Unmarshal(out, &groups); err != nil {
+		return []string{}, err
+	}
+
+	unique := map[string]bool{}
+	hosts := []string{}
+	for name, data := range groups {
+		// The _meta key contains host variables, not hosts.
+		if name == "_meta" {
+			continue
+		}
+		group := struct {
+			Hosts []string `json:"hosts"`
+		}{}
+		if err := json.Unmarshal(data, &group); err != nil {
+			continue
+		}
+		for _, host := range group.Hosts {
+			if !unique[host] {
+				unique[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	sort.Strings(hosts)
+
+	return hosts, nil
+}
+
+// writeInventoryOverrides will create a temporary inventory source which
+// points every host in the inventory file at the container. Variables are
+// written as a host_vars drop-in, which takes precedence over any host
+// variables inside the inventory file itself. The directory is returned.
+func (dist *Distribution) writeInventoryOverrides(config *AnsibleConfig) (string, error) {
+
+	dir, err := ioutil.TempDir("", "ansible-role-tester-inventory")
+	if err != nil {
+		return "", err
+	}
+
+	vars := fmt.Sprintf("ansible_host: %v\nansible_connection: %v\n", dist.CID, connectionPlugin(config))
+
+	hosts, err := inventoryHosts(config.InventoryFile)
+	if err != nil || len(hosts) == 0 {
+		// We can't find the hosts, so all of them will be targeted.
+		if !config.Quiet {
+			log.Warnf("could not list hosts in %v, overriding variables for all hosts", config.InventoryFile)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "group_vars"), 0755); err != nil {
+			return dir, err
+		}
+		return dir, ioutil.WriteFile(filepath.Join(dir, "group_vars", "all.yml"), []byte(vars), 0644)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "host_vars"), 0755); err != nil {
+		return dir, err
+	}
+	for _, host := range hosts {
+		if err := ioutil.WriteFile(filepath.Join(dir, "host_vars", host+".yml"), []byte(vars), 0644); err != nil {
+			return dir, err
+		}
+	}
+
+	// Declare the hosts so the directory is a valid inventory source.
+	return dir, ioutil.WriteFile(filepath.Join(dir, "hosts"), []byte(strings.Join(hosts, "\n")+"\n"), 0644)
+}
+
+// buildInventoryArgs returns the inventory arguments for playbooks which
+// are run from the host against the container. By default the inventory
+// only contains the container, unless an inventory file was configured.
+// The returned function removes any temporary files and should be deferred.
+func (dist *Distribution) buildInventoryArgs(config *AnsibleConfig) ([]string, func()) {
+
+	if config.InventoryFile == "" {
+		return []string{"-i", dist.CID + ","}, func() {}
+	}
+
+	dir, err := dist.writeInventoryOverrides(config)
+	cleanup := func() {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}
+	if err != nil {
+		log.Errorln(err)
+		return []string{"-i", config.InventoryFile}, cleanup
+	}
+
+	return []string{"-i", config.InventoryFile, "-i", dir}, cleanup
+}
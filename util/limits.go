@@ -0,0 +1,76 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minMemory is the smallest memory limit docker allows.
+const minMemory = 6 * 1024 * 1024
+
+// memoryUnits are the multipliers of the units of memory limits.
+var memoryUnits = map[string]float64{
+	"":  1,
+	"b": 1,
+	"k": 1024,
+	"m": 1024 * 1024,
+	"g": 1024 * 1024 * 1024,
+	"t": 1024 * 1024 * 1024 * 1024,
+}
+
+// ParseMemory will convert a memory limit such as 512m or 2g into
+// bytes, where an empty limit is 0 which is no limit.
+func ParseMemory(value string) (int64, error) {
+
+	if value == "" {
+		return 0, nil
+	}
+
+	number := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "b")
+	unit := ""
+	if i := strings.IndexAny(number, "kmgt"); i >= 0 {
+		number, unit = number[:i], number[i:]
+	}
+
+	multiplier, ok := memoryUnits[unit]
+	size, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid memory limit '%v', expected a size such as 512m or 2g", value)
+	}
+
+	bytes := int64(size * multiplier)
+	if bytes < minMemory {
+		return 0, fmt.Errorf("invalid memory limit '%v', the minimum is 6m", value)
+	}
+
+	return bytes, nil
+}
+
+// ParseCPUs will convert a CPU limit such as 1.5 into the number of
+// CPUs, where an empty limit is 0 which is no limit.
+func ParseCPUs(value string) (float64, error) {
+
+	if value == "" {
+		return 0, nil
+	}
+
+	cpus, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || cpus <= 0 {
+		return 0, fmt.Errorf("invalid CPU limit '%v', expected a number of CPUs such as 1.5", value)
+	}
+
+	return cpus, nil
+}
+
+// dockerWarnings will return the warnings of the docker daemon in the
+// output of a docker command, ie when the kernel can't limit swap.
+func dockerWarnings(output string) []string {
+	warnings := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "WARNING: ") {
+			warnings = append(warnings, strings.TrimSpace(strings.TrimPrefix(line, "WARNING: ")))
+		}
+	}
+	return warnings
+}
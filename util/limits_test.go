@@ -0,0 +1,62 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLimits(t *testing.T) {
+
+	Convey("Limiting the resources of the container", t, func() {
+
+		Convey("Memory limits are parsed into bytes", func() {
+			for value, expected := range map[string]int64{
+				"":       0,
+				"512m":   512 * 1024 * 1024,
+				"2g":     2 * 1024 * 1024 * 1024,
+				"2GB":    2 * 1024 * 1024 * 1024,
+				"1.5g":   1536 * 1024 * 1024,
+				"65536k": 64 * 1024 * 1024,
+			} {
+				memory, err := ParseMemory(value)
+				So(err, ShouldBeNil)
+				So(memory, ShouldEqual, expected)
+			}
+
+			for _, value := range []string{"lots", "2x", "-1g", "0", "1m", "g"} {
+				_, err := ParseMemory(value)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("CPU limits are parsed", func() {
+			cpus, err := ParseCPUs("1.5")
+			So(err, ShouldBeNil)
+			So(cpus, ShouldEqual, 1.5)
+
+			for _, value := range []string{"half", "0", "-2"} {
+				_, err := ParseCPUs(value)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Limits are applied when the container is created", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: cgroupVolume}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", Memory: 536870912, CPUs: 1.5}
+			report := AnsibleReport{}
+
+			args := buildRunCommand(&dist, &config, &report).args()
+			So(args, ShouldContain, "--memory=536870912")
+			So(args, ShouldContain, "--cpus=1.5")
+			So(report.NewJSONReport().Metadata.MemoryLimit, ShouldEqual, int64(536870912))
+			So(report.NewJSONReport().Metadata.CPULimit, ShouldEqual, 1.5)
+		})
+
+		Convey("Warnings of docker are found", func() {
+			So(dockerWarnings("WARNING: Your kernel does not support swap limit capabilities or the cgroup is not mounted. Memory limited without swap.\n0123456789ab\n"), ShouldResemble, []string{
+				"Your kernel does not support swap limit capabilities or the cgroup is not mounted. Memory limited without swap.",
+			})
+		})
+	})
+}
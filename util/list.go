@@ -0,0 +1,72 @@
+package util
+
+import (
+	"encoding/json"
+)
+
+// JSONListDistribution is a distribution of the list command.
+type JSONListDistribution struct {
+
+	// Name is the name the distribution is selected by.
+	Name string `json:"name"`
+
+	// Aliases are the short names the distribution is also selected by.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// User is the user the distribution is selected with.
+	User string `json:"user"`
+
+	// Image is the image of the container.
+	Image string `json:"image"`
+
+	// Family is the family of the distribution, ie Ubuntu.
+	Family string `json:"family"`
+
+	// Group is the wider family of the distribution, ie debian.
+	Group string `json:"group"`
+
+	// Init is the init system of the container, ie systemd.
+	Init string `json:"init"`
+
+	// InitCommand is the command the container is started with.
+	InitCommand string `json:"init_command"`
+
+	// Privileged indicates the container is privileged.
+	Privileged bool `json:"privileged"`
+
+	// EOL is the end of life of the release upstream, as YYYY-MM-DD.
+	EOL string `json:"eol,omitempty"`
+
+	// Deprecated indicates the release is past its end of life.
+	Deprecated bool `json:"deprecated"`
+}
+
+// NewJSONListDistribution will return the distribution as it is listed.
+func NewJSONListDistribution(dist *Distribution) JSONListDistribution {
+	result := JSONListDistribution{
+		Name:        dist.Distro,
+		Aliases:     AliasesOf(dist.Distro),
+		User:        dist.User,
+		Image:       dist.Container,
+		Family:      dist.Family.Name,
+		Group:       dist.Family.Group,
+		Init:        dist.InitSystem(),
+		InitCommand: dist.Family.Initialise,
+		Privileged:  dist.Privileged,
+		Deprecated:  dist.Deprecated(),
+	}
+	if !dist.EOL.IsZero() {
+		result.EOL = dist.EOL.Format("2006-01-02")
+	}
+	return result
+}
+
+// DistributionsJSON will return the distributions of the family as JSON,
+// which is every distribution when the family is empty.
+func DistributionsJSON(family string) ([]byte, error) {
+	result := []JSONListDistribution{}
+	for _, dist := range ListDistributions(family) {
+		result = append(result, NewJSONListDistribution(&dist))
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
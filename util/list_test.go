@@ -0,0 +1,73 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestListDistributions(t *testing.T) {
+
+	Convey("Listing the distributions", t, func() {
+
+		Convey("Every distribution is listed without a family", func() {
+			So(len(ListDistributions("")), ShouldEqual, len(Distributions))
+		})
+
+		Convey("Distributions are filtered by family or wider family", func() {
+			for _, dist := range ListDistributions("ubuntu") {
+				So(dist.Family.Name, ShouldEqual, "Ubuntu")
+			}
+			redhat := ListDistributions("redhat")
+			So(len(redhat), ShouldBeGreaterThan, 0)
+			for _, dist := range redhat {
+				So(dist.Family.Group, ShouldEqual, "redhat")
+			}
+			debian := ListDistributions("debian")
+			for _, dist := range ListDistributions("ubuntu") {
+				So(debian, ShouldContain, dist)
+			}
+			So(ListDistributions("solaris"), ShouldBeEmpty)
+		})
+
+		Convey("Distributions past their end of life are deprecated", func() {
+			dist := Distribution{EOL: time.Now().Add(-time.Hour)}
+			So(dist.Deprecated(), ShouldBeTrue)
+			dist.EOL = time.Now().Add(time.Hour)
+			So(dist.Deprecated(), ShouldBeFalse)
+			So((&Distribution{}).Deprecated(), ShouldBeFalse)
+			So(CentOS6.Deprecated(), ShouldBeTrue)
+		})
+
+		Convey("The init system is listed", func() {
+			So(Ubuntu1804.InitSystem(), ShouldEqual, "systemd")
+			So(CentOS6.InitSystem(), ShouldEqual, "init")
+			dist := Ubuntu1804
+			dist.Override(nil, true, "")
+			So(dist.InitSystem(), ShouldEqual, "none")
+		})
+
+		Convey("Distributions are listed as JSON", func() {
+			data, err := DistributionsJSON("fedora")
+			So(err, ShouldBeNil)
+			result := []JSONListDistribution{}
+			So(json.Unmarshal(data, &result), ShouldBeNil)
+			So(len(result), ShouldEqual, len(ListDistributions("fedora")))
+			So(result[0].Name, ShouldEqual, Fedora24.Distro)
+			So(result[0].Image, ShouldEqual, Fedora24.Container)
+			So(result[0].Group, ShouldEqual, "redhat")
+			So(result[0].Init, ShouldEqual, "systemd")
+			So(result[0].EOL, ShouldEqual, "2017-08-08")
+			So(result[0].Deprecated, ShouldBeTrue)
+			So(result[0].Aliases, ShouldBeEmpty)
+
+			data, err = DistributionsJSON("amazonlinux")
+			So(err, ShouldBeNil)
+			So(json.Unmarshal(data, &result), ShouldBeNil)
+			So(result[0].Image, ShouldEqual, AmazonLinux2.Container)
+			So(result[0].Aliases, ShouldResemble, []string{"al2"})
+		})
+	})
+}
@@ -0,0 +1,180 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Output is where the output of the commands of a distribution is
+// written. Each distribution which is tested concurrently has an Output
+// of its own, so their stages don't share the log file or prefix.
+type Output struct {
+
+	// Stdout is where the printed output is written, ie a buffer
+	// which is printed once the distribution is tested. It is
+	// os.Stdout when nil, where commands may use the terminal.
+	Stdout io.Writer
+
+	// stageLog is the log file of the stage which is running, which
+	// the output of every command is written to as it runs. It is
+	// nil when no log directory is configured.
+	stageLog io.Writer
+
+	// prefix is the name of the distribution and container which
+	// every printed line of output is prefixed with, along with the
+	// time. It is empty when the output is not prefixed.
+	prefix string
+}
+
+// defaultOutput is the output of the commands which don't belong to
+// the config of a distribution, and of configs without an Output.
+var defaultOutput = &Output{}
+
+// output will return the output of the config.
+func (config *AnsibleConfig) output() *Output {
+	if config.Output == nil {
+		return defaultOutput
+	}
+	return config.Output
+}
+
+// interactive will return true when the output is the terminal.
+func (output *Output) interactive() bool {
+	return output.Stdout == nil
+}
+
+// writer will return the writer for the output of a command, which
+// is captured in the buffer, printed when stdout is true, and written
+// to the log file of the stage which is running. The printed and
+// logged lines are prefixed when an output prefix is set, and the
+// returned function must be called once the command has finished.
+func (output *Output) writer(out *bytes.Buffer, stdout bool) (io.Writer, func()) {
+	writers := []io.Writer{}
+	if stdout && output.interactive() {
+		writers = append(writers, os.Stdout)
+	} else if stdout {
+		writers = append(writers, output.Stdout)
+	}
+	if output.stageLog != nil {
+		writers = append(writers, output.stageLog)
+	}
+
+	if output.prefix == "" || len(writers) == 0 {
+		return io.MultiWriter(append(writers, out)...), func() {}
+	}
+
+	prefixed := newPrefixWriter(io.MultiWriter(writers...), output.prefix)
+	return io.MultiWriter(out, prefixed), prefixed.Close
+}
+
+// prefixWriter is a writer which prefixes every line written to it
+// with the time and a prefix, regardless of how the lines are split
+// across writes.
+type prefixWriter struct {
+	writer *io.PipeWriter
+	done   chan struct{}
+}
+
+// newPrefixWriter will return a prefixWriter which writes the
+// prefixed lines to w until it is closed.
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	reader, writer := io.Pipe()
+	p := &prefixWriter{writer: writer, done: make(chan struct{})}
+
+	go func() {
+		defer close(p.done)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			fmt.Fprintf(w, "%v %v | %v\n", time.Now().Format(time.RFC3339), prefix, scanner.Text())
+		}
+		// Lines which are too long stop the scanner, the rest
+		// of the output is discarded so the command can finish.
+		io.Copy(ioutil.Discard, reader)
+	}()
+
+	return p
+}
+
+// Write will write the output to the prefixWriter.
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	return p.writer.Write(b)
+}
+
+// Close will write any remaining output and wait for it to be printed.
+func (p *prefixWriter) Close() {
+	p.writer.Close()
+	<-p.done
+}
+
+// linePrefix will return the prefix of the output lines of the
+// distribution, which is the name and the short container id.
+func (report *AnsibleReport) linePrefix() string {
+	cid := report.Ansible.Distribution.CID
+	if len(cid) > 12 {
+		cid = cid[:12]
+	}
+	if cid == "" {
+		return report.distributionName()
+	}
+	return report.distributionName() + "/" + cid
+}
+
+// logDir will create the log directory of the distribution.
+func (report *AnsibleReport) logDir(config *AnsibleConfig) (string, error) {
+
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(report.distributionName())
+	if name == "" {
+		name = "default"
+	}
+
+	dir := filepath.Join(config.LogDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return dir, fmt.Errorf("could not create the log directory %v: %v", dir, err)
+	}
+	return dir, nil
+}
+
+// openStageLog will create the log file of the stage in the log
+// directory, in a directory for the distribution, and will record
+// its path in the report. The returned function closes the file.
+func (report *AnsibleReport) openStageLog(config *AnsibleConfig, stage string) func() {
+
+	if config.LogDir == "" {
+		return func() {}
+	}
+
+	dir, err := report.logDir(config)
+	if err != nil {
+		log.Errorln(err)
+		return func() {}
+	}
+
+	filename := filepath.Join(dir, stage+".log")
+	file, err := os.Create(filename)
+	if err != nil {
+		log.Errorf("could not create the log file %v: %v", filename, err)
+		return func() {}
+	}
+
+	if report.Ansible.Logs == nil {
+		report.Ansible.Logs = map[string]string{}
+	}
+	report.Ansible.Logs[stage] = filename
+	output := config.output()
+	output.stageLog = file
+
+	return func() {
+		output.stageLog = nil
+		file.Close()
+	}
+}
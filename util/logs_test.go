@@ -0,0 +1,129 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStageLogs(t *testing.T) {
+
+	Convey("Writing the output of each stage to log files", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Logs are written per distribution and stage", func() {
+			dir, err := ioutil.TempDir("", "ansible-role-tester")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+
+			config := AnsibleConfig{LogDir: filepath.Join(dir, "logs")}
+			report := AnsibleReport{}
+			report.Ansible.Distribution = Distribution{Distro: "ubuntu:bionic"}
+
+			result := report.RunStages(&config, []Stage{
+				{Name: "syntax", Run: func() bool {
+					var out bytes.Buffer
+					writer, done := config.output().writer(&out, false)
+					fmt.Fprintln(writer, "playbook: site.yml")
+					done()
+					return true
+				}},
+				{Name: "converge", Skip: true, Run: func() bool { return true }},
+			})
+			So(result, ShouldBeTrue)
+
+			filename := filepath.Join(dir, "logs", "ubuntu_bionic", "syntax.log")
+			So(report.Ansible.Logs, ShouldResemble, map[string]string{"syntax": filename})
+
+			content, err := ioutil.ReadFile(filename)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldContainSubstring, "playbook: site.yml")
+			So(config.output().stageLog, ShouldBeNil)
+		})
+
+		Convey("Nothing is written without a log directory", func() {
+			config := AnsibleConfig{}
+			report := AnsibleReport{}
+			report.openStageLog(&config, "syntax")()
+			So(report.Ansible.Logs, ShouldBeEmpty)
+		})
+
+		Convey("Lines are prefixed across writes", func() {
+			var prefixed bytes.Buffer
+			writer := newPrefixWriter(&prefixed, "centos:7/0123456789ab")
+			fmt.Fprint(writer, "TASK [Gathering ")
+			fmt.Fprint(writer, "Facts]\nok: [localhost]\nno newline")
+			writer.Close()
+
+			lines := strings.Split(strings.TrimSpace(prefixed.String()), "\n")
+			So(lines, ShouldHaveLength, 3)
+			So(lines[0], ShouldEndWith, " centos:7/0123456789ab | TASK [Gathering Facts]")
+			So(lines[1], ShouldEndWith, " centos:7/0123456789ab | ok: [localhost]")
+			So(lines[2], ShouldEndWith, " centos:7/0123456789ab | no newline")
+
+			_, err := time.Parse(time.RFC3339, strings.Fields(lines[0])[0])
+			So(err, ShouldBeNil)
+		})
+
+		Convey("The captured output is not prefixed", func() {
+			dir, err := ioutil.TempDir("", "ansible-role-tester")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+
+			config := AnsibleConfig{LogDir: dir, PrefixOutput: true}
+			report := AnsibleReport{}
+			report.Ansible.Distribution = Distribution{Distro: "centos:7", CID: "0123456789abcdef"}
+
+			var out bytes.Buffer
+			report.RunStages(&config, []Stage{
+				{Name: "converge", Run: func() bool {
+					writer, done := config.output().writer(&out, false)
+					fmt.Fprintln(writer, "ok: [localhost]")
+					done()
+					return true
+				}},
+			})
+			So(out.String(), ShouldEqual, "ok: [localhost]\n")
+			So(config.output().prefix, ShouldEqual, "")
+
+			content, err := ioutil.ReadFile(report.Ansible.Logs["converge"])
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEndWith, " centos:7/0123456789ab | ok: [localhost]\n")
+		})
+
+		Convey("Each distribution has an output of its own", func() {
+			var printed, other bytes.Buffer
+			config := AnsibleConfig{PrefixOutput: true, Output: &Output{Stdout: &printed}}
+			otherConfig := AnsibleConfig{Output: &Output{Stdout: &other}}
+			report := AnsibleReport{}
+			report.Ansible.Distribution = Distribution{Distro: "centos:7", CID: "0123456789abcdef"}
+
+			var out bytes.Buffer
+			report.RunStages(&config, []Stage{
+				{Name: "converge", Run: func() bool {
+					writer, done := config.output().writer(&out, true)
+					fmt.Fprintln(writer, "ok: [localhost]")
+					done()
+					writer, done = otherConfig.output().writer(&bytes.Buffer{}, true)
+					fmt.Fprintln(writer, "changed: [localhost]")
+					done()
+					return true
+				}},
+			})
+			So(out.String(), ShouldEqual, "ok: [localhost]\n")
+			So(printed.String(), ShouldEndWith, " centos:7/0123456789ab | ok: [localhost]\n")
+			So(other.String(), ShouldEqual, "changed: [localhost]\n")
+			So(config.output().interactive(), ShouldBeFalse)
+			So((&AnsibleConfig{}).output(), ShouldEqual, defaultOutput)
+		})
+	})
+}
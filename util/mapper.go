@@ -6,8 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	log "github.com/sirupsen/logrus"
 )
 
 // GenericFileAssignment will take a path and parse check it for specific
@@ -74,6 +72,11 @@ func GenericPlaybookAssignment(input, path string) (string, error) {
 // /, ./ or otherwise.
 func MapPlaybook(config *AnsibleConfig) {
 
+	// The first of many playbooks is the main playbook.
+	if len(config.PlaybookFiles) > 0 {
+		config.PlaybookFile = config.PlaybookFiles[0]
+	}
+
 	playbook, err := GenericFileAssignment(config.PlaybookFile, config.HostPath, true)
 	if err != nil {
 		playbook, err = GenericPlaybookAssignment(config.PlaybookFile, config.HostPath)
@@ -81,7 +84,7 @@ func MapPlaybook(config *AnsibleConfig) {
 	config.PlaybookFile = playbook
 
 	if err != nil {
-		log.Fatalf("Specified playbook file %v does not exist.", config.PlaybookFile)
+		ConfigError("Specified playbook file %v does not exist.", config.PlaybookFile)
 	}
 
 	if !config.Remote && config.PlaybookFile != "" {
@@ -89,6 +92,30 @@ func MapPlaybook(config *AnsibleConfig) {
 		config.PlaybookFile = strings.Replace(config.PlaybookFile, pwd, config.RemotePath, -1)
 	}
 
+	// The remaining playbooks must exist, there is nothing to guess.
+	for i := range config.PlaybookFiles {
+		if i == 0 {
+			config.PlaybookFiles[i] = config.PlaybookFile
+			continue
+		}
+		config.PlaybookFiles[i] = mapPlaybookFile(config, config.PlaybookFiles[i])
+	}
+	if config.PreparePlaybook != "" {
+		config.PreparePlaybook = mapPlaybookFile(config, config.PreparePlaybook)
+	}
+	if config.CleanupPlaybook != "" {
+		config.CleanupPlaybook = mapPlaybookFile(config, config.CleanupPlaybook)
+	}
+
+	// A missing verify playbook skips the verify stage.
+	if config.VerifyPlaybook != "" {
+		if _, err := GenericFileAssignment(config.VerifyPlaybook, config.HostPath, true); err != nil {
+			config.VerifyPlaybook = ""
+		} else {
+			config.VerifyPlaybook = mapPlaybookFile(config, config.VerifyPlaybook)
+		}
+	}
+
 	if err == nil {
 		if config.Remote && config.RemotePath == "" {
 			pwd, _ := os.Getwd()
@@ -100,6 +127,40 @@ func MapPlaybook(config *AnsibleConfig) {
 
 }
 
+// mapPlaybookFile will adjust the path of an additional playbook
+// in the same way as the main playbook, it must exist.
+func mapPlaybookFile(config *AnsibleConfig, input string) string {
+
+	playbook, err := GenericFileAssignment(input, config.HostPath, true)
+	if err != nil {
+		ConfigError("Specified playbook file %v does not exist.", playbook)
+	}
+
+	if !config.Remote {
+		pwd, _ := os.Getwd()
+		playbook = strings.Replace(playbook, pwd, config.RemotePath, -1)
+	}
+
+	return playbook
+}
+
+// playbooks returns the playbooks to run in order.
+func (config *AnsibleConfig) playbooks() []string {
+	if len(config.PlaybookFiles) > 0 {
+		return config.PlaybookFiles
+	}
+	return []string{config.PlaybookFile}
+}
+
+// idempotencePlaybooks returns the playbooks to run for the idempotence
+// test, which is only the first playbook unless configured otherwise.
+func (config *AnsibleConfig) idempotencePlaybooks() []string {
+	if config.IdempotenceAllPlaybooks {
+		return config.playbooks()
+	}
+	return config.playbooks()[:1]
+}
+
 // MapInventory will adjust the inventory path for the appropriate
 // path based on the configuration. ie remote or not, and
 // guesswork based upon input. For example, paths starting with
@@ -110,7 +171,7 @@ func MapInventory(CID string, config *AnsibleConfig) {
 	config.Inventory = inventory
 
 	if err != nil {
-		log.Fatalf("Specified inventory file %v does not exist.", config.Inventory)
+		ConfigError("Specified inventory file %v does not exist.", config.Inventory)
 	}
 
 	if !config.Remote && config.Inventory != "" {
@@ -124,20 +185,202 @@ func MapInventory(CID string, config *AnsibleConfig) {
 // MapRequirements will adjust the requirements path for the appropriate
 // path based on the configuration. ie remote or not, and
 // guesswork based upon input. For example, paths starting with
-// /, ./ or otherwise.
+// /, ./ or otherwise. When no file is configured, requirements.yml
+// or tests/requirements.yml of the role is used if it exists. The
+// collections are installed from the requirements file when it has
+// a collections section, or from collections/requirements.yml. The
+// dependencies of meta/main.yml are found in DepsFrom or Galaxy.
 func MapRequirements(config *AnsibleConfig) {
 
+	if config.RequirementsFile == "" {
+		config.RequirementsFile = detectRequirements(config.HostPath)
+	}
+
 	requirements, err := GenericFileAssignment(config.RequirementsFile, config.HostPath, true)
 	config.RequirementsFile = requirements
 
 	if err != nil {
-		log.Fatalf("Specified requirements file %v does not exist.", config.RequirementsFile)
+		ConfigError("Specified requirements file %v does not exist.", config.RequirementsFile)
 	}
 
-	if !config.Remote && config.RequirementsFile != "" {
-		pwd, _ := os.Getwd()
-		config.RequirementsFile = strings.Replace(config.RequirementsFile, pwd, config.RemotePath, -1)
-		config.RequirementsFile = fmt.Sprintf("%v/%v", config.RemotePath, config.RequirementsFile)
+	if config.RequirementsFile != "" {
+		roles, collections := requirementsSections(config.RequirementsFile)
+		if collections && config.CollectionsFile == "" {
+			config.CollectionsFile = config.RequirementsFile
+		}
+		if !roles {
+			config.RequirementsFile = ""
+		}
+	}
+	if config.CollectionsFile == "" {
+		config.CollectionsFile = detectCollections(config.HostPath)
+	}
+
+	if config.DepsFrom != "" {
+		deps, err := filepath.Abs(config.DepsFrom)
+		if info, statErr := os.Stat(deps); err != nil || statErr != nil || !info.IsDir() {
+			ConfigError("Specified dependencies directory %v does not exist.", config.DepsFrom)
+		}
+		config.DepsFrom = deps
+	}
+	if config.HostPath != "" {
+		galaxy, local, err := resolveDependencies(config.HostPath, config.DepsFrom)
+		if err != nil {
+			ConfigError("Unable to resolve the dependencies of the role: %v", err)
+		}
+		config.Dependencies, config.LocalDependencies = []MetaDependency{}, local
+
+		// The roles of the extra roles path are already mounted.
+		for _, dep := range galaxy {
+			if localDependency(config.ExtraRolesPath, dep) == "" {
+				config.Dependencies = append(config.Dependencies, dep)
+			}
+		}
+	}
+
+	if config.CollectionsOffline != "" {
+		offline, err := filepath.Abs(config.CollectionsOffline)
+		if info, statErr := os.Stat(offline); err != nil || statErr != nil || !info.IsDir() {
+			ConfigError("Specified collections directory %v does not exist.", config.CollectionsOffline)
+		}
+		config.CollectionsOffline = offline
+	}
+
+	if !config.Remote {
+		config.RequirementsFile = containerFile(config, config.RequirementsFile)
+		config.CollectionsFile = containerFile(config, config.CollectionsFile)
+	}
+
+}
+
+// containerFile will return the path of a file of the role inside of
+// the container, which is unchanged when it is outside of the role.
+func containerFile(config *AnsibleConfig, file string) string {
+	pwd, _ := os.Getwd()
+	switch {
+	case file == "":
+		return file
+	case config.HostPath != "" && strings.HasPrefix(file, config.HostPath+"/"):
+		return strings.Replace(file, config.HostPath, config.RemotePath, 1)
+	case strings.HasPrefix(file, pwd+"/"):
+		return strings.Replace(file, pwd, config.RemotePath, 1)
+	case !strings.HasPrefix(file, "/"):
+		return fmt.Sprintf("%v/%v", config.RemotePath, file)
+	}
+	return file
+}
+
+// requirementsFiles are the requirements files of a role which are
+// installed when no file is configured, in order of preference.
+var requirementsFiles = []string{"requirements.yml", "tests/requirements.yml"}
+
+// detectRequirements will return the path of the requirements file of
+// the role, or an empty string when the role has none.
+func detectRequirements(path string) string {
+	for _, file := range requirementsFiles {
+		if _, err := os.Stat(filepath.Join(path, file)); err == nil {
+			return filepath.Join(path, file)
+		}
+	}
+	return ""
+}
+
+// resolveHostFile will resolve a path on the host to an absolute path
+// so it can be mounted into a container, and will return an error if
+// the file does not exist.
+func resolveHostFile(input string) (string, error) {
+
+	path, err := filepath.Abs(input)
+	if err != nil {
+		return input, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return input, err
+	}
+
+	return path, nil
+
+}
+
+// MapVaultPasswordFile will resolve the vault password file to an
+// absolute path on the host so it can be mounted or passed to
+// ansible-playbook, and will fail if the file does not exist.
+func MapVaultPasswordFile(config *AnsibleConfig) {
+
+	if config.VaultPasswordFile == "" {
+		return
+	}
+
+	path, err := resolveHostFile(config.VaultPasswordFile)
+	if err != nil {
+		ConfigError("Specified vault password file %v does not exist.", config.VaultPasswordFile)
+	}
+
+	config.VaultPasswordFile = path
+
+}
+
+// MapPythonRequirements will resolve the Python requirements file to an
+// absolute path on the host so it can be mounted, and will fail if the
+// file does not exist. When no file is configured, tests/requirements.txt
+// of the role is used if it exists.
+func MapPythonRequirements(config *AnsibleConfig) {
+
+	if config.PythonRequirements == "" {
+		file := filepath.Join(config.HostPath, pythonRequirementsFile)
+		if _, err := os.Stat(file); err != nil || config.HostPath == "" {
+			return
+		}
+		config.PythonRequirements = file
+	}
+
+	path, err := resolveHostFile(config.PythonRequirements)
+	if err != nil {
+		ConfigError("Specified Python requirements file %v does not exist.", config.PythonRequirements)
+	}
+
+	config.PythonRequirements = path
+
+}
+
+// MapAnsibleCfg will resolve the ansible.cfg file to an absolute
+// path on the host so it can be mounted or used by ansible-playbook,
+// and will fail if the file does not exist.
+func MapAnsibleCfg(config *AnsibleConfig) {
+
+	if config.AnsibleCfg == "" {
+		return
+	}
+
+	path, err := resolveHostFile(config.AnsibleCfg)
+	if err != nil {
+		ConfigError("Specified ansible.cfg file %v does not exist.", config.AnsibleCfg)
+	}
+
+	config.AnsibleCfg = path
+
+}
+
+// MapInventoryFile will resolve the inventory file to an absolute path
+// on the host, and will fail if the file does not exist. When running
+// inside the container, the mounted inventory file is used as the
+// inventory and all hosts are connected to locally.
+func MapInventoryFile(config *AnsibleConfig) {
+
+	if config.InventoryFile == "" {
+		return
+	}
+
+	path, err := resolveHostFile(config.InventoryFile)
+	if err != nil {
+		ConfigError("Specified inventory file %v does not exist.", config.InventoryFile)
+	}
+
+	config.InventoryFile = path
+
+	if !config.Remote && config.Inventory == "" {
+		config.Inventory = inventoryPath
 	}
 
 }
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the number of distributions suggested when the
+// name of a distribution isn't found.
+const maxSuggestions = 3
+
+// normalizeDistribution will return the name of a distribution without
+// case, dots, dashes, underscores or spaces, ie ubuntu22.04 is ubuntu2204.
+func normalizeDistribution(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '-', '_', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// editDistance will return the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = current[j-1] + 1
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// MatchDistribution will return the name among names of the distribution
+// which was given, which is matched as it is, as an alias, and finally
+// normalized, ie centos-7 is centos7. When nothing matches, the nearest
+// names by edit distance are returned as suggestions instead.
+func MatchDistribution(input string, names []string, aliases map[string]string) (string, []string) {
+
+	known := map[string]bool{}
+	for _, name := range names {
+		known[name] = true
+	}
+
+	lower := strings.ToLower(strings.TrimSpace(input))
+	if known[lower] {
+		return lower, nil
+	}
+	if name, ok := aliases[lower]; ok && known[name] {
+		return name, nil
+	}
+
+	normalized := normalizeDistribution(input)
+	for _, name := range names {
+		if normalizeDistribution(name) == normalized {
+			return name, nil
+		}
+	}
+	for alias, name := range aliases {
+		if normalizeDistribution(alias) == normalized && known[name] {
+			return name, nil
+		}
+	}
+
+	// Names further than a third of their length are not suggested.
+	distances := map[string]int{}
+	suggestions := []string{}
+	for name := range known {
+		distance := editDistance(normalized, normalizeDistribution(name))
+		if distance <= 2 || distance*3 <= len(name) {
+			distances[name] = distance
+			suggestions = append(suggestions, name)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if distances[suggestions[i]] != distances[suggestions[j]] {
+			return distances[suggestions[i]] < distances[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return "", suggestions
+}
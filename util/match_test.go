@@ -0,0 +1,83 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatchDistribution(t *testing.T) {
+
+	Convey("Matching the names of distributions", t, func() {
+
+		names := []string{"centos7", "debian12", "rockylinux9", "ubuntu1804", "ubuntu2004", "ubuntu2204"}
+		aliases := map[string]string{
+			"jammy":         "ubuntu2204",
+			"bookworm":      "debian12",
+			"el9":           "rockylinux9",
+			"debian-latest": "debian12",
+			"el10":          "rockylinux10",
+		}
+
+		Convey("Names, aliases and normalized names are matched", func() {
+			cases := []struct {
+				input string
+				match string
+			}{
+				{"centos7", "centos7"},
+				{"CentOS7", "centos7"},
+				{"centos-7", "centos7"},
+				{"ubuntu22.04", "ubuntu2204"},
+				{" Ubuntu_18.04 ", "ubuntu1804"},
+				{"jammy", "ubuntu2204"},
+				{"Bookworm", "debian12"},
+				{"EL9", "rockylinux9"},
+				{"el-9", "rockylinux9"},
+				{"debian_latest", "debian12"},
+			}
+			for _, c := range cases {
+				match, suggestions := MatchDistribution(c.input, names, aliases)
+				So(match, ShouldEqual, c.match)
+				So(suggestions, ShouldBeEmpty)
+			}
+		})
+
+		Convey("The nearest names are suggested otherwise", func() {
+			cases := []struct {
+				input       string
+				suggestions []string
+			}{
+				{"ubuntu2205", []string{"ubuntu2204", "ubuntu2004", "ubuntu1804"}},
+				{"ubuntu-20.4", []string{"ubuntu2004", "ubuntu2204", "ubuntu1804"}},
+				{"centos8", []string{"centos7"}},
+				{"rocky9", []string{}},
+				{"solaris11", []string{}},
+				{"el10", []string{}},
+			}
+			for _, c := range cases {
+				match, suggestions := MatchDistribution(c.input, names, aliases)
+				So(match, ShouldEqual, "")
+				So(suggestions, ShouldResemble, c.suggestions)
+			}
+		})
+
+		Convey("The edit distance counts insertions, deletions and substitutions", func() {
+			So(editDistance("", ""), ShouldEqual, 0)
+			So(editDistance("centos7", "centos7"), ShouldEqual, 0)
+			So(editDistance("centos7", "centos8"), ShouldEqual, 1)
+			So(editDistance("ubuntu204", "ubuntu2004"), ShouldEqual, 1)
+			So(editDistance("kitten", "sitting"), ShouldEqual, 3)
+			So(editDistance("", "el9"), ShouldEqual, 3)
+		})
+
+		Convey("Distributions are found with suggestions", func() {
+			dist, err := GetDistribution("", "", "", "", "geerlingguy", "Ubuntu-22.04")
+			So(err, ShouldBeNil)
+			So(dist.Container, ShouldEqual, JeffUbuntu2204.Container)
+
+			_, err = GetDistribution("", "", "", "", "geerlingguy", "ubuntu2205")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "did you mean: ubuntu2204, ubuntu1204")
+		})
+	})
+}
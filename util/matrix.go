@@ -0,0 +1,105 @@
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// copyStrings will return a copy of the slice, which is nil for nil.
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
+
+// copyMap will return a copy of the map, which is nil for nil.
+func copyMap(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	result := map[string]string{}
+	for key, value := range values {
+		result[key] = value
+	}
+	return result
+}
+
+// Clone will return a copy of the config which shares none of its
+// slices and maps, as the mappers change them in place, so the config
+// of each distribution of a run starts from the flags.
+func (config *AnsibleConfig) Clone() AnsibleConfig {
+
+	clone := *config
+	clone.GalaxyServers = copyStrings(config.GalaxyServers)
+	clone.GalaxyServerTokens = copyMap(config.GalaxyServerTokens)
+	if config.Dependencies != nil {
+		clone.Dependencies = append([]MetaDependency{}, config.Dependencies...)
+	}
+	clone.LocalDependencies = copyMap(config.LocalDependencies)
+	clone.PlaybookFiles = copyStrings(config.PlaybookFiles)
+	clone.AllowedChangedTasks = copyStrings(config.AllowedChangedTasks)
+	clone.ExtraVars = copyMap(config.ExtraVars)
+	clone.ExtraVarsFiles = copyStrings(config.ExtraVarsFiles)
+	clone.Tags = copyStrings(config.Tags)
+	clone.SkipTags = copyStrings(config.SkipTags)
+	clone.IgnoreWarnings = copyStrings(config.IgnoreWarnings)
+	clone.Env = copyMap(config.Env)
+	clone.Volumes = copyStrings(config.Volumes)
+	clone.Publish = copyStrings(config.Publish)
+	clone.DockerArgs = copyStrings(config.DockerArgs)
+	clone.AnsibleArgs = copyStrings(config.AnsibleArgs)
+	clone.DockerEnv = copyStrings(config.DockerEnv)
+	clone.Tmpfs = copyStrings(config.Tmpfs)
+	clone.BuildArgs = copyStrings(config.BuildArgs)
+
+	if config.StageBudgets != nil {
+		clone.StageBudgets = map[string]time.Duration{}
+		for stage, budget := range config.StageBudgets {
+			clone.StageBudgets[stage] = budget
+		}
+	}
+
+	return clone
+}
+
+// SelectDistributions will return the distributions of a run, which are
+// every distribution with all, those of the family, or those named.
+// Distributions which several users provide are those of the user, and
+// each distribution is only tested once.
+func SelectDistributions(names []string, all bool, family, user string) ([]Distribution, error) {
+
+	distributions := []Distribution{}
+	seen := map[string]bool{}
+	add := func(dist Distribution) {
+		key := dist.User + "/" + dist.Distro
+		if !seen[key] {
+			seen[key] = true
+			distributions = append(distributions, dist)
+		}
+	}
+
+	if all || family != "" {
+		for _, dist := range ListDistributions(family) {
+			if found, ok := findDistro(dist.Distro, user); ok && found.Container == dist.Container {
+				add(dist)
+			}
+		}
+		if len(distributions) == 0 {
+			return nil, fmt.Errorf("there are no distributions of the %v family", family)
+		}
+		return distributions, nil
+	}
+
+	for _, name := range names {
+		dist, err := GetDistribution("", "", "", "", user, name)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+		add(dist)
+	}
+	if len(distributions) == 0 {
+		return nil, fmt.Errorf("no distribution was given")
+	}
+	return distributions, nil
+}
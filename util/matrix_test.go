@@ -0,0 +1,94 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatrix(t *testing.T) {
+
+	Convey("Testing several distributions in a run", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+		engine = &fakeEngine{}
+
+		Convey("Distributions are selected by name", func() {
+			distributions, err := SelectDistributions([]string{"centos7", "ubuntu-18.04", "centos7", "al2023"}, false, "", "fubarhouse")
+			So(err, ShouldBeNil)
+			So(len(distributions), ShouldEqual, 3)
+			So(distributions[0].Container, ShouldEqual, CentOS7.Container)
+			So(distributions[1].Container, ShouldEqual, Ubuntu1804.Container)
+			So(distributions[2].Container, ShouldEqual, AmazonLinux2023.Container)
+
+			_, err = SelectDistributions([]string{"centos7", "solaris11"}, false, "", "fubarhouse")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "solaris11: ")
+		})
+
+		Convey("Every distribution of the user is selected with all", func() {
+			distributions, err := SelectDistributions(nil, true, "", "fubarhouse")
+			So(err, ShouldBeNil)
+			So(distributions, ShouldContain, CentOS7)
+			So(distributions, ShouldNotContain, JeffCentOS7)
+			So(distributions, ShouldContain, JeffRockyLinux9)
+
+			seen := map[string]bool{}
+			for _, dist := range distributions {
+				So(seen[dist.Distro], ShouldBeFalse)
+				seen[dist.Distro] = true
+			}
+		})
+
+		Convey("The distributions of a family are selected", func() {
+			distributions, err := SelectDistributions(nil, false, "redhat", "geerlingguy")
+			So(err, ShouldBeNil)
+			So(distributions, ShouldContain, JeffCentOS7)
+			So(distributions, ShouldContain, Fedora31)
+			for _, dist := range distributions {
+				So(dist.Family.Group, ShouldEqual, "redhat")
+			}
+
+			_, err = SelectDistributions(nil, false, "solaris", "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("The config of each distribution is independent", func() {
+			config := AnsibleConfig{
+				PlaybookFile:  "playbook.yml",
+				PlaybookFiles: []string{"playbook.yml", "tests/second.yml"},
+				ExtraVars:     map[string]string{"greeting": "hello"},
+				Volumes:       []string{"/srv:/srv"},
+				StageBudgets:  map[string]time.Duration{"converge": time.Minute},
+
+				GalaxyServers:      []string{"https://galaxy.example.com"},
+				GalaxyServerTokens: map[string]string{"https://galaxy.example.com": "secret"},
+				Dependencies:       []MetaDependency{{Name: "acme.common"}},
+				LocalDependencies:  map[string]string{"acme.base": "/src/base"},
+			}
+			clone := config.Clone()
+			clone.PlaybookFile = "/etc/ansible/roles/role_under_test/playbook.yml"
+			clone.PlaybookFiles[0] = clone.PlaybookFile
+			clone.ExtraVars["greeting"] = "goodbye"
+			clone.Volumes[0] = "/home/user/srv:/srv"
+			clone.StageBudgets["converge"] = time.Hour
+			clone.GalaxyServers[0] = "https://galaxy.ansible.com"
+			clone.GalaxyServerTokens["https://galaxy.example.com"] = "other"
+			clone.Dependencies[0].Name = "acme.other"
+			clone.LocalDependencies["acme.base"] = "/src/other"
+
+			So(config.PlaybookFile, ShouldEqual, "playbook.yml")
+			So(config.PlaybookFiles, ShouldResemble, []string{"playbook.yml", "tests/second.yml"})
+			So(config.ExtraVars["greeting"], ShouldEqual, "hello")
+			So(config.Volumes, ShouldResemble, []string{"/srv:/srv"})
+			So(config.StageBudgets["converge"], ShouldEqual, time.Minute)
+			So(config.GalaxyServers, ShouldResemble, []string{"https://galaxy.example.com"})
+			So(config.GalaxyServerTokens["https://galaxy.example.com"], ShouldEqual, "secret")
+			So(config.Dependencies[0].Name, ShouldEqual, "acme.common")
+			So(config.LocalDependencies["acme.base"], ShouldEqual, "/src/base")
+			So((&AnsibleConfig{}).Clone().Tags, ShouldBeNil)
+		})
+	})
+}
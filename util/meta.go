@@ -0,0 +1,216 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// MetaPlatform is a platform of galaxy_info.platforms in meta/main.yml,
+// ie EL with the versions 7 and 8.
+type MetaPlatform struct {
+	Name     string   `yaml:"name"`
+	Versions []string `yaml:"versions"`
+}
+
+// platformVersions are the distributions of the versions of each
+// platform, by the lowercase name of the platform. Versions which
+// aren't listed are found by their major version, ie 15.5 is 15.
+var platformVersions = map[string]map[string]string{
+	"el": {
+		"6": "centos6",
+		"7": "centos7",
+		"8": "rockylinux8",
+		"9": "rockylinux9",
+	},
+	"ubuntu": {
+		"precise": "ubuntu1204",
+		"quantal": "ubuntu1210",
+		"raring":  "ubuntu1304",
+		"saucy":   "ubuntu1310",
+		"trusty":  "ubuntu1404",
+		"utopic":  "ubuntu1410",
+		"vivid":   "ubuntu1504",
+		"wily":    "ubuntu1510",
+		"xenial":  "ubuntu1604",
+		"yakkety": "ubuntu1610",
+		"zesty":   "ubuntu1704",
+		"artful":  "ubuntu1710",
+		"bionic":  "ubuntu1804",
+		"cosmic":  "ubuntu1810",
+		"disco":   "ubuntu1904",
+		"focal":   "ubuntu2004",
+		"jammy":   "ubuntu2204",
+		"noble":   "ubuntu2404",
+	},
+	"debian": {
+		"wheezy":   "debian7",
+		"jessie":   "debian8",
+		"stretch":  "debian9",
+		"buster":   "debian10",
+		"bookworm": "debian12",
+	},
+	"fedora": {
+		"24": "fedora24",
+		"25": "fedora25",
+		"26": "fedora26",
+		"27": "fedora27",
+		"28": "fedora28",
+		"29": "fedora29",
+		"30": "fedora30",
+		"31": "fedora31",
+	},
+	"amazon": {
+		"2":    "amazonlinux2",
+		"2023": "amazonlinux2023",
+	},
+	"opensuse": {
+		"15":         "opensuse15",
+		"tumbleweed": "tumbleweed",
+	},
+	"archlinux": {
+		"any": "archlinux",
+	},
+	"alpine": {
+		"3": "alpine3",
+	},
+}
+
+// platformAliases are the other names of the platforms.
+var platformAliases = map[string]string{
+	"amazonlinux":  "amazon",
+	"amazon linux": "amazon",
+	"suse":         "opensuse",
+	"arch":         "archlinux",
+}
+
+// ReadMetaPlatforms will return the platforms of galaxy_info.platforms
+// in the meta/main.yml of the role.
+func ReadMetaPlatforms(role string) ([]MetaPlatform, error) {
+
+	file := filepath.Join(role, "meta", "main.yml")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := struct {
+		GalaxyInfo struct {
+			Platforms []MetaPlatform `yaml:"platforms"`
+		} `yaml:"galaxy_info"`
+	}{}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", file, err)
+	}
+	if len(meta.GalaxyInfo.Platforms) == 0 {
+		return nil, fmt.Errorf("%v has no galaxy_info.platforms", file)
+	}
+	return meta.GalaxyInfo.Platforms, nil
+}
+
+// platformDistros will return the names of the distributions of a
+// version of the platform, which are all its distributions for the
+// version all, or nothing when the version is unknown.
+func platformDistros(platform, version string) []string {
+
+	name := strings.ToLower(platform)
+	if alias, ok := platformAliases[name]; ok {
+		name = alias
+	}
+	versions := platformVersions[name]
+	version = strings.ToLower(version)
+
+	if version == "all" {
+		distros := []string{}
+		for _, distro := range versions {
+			distros = append(distros, distro)
+		}
+		sort.Strings(distros)
+		return distros
+	}
+	if distro, ok := versions[version]; ok {
+		return []string{distro}
+	}
+	if distro, ok := versions[strings.SplitN(version, ".", 2)[0]]; ok {
+		return []string{distro}
+	}
+	return nil
+}
+
+// metaExcluded will return true when the version of the platform or
+// the distribution is excluded, where exclusions are PLATFORM,
+// PLATFORM:VERSION or the name of a distribution.
+func metaExcluded(exclude []string, platform, version, distro string) bool {
+	for _, entry := range exclude {
+		parts := strings.SplitN(entry, ":", 2)
+		switch {
+		case len(parts) == 2 && strings.EqualFold(parts[0], platform) && strings.EqualFold(parts[1], version):
+			return true
+		case len(parts) == 1 && strings.EqualFold(entry, platform):
+			return true
+		case len(parts) == 1 && distro != "" && ResolveAlias(entry) == distro:
+			return true
+		}
+	}
+	return false
+}
+
+// findDistro will return the distribution of the name, preferring that
+// of the user when several users provide it.
+func findDistro(distro, user string) (Distribution, bool) {
+	found, ok := Distribution{}, false
+	for _, dist := range ListDistributions("") {
+		if dist.Distro != distro {
+			continue
+		}
+		if !ok || dist.User == user {
+			found, ok = dist, true
+		}
+	}
+	return found, ok
+}
+
+// MetaDistributions will return the distributions of the platforms, in
+// the order they are declared, along with the platforms which have no
+// distribution, ie "EL 5". Excluded platforms are left out of both.
+func MetaDistributions(platforms []MetaPlatform, user string, exclude []string) ([]Distribution, []string) {
+
+	distributions := []Distribution{}
+	skipped := []string{}
+	seen := map[string]bool{}
+
+	for _, platform := range platforms {
+		versions := platform.Versions
+		if len(versions) == 0 {
+			versions = []string{"all"}
+		}
+		for _, version := range versions {
+			if metaExcluded(exclude, platform.Name, version, "") {
+				continue
+			}
+			distros := platformDistros(platform.Name, version)
+			found := false
+			for _, distro := range distros {
+				dist, ok := findDistro(distro, user)
+				if !ok {
+					continue
+				}
+				found = true
+				if seen[distro] || metaExcluded(exclude, platform.Name, version, distro) {
+					continue
+				}
+				seen[distro] = true
+				distributions = append(distributions, dist)
+			}
+			if !found {
+				skipped = append(skipped, platform.Name+" "+version)
+			}
+		}
+	}
+
+	return distributions, skipped
+}
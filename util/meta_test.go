@@ -0,0 +1,97 @@
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetaPlatforms(t *testing.T) {
+
+	Convey("Testing the platforms of meta/main.yml", t, func() {
+
+		dir, _ := ioutil.TempDir("", "meta")
+		defer os.RemoveAll(dir)
+
+		Convey("The platforms are read from galaxy_info", func() {
+			writeFixture(dir, "meta/main.yml", `
+galaxy_info:
+  role_name: web
+  platforms:
+    - name: EL
+      versions:
+        - "7"
+        - "8"
+`)
+			platforms, err := ReadMetaPlatforms(dir)
+			So(err, ShouldBeNil)
+			So(platforms, ShouldResemble, []MetaPlatform{{Name: "EL", Versions: []string{"7", "8"}}})
+
+			writeFixture(dir, "meta/main.yml", `
+galaxy_info:
+  role_name: web
+`)
+			_, err = ReadMetaPlatforms(dir)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "has no galaxy_info.platforms")
+		})
+
+		Convey("Each version is tested on its closest distribution", func() {
+			platforms := []MetaPlatform{
+				{Name: "EL", Versions: []string{"7", "8"}},
+				{Name: "Ubuntu", Versions: []string{"bionic", "kinetic"}},
+				{Name: "Debian", Versions: []string{"bookworm"}},
+				{Name: "opensuse", Versions: []string{"15.5"}},
+				{Name: "Solaris", Versions: []string{"11"}},
+			}
+			distributions, skipped := MetaDistributions(platforms, "fubarhouse", nil)
+			names := []string{}
+			for _, dist := range distributions {
+				names = append(names, dist.Distro)
+			}
+			So(names, ShouldResemble, []string{"centos7", "rockylinux8", "ubuntu1804", "debian12", "opensuse15"})
+			So(distributions[0].User, ShouldEqual, "fubarhouse")
+			So(skipped, ShouldResemble, []string{"Ubuntu kinetic", "Solaris 11"})
+
+			distributions, _ = MetaDistributions(platforms, "geerlingguy", nil)
+			So(distributions[0].Container, ShouldEqual, JeffCentOS7.Container)
+		})
+
+		Convey("Every version of a platform is tested for all", func() {
+			distributions, skipped := MetaDistributions([]MetaPlatform{{Name: "Amazon", Versions: []string{"all"}}, {Name: "ArchLinux"}}, "", nil)
+			So(len(distributions), ShouldEqual, 3)
+			So(distributions[0].Distro, ShouldEqual, "amazonlinux2")
+			So(distributions[2].Distro, ShouldEqual, "archlinux")
+			So(skipped, ShouldBeEmpty)
+		})
+
+		Convey("Platforms, versions and distributions are excluded", func() {
+			platforms := []MetaPlatform{
+				{Name: "EL", Versions: []string{"7", "8", "9"}},
+				{Name: "Ubuntu", Versions: []string{"focal", "jammy"}},
+				{Name: "Fedora", Versions: []string{"all"}},
+			}
+			distributions, skipped := MetaDistributions(platforms, "fubarhouse", []string{"el:7", "rocky9", "Ubuntu:jammy", "fedora"})
+			So(len(distributions), ShouldEqual, 2)
+			So(distributions[0].Distro, ShouldEqual, "rockylinux8")
+			So(distributions[1].Distro, ShouldEqual, "ubuntu2004")
+			So(skipped, ShouldBeEmpty)
+		})
+
+		Convey("Skipped platforms are reported", func() {
+			passed, _ := aggregateReports()
+			aggregate := NewAggregateReport(false)
+			aggregate.Add(passed)
+			aggregate.Skipped = []string{"Ubuntu jammy"}
+			So(aggregate.Summary(false), ShouldContainSubstring, "Skipped: Ubuntu jammy")
+			So(aggregate.Passed(), ShouldBeTrue)
+
+			data, err := json.Marshal(aggregate.NewJSONReport())
+			So(err, ShouldBeNil)
+			So(string(data), ShouldContainSubstring, `"skipped_platforms":["Ubuntu jammy"]`)
+		})
+	})
+}
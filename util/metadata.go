@@ -0,0 +1,70 @@
+package util
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Version is the version of ansible-role-tester, which is set
+// when building a release with -ldflags "-X .../util.Version=x".
+var Version = "dev"
+
+// CollectMetadata will record what the role is tested with in the
+// report before the first stage runs, which is the version of this
+// tool, the image digest of the container, the versions of Ansible in
+// the container and on the host, and the git commit of the role.
+// Anything which cannot be found is left empty.
+func (report *AnsibleReport) CollectMetadata(config *AnsibleConfig, dist *Distribution) {
+
+	report.Meta.ToolVersion = Version
+	report.Meta.ImageDigest = dist.imageDigest()
+
+	if version, err := dist.AnsibleVersion(config); err == nil {
+		report.Ansible.Version = version
+		report.Meta.AnsibleVersion = version
+	} else if !config.Quiet {
+		log.Warnf("could not find the Ansible version: %v", err)
+	}
+	if out, err := exec.Command("ansible-playbook", "--version").Output(); err == nil {
+		report.Meta.HostAnsibleVersion, _ = parseAnsibleVersion(string(out))
+	}
+
+	if commit := gitOutput(config.HostPath, "rev-parse", "HEAD"); commit != "" {
+		report.Meta.CommitHash = commit
+		report.Meta.Repository = redactURL(gitOutput(config.HostPath, "ls-remote", "--get-url", "origin"))
+		report.Meta.LocalChanges = gitOutput(config.HostPath, "status", "--porcelain") != ""
+	}
+}
+
+// imageDigest will return the repository digest of the image the
+// container was created from, or the image id for local images.
+func (dist *Distribution) imageDigest() string {
+
+	var out bytes.Buffer
+	if err := engineCommand(inspectCommand{Kind: "container", Field: fieldImage, Name: dist.CID}, false, &out); err != nil {
+		return ""
+	}
+	id := strings.TrimSpace(out.String())
+
+	out.Reset()
+	err := engineCommand(inspectCommand{Kind: "image", Field: fieldDigests, Name: id}, false, &out)
+	if digests := strings.Fields(out.String()); err == nil && len(digests) > 0 {
+		return digests[0]
+	}
+	return id
+}
+
+// gitOutput will return the trimmed output of the git command in
+// the path, or an empty string if git is missing or the command fails.
+func gitOutput(path string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
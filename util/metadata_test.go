@@ -0,0 +1,45 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetadata(t *testing.T) {
+
+	Convey("Collecting the metadata of the role", t, func() {
+
+		Convey("Git output is empty outside of a repository", func() {
+			dir, _ := ioutil.TempDir("", "ansible-role-tester")
+			defer os.RemoveAll(dir)
+			So(gitOutput(dir, "rev-parse", "HEAD"), ShouldEqual, "")
+		})
+
+		Convey("The commit of a repository is found", func() {
+			if _, err := exec.LookPath("git"); err != nil {
+				return
+			}
+			dir, _ := ioutil.TempDir("", "ansible-role-tester")
+			defer os.RemoveAll(dir)
+			gitOutput(dir, "init")
+			gitOutput(dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--allow-empty", "-m", "test")
+			So(gitOutput(dir, "rev-parse", "HEAD"), ShouldHaveLength, 40)
+		})
+
+		Convey("The metadata is in the JSON report", func() {
+			report := AnsibleReport{}
+			report.Meta.ToolVersion = "1.0.0"
+			report.Meta.ImageDigest = "fubarhouse/docker-ansible@sha256:0123"
+			report.Meta.CommitHash = "abc123"
+
+			result := report.NewJSONReport()
+			So(result.Metadata.ToolVersion, ShouldEqual, "1.0.0")
+			So(result.Metadata.ImageDigest, ShouldEqual, "fubarhouse/docker-ansible@sha256:0123")
+			So(result.Metadata.Commit, ShouldEqual, "abc123")
+		})
+	})
+}
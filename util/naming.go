@@ -0,0 +1,98 @@
+package util
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+
+	// roleLabel is the label of the name of the role under test.
+	roleLabel = "art.role"
+
+	// distributionLabel is the label of the name of the distribution.
+	distributionLabel = "art.distribution"
+
+	// runLabel is the label of the id of the run which created the
+	// container, so the containers of a run can always be found.
+	runLabel = "art.run-id"
+)
+
+// RunID is the id of this run of the tool, which is a random UUID.
+var RunID = newRunID()
+
+// newRunID will return a random (version 4) UUID.
+func newRunID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+// invalidNameCharacters are the characters which docker doesn't
+// allow in the name of a container.
+var invalidNameCharacters = regexp.MustCompile("[^a-z0-9_.-]+")
+
+// ContainerName will return the name of the container of the role and
+// distribution, which is art-<role>-<distribution>-<id of the run>.
+func ContainerName(dist *Distribution, config *AnsibleConfig) string {
+	name := dist.Name
+	if name == "" {
+		name = "custom"
+	}
+	parts := []string{"art", config.ResolveRoleName(), name, RunID[:8]}
+	for i, part := range parts {
+		parts[i] = strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(part), "_"), "_.-")
+	}
+	return strings.Join(parts, "-")
+}
+
+// ownershipLabels will return the labels which identify the role,
+// distribution and run which created the container.
+func ownershipLabels(dist *Distribution, config *AnsibleConfig) []string {
+	return []string{
+		roleLabel + "=" + config.ResolveRoleName(),
+		distributionLabel + "=" + dist.Name,
+		runLabel + "=" + RunID,
+	}
+}
+
+// runContainers will return the names of the containers which this
+// run created for the role and distribution, including stopped ones.
+func (dist *Distribution) runContainers(config *AnsibleConfig) []string {
+
+	ps := psCommand{All: true}
+	for _, label := range ownershipLabels(dist, config) {
+		ps.Filters = append(ps.Filters, "label="+label)
+	}
+
+	out, err := config.dockerExec(ps, false)
+	if err != nil {
+		return []string{}
+	}
+	return strings.Fields(out)
+}
+
+// DockerRemoveRun will remove the containers which this run created for
+// the role and distribution, other than the container of the
+// distribution, so containers are not left behind when the container
+// of the distribution was lost track of, ie when it stopped.
+func (dist *Distribution) DockerRemoveRun(config *AnsibleConfig) {
+
+	for _, name := range dist.runContainers(config) {
+		if name == dist.CID && dist.DockerCheck() {
+			continue
+		}
+		if !config.Quiet {
+			log.Printf("Removing %v\n", name)
+		}
+		if _, err := config.dockerExec(rmCommand{Container: name, Force: true}, false); err != nil {
+			log.Errorln(err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestContainerName(t *testing.T) {
+
+	Convey("Naming and labelling containers by their owner", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", Quiet: true}
+
+		Convey("Run ids are random UUIDs", func() {
+			So(RunID, ShouldNotEqual, newRunID())
+			So(len(RunID), ShouldEqual, 36)
+			So(RunID[14:15], ShouldEqual, "4")
+		})
+
+		Convey("Containers are named by the role, distribution and run", func() {
+			dist := Distribution{Name: "ubuntu1804"}
+			So(ContainerName(&dist, &config), ShouldEqual, "art-web-ubuntu1804-"+RunID[:8])
+		})
+
+		Convey("Names only have characters docker allows", func() {
+			dist := Distribution{}
+			named := config
+			named.RoleName = "Acme.Web Server"
+			So(ContainerName(&dist, &named), ShouldEqual, "art-acme.web_server-custom-"+RunID[:8])
+		})
+
+		Convey("Containers are named and labelled when they are run", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{Name: "ubuntu1804", Container: "fubarhouse/docker-ansible:bionic", Family: Ubuntu}
+
+			So(dist.DockerRun(&config, &AnsibleReport{}), ShouldBeTrue)
+			So(dist.CID, ShouldEqual, "art-web-ubuntu1804-"+RunID[:8])
+			So(fake.commands[2], ShouldContain, "--label=art.role=web")
+			So(fake.commands[2], ShouldContain, "--label=art.distribution=ubuntu1804")
+			So(fake.commands[2], ShouldContain, "--label=art.run-id="+RunID)
+		})
+
+		Convey("Containers of the run are removed by their labels", func() {
+			fake := &fakeEngine{running: []string{"test"}, labelled: []string{"test", "leaked"}}
+			engine = fake
+			dist := Distribution{CID: "test", Name: "ubuntu1804"}
+			report := AnsibleReport{}
+
+			dist.DockerCleanup(&config, &report)
+			So(fake.commands, ShouldContain, []string{"ps", "-a", "--filter", "label=art.role=web", "--filter", "label=art.distribution=ubuntu1804", "--filter", "label=art.run-id=" + RunID, "--format", "{{.Names}}"})
+			So(fake.commands, ShouldContain, []string{"rm", "--force", "leaked"})
+		})
+
+		Convey("Reports have the id of the run", func() {
+			report := NewReport(&config)
+			So(report.NewJSONReport().RunID, ShouldEqual, RunID)
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// builtinNetworks are the networks of docker, which always exist.
+var builtinNetworks = []string{"bridge", "host", "none"}
+
+// networkExists will return true when the docker network exists.
+func networkExists(network string) bool {
+	for _, builtin := range builtinNetworks {
+		if network == builtin {
+			return true
+		}
+	}
+	return engineCommand(inspectCommand{Kind: "network", Field: fieldName, Name: network}, false, ioutil.Discard) == nil
+}
+
+// DockerNetwork will create the network of the config when it doesn't
+// exist, so the container can reach other containers on the network by
+// name. The network and whether it was created are recorded in the report.
+func (dist *Distribution) DockerNetwork(config *AnsibleConfig, report *AnsibleReport) error {
+
+	if config.Network == "" {
+		return nil
+	}
+	report.Docker.Network = config.Network
+
+	if networkExists(config.Network) {
+		return nil
+	}
+
+	if !config.Quiet {
+		log.Printf("Creating network %v", config.Network)
+	}
+	if _, err := config.dockerExec(networkCreateCommand{Name: config.Network, Labels: []string{fmt.Sprintf("%v=true", containerLabel)}}, false); err != nil {
+		return err
+	}
+	report.Docker.NetworkCreated = true
+
+	return nil
+}
+
+// DockerNetworkRemove will remove the network when it was created for
+// the container, networks which already existed are never removed.
+func (dist *Distribution) DockerNetworkRemove(config *AnsibleConfig, report *AnsibleReport) {
+
+	if !report.Docker.NetworkCreated {
+		return
+	}
+
+	if !config.Quiet {
+		log.Printf("Removing network %v", report.Docker.Network)
+	}
+	if _, err := config.dockerExec(networkRmCommand{Name: report.Docker.Network}, false); err == nil {
+		report.Docker.NetworkCreated = false
+	}
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerNetwork(t *testing.T) {
+
+	Convey("Running the container on a docker network", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: cgroupVolume}}
+
+		Convey("Missing networks are created and removed with the container", func() {
+			fake := &fakeEngine{running: []string{"test"}}
+			engine = fake
+			config := AnsibleConfig{Network: "scenario", Quiet: true}
+			report := AnsibleReport{}
+
+			So(dist.DockerNetwork(&config, &report), ShouldBeNil)
+			So(fake.commands, ShouldContain, []string{"network", "create", "--label=ansible-role-tester=true", "scenario"})
+			So(report.Docker.Network, ShouldEqual, "scenario")
+			So(report.Docker.NetworkCreated, ShouldBeTrue)
+			So(report.NewJSONReport().Network, ShouldEqual, "scenario")
+
+			fake.running = []string{}
+			dist.DockerCleanup(&config, &report)
+			So(fake.commands, ShouldContain, []string{"network", "rm", "scenario"})
+			So(fake.networks["scenario"], ShouldBeFalse)
+		})
+
+		Convey("Existing networks are never removed", func() {
+			fake := &fakeEngine{networks: map[string]bool{"database": true}}
+			engine = fake
+			config := AnsibleConfig{Network: "database", Quiet: true}
+			report := AnsibleReport{}
+
+			So(dist.DockerNetwork(&config, &report), ShouldBeNil)
+			So(report.Docker.NetworkCreated, ShouldBeFalse)
+
+			dist.DockerCleanup(&config, &report)
+			So(fake.commands, ShouldNotContain, []string{"network", "rm", "database"})
+			So(fake.networks["database"], ShouldBeTrue)
+		})
+
+		Convey("The host network is used as it is", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", Network: "host", Quiet: true}
+			report := AnsibleReport{}
+
+			So(dist.DockerNetwork(&config, &report), ShouldBeNil)
+			So(fake.commands, ShouldBeEmpty)
+			So(buildRunCommand(&dist, &config, &report).args(), ShouldContain, "--network=host")
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// notifyTimeout is the time a webhook has to accept the
+// notification before it is retried once.
+var notifyTimeout = 10 * time.Second
+
+// NotifyPayload is the JSON payload of the webhook notification
+// which is sent once the run completes.
+type NotifyPayload struct {
+
+	// Role is the name of the role which was tested.
+	Role string `json:"role"`
+
+	// Passed indicates the tests passed on every distribution.
+	Passed bool `json:"passed"`
+
+	// Duration is the wall time of the whole run in seconds.
+	Duration float64 `json:"duration_seconds"`
+
+	// LogDir is the directory the output was written to, if any.
+	LogDir string `json:"log_dir,omitempty"`
+
+	// Distributions are the results of each distribution.
+	Distributions []NotifyDistribution `json:"distributions"`
+}
+
+// NotifyDistribution is the result of a distribution in the notification.
+type NotifyDistribution struct {
+
+	// Name is the name of the distribution, ie centos7.
+	Name string `json:"name"`
+
+	// Passed indicates every stage passed or was skipped.
+	Passed bool `json:"passed"`
+
+	// Stages is the status of each stage, keyed by stage.
+	Stages map[string]string `json:"stages"`
+}
+
+// NewNotifyPayload will return the webhook payload of the reports.
+func (aggregate *AggregateReport) NewNotifyPayload() NotifyPayload {
+
+	payload := NotifyPayload{
+		Passed:        aggregate.Passed(),
+		Duration:      aggregate.Duration().Seconds(),
+		Distributions: []NotifyDistribution{},
+	}
+
+	for i := range aggregate.Reports {
+		report := &aggregate.Reports[i]
+		if payload.Role == "" {
+			payload.Role = report.Ansible.Config.ResolveRoleName()
+			payload.LogDir = report.Ansible.Config.LogDir
+		}
+
+		distribution := NotifyDistribution{
+			Name:   report.distributionName(),
+			Passed: report.ExitCode() == OKCode,
+			Stages: map[string]string{},
+		}
+		for _, stage := range report.stageResults() {
+			distribution.Stages[stage.name] = report.stageStatus(stage)
+		}
+		payload.Distributions = append(payload.Distributions, distribution)
+	}
+
+	return payload
+}
+
+// Notify will POST the webhook payload of the reports to the url,
+// which is retried once if it fails or times out.
+func (aggregate *AggregateReport) Notify(url string) error {
+
+	data, err := json.Marshal(aggregate.NewNotifyPayload())
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: notifyTimeout}
+	for attempt := 1; ; attempt++ {
+		err = postNotification(&client, url, data)
+		if err == nil || attempt == 2 {
+			return err
+		}
+	}
+}
+
+// postNotification will POST the payload to the url, where any
+// response other than a 2xx status is an error.
+func postNotification(client *http.Client, url string, data []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %v returned %v", url, resp.Status)
+	}
+	return nil
+}
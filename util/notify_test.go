@@ -0,0 +1,62 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNotify(t *testing.T) {
+
+	Convey("Notifying a webhook when the run completes", t, func() {
+
+		Convey("The payload has the result of each distribution", func() {
+			var payload map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&payload)
+			}))
+			defer server.Close()
+
+			passed, failed := aggregateReports()
+			passed.Ansible.Config.RoleName = "fubarhouse.web"
+			passed.Ansible.Config.LogDir = "logs"
+			aggregate := &AggregateReport{Reports: []AnsibleReport{passed, failed}}
+			aggregate.Finished = aggregate.Started.Add(2 * time.Minute)
+
+			So(aggregate.Notify(server.URL), ShouldBeNil)
+			So(payload["role"], ShouldEqual, "fubarhouse.web")
+			So(payload["passed"], ShouldBeFalse)
+			So(payload["duration_seconds"], ShouldEqual, 120.0)
+			So(payload["log_dir"], ShouldEqual, "logs")
+			So(payload["distributions"], ShouldResemble, []interface{}{
+				map[string]interface{}{
+					"name":   "ubuntu1804",
+					"passed": true,
+					"stages": map[string]interface{}{"syntax": "passed", "converge": "passed", "idempotence": "passed"},
+				},
+				map[string]interface{}{
+					"name":   "centos7",
+					"passed": false,
+					"stages": map[string]interface{}{"syntax": "passed", "converge": "passed", "idempotence": "failed"},
+				},
+			})
+		})
+
+		Convey("A failed notification is retried once", func() {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer server.Close()
+
+			aggregate := &AggregateReport{}
+			So(aggregate.Notify(server.URL), ShouldNotBeNil)
+			So(requests, ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigOverride is the settings of a distribution in the overrides of
+// the distributions file, which replace those of the flags when the
+// distribution is tested. Variables and environment variables are
+// merged with those of the flags instead.
+type ConfigOverride struct {
+	Playbook              *string           `yaml:"playbook"`
+	Playbooks             []string          `yaml:"playbooks"`
+	PreparePlaybook       *string           `yaml:"prepare_playbook"`
+	VerifyPlaybook        *string           `yaml:"verify_playbook"`
+	CleanupPlaybook       *string           `yaml:"cleanup_playbook"`
+	ExtraVars             map[string]string `yaml:"extra_vars"`
+	ExtraVarsFiles        []string          `yaml:"extra_vars_files"`
+	Tags                  []string          `yaml:"tags"`
+	SkipTags              []string          `yaml:"skip_tags"`
+	Env                   map[string]string `yaml:"env"`
+	SkipSyntax            *bool             `yaml:"skip_syntax"`
+	SkipConverge          *bool             `yaml:"skip_converge"`
+	SkipIdempotence       *bool             `yaml:"skip_idempotence"`
+	IdempotencePasses     *int              `yaml:"idempotence_passes"`
+	IdempotenceMaxChanged *int              `yaml:"idempotence_max_changed"`
+	AllowedChangedTasks   []string          `yaml:"allow_changed_tasks"`
+	Strict                *bool             `yaml:"strict"`
+}
+
+// distributionOverrides are the overrides of the distributions file,
+// by the name of the distribution.
+var distributionOverrides = map[string]ConfigOverride{}
+
+// ReadOverrides will read the overrides of the distributions file,
+// which are keyed by the name or alias of the distribution.
+func ReadOverrides(file string) (map[string]ConfigOverride, error) {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	document := struct {
+		Overrides map[string]ConfigOverride `yaml:"overrides"`
+	}{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", file, err)
+	}
+
+	overrides := map[string]ConfigOverride{}
+	unknown := []string{}
+	for name, override := range document.Overrides {
+		distro := ResolveAlias(name)
+		if _, ok := findDistro(distro, ""); !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if override.IdempotencePasses != nil && *override.IdempotencePasses < 1 {
+			return nil, fmt.Errorf("%v: the idempotence passes of %v must be at least 1", file, name)
+		}
+		overrides[distro] = override
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%v: overrides of unknown distributions: %v", file, strings.Join(unknown, ", "))
+	}
+	return overrides, nil
+}
+
+// SetOverrides will set the overrides which are applied to the config
+// of each distribution.
+func SetOverrides(overrides map[string]ConfigOverride) {
+	distributionOverrides = overrides
+}
+
+// ApplyOverrides will merge the overrides of the distribution over the
+// config, returning false when the distribution has none. The config
+// must not share its maps, ie it is a clone.
+func ApplyOverrides(config *AnsibleConfig, distro string) bool {
+
+	override, ok := distributionOverrides[distro]
+	if !ok {
+		return false
+	}
+
+	// A playbook replaces all of the playbooks.
+	if override.Playbook != nil {
+		config.PlaybookFile = *override.Playbook
+		config.PlaybookFiles = nil
+	}
+	if override.Playbooks != nil {
+		config.PlaybookFiles = copyStrings(override.Playbooks)
+	}
+	if override.PreparePlaybook != nil {
+		config.PreparePlaybook = *override.PreparePlaybook
+	}
+	if override.VerifyPlaybook != nil {
+		config.VerifyPlaybook = *override.VerifyPlaybook
+	}
+	if override.CleanupPlaybook != nil {
+		config.CleanupPlaybook = *override.CleanupPlaybook
+	}
+
+	if len(override.ExtraVars) > 0 && config.ExtraVars == nil {
+		config.ExtraVars = map[string]string{}
+	}
+	for key, value := range override.ExtraVars {
+		config.ExtraVars[key] = value
+	}
+	if override.ExtraVarsFiles != nil {
+		config.ExtraVarsFiles = copyStrings(override.ExtraVarsFiles)
+	}
+	if override.Tags != nil {
+		config.Tags = copyStrings(override.Tags)
+	}
+	if override.SkipTags != nil {
+		config.SkipTags = copyStrings(override.SkipTags)
+	}
+	if len(override.Env) > 0 && config.Env == nil {
+		config.Env = map[string]string{}
+	}
+	for key, value := range override.Env {
+		config.Env[key] = value
+	}
+
+	if override.SkipSyntax != nil {
+		config.SkipSyntax = *override.SkipSyntax
+	}
+	if override.SkipConverge != nil {
+		config.SkipConverge = *override.SkipConverge
+	}
+	if override.SkipIdempotence != nil {
+		config.SkipIdempotence = *override.SkipIdempotence
+	}
+	if override.IdempotencePasses != nil {
+		config.IdempotencePasses = *override.IdempotencePasses
+	}
+	if override.IdempotenceMaxChanged != nil {
+		config.IdempotenceMaxChanged = *override.IdempotenceMaxChanged
+	}
+	if override.AllowedChangedTasks != nil {
+		config.AllowedChangedTasks = copyStrings(override.AllowedChangedTasks)
+	}
+	if override.Strict != nil {
+		config.Strict = *override.Strict
+	}
+
+	return true
+}
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOverrides(t *testing.T) {
+
+	Convey("Overriding the config of a distribution", t, func() {
+
+		builtin, overrides := Distributions, distributionOverrides
+		defer func() { Distributions, distributionOverrides = builtin, overrides }()
+
+		dir, _ := ioutil.TempDir("", "overrides")
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, DistributionsFile)
+
+		Convey("Overrides are merged over the config", func() {
+			writeFixture(dir, DistributionsFile, `
+overrides:
+  el8:
+    extra_vars_files:
+      - vars/el.yml
+    extra_vars:
+      repo: epel
+    skip_idempotence: true
+  alpine3:
+    verify_playbook: tests/verify_alpine.yml
+`)
+			So(LoadDistributions(file, dir), ShouldBeNil)
+
+			base := AnsibleConfig{
+				ExtraVars:      map[string]string{"user": "root"},
+				ExtraVarsFiles: []string{"vars/common.yml"},
+				VerifyPlaybook: "tests/verify.yml",
+			}
+
+			config := base.Clone()
+			So(ApplyOverrides(&config, "rockylinux8"), ShouldBeTrue)
+			So(config.ExtraVarsFiles, ShouldResemble, []string{"vars/el.yml"})
+			So(config.ExtraVars, ShouldResemble, map[string]string{"user": "root", "repo": "epel"})
+			So(config.SkipIdempotence, ShouldBeTrue)
+			So(config.VerifyPlaybook, ShouldEqual, "tests/verify.yml")
+			So(base.ExtraVars, ShouldResemble, map[string]string{"user": "root"})
+
+			config = base.Clone()
+			So(ApplyOverrides(&config, "alpine3"), ShouldBeTrue)
+			So(config.VerifyPlaybook, ShouldEqual, "tests/verify_alpine.yml")
+			So(config.SkipIdempotence, ShouldBeFalse)
+
+			config = base.Clone()
+			So(ApplyOverrides(&config, "ubuntu1804"), ShouldBeFalse)
+			So(config, ShouldResemble, base.Clone())
+		})
+
+		Convey("Overrides of unknown distributions are rejected", func() {
+			writeFixture(dir, DistributionsFile, `
+overrides:
+  ubuntu1804: {}
+  solaris11: {}
+`)
+			err := LoadDistributions(file, dir)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "overrides of unknown distributions: solaris11")
+		})
+
+		Convey("Overrides of custom distributions are known", func() {
+			writeFixture(dir, DistributionsFile, `
+distributions:
+  - name: internal8
+    image: registry.example.com/base/centos:8
+    family: centos
+overrides:
+  internal8:
+    playbook: tests/internal.yml
+`)
+			So(LoadDistributions(file, dir), ShouldBeNil)
+
+			config := AnsibleConfig{PlaybookFiles: []string{"converge.yml", "verify.yml"}}
+			So(ApplyOverrides(&config, "internal8"), ShouldBeTrue)
+			So(config.PlaybookFile, ShouldEqual, "tests/internal.yml")
+			So(config.PlaybookFiles, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,282 @@
+package util
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The status of a stage in the reports.
+const (
+	StagePassed  = "passed"
+	StageFailed  = "failed"
+	StageSkipped = "skipped"
+	StageNotRun  = "not_run"
+)
+
+// Stage is a single stage of the test pipeline, which will
+// record its own result in the report when it is run.
+type Stage struct {
+	Name string
+	Skip bool
+	Run  func() bool
+}
+
+// Stages will return the ordered stages of the test pipeline for the
+// role, which are requirements, syntax, converge, idempotence and verify.
+// Stages which are not configured (ie no verify playbook) are left out,
+// and stages which are disabled with a skip flag are marked as skipped.
+func (dist *Distribution) Stages(config *AnsibleConfig, report *AnsibleReport) []Stage {
+
+	stages := []Stage{}
+
+	// The requirements are installed first, as the syntax check fails
+	// on roles and modules which are not installed.
+	if config.RequirementsFile != "" || config.installsDependencies() || config.installsCollections() {
+		report.Ansible.Requirements.Enabled = true
+		stages = append(stages, Stage{
+			Name: "requirements",
+			Run: func() bool {
+				report.Ansible.Requirements.Result, report.Ansible.Requirements.Time = dist.RoleRequirements(config, report)
+				return report.Ansible.Requirements.Result
+			},
+		})
+	}
+
+	stages = append(stages, Stage{
+		Name: "syntax",
+		Skip: config.SkipSyntax,
+		Run: func() bool {
+			if config.Remote {
+				report.Ansible.Syntax = dist.RoleSyntaxCheckRemote(config, report)
+			} else {
+				report.Ansible.Syntax = dist.RoleSyntaxCheck(config, report)
+			}
+			return report.Ansible.Syntax
+		},
+	})
+
+	if report.Ansible.Prepare.Enabled {
+		stages = append(stages, Stage{
+			Name: "prepare",
+			// A reused container was prepared when it was created.
+			Skip: config.SkipConverge || report.Docker.Reused,
+			Run: func() bool {
+				report.Ansible.Prepare.Result, report.Ansible.Prepare.Time = dist.RolePrepare(config)
+				return report.Ansible.Prepare.Result
+			},
+		})
+	}
+
+	if report.Ansible.Check.Enabled {
+		stages = append(stages, Stage{
+			Name: "check",
+			Skip: config.SkipConverge,
+			Run: func() bool {
+				if config.Remote {
+					report.Ansible.Check.Result, report.Ansible.Check.Time = dist.CheckTestRemote(config)
+				} else {
+					report.Ansible.Check.Result, report.Ansible.Check.Time = dist.CheckTest(config)
+				}
+				return report.Ansible.Check.Result
+			},
+		})
+	}
+
+	stages = append(stages, Stage{
+		Name: "converge",
+		Skip: config.SkipConverge,
+		Run: func() bool {
+			if config.Remote {
+				report.Ansible.Run.Result, report.Ansible.Run.Time = dist.RoleTestRemote(config, report)
+			} else {
+				report.Ansible.Run.Result, report.Ansible.Run.Time = dist.RoleTest(config, report)
+			}
+			return report.Ansible.Run.Result
+		},
+	})
+
+	stages = append(stages, Stage{
+		Name: "idempotence",
+		Skip: config.SkipIdempotence,
+		Run: func() bool {
+			if config.Remote {
+				report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time = dist.IdempotenceTestRemote(config, report)
+			} else {
+				report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time = dist.IdempotenceTest(config, report)
+			}
+			return report.Ansible.Idempotence.Result
+		},
+	})
+
+	if config.VerifyPlaybook != "" {
+		stages = append(stages, Stage{
+			Name: "verify",
+			Run: func() bool {
+				if config.Remote {
+					report.Ansible.Verify.Result, report.Ansible.Verify.Time = dist.RoleVerifyRemote(config, report)
+				} else {
+					report.Ansible.Verify.Result, report.Ansible.Verify.Time = dist.RoleVerify(config, report)
+				}
+				return report.Ansible.Verify.Result
+			},
+		})
+	}
+
+	return stages
+}
+
+// RunStages will run the stages in order until one of them fails.
+// Skipped stages are recorded in the report and do not stop the
+// stages after them, returns false if any stage failed. Stages which
+// exceed their duration budget do not stop the stages after them.
+func (report *AnsibleReport) RunStages(config *AnsibleConfig, stages []Stage) bool {
+
+	if config.SkipConverge && !config.SkipIdempotence && !config.Quiet {
+		log.Warnln("The converge stage is skipped, the idempotence test will run against a role which may not have been applied.")
+	}
+
+	if config.PrefixOutput {
+		output := config.output()
+		output.prefix = report.linePrefix()
+		defer func() { output.prefix = "" }()
+	}
+
+	// The budgets are checked for the stages which ran, even
+	// when a stage failed.
+	defer report.checkBudgets(config)
+
+	for _, stage := range stages {
+		if stage.Skip {
+			if !config.Quiet {
+				log.Infof("Skipping the %v stage", stage.Name)
+			}
+			report.Ansible.Skipped = append(report.Ansible.Skipped, stage.Name)
+			continue
+		}
+		report.Ansible.Stages = append(report.Ansible.Stages, stage.Name)
+		closeLog := report.openStageLog(config, stage.Name)
+		endStage := config.startStage()
+		result := stage.Run()
+		endStage()
+		closeLog()
+
+		// The remaining stages are not run once the run was interrupted.
+		if Interrupted() {
+			report.Ansible.Interrupted = true
+			if !config.Quiet {
+				log.Warnf("The run was interrupted during the %v stage", stage.Name)
+			}
+			return false
+		}
+		if !result {
+			return false
+		}
+	}
+
+	return true
+}
+
+// startStage will start the timeout of a stage, which bounds every
+// command of the stage together, ie each playbook, the idempotence run
+// and the retries of the converge. The returned func ends the stage.
+func (config *AnsibleConfig) startStage() func() {
+	if config.Timeout <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	config.stage = ctx
+	return func() {
+		cancel()
+		config.stage = nil
+	}
+}
+
+// stageContext will return the context of the commands of the stage
+// which runs, which is done once the timeout of the stage is reached.
+// Commands outside the stages, such as running the container, have no
+// timeout.
+func (config *AnsibleConfig) stageContext() context.Context {
+	if config.stage == nil {
+		return context.Background()
+	}
+	return config.stage
+}
+
+// Skipped will identify if the stage was skipped.
+func (report *AnsibleReport) Skipped(stage string) bool {
+	for _, skipped := range report.Ansible.Skipped {
+		if skipped == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Ran will identify if the stage was run.
+func (report *AnsibleReport) Ran(stage string) bool {
+	for _, ran := range report.Ansible.Stages {
+		if ran == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Passed will identify if the stage passed, where a skipped stage
+// is not a failure so the tests after it can still pass.
+func (report *AnsibleReport) Passed(stage string, result bool) bool {
+	return result || report.Skipped(stage)
+}
+
+// stageResult is the result of a stage as it appears in the report.
+type stageResult struct {
+	name   string
+	result bool
+	time   time.Duration
+	stats  PlayStats
+}
+
+// stageResults will return the stages of the tests in the order they
+// are run, leaving out the optional stages which are not enabled.
+func (report *AnsibleReport) stageResults() []stageResult {
+
+	stages := []stageResult{}
+	if report.Ansible.Requirements.Enabled {
+		stages = append(stages, stageResult{"requirements", report.Ansible.Requirements.Result, report.Ansible.Requirements.Time, nil})
+	}
+	stages = append(stages, stageResult{"syntax", report.Ansible.Syntax, 0, nil})
+
+	if report.Ansible.Prepare.Enabled {
+		stages = append(stages, stageResult{"prepare", report.Ansible.Prepare.Result, report.Ansible.Prepare.Time, nil})
+	}
+	if report.Ansible.Check.Enabled {
+		stages = append(stages, stageResult{"check", report.Ansible.Check.Result, report.Ansible.Check.Time, nil})
+	}
+
+	stages = append(stages,
+		stageResult{"converge", report.Ansible.Run.Result, report.Ansible.Run.Time, report.Ansible.Run.Stats},
+		stageResult{"idempotence", report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time, report.Ansible.Idempotence.Stats},
+	)
+
+	if report.Ansible.Verify.Enabled || report.Ansible.Config.VerifyPlaybook != "" {
+		stages = append(stages, stageResult{"verify", report.Ansible.Verify.Result, report.Ansible.Verify.Time, report.Ansible.Verify.Stats})
+	}
+
+	return stages
+}
+
+// stageStatus will return the status of the stage, where stages which
+// were not run because an earlier stage failed are not failures.
+func (report *AnsibleReport) stageStatus(stage stageResult) string {
+	switch {
+	case report.Skipped(stage.name):
+		return StageSkipped
+	case !report.Ran(stage.name):
+		return StageNotRun
+	case !stage.result:
+		return StageFailed
+	}
+	return StagePassed
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"io/ioutil"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunStages(t *testing.T) {
+
+	Convey("Running the stages of the test pipeline", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Stages run in order until one fails", func() {
+			config := AnsibleConfig{Quiet: true}
+			report := AnsibleReport{}
+			ran := []string{}
+			stage := func(name string, result bool) Stage {
+				return Stage{Name: name, Run: func() bool {
+					ran = append(ran, name)
+					return result
+				}}
+			}
+			result := report.RunStages(&config, []Stage{
+				stage("syntax", true),
+				stage("converge", false),
+				stage("idempotence", true),
+			})
+			So(result, ShouldBeFalse)
+			So(ran, ShouldResemble, []string{"syntax", "converge"})
+		})
+
+		Convey("Skipped stages are recorded and are not failures", func() {
+			config := AnsibleConfig{Quiet: true}
+			report := AnsibleReport{}
+			result := report.RunStages(&config, []Stage{
+				{Name: "syntax", Skip: true, Run: func() bool { return false }},
+				{Name: "converge", Run: func() bool { return true }},
+			})
+			So(result, ShouldBeTrue)
+			So(report.Ansible.Skipped, ShouldResemble, []string{"syntax"})
+			So(report.Passed("syntax", report.Ansible.Syntax), ShouldBeTrue)
+			So(report.Passed("converge", report.Ansible.Run.Result), ShouldBeFalse)
+		})
+
+		Convey("Skipped stages are set from the configuration", func() {
+			config := AnsibleConfig{SkipSyntax: true, SkipIdempotence: true}
+			report := AnsibleReport{}
+			dist := Distribution{}
+			skipped := []string{}
+			for _, stage := range dist.Stages(&config, &report) {
+				if stage.Skip {
+					skipped = append(skipped, stage.Name)
+				}
+			}
+			So(skipped, ShouldResemble, []string{"syntax", "idempotence"})
+		})
+	})
+}
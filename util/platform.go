@@ -0,0 +1,97 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// binfmtDir is where binfmt_misc lists the interpreters of the
+// binaries of other architectures, such as qemu.
+var binfmtDir = "/proc/sys/fs/binfmt_misc"
+
+// qemuArchitectures are the names qemu uses for the architectures
+// of docker platforms.
+var qemuArchitectures = map[string]string{
+	"amd64":   "x86_64",
+	"386":     "i386",
+	"arm64":   "aarch64",
+	"arm":     "arm",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// ParsePlatforms will parse the platforms of the containers, which are
+// either os/arch[/variant] for every distribution or distribution=platform
+// to override the platform of a single distribution. The platform of
+// every distribution is keyed by an empty name.
+func ParsePlatforms(values []string) (map[string]string, error) {
+
+	platforms := map[string]string{}
+	for _, value := range values {
+		name, platform := "", value
+		if pair := strings.SplitN(value, "=", 2); len(pair) == 2 {
+			name, platform = pair[0], pair[1]
+		}
+		parts := strings.Split(platform, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid platform '%v', expected os/arch[/variant], ie linux/arm64", value)
+		}
+		platforms[name] = platform
+	}
+	return platforms, nil
+}
+
+// PlatformFor will return the platform of the distribution, which is
+// its override or the platform of every distribution otherwise.
+func PlatformFor(platforms map[string]string, distribution string) string {
+	if platform, ok := platforms[distribution]; ok {
+		return platform
+	}
+	return platforms[""]
+}
+
+// platformArchitecture will return the architecture of the platform.
+func platformArchitecture(platform string) string {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// CheckPlatform will return an error when containers of the platform
+// can't run on this host, which needs qemu registered with binfmt_misc
+// for platforms of other architectures. Remote daemons are not checked.
+func CheckPlatform(platform string) error {
+
+	arch := platformArchitecture(platform)
+	if platform == "" || arch == runtime.GOARCH || RemoteDaemon() {
+		return nil
+	}
+
+	qemu, ok := qemuArchitectures[arch]
+	if !ok {
+		return nil
+	}
+
+	// Interpreters which are registered are listed by name, ie qemu-aarch64.
+	data, err := ioutil.ReadFile(fmt.Sprintf("%v/qemu-%v", binfmtDir, qemu))
+	if os.IsNotExist(err) || (err == nil && !strings.HasPrefix(string(data), "enabled")) {
+		return fmt.Errorf("containers of %v need qemu registered with binfmt_misc to run on %v, install it with:\n\tdocker run --privileged --rm tonistiigi/binfmt --install %v", platform, runtime.GOARCH, arch)
+	}
+	return nil
+}
+
+// imagePlatform will return the platform of the image of the
+// distribution, ie linux/arm64.
+func (dist *Distribution) imagePlatform() string {
+	out, err := dockerExec(inspectCommand{Kind: "image", Field: fieldPlatform, Name: dist.Container}, false)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPlatform(t *testing.T) {
+
+	Convey("Running containers of other platforms", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Platforms are set for every distribution or overridden", func() {
+			platforms, err := ParsePlatforms([]string{"linux/arm64", "centos7=linux/amd64"})
+			So(err, ShouldBeNil)
+			So(PlatformFor(platforms, "ubuntu1804"), ShouldEqual, "linux/arm64")
+			So(PlatformFor(platforms, "centos7"), ShouldEqual, "linux/amd64")
+
+			platforms, _ = ParsePlatforms([]string{"debian9=linux/arm/v7"})
+			So(PlatformFor(platforms, "ubuntu1804"), ShouldEqual, "")
+			So(PlatformFor(platforms, "debian9"), ShouldEqual, "linux/arm/v7")
+		})
+
+		Convey("Invalid platforms are rejected", func() {
+			_, err := ParsePlatforms([]string{"arm64"})
+			So(err, ShouldNotBeNil)
+			_, err = ParsePlatforms([]string{"centos7=linux/"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Other architectures need qemu", func() {
+			dir, _ := ioutil.TempDir("", "binfmt")
+			defer os.RemoveAll(dir)
+			binfmt := binfmtDir
+			binfmtDir = dir
+			defer func() { binfmtDir = binfmt }()
+
+			host := os.Getenv("DOCKER_HOST")
+			os.Unsetenv("DOCKER_HOST")
+			defer os.Setenv("DOCKER_HOST", host)
+
+			other := "arm64"
+			if runtime.GOARCH == "arm64" {
+				other = "amd64"
+			}
+
+			So(CheckPlatform(""), ShouldBeNil)
+			So(CheckPlatform("linux/"+runtime.GOARCH), ShouldBeNil)
+
+			err := CheckPlatform("linux/" + other)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "binfmt")
+
+			ioutil.WriteFile(filepath.Join(dir, "qemu-"+qemuArchitectures[other]), []byte("enabled\ninterpreter /usr/bin/qemu\n"), 0644)
+			So(CheckPlatform("linux/"+other), ShouldBeNil)
+		})
+
+		Convey("Images are pulled and run for the platform", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{CID: "test", Container: "fubarhouse/docker-ansible:bionic", Family: Ubuntu}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", PullPolicy: PullAlways, Platform: "linux/" + runtime.GOARCH, Quiet: true}
+			report := AnsibleReport{}
+
+			So(dist.DockerRun(&config, &report), ShouldBeTrue)
+			So(fake.commands[1], ShouldResemble, []string{"pull", "--platform", "linux/" + runtime.GOARCH, "fubarhouse/docker-ansible:bionic"})
+			So(fake.commands[2], ShouldContain, "--platform=linux/"+runtime.GOARCH)
+			So(report.NewJSONReport().Distribution.Platform, ShouldEqual, "linux/"+runtime.GOARCH)
+		})
+	})
+}
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PortMapping is a port of the container which is published to the host.
+type PortMapping struct {
+	ContainerPort int
+	Protocol      string
+	HostIP        string
+	HostPort      int
+}
+
+// ParsePublish will validate a list of ports in the format
+// hostPort:containerPort[/proto], where the protocol is tcp, udp or
+// sctp and a host port of 0 is assigned by docker.
+func ParsePublish(values []string) ([]string, error) {
+
+	ports := []string{}
+
+	for _, value := range values {
+		protocol := "tcp"
+		mapping := value
+		if i := strings.LastIndex(value, "/"); i >= 0 {
+			mapping, protocol = value[:i], value[i+1:]
+		}
+		if protocol != "tcp" && protocol != "udp" && protocol != "sctp" {
+			return ports, fmt.Errorf("invalid port '%v', the protocol must be tcp, udp or sctp", value)
+		}
+
+		pair := strings.Split(mapping, ":")
+		if len(pair) != 2 {
+			return ports, fmt.Errorf("invalid port '%v', expected HOSTPORT:CONTAINERPORT[/PROTO]", value)
+		}
+		host, err := strconv.Atoi(pair[0])
+		if err != nil || host < 0 || host > 65535 {
+			return ports, fmt.Errorf("invalid port '%v', the host port must be between 0 and 65535", value)
+		}
+		container, err := strconv.Atoi(pair[1])
+		if err != nil || container < 1 || container > 65535 {
+			return ports, fmt.Errorf("invalid port '%v', the container port must be between 1 and 65535", value)
+		}
+
+		ports = append(ports, fmt.Sprintf("%v:%v/%v", host, container, protocol))
+	}
+
+	return ports, nil
+}
+
+// checkPorts will return an error when a host port of the ports is
+// already in use on this host. Ports of remote daemons can't be checked.
+func checkPorts(ports []string) error {
+
+	if RemoteDaemon() {
+		return nil
+	}
+
+	for _, port := range ports {
+		host := strings.SplitN(port, ":", 2)[0]
+		protocol := port[strings.LastIndex(port, "/")+1:]
+		if host == "0" || protocol == "sctp" {
+			continue
+		}
+
+		if protocol == "udp" {
+			conn, err := net.ListenPacket("udp", ":"+host)
+			if err != nil {
+				return fmt.Errorf("host port %v/%v is already in use, choose another port or 0 for any free port", host, protocol)
+			}
+			conn.Close()
+			continue
+		}
+		listener, err := net.Listen("tcp", ":"+host)
+		if err != nil {
+			return fmt.Errorf("host port %v/%v is already in use, choose another port or 0 for any free port", host, protocol)
+		}
+		listener.Close()
+	}
+
+	return nil
+}
+
+// parsePorts will convert the output of "docker port", ie
+// "80/tcp -> 0.0.0.0:32768", into the published ports.
+func parsePorts(output string) []PortMapping {
+
+	ports := []PortMapping{}
+
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Split(strings.TrimSpace(line), " -> ")
+		if len(parts) != 2 {
+			continue
+		}
+
+		containerPort := strings.SplitN(parts[0], "/", 2)
+		hostIP, hostPort, err := net.SplitHostPort(parts[1])
+		if err != nil || len(containerPort) != 2 {
+			continue
+		}
+
+		mapping := PortMapping{Protocol: containerPort[1], HostIP: hostIP}
+		mapping.ContainerPort, _ = strconv.Atoi(containerPort[0])
+		mapping.HostPort, _ = strconv.Atoi(hostPort)
+		ports = append(ports, mapping)
+	}
+
+	return ports
+}
+
+// DockerPorts will record the ports of the container which are published
+// to the host in the report, including the ports which docker assigned.
+func (dist *Distribution) DockerPorts(report *AnsibleReport) error {
+
+	out, err := dockerExec(portCommand{Container: dist.CID}, false)
+	if err != nil {
+		return err
+	}
+	report.Docker.Ports = parsePorts(out)
+
+	return nil
+}
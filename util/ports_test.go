@@ -0,0 +1,60 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPublish(t *testing.T) {
+
+	Convey("Publishing ports of the container", t, func() {
+
+		Convey("Ports are validated with tcp by default", func() {
+			ports, err := ParsePublish([]string{"8080:80", "0:53/udp"})
+			So(err, ShouldBeNil)
+			So(ports, ShouldResemble, []string{"8080:80/tcp", "0:53/udp"})
+
+			for _, value := range []string{"80", "a:80", "80:0", "70000:80", "80:80/icmp", "1:2:3"} {
+				_, err := ParsePublish([]string{value})
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Host ports in use are rejected", func() {
+			host := os.Getenv("DOCKER_HOST")
+			defer os.Setenv("DOCKER_HOST", host)
+			os.Setenv("DOCKER_HOST", "")
+
+			listener, err := net.Listen("tcp", ":0")
+			So(err, ShouldBeNil)
+			defer listener.Close()
+			port := listener.Addr().(*net.TCPAddr).Port
+
+			err = checkPorts([]string{fmt.Sprintf("%v:80/tcp", port)})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "is already in use")
+			So(checkPorts([]string{"0:80/tcp"}), ShouldBeNil)
+		})
+
+		Convey("Published ports are recorded in the report", func() {
+			So(parsePorts("80/tcp -> 0.0.0.0:32768\n53/udp -> [::]:32769\n"), ShouldResemble, []PortMapping{
+				{ContainerPort: 80, Protocol: "tcp", HostIP: "0.0.0.0", HostPort: 32768},
+				{ContainerPort: 53, Protocol: "udp", HostIP: "::", HostPort: 32769},
+			})
+
+			report := AnsibleReport{}
+			report.Docker.Ports = parsePorts("80/tcp -> 0.0.0.0:32768\n")
+			So(report.NewJSONReport().Ports, ShouldResemble, []JSONPort{{ContainerPort: 80, Protocol: "tcp", HostIP: "0.0.0.0", HostPort: 32768}})
+		})
+
+		Convey("Ports are published when the container is created", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: cgroupVolume}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", Publish: []string{"0:80/tcp"}}
+			So(buildRunCommand(&dist, &config, &AnsibleReport{}).args(), ShouldContain, "--publish=0:80/tcp")
+		})
+	})
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+
+	// profileCallback is the callback plugin which times each task.
+	profileCallback = "profile_tasks"
+
+	// profilePattern matches a task in the summary of the profile_tasks
+	// callback, ie "role : Install packages ------------------- 5.23s".
+	profilePattern = regexp.MustCompile(`^(.+?)\s+-{3,}\s+(\d+(?:\.\d+)?)s$`)
+)
+
+// TaskTiming is the time a single task took to run.
+type TaskTiming struct {
+	Task string
+	Time time.Duration
+}
+
+// profiled will return the configuration for the converge run, which
+// enables the profile_tasks callback when the slowest tasks are reported.
+func (config *AnsibleConfig) profiled() *AnsibleConfig {
+
+	if config.ProfileTasks <= 0 {
+		return config
+	}
+
+	profiled := *config
+	profiled.Env = map[string]string{}
+	for key, value := range config.Env {
+		profiled.Env[key] = value
+	}
+
+	// Older versions of Ansible use the whitelist instead.
+	for _, key := range []string{"ANSIBLE_CALLBACKS_ENABLED", "ANSIBLE_CALLBACK_WHITELIST"} {
+		if profiled.Env[key] != "" {
+			profiled.Env[key] += "," + profileCallback
+		} else {
+			profiled.Env[key] = profileCallback
+		}
+	}
+
+	return &profiled
+}
+
+// parseTaskTimings will read the summary of the profile_tasks callback,
+// which follows a line of "=" at the end of the output.
+func parseTaskTimings(output string) []TaskTiming {
+
+	timings := []TaskTiming{}
+	summary := false
+
+	for _, line := range strings.Split(ansiPattern.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "=====") {
+			summary = true
+			timings = []TaskTiming{}
+			continue
+		}
+		if !summary {
+			continue
+		}
+
+		match := profilePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+		timings = append(timings, TaskTiming{
+			Task: match[1],
+			Time: time.Duration(seconds * float64(time.Second)),
+		})
+	}
+
+	return timings
+}
+
+// recordTaskTimings will add the timings of the tasks in the output to
+// the report, keeping only the configured number of slowest tasks. When
+// the callback is unavailable in the image the timings are skipped.
+func (report *AnsibleReport) recordTaskTimings(config *AnsibleConfig, output string) {
+
+	if config.ProfileTasks <= 0 {
+		return
+	}
+
+	timings := parseTaskTimings(output)
+	if len(timings) == 0 {
+		if !config.Quiet {
+			log.Warnf("No task timings were found, the %v callback may not be available", profileCallback)
+		}
+		return
+	}
+
+	timings = append(report.Ansible.Run.SlowestTasks, timings...)
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Time > timings[j].Time
+	})
+	if len(timings) > config.ProfileTasks {
+		timings = timings[:config.ProfileTasks]
+	}
+	report.Ansible.Run.SlowestTasks = timings
+}
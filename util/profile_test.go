@@ -0,0 +1,67 @@
+package util
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// profileOutput is the end of a role run with the profile_tasks callback.
+const profileOutput = `
+PLAY RECAP *********************************************************************
+test                       : ok=4    changed=2    unreachable=0    failed=0
+
+Wednesday 21 August 2019  10:00:12 +0000 (0:00:00.512)       0:00:12.345 ******
+===============================================================================
+web : Install packages ------------------------------------------------- 5.23s
+Gathering Facts -------------------------------------------------------- 1.02s
+web : Template config -------------------------------------------------- 0.51s
+`
+
+func TestTaskTimings(t *testing.T) {
+
+	Convey("Timing the tasks of the role run", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Timings are read from the summary", func() {
+			So(parseTaskTimings(profileOutput), ShouldResemble, []TaskTiming{
+				{Task: "web : Install packages", Time: 5230 * time.Millisecond},
+				{Task: "Gathering Facts", Time: 1020 * time.Millisecond},
+				{Task: "web : Template config", Time: 510 * time.Millisecond},
+			})
+		})
+
+		Convey("Only the slowest tasks are kept", func() {
+			config := AnsibleConfig{ProfileTasks: 2}
+			report := AnsibleReport{}
+			report.recordTaskTimings(&config, profileOutput)
+			report.recordTaskTimings(&config, "===============================================================================\nweb : Restart nginx ----------------------- 2.00s")
+			So(report.Ansible.Run.SlowestTasks, ShouldResemble, []TaskTiming{
+				{Task: "web : Install packages", Time: 5230 * time.Millisecond},
+				{Task: "web : Restart nginx", Time: 2 * time.Second},
+			})
+		})
+
+		Convey("Output without the callback is skipped", func() {
+			config := AnsibleConfig{ProfileTasks: 2}
+			report := AnsibleReport{}
+			report.recordTaskTimings(&config, idempotenceOutput)
+			So(report.Ansible.Run.SlowestTasks, ShouldBeEmpty)
+		})
+
+		Convey("The callback is only enabled when configured", func() {
+			config := AnsibleConfig{Env: map[string]string{"ANSIBLE_CALLBACKS_ENABLED": "timer"}}
+			So(config.profiled(), ShouldEqual, &config)
+
+			config.ProfileTasks = 5
+			env := config.profiled().Env
+			So(env["ANSIBLE_CALLBACKS_ENABLED"], ShouldEqual, "timer,profile_tasks")
+			So(env["ANSIBLE_CALLBACK_WHITELIST"], ShouldEqual, "profile_tasks")
+			So(config.Env["ANSIBLE_CALLBACKS_ENABLED"], ShouldEqual, "timer")
+		})
+	})
+}
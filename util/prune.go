@@ -0,0 +1,87 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PruneResource is a container, network or image which was created
+// by the tool and may be removed by prune.
+type PruneResource struct {
+	Kind    string
+	Name    string
+	Created time.Time
+}
+
+// String will return the kind and name of the resource.
+func (resource PruneResource) String() string {
+	return fmt.Sprintf("%v %v", resource.Kind, resource.Name)
+}
+
+// pruneKinds are the kinds of resources which are pruned, in the order
+// they are removed, with the commands to list and remove them. Only
+// resources with the label of the tool are ever listed.
+var pruneKinds = []struct {
+	kind   string
+	list   dockerCommand
+	remove func(name string) dockerCommand
+}{
+	{
+		kind:   "container",
+		list:   psCommand{All: true, Filters: []string{"label=" + containerLabel + "=true"}},
+		remove: func(name string) dockerCommand { return rmCommand{Container: name, Force: true} },
+	},
+	{
+		kind:   "network",
+		list:   networkLsCommand{Filters: []string{"label=" + containerLabel + "=true"}},
+		remove: func(name string) dockerCommand { return networkRmCommand{Name: name} },
+	},
+	{
+		kind:   "image",
+		list:   imagesCommand{Filters: []string{"label=" + containerLabel + "=true"}},
+		remove: func(name string) dockerCommand { return rmiCommand{Image: name} },
+	},
+}
+
+// PruneCandidates will return the containers, networks and images which
+// were created by the tool at least olderThan ago, as identified by the
+// label of the tool.
+func PruneCandidates(olderThan time.Duration) ([]PruneResource, error) {
+
+	resources := []PruneResource{}
+	for _, kind := range pruneKinds {
+		out, err := dockerExec(kind.list, false)
+		if err != nil {
+			return resources, err
+		}
+
+		for _, name := range strings.Fields(out) {
+			out, err := dockerExec(inspectCommand{Kind: kind.kind, Field: fieldCreated, Name: name}, false)
+			if err != nil {
+				return resources, err
+			}
+			resource := PruneResource{Kind: kind.kind, Name: name}
+			if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &resource.Created); err != nil {
+				return resources, fmt.Errorf("unable to read the creation time of %v: %v", resource, err)
+			}
+			if time.Since(resource.Created) >= olderThan {
+				resources = append(resources, resource)
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// Remove will remove the resource.
+func (resource PruneResource) Remove() error {
+	for _, kind := range pruneKinds {
+		if kind.kind == resource.Kind {
+			_, err := dockerExec(kind.remove(resource.Name), false)
+			return err
+		}
+	}
+	return fmt.Errorf("unknown resource %v", resource)
+}
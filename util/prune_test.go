@@ -0,0 +1,103 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// scriptedEngine answers each docker command with its output, keyed by
+// the arguments of the command, and records the commands. Commands
+// which are failures return an error.
+type scriptedEngine struct {
+	commands [][]string
+	outputs  map[string]string
+	failures map[string]bool
+}
+
+// Command will write the output of the docker command.
+func (scripted *scriptedEngine) Command(ctx context.Context, command dockerCommand, stdout bool, out io.Writer) error {
+	args := command.args()
+	scripted.commands = append(scripted.commands, args)
+	line := strings.Join(args, " ")
+	if scripted.failures[line] {
+		return fmt.Errorf("%v failed", line)
+	}
+	fmt.Fprintln(out, scripted.outputs[line])
+	return nil
+}
+
+func TestPrune(t *testing.T) {
+
+	Convey("Pruning the leftovers of the tests", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		created := func(age time.Duration) string {
+			return `"` + time.Now().Add(-age).UTC().Format(time.RFC3339Nano) + `"`
+		}
+		scripted := func() *scriptedEngine {
+			return &scriptedEngine{outputs: map[string]string{
+				"ps -a --filter label=ansible-role-tester=true --format {{.Names}}":        "art-web-ubuntu1804-1 art-web-centos7-2",
+				"inspect --type container --format {{json .Created}} art-web-ubuntu1804-1": created(3 * time.Hour),
+				"inspect --type container --format {{json .Created}} art-web-centos7-2":    created(time.Minute),
+				"network ls --filter label=ansible-role-tester=true --format {{.Name}}":    "scenario",
+				"network inspect --format {{json .Created}} scenario":                      created(5 * time.Hour),
+				"images --filter label=ansible-role-tester=true --format {{.ID}}":          "0123456789ab",
+				"inspect --type image --format {{json .Created}} 0123456789ab":             created(24 * time.Hour),
+			}}
+		}
+
+		Convey("Labelled resources are listed", func() {
+			engine = scripted()
+			resources, err := PruneCandidates(0)
+			So(err, ShouldBeNil)
+			names := []string{}
+			for _, resource := range resources {
+				names = append(names, resource.String())
+			}
+			So(names, ShouldResemble, []string{"container art-web-ubuntu1804-1", "container art-web-centos7-2", "network scenario", "image 0123456789ab"})
+		})
+
+		Convey("Recent resources are kept", func() {
+			engine = scripted()
+			resources, err := PruneCandidates(2 * time.Hour)
+			So(err, ShouldBeNil)
+			So(len(resources), ShouldEqual, 3)
+			for _, resource := range resources {
+				So(resource.Name, ShouldNotEqual, "art-web-centos7-2")
+			}
+		})
+
+		Convey("Resources are only found by the label of the tool", func() {
+			fake := scripted()
+			engine = fake
+			PruneCandidates(0)
+			for _, command := range fake.commands {
+				if command[len(command)-2] == "--format" && !strings.Contains(command[len(command)-1], "Created") {
+					So(command, ShouldContain, "label=ansible-role-tester=true")
+				}
+			}
+		})
+
+		Convey("Resources are removed by their kind", func() {
+			fake := scripted()
+			engine = fake
+			So(PruneResource{Kind: "container", Name: "art-web-ubuntu1804-1"}.Remove(), ShouldBeNil)
+			So(PruneResource{Kind: "network", Name: "scenario"}.Remove(), ShouldBeNil)
+			So(PruneResource{Kind: "image", Name: "0123456789ab"}.Remove(), ShouldBeNil)
+			So(fake.commands, ShouldResemble, [][]string{
+				{"rm", "--force", "art-web-ubuntu1804-1"},
+				{"network", "rm", "scenario"},
+				{"rmi", "0123456789ab"},
+			})
+			So(PruneResource{Kind: "volume", Name: "data"}.Remove(), ShouldNotBeNil)
+		})
+	})
+}
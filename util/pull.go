@@ -0,0 +1,82 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+
+	// PullAlways will pull the image before every container is created.
+	PullAlways = "always"
+
+	// PullMissing will pull the image only when it isn't present.
+	PullMissing = "missing"
+
+	// PullNever will never pull the image, which must be present.
+	PullNever = "never"
+)
+
+// CheckPullPolicy will return an error when the policy is not
+// one of always, missing or never.
+func CheckPullPolicy(policy string) error {
+	switch policy {
+	case PullAlways, PullMissing, PullNever:
+		return nil
+	}
+	return fmt.Errorf("invalid pull policy '%v', expected %v, %v or %v", policy, PullAlways, PullMissing, PullNever)
+}
+
+// imagePresent will return true when the image of the distribution
+// is present on the docker daemon.
+func (dist *Distribution) imagePresent() bool {
+	return engineCommand(inspectCommand{Kind: "image", Field: fieldID, Name: dist.Container}, false, ioutil.Discard) == nil
+}
+
+// DockerPull will pull the image of the distribution according to the
+// pull policy of the config, or of the distribution when the config has
+// none, which is missing by default. Images built
+// from a Dockerfile are never pulled. The policy and whether the image
+// was pulled are recorded in the report.
+func (dist *Distribution) DockerPull(config *AnsibleConfig, report *AnsibleReport) error {
+
+	// Images which were built are only present locally.
+	if config.Dockerfile != "" {
+		return nil
+	}
+
+	policy := config.PullPolicy
+	if policy == "" {
+		policy = dist.PullPolicy
+	}
+	if policy == "" {
+		policy = PullMissing
+	}
+	report.Docker.PullPolicy = policy
+
+	switch policy {
+	case PullNever:
+		if !dist.imagePresent() {
+			return fmt.Errorf("image %v is not present and the pull policy is %v, pull it first or use --pull %v", dist.Container, PullNever, PullMissing)
+		}
+		return nil
+	case PullMissing:
+		// Images of another platform are pulled for the platform.
+		if dist.imagePresent() && (config.Platform == "" || strings.HasPrefix(config.Platform, dist.imagePlatform())) {
+			return nil
+		}
+	}
+
+	if !config.Quiet {
+		log.Printf("Pulling %v", dist.Container)
+	}
+	if _, err := config.dockerExec(pullCommand{Image: dist.Container, Platform: config.Platform}, !config.Quiet); err != nil {
+		return err
+	}
+	report.Docker.Pulled = true
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerPull(t *testing.T) {
+
+	Convey("Pulling images by the pull policy", t, func() {
+
+		image := "fubarhouse/docker-ansible:bionic"
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Unknown policies are rejected", func() {
+			So(CheckPullPolicy(PullAlways), ShouldBeNil)
+			So(CheckPullPolicy(PullMissing), ShouldBeNil)
+			So(CheckPullPolicy(PullNever), ShouldBeNil)
+			So(CheckPullPolicy("sometimes"), ShouldNotBeNil)
+		})
+
+		Convey("Images are always pulled with always", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{Container: image}
+			report := AnsibleReport{}
+
+			So(dist.DockerPull(&AnsibleConfig{PullPolicy: PullAlways, Quiet: true}, &report), ShouldBeNil)
+			So(fake.commands, ShouldResemble, [][]string{{"pull", image}})
+			So(report.Docker.PullPolicy, ShouldEqual, PullAlways)
+			So(report.Docker.Pulled, ShouldBeTrue)
+		})
+
+		Convey("Present images are not pulled by default", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{Container: image}
+			report := AnsibleReport{}
+
+			So(dist.DockerPull(&AnsibleConfig{Quiet: true}, &report), ShouldBeNil)
+			So(fake.commands, ShouldResemble, [][]string{{"inspect", "--type", "image", "--format", "{{.Id}}", image}})
+			So(report.Docker.PullPolicy, ShouldEqual, PullMissing)
+			So(report.Docker.Pulled, ShouldBeFalse)
+		})
+
+		Convey("Missing images are pulled with missing", func() {
+			fake := &fakeEngine{missing: map[string]bool{image: true}}
+			engine = fake
+			dist := Distribution{Container: image}
+			report := AnsibleReport{}
+
+			So(dist.DockerPull(&AnsibleConfig{PullPolicy: PullMissing, Quiet: true}, &report), ShouldBeNil)
+			So(fake.commands[1], ShouldResemble, []string{"pull", image})
+			So(report.Docker.Pulled, ShouldBeTrue)
+		})
+
+		Convey("Missing images fail with never", func() {
+			fake := &fakeEngine{missing: map[string]bool{image: true}}
+			engine = fake
+			dist := Distribution{Container: image}
+			report := AnsibleReport{}
+
+			err := dist.DockerPull(&AnsibleConfig{PullPolicy: PullNever, Quiet: true}, &report)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "is not present and the pull policy is never")
+			So(len(fake.commands), ShouldEqual, 1)
+			So(report.Docker.Pulled, ShouldBeFalse)
+		})
+
+		Convey("Containers are not created when the image is missing with never", func() {
+			fake := &fakeEngine{missing: map[string]bool{image: true}}
+			engine = fake
+			dist := Distribution{CID: "test", Container: image}
+			report := AnsibleReport{}
+
+			So(dist.DockerRun(&AnsibleConfig{PullPolicy: PullNever, Quiet: true}, &report), ShouldBeFalse)
+			So(fake.running, ShouldBeEmpty)
+		})
+	})
+}
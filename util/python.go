@@ -0,0 +1,73 @@
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pythonRequirementsFile is the requirements file of the Python
+// libraries of a role which is installed when none is configured.
+var pythonRequirementsFile = filepath.Join("tests", "requirements.txt")
+
+// pythonInterpreter will return the Python interpreter Ansible runs the
+// modules with, which is ansible_python_interpreter when it is given
+// and python3 otherwise, as interpreter discovery finds it first.
+func (config *AnsibleConfig) pythonInterpreter() string {
+	for _, interpreter := range []string{config.ExtraVars["ansible_python_interpreter"], config.Env["ANSIBLE_PYTHON_INTERPRETER"]} {
+		if interpreter != "" && !strings.HasPrefix(interpreter, "auto") {
+			return interpreter
+		}
+	}
+	return "python3"
+}
+
+// pythonRequirementsCommands will return the commands which install the
+// Python requirements in the container, with pip installed first with
+// the package manager of the family when it is missing.
+func (dist *Distribution) pythonRequirementsCommands(config *AnsibleConfig) []string {
+
+	pip := config.pythonInterpreter() + " -m pip"
+	commands := []string{}
+	if command, ok := pipPackages[strings.ToLower(dist.Family.Group)]; ok {
+		commands = append(commands, pip+" --version >/dev/null 2>&1 || ("+command+")")
+	}
+
+	flags := ""
+	if config.PipFlags != "" {
+		flags = config.PipFlags + " "
+	}
+
+	// Newer images refuse to install packages outside of a virtualenv.
+	install := fmt.Sprintf("%v install %v-r %v", pip, flags, pythonRequirementsPath)
+	fallback := fmt.Sprintf("%v install --break-system-packages %v-r %v", pip, flags, pythonRequirementsPath)
+	return append(commands, install+" || "+fallback)
+}
+
+// DockerPythonRequirements will install the Python requirements of the
+// role in the container with pip once it is bootstrapped, as the modules
+// of the role run in the container for remote runs too. The output of
+// pip is written to the report.
+func (dist *Distribution) DockerPythonRequirements(config *AnsibleConfig, report *AnsibleReport) error {
+
+	if config.PythonRequirements == "" {
+		return nil
+	}
+
+	if !config.Quiet {
+		log.Printf("Installing the Python requirements of %v in %v", config.PythonRequirements, dist.CID)
+	}
+	output := ""
+	for _, command := range dist.pythonRequirementsCommands(config) {
+		out, err := dist.runScript(config, command)
+		output += out
+		if err != nil {
+			report.recordOutput(config, "python", output)
+			return fmt.Errorf("unable to install the Python requirements in the image %v, %v", dist.Container, err)
+		}
+	}
+	report.recordOutput(config, "python", output)
+	return nil
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPythonRequirements(t *testing.T) {
+
+	Convey("Installing the Python requirements in the container", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("The requirements file of the role is found", func() {
+			dir, _ := ioutil.TempDir("", "python")
+			defer os.RemoveAll(dir)
+
+			config := AnsibleConfig{HostPath: dir}
+			MapPythonRequirements(&config)
+			So(config.PythonRequirements, ShouldEqual, "")
+
+			os.MkdirAll(filepath.Join(dir, "tests"), 0755)
+			ioutil.WriteFile(filepath.Join(dir, "tests", "requirements.txt"), []byte("docker>=6\n"), 0644)
+			MapPythonRequirements(&config)
+			So(config.PythonRequirements, ShouldEqual, filepath.Join(dir, "tests", "requirements.txt"))
+		})
+
+		Convey("pip is installed with the package manager of the family", func() {
+			dist := JeffDebian12
+			So(dist.pythonRequirementsCommands(&AnsibleConfig{}), ShouldResemble, []string{
+				"python3 -m pip --version >/dev/null 2>&1 || (apt-get update && apt-get install -y python3-pip)",
+				"python3 -m pip install -r " + pythonRequirementsPath + " || python3 -m pip install --break-system-packages -r " + pythonRequirementsPath,
+			})
+
+			config := AnsibleConfig{ExtraVars: map[string]string{"ansible_python_interpreter": "/usr/bin/python3.11"}, PipFlags: "--no-cache-dir"}
+			commands := dist.pythonRequirementsCommands(&config)
+			So(commands[1], ShouldStartWith, "/usr/bin/python3.11 -m pip install --no-cache-dir -r ")
+
+			config = AnsibleConfig{Env: map[string]string{"ANSIBLE_PYTHON_INTERPRETER": "auto_silent"}}
+			So(config.pythonInterpreter(), ShouldEqual, "python3")
+		})
+
+		Convey("The output of pip is in the report", func() {
+			install := "sh -c exec 2>&1; python3 -m pip install -r " + pythonRequirementsPath + " || python3 -m pip install --break-system-packages -r " + pythonRequirementsPath
+			fake := &fakeEngine{
+				outputs: map[string][]string{install: {"ERROR: No matching distribution found for kubernetes==99"}},
+				failing: map[string]bool{install: true},
+			}
+			engine = fake
+			dist := JeffDebian12
+			dist.CID = "test"
+			report := AnsibleReport{}
+
+			So(dist.DockerPythonRequirements(&AnsibleConfig{Quiet: true}, &report), ShouldBeNil)
+			So(fake.commands, ShouldBeEmpty)
+
+			err := dist.DockerPythonRequirements(&AnsibleConfig{Quiet: true, PythonRequirements: "/srv/role/tests/requirements.txt"}, &report)
+			So(err, ShouldNotBeNil)
+			So(fake.commands, ShouldHaveLength, 2)
+			So(report.Ansible.Output["python"], ShouldContainSubstring, "No matching distribution")
+		})
+	})
+}
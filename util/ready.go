@@ -0,0 +1,76 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// readyInterval is the time between the readiness checks of a container.
+var readyInterval = 500 * time.Millisecond
+
+// initProcess will return the name of the init process of the
+// container, which is empty when there is none yet.
+func (dist *Distribution) initProcess() string {
+	out, err := dockerExec(dist.containerExec("cat", "/proc/1/comm"), false)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// initReady will return true when the init process of the container is
+// ready, which for systemd is when the system is running. Degraded
+// systems are ready, as units which can't run in containers often fail.
+func (dist *Distribution) initReady() (bool, string) {
+
+	process := dist.initProcess()
+	if process == "" {
+		return false, process
+	}
+	if process != "systemd" {
+		return true, process
+	}
+
+	// systemctl exits with an error until the system is running.
+	out, _ := dockerExec(dist.containerExec("systemctl", "is-system-running"), false)
+	state := strings.TrimSpace(out)
+	return state == "running" || state == "degraded", state
+}
+
+// DockerReady will wait for the init process of the container to be
+// ready before the role is run, for up to the ReadyTimeout of the
+// config. Zero disables the check. When the container isn't ready in
+// time, the systemd journal is logged for diagnosis.
+func (dist *Distribution) DockerReady(config *AnsibleConfig) error {
+
+	if config.ReadyTimeout <= 0 {
+		return nil
+	}
+
+	start := time.Now()
+	state := ""
+	for {
+		var ready bool
+		if ready, state = dist.initReady(); ready {
+			if !config.Quiet {
+				log.Printf("Container %v is ready (%v) after %v", dist.CID, state, time.Since(start).Round(time.Millisecond))
+			}
+			return nil
+		}
+		if time.Since(start) >= config.ReadyTimeout {
+			break
+		}
+		time.Sleep(readyInterval)
+	}
+
+	if state == "" {
+		state = "no init process"
+	}
+	if out, err := config.dockerExec(dist.containerExec("journalctl", "-xb", "--no-pager"), false); err == nil && out != "" {
+		log.Errorf("journalctl -xb of %v:\n%v", dist.CID, out)
+	}
+	return fmt.Errorf("container init not ready after %v (%v)", config.ReadyTimeout, state)
+}
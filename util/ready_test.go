@@ -0,0 +1,83 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerReady(t *testing.T) {
+
+	Convey("Waiting for the init process of the container", t, func() {
+
+		previous, interval := engine, readyInterval
+		readyInterval = time.Millisecond
+		defer func() { engine, readyInterval = previous, interval }()
+
+		Convey("The check is disabled without a timeout", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{CID: "test"}
+
+			So(dist.DockerReady(&AnsibleConfig{Quiet: true}), ShouldBeNil)
+			So(fake.commands, ShouldBeEmpty)
+		})
+
+		Convey("Containers without systemd are ready with an init process", func() {
+			fake := &fakeEngine{outputs: map[string][]string{"cat /proc/1/comm": {"tail"}}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+
+			So(dist.DockerReady(&AnsibleConfig{ReadyTimeout: time.Second, Quiet: true}), ShouldBeNil)
+			So(fake.commands, ShouldResemble, [][]string{{"exec", "test", "cat", "/proc/1/comm"}})
+		})
+
+		Convey("Containers with systemd are ready when the system is running", func() {
+			fake := &fakeEngine{outputs: map[string][]string{
+				"cat /proc/1/comm":            {"systemd"},
+				"systemctl is-system-running": {"starting", "starting", "running"},
+			}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+
+			So(dist.DockerReady(&AnsibleConfig{ReadyTimeout: time.Second, Quiet: true}), ShouldBeNil)
+			So(len(fake.commands), ShouldEqual, 6)
+		})
+
+		Convey("Degraded systems are ready", func() {
+			fake := &fakeEngine{outputs: map[string][]string{
+				"cat /proc/1/comm":            {"systemd"},
+				"systemctl is-system-running": {"degraded"},
+			}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+
+			So(dist.DockerReady(&AnsibleConfig{ReadyTimeout: time.Second, Quiet: true}), ShouldBeNil)
+		})
+
+		Convey("Containers which are not ready in time fail with the journal", func() {
+			fake := &fakeEngine{outputs: map[string][]string{
+				"cat /proc/1/comm":            {"systemd"},
+				"systemctl is-system-running": {"starting"},
+			}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+
+			err := dist.DockerReady(&AnsibleConfig{ReadyTimeout: 10 * time.Millisecond, Quiet: true})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "container init not ready")
+			So(err.Error(), ShouldContainSubstring, "starting")
+			So(fake.commands[len(fake.commands)-1], ShouldResemble, []string{"exec", "test", "journalctl", "-xb", "--no-pager"})
+		})
+
+		Convey("Containers which are not ready are not run", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{CID: "test", Container: "fubarhouse/docker-ansible:bionic", Family: Ubuntu}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", ReadyTimeout: 10 * time.Millisecond, Quiet: true}
+
+			So(dist.DockerRun(&config, &AnsibleReport{}), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,214 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerHubRegistry is the name of Docker Hub in the docker config,
+// which is the registry of images without a registry.
+const dockerHubRegistry = "https://index.docker.io/v1/"
+
+// RegistryAuth is the credentials of a registry, which are sent by the
+// Docker Engine API as the X-Registry-Auth header of a pull.
+type RegistryAuth struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// registryLogin is the username and password of the registry which
+// are given explicitly, instead of using the docker config.
+var registryLogin *RegistryAuth
+
+// SetRegistryAuth will set the username and password used to pull
+// images, which otherwise come from the REGISTRY_AUTH environment
+// variable, ie the base64 of "username:password", or the credentials
+// of the docker config.
+func SetRegistryAuth(username, password string) error {
+
+	registryLogin = nil
+	if username == "" && password == "" {
+		if encoded := os.Getenv("REGISTRY_AUTH"); encoded != "" {
+			var err error
+			if username, password, err = decodeAuth(encoded); err != nil {
+				return fmt.Errorf("invalid REGISTRY_AUTH: %v", err)
+			}
+		}
+	}
+	if username == "" && password == "" {
+		return nil
+	}
+	if username == "" || password == "" {
+		return fmt.Errorf("the registry username and password must both be given")
+	}
+
+	registryLogin = &RegistryAuth{Username: username, Password: password}
+	return nil
+}
+
+// decodeAuth will decode the base64 of "username:password".
+func decodeAuth(encoded string) (string, string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", err
+	}
+	pair := strings.SplitN(string(decoded), ":", 2)
+	if len(pair) != 2 {
+		return "", "", fmt.Errorf("expected the base64 of username:password")
+	}
+	return pair[0], pair[1], nil
+}
+
+// registryOf will return the registry of the image as it is named in
+// the docker config, which is Docker Hub unless the first part of the
+// image is a host name.
+func registryOf(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0]
+	}
+	return dockerHubRegistry
+}
+
+// normalizeRegistry will return the host of a registry of the docker
+// config, which may be a URL.
+func normalizeRegistry(registry string) string {
+	if registry == dockerHubRegistry {
+		return registry
+	}
+	registry = strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	return strings.SplitN(registry, "/", 2)[0]
+}
+
+// dockerConfigDir will return the directory of the docker config,
+// which is DOCKER_CONFIG when it is set.
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".docker")
+}
+
+// registryAuth will return the credentials to pull the image, which
+// are the explicit credentials when they are set, or those of the
+// docker config otherwise. Nil is returned for anonymous pulls.
+func registryAuth(image string) (*RegistryAuth, error) {
+
+	registry := registryOf(image)
+	if registryLogin != nil {
+		auth := *registryLogin
+		auth.ServerAddress = registry
+		return &auth, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dockerConfigDir(), "config.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	config := struct {
+		Auths map[string]struct {
+			Auth          string `json:"auth"`
+			IdentityToken string `json:"identitytoken"`
+		} `json:"auths"`
+		CredsStore  string            `json:"credsStore"`
+		CredHelpers map[string]string `json:"credHelpers"`
+	}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("unable to read the docker config: %v", err)
+	}
+
+	// Credential helpers take precedence over the stored credentials.
+	helper := config.CredsStore
+	for name, value := range config.CredHelpers {
+		if normalizeRegistry(name) == registry {
+			helper = value
+		}
+	}
+	if helper != "" {
+		return credentialHelper(helper, registry)
+	}
+
+	for name, value := range config.Auths {
+		if normalizeRegistry(name) != registry {
+			continue
+		}
+		auth := RegistryAuth{IdentityToken: value.IdentityToken, ServerAddress: registry}
+		if value.Auth != "" {
+			if auth.Username, auth.Password, err = decodeAuth(value.Auth); err != nil {
+				return nil, fmt.Errorf("invalid credentials of %v in the docker config: %v", registry, err)
+			}
+		}
+		return &auth, nil
+	}
+
+	return nil, nil
+}
+
+// credentialHelper will return the credentials of the registry from
+// a docker credential helper, ie docker-credential-desktop. Registries
+// which are unknown to the helper are pulled anonymously.
+func credentialHelper(helper, registry string) (*RegistryAuth, error) {
+
+	var out bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(out.String(), "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to get the credentials of %v from docker-credential-%v: %v", registry, helper, err)
+	}
+
+	result := struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}{}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		return nil, err
+	}
+
+	// Identity tokens are returned with the username <token>.
+	if result.Username == "<token>" {
+		return &RegistryAuth{IdentityToken: result.Secret, ServerAddress: registry}, nil
+	}
+	return &RegistryAuth{Username: result.Username, Password: result.Secret, ServerAddress: registry}, nil
+}
+
+// header will return the X-Registry-Auth header of the credentials.
+func (auth *RegistryAuth) header() (string, error) {
+	encoded, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(encoded), nil
+}
+
+// dockerConfig will write a docker config of the credentials to dir,
+// which the docker CLI uses when DOCKER_CONFIG is dir.
+func (auth *RegistryAuth) dockerConfig(dir string) error {
+	config := map[string]map[string]map[string]string{
+		"auths": {
+			auth.ServerAddress: {
+				"auth": base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password)),
+			},
+		},
+	}
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "config.json"), encoded, 0600)
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistryAuth(t *testing.T) {
+
+	Convey("Authenticating to the registry of the image", t, func() {
+
+		dir, _ := ioutil.TempDir("", "docker-config")
+		defer os.RemoveAll(dir)
+
+		config := os.Getenv("DOCKER_CONFIG")
+		auth := os.Getenv("REGISTRY_AUTH")
+		os.Setenv("DOCKER_CONFIG", dir)
+		os.Unsetenv("REGISTRY_AUTH")
+		defer func() {
+			os.Setenv("DOCKER_CONFIG", config)
+			os.Setenv("REGISTRY_AUTH", auth)
+			registryLogin = nil
+		}()
+
+		Convey("Registries are found from the image", func() {
+			So(registryOf("fubarhouse/docker-ansible:bionic"), ShouldEqual, dockerHubRegistry)
+			So(registryOf("ubuntu"), ShouldEqual, dockerHubRegistry)
+			So(registryOf("registry.example.com/team/image:1"), ShouldEqual, "registry.example.com")
+			So(registryOf("localhost:5000/image"), ShouldEqual, "localhost:5000")
+		})
+
+		Convey("Images are pulled anonymously without credentials", func() {
+			SetRegistryAuth("", "")
+			auth, err := registryAuth("registry.example.com/image")
+			So(err, ShouldBeNil)
+			So(auth, ShouldBeNil)
+		})
+
+		Convey("Credentials are read from the docker config", func() {
+			SetRegistryAuth("", "")
+			ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"auths": {"https://registry.example.com": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("user:secret"))+`"}}}`), 0600)
+
+			auth, err := registryAuth("registry.example.com/image")
+			So(err, ShouldBeNil)
+			So(*auth, ShouldResemble, RegistryAuth{Username: "user", Password: "secret", ServerAddress: "registry.example.com"})
+
+			auth, err = registryAuth("fubarhouse/docker-ansible:bionic")
+			So(err, ShouldBeNil)
+			So(auth, ShouldBeNil)
+		})
+
+		Convey("Explicit credentials take precedence", func() {
+			So(SetRegistryAuth("ci", "token"), ShouldBeNil)
+			auth, err := registryAuth("registry.example.com/image")
+			So(err, ShouldBeNil)
+			So(*auth, ShouldResemble, RegistryAuth{Username: "ci", Password: "token", ServerAddress: "registry.example.com"})
+		})
+
+		Convey("Credentials are read from REGISTRY_AUTH", func() {
+			os.Setenv("REGISTRY_AUTH", base64.StdEncoding.EncodeToString([]byte("ci:token")))
+			So(SetRegistryAuth("", ""), ShouldBeNil)
+			So(*registryLogin, ShouldResemble, RegistryAuth{Username: "ci", Password: "token"})
+
+			os.Setenv("REGISTRY_AUTH", "not base64")
+			So(SetRegistryAuth("", ""), ShouldNotBeNil)
+		})
+
+		Convey("Usernames need a password", func() {
+			So(SetRegistryAuth("ci", ""), ShouldNotBeNil)
+		})
+
+		Convey("Pulls send the credentials as a header", func() {
+			So(SetRegistryAuth("ci", "token"), ShouldBeNil)
+
+			header := ""
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+					header = r.Header.Get("X-Registry-Auth")
+					w.Write([]byte(`{"status": "Status: Downloaded newer image"}`))
+				},
+			})
+			defer server.Close()
+
+			var out bytes.Buffer
+			So(api.Command(context.Background(), pullCommand{Image: "registry.example.com/image:1"}, false, &out), ShouldBeNil)
+
+			decoded, err := base64.URLEncoding.DecodeString(header)
+			So(err, ShouldBeNil)
+			auth := RegistryAuth{}
+			So(json.Unmarshal(decoded, &auth), ShouldBeNil)
+			So(auth, ShouldResemble, RegistryAuth{Username: "ci", Password: "token", ServerAddress: "registry.example.com"})
+			So(out.String(), ShouldNotContainSubstring, "token")
+		})
+
+		Convey("The docker CLI is given a docker config of the credentials", func() {
+			auth := RegistryAuth{Username: "ci", Password: "token", ServerAddress: "registry.example.com"}
+			So(auth.dockerConfig(dir), ShouldBeNil)
+
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+			So(string(data), ShouldEqual, `{"auths":{"registry.example.com":{"auth":"`+base64.StdEncoding.EncodeToString([]byte("ci:token"))+`"}}}`)
+		})
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,34 +20,122 @@ import (
 // AnsibleReport will contain metadata about the run which will be, is and has executed.
 type AnsibleReport struct {
 	Meta struct {
-		Timestamp    time.Time
-		Repository   string
-		CommitHash   string
-		LocalChanges bool
-		ReportFile   string
+		Timestamp          time.Time
+		Repository         string
+		CommitHash         string
+		LocalChanges       bool
+		ReportFile         string
+		ToolVersion        string
+		ImageDigest        string
+		AnsibleVersion     string
+		HostAnsibleVersion string
+		RunID              string
 	}
 	Ansible struct {
 		Config       AnsibleConfig
 		Distribution Distribution
+		RoleName     string
+		Version      string
 		Hosts        []string
+		Warnings     []string
+		Skipped      []string
 		Syntax       bool
-		Requirements bool
-		Run          struct {
-			Result bool
-			Time   time.Duration
+		Requirements struct {
+			Enabled bool
+			Result  bool
+			Time    time.Duration
+		}
+		Check struct {
+			Enabled bool
+			Result  bool
+			Time    time.Duration
+		}
+		Prepare struct {
+			Enabled bool
+			Result  bool
+			Time    time.Duration
+		}
+		FactCache struct {
+			Enabled bool
+			Time    time.Duration
+			Reused  int
+			Saved   time.Duration
+		}
+		Run struct {
+			Result       bool
+			Time         time.Duration
+			Stats        PlayStats
+			Playbooks    []PlaybookReport
+			Attempts     []AttemptReport
+			SlowestTasks []TaskTiming
 		}
 		Idempotence struct {
-			Result bool
-			Time   time.Duration
+			Result         bool
+			Time           time.Duration
+			Output         string
+			Stats          PlayStats
+			Changed        []int
+			AllowedChanges []string
+		}
+		Verify struct {
+			Enabled bool
+			Result  bool
+			Time    time.Duration
+			Stats   PlayStats
 		}
+		Cleanup struct {
+			Enabled bool
+			Result  bool
+			Time    time.Duration
+		}
+		Timeout struct {
+			Stage  string
+			Output string
+		}
+		Interrupted            bool
+		Installed              bool
+		BudgetExceeded         []string
+		FailedIdempotenceTasks map[string][]string
+		Unreachable            map[string][]string
+		FailedTasks            map[string][]string
+		Stages                 []string
+		Logs                   map[string]string
+		Diagnostics            map[string]string
+		Output                 map[string]string `json:"-" yaml:"-"`
 	}
 	Docker struct {
-		Run     bool
-		Kill    bool
-		Volumes []string
+		Version        string
+		Run            bool
+		Kill           bool
+		Killed         bool
+		Kept           bool
+		Reused         bool
+		Ports          []PortMapping
+		Network        string
+		NetworkCreated bool
+		Memory         int64
+		CPUs           float64
+		Warnings       []string
+		Dockerfile     string
+		Built          bool
+		Platform       string
+		Volumes        []string
+		Tmpfs          []string
+		Copies         []string
+		Transfer       string
+		PullPolicy     string
+		Pulled         bool
 	}
 }
 
+// PlaybookReport contains the result of a single playbook
+// when multiple playbooks are run for the role.
+type PlaybookReport struct {
+	File   string
+	Result bool
+	Time   time.Duration
+}
+
 // GitCmd will run git commands in the specified directory.
 func GitCmd(path string, args []string) (string, error) {
 	// Find git.
@@ -96,32 +185,76 @@ func (report *AnsibleReport) IsGit() bool {
 	return isGitRepo
 }
 
-// getGitChanges will identify if there are local changes in git.
-func getGitChanges(path string) (bool, error) {
-	// Pass arguments to common git command runner; return results.
-	git, err := GitCmd(path, []string{"git", "status", "--porcelain"})
-	if git != "" {
-		return true, err
-	}
-	return false, err
+// timedOut will record the stage which did not complete within the
+// timeout, along with the output which was captured before it was killed.
+func (report *AnsibleReport) timedOut(stage, output string) {
+	report.Ansible.Timeout.Stage = stage
+	report.Ansible.Timeout.Output = output
 }
 
-// getGitRepo will return the URL path for the repository.
-func getGitRepo(path string) (string, error) {
+// checkUnreachable will record the hosts which were unreachable during
+// the stage, which usually means the container has stopped. Returns
+// false if any host was unreachable.
+func (report *AnsibleReport) checkUnreachable(stage string, stats PlayStats) bool {
 
-	// Pass arguments to common git command runner; return results.
-	git, err := GitCmd(path, []string{"git", "ls-remote", "--get-url", "origin"})
-	return git, err
+	hosts := stats.Unreachable()
+	if len(hosts) == 0 {
+		return true
+	}
+
+	log.Errorf("Unreachable hosts during the %v stage: %v", stage, strings.Join(hosts, ", "))
+	if report.Ansible.Unreachable == nil {
+		report.Ansible.Unreachable = map[string][]string{}
+	}
+	report.Ansible.Unreachable[stage] = hosts
+
+	return false
+}
 
+// recordOutput will keep the last lines of the output of a stage and
+// the names of the tasks which failed, so the cause of a failure can
+// be included in the test reports.
+func (report *AnsibleReport) recordOutput(config *AnsibleConfig, stage, output string) {
+	if report.Ansible.Output == nil {
+		report.Ansible.Output = map[string]string{}
+	}
+	if tasks := config.failedTasks(output); len(tasks) > 0 {
+		if report.Ansible.FailedTasks == nil {
+			report.Ansible.FailedTasks = map[string][]string{}
+		}
+		report.Ansible.FailedTasks[stage] = tasks
+	}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > outputLines {
+		lines = lines[len(lines)-outputLines:]
+	}
+	report.Ansible.Output[stage] = strings.Join(lines, "\n")
 }
 
-// getGitRepo will return the commit hash for the repository.
-func getGitCommit(path string) (string, error) {
+// distributionName is the name of the tested distribution in the
+// test reports, which is the image for custom distributions.
+func (report *AnsibleReport) distributionName() string {
+	if report.Ansible.Distribution.Distro != "" {
+		return report.Ansible.Distribution.Distro
+	}
+	return report.Ansible.Distribution.Container
+}
 
-	// Pass arguments to common git command runner; return results.
-	git, err := GitCmd(path, []string{"git", "rev-parse", "HEAD"})
-	return git, err
+// sortedKeys will return the keys of the map in order.
+func sortedKeys(values map[string][]string) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
+// Prepared will identify if the container is ready for the role to
+// run, which is when no prepare playbook is configured, or it passed
+// or was skipped.
+func (report *AnsibleReport) Prepared() bool {
+	return !report.Ansible.Prepare.Enabled || report.Passed("prepare", report.Ansible.Prepare.Result)
 }
 
 // NewReport will generate a new Report variable from the input configuration.
@@ -130,28 +263,18 @@ func NewReport(config *AnsibleConfig) AnsibleReport {
 	// Create the variable.
 	report := new(AnsibleReport)
 
-	// Set appropriate values for git integration.
-	if isGitRepo, _ := isGit(config.HostPath); isGitRepo == true {
-		gitRepository, _ := getGitRepo(config.HostPath)
-		gitRepository = strings.Replace(gitRepository, "\n", "", -1)
-		gitCommit, _ := getGitCommit(config.HostPath)
-		gitCommit = strings.Replace(gitCommit, "\n", "", -1)
-		gitChanges, _ := getGitChanges(config.HostPath)
-
-		report.Meta.CommitHash = gitCommit
-		report.Meta.Repository = gitRepository
-		report.Meta.LocalChanges = gitChanges
-	}
-
 	// Set appropriate defaults as needed.
 	report.Meta.Timestamp = time.Now()
+	report.Meta.RunID = RunID
 	report.Ansible.Config = *config
+	report.Ansible.RoleName = config.ResolveRoleName()
 	report.Ansible.Syntax = false
-	report.Ansible.Requirements = false
 	report.Ansible.Run.Result = false
 	report.Ansible.Run.Time = 0
 	report.Ansible.Idempotence.Result = false
 	report.Ansible.Idempotence.Time = 0
+	report.Ansible.Prepare.Enabled = config.PreparePlaybook != ""
+	report.Ansible.Cleanup.Enabled = config.CleanupPlaybook != ""
 	report.Docker.Run = false
 	report.Docker.Kill = false
 
@@ -239,24 +362,178 @@ func (report *AnsibleReport) Printf() {
 	fmt.Println("Ansible Role Tester Report")
 	fmt.Println("----------------------------------------------------------")
 	fmt.Printf("Timestamp: \t\t\t%v\n", report.Meta.Timestamp)
-	if report.IsGit() {
+	if report.Meta.ToolVersion != "" {
+		fmt.Printf("Tester version: \t\t%v\n", report.Meta.ToolVersion)
+	}
+	if report.Ansible.RoleName != "" {
+		fmt.Printf("Role name: \t\t\t%v\n", report.Ansible.RoleName)
+	}
+	if report.Meta.CommitHash != "" {
 		fmt.Printf("Repository URL: \t\t%v\n", report.Meta.Repository)
 		fmt.Printf("Repository commit: \t\t%v\n", report.Meta.CommitHash)
 		fmt.Printf("Local changes: \t\t\t%v\n", report.Meta.LocalChanges)
 	}
+	if report.Meta.ImageDigest != "" {
+		fmt.Printf("Image digest: \t\t\t%v\n", report.Meta.ImageDigest)
+	}
+	if dist := report.Ansible.Distribution; dist.Deprecated() {
+		fmt.Printf("End of life: \t\t\t%v (deprecated)\n", dist.EOL.Format("2006-01-02"))
+	}
+	if report.Ansible.Version != "" && report.Ansible.Installed {
+		fmt.Printf("Ansible version: \t\t%v (installed)\n", report.Ansible.Version)
+	} else if report.Ansible.Version != "" {
+		fmt.Printf("Ansible version: \t\t%v\n", report.Ansible.Version)
+	}
+	if report.Meta.HostAnsibleVersion != "" {
+		fmt.Printf("Host Ansible version: \t\t%v\n", report.Meta.HostAnsibleVersion)
+	}
+	if report.Ansible.Config.AnsibleCfg != "" {
+		fmt.Printf("Ansible config: \t\t%v\n", report.Ansible.Config.AnsibleCfg)
+	}
 	fmt.Println("----------------------------------------------------------")
 	fmt.Printf("Syntax check: \t\t\t%v\n", report.Ansible.Syntax)
-	fmt.Printf("Requirements installed: \t%v\n", report.Ansible.Requirements)
+	if report.Ansible.Requirements.Enabled {
+		fmt.Printf("Requirements installed: \t%v\n", report.Ansible.Requirements.Result)
+		fmt.Printf("Requirements time: \t\t%v\n", report.Ansible.Requirements.Time)
+	}
+	if report.Ansible.Check.Enabled {
+		fmt.Printf("Check mode result: \t\t%v\n", report.Ansible.Check.Result)
+		fmt.Printf("Check mode time: \t\t%v\n", report.Ansible.Check.Time)
+	}
+	if report.Ansible.Prepare.Enabled {
+		fmt.Printf("Prepare result: \t\t%v\n", report.Ansible.Prepare.Result)
+		fmt.Printf("Prepare time: \t\t\t%v\n", report.Ansible.Prepare.Time)
+	}
+	if report.Ansible.FactCache.Enabled {
+		fmt.Printf("Fact gathering time: \t\t%v\n", report.Ansible.FactCache.Time)
+		fmt.Printf("Fact cache time saved: \t\t%v\n", report.Ansible.FactCache.Saved)
+	}
 	fmt.Printf("Run result: \t\t\t%v\n", report.Ansible.Run.Result)
 	fmt.Printf("Run time: \t\t\t%v\n", report.Ansible.Run.Time)
+	if len(report.Ansible.Run.Playbooks) > 1 {
+		for _, playbook := range report.Ansible.Run.Playbooks {
+			fmt.Printf("  %v: \t%v (%v)\n", playbook.File, playbook.Result, playbook.Time)
+		}
+	}
+	if len(report.Ansible.Run.Attempts) > 1 {
+		fmt.Printf("Run attempts: \t\t\t%v\n", len(report.Ansible.Run.Attempts))
+	}
+	if len(report.Ansible.Run.SlowestTasks) > 0 {
+		fmt.Println("Slowest tasks:")
+		for _, timing := range report.Ansible.Run.SlowestTasks {
+			fmt.Printf("  %v: \t%v\n", timing.Task, timing.Time)
+		}
+	}
+	if total := report.Ansible.Run.Stats.Total(); total.Rescued > 0 || total.Ignored > 0 {
+		fmt.Printf("Rescued tasks: \t\t\t%v\n", total.Rescued)
+		fmt.Printf("Ignored tasks: \t\t\t%v\n", total.Ignored)
+	}
 	fmt.Printf("Idempotence result: \t\t%v\n", report.Ansible.Idempotence.Result)
 	fmt.Printf("Idempotence time: \t\t%v\n", report.Ansible.Idempotence.Time)
+	if len(report.Ansible.Idempotence.AllowedChanges) > 0 {
+		fmt.Printf("Ignored changes: \t\t%v\n", strings.Join(report.Ansible.Idempotence.AllowedChanges, ", "))
+	}
+	for _, host := range sortedKeys(report.Ansible.FailedIdempotenceTasks) {
+		fmt.Printf("Non-idempotent tasks (%v): \t%v\n", host, strings.Join(report.Ansible.FailedIdempotenceTasks[host], ", "))
+	}
+	if len(report.Ansible.Idempotence.Changed) > 1 {
+		fmt.Printf("Idempotence changes: \t\t%v\n", report.Ansible.Idempotence.Changed)
+	}
+	if report.Ansible.Verify.Enabled {
+		fmt.Printf("Verify result: \t\t\t%v\n", report.Ansible.Verify.Result)
+		fmt.Printf("Verify time: \t\t\t%v\n", report.Ansible.Verify.Time)
+	}
+	if report.Ansible.Cleanup.Enabled {
+		fmt.Printf("Cleanup result: \t\t%v\n", report.Ansible.Cleanup.Result)
+		fmt.Printf("Cleanup time: \t\t\t%v\n", report.Ansible.Cleanup.Time)
+	}
+	if len(report.Ansible.Warnings) > 0 {
+		fmt.Printf("Warnings: \t\t\t%v\n", len(report.Ansible.Warnings))
+	}
+	for _, stage := range []string{"run", "idempotence", "verify"} {
+		if hosts, ok := report.Ansible.Unreachable[stage]; ok {
+			fmt.Printf("Unreachable (%v): \t\t%v\n", stage, strings.Join(hosts, ", "))
+		}
+	}
+	for _, stage := range report.Ansible.Stages {
+		if filename, ok := report.Ansible.Logs[stage]; ok {
+			fmt.Printf("Log (%v): \t\t%v\n", stage, filename)
+		}
+	}
+	if len(report.Ansible.Skipped) > 0 {
+		fmt.Printf("Skipped stages: \t\t%v\n", strings.Join(report.Ansible.Skipped, ", "))
+	}
+	if report.Ansible.Timeout.Stage != "" {
+		fmt.Printf("Timed out: \t\t\t%v\n", report.Ansible.Timeout.Stage)
+	}
+	for _, stage := range report.stageResults() {
+		if budget, ok := report.Ansible.Config.StageBudgets[stage.name]; ok && report.Ran(stage.name) {
+			fmt.Printf("Budget (%v): \t\t%v of %v\n", stage.name, stage.time, budget)
+		}
+	}
+	if report.Ansible.Config.MaxDuration > 0 {
+		fmt.Printf("Budget (%v): \t\t%v of %v\n", budgetTotal, report.duration(), report.Ansible.Config.MaxDuration)
+	}
+	if len(report.Ansible.BudgetExceeded) > 0 {
+		fmt.Printf("Budget exceeded: \t\t%v\n", strings.Join(report.Ansible.BudgetExceeded, ", "))
+	}
 	fmt.Println("----------------------------------------------------------")
+	for _, volume := range report.Docker.Volumes {
+		fmt.Printf("Docker volume: \t\t\t%v\n", volume)
+	}
+	fmt.Printf("Docker privileged: \t\t%v\n", report.Ansible.Distribution.Privileged)
+	fmt.Printf("Docker init command: \t\t%v\n", report.Ansible.Distribution.Family.Initialise)
+	if report.Docker.Memory > 0 {
+		fmt.Printf("Docker memory limit: \t\t%v bytes\n", report.Docker.Memory)
+	}
+	if report.Docker.CPUs > 0 {
+		fmt.Printf("Docker CPU limit: \t\t%v\n", report.Docker.CPUs)
+	}
+	for _, warning := range report.Docker.Warnings {
+		fmt.Printf("Docker warning: \t\t%v\n", warning)
+	}
+	if report.Docker.Network != "" {
+		fmt.Printf("Docker network: \t\t%v\n", report.Docker.Network)
+	}
+	for _, port := range report.Docker.Ports {
+		fmt.Printf("Docker port: \t\t\t%v:%v -> %v/%v\n", port.HostIP, port.HostPort, port.ContainerPort, port.Protocol)
+	}
+	if report.Docker.Platform != "" {
+		fmt.Printf("Docker platform: \t\t%v\n", report.Docker.Platform)
+	}
+	if report.Docker.Transfer != "" {
+		fmt.Printf("Role transfer: \t\t\t%v\n", report.Docker.Transfer)
+	}
+	if report.Docker.Dockerfile != "" {
+		fmt.Printf("Dockerfile: \t\t\t%v\n", report.Docker.Dockerfile)
+		fmt.Printf("Image built: \t\t\t%v\n", report.Docker.Built)
+	}
+	if report.Docker.PullPolicy != "" {
+		fmt.Printf("Image pull policy: \t\t%v\n", report.Docker.PullPolicy)
+		fmt.Printf("Image pulled: \t\t\t%v\n", report.Docker.Pulled)
+	}
 	fmt.Printf("Docker run: \t\t\t%v\n", report.Docker.Run)
 	fmt.Printf("Docker kill: \t\t\t%v\n", report.Docker.Kill)
+	if report.Docker.Killed {
+		fmt.Printf("Docker killed: \t\t\t%v (the container did not stop in time)\n", report.Docker.Killed)
+	}
+	if report.Docker.Reused {
+		fmt.Printf("Docker reused: \t\t\t%v (state of previous runs may affect idempotence)\n", report.Docker.Reused)
+	}
+	if report.Docker.Kept {
+		fmt.Printf("Docker kept: \t\t\t%v\n", report.Docker.Kept)
+	}
 	fmt.Println("----------------------------------------------------------")
 	fmt.Println()
 
+	report.WriteFile()
+
+}
+
+// WriteFile will write the report to the report file as YAML or
+// JSON, depending on the extension of the file.
+func (report *AnsibleReport) WriteFile() {
+
 	if strings.HasSuffix(report.Meta.ReportFile, ".yaml") {
 		yamlReport, _ := report.GetYAML(report)
 		report.printFile(yamlReport)
@@ -0,0 +1,243 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AggregateReport contains the reports of every distribution which was
+// tested in a run, which the report writers and the exit code use.
+type AggregateReport struct {
+	Reports  []AnsibleReport
+	FailFast bool
+	Started  time.Time
+	Finished time.Time
+
+	// Skipped are the platforms which were not tested, as there is
+	// no distribution of them, ie "EL 5".
+	Skipped []string
+
+	// lock guards Reports, as the distributions which are tested
+	// concurrently add their reports as they finish.
+	lock sync.Mutex
+}
+
+// NewAggregateReport will return an empty AggregateReport for a run
+// which starts now, which stops at the first failure when failFast is set.
+func NewAggregateReport(failFast bool) *AggregateReport {
+	now := time.Now()
+	return &AggregateReport{
+		Reports:  []AnsibleReport{},
+		FailFast: failFast,
+		Started:  now,
+		Finished: now,
+	}
+}
+
+// Add will add the report of a distribution once its tests have finished,
+// and will return false if the remaining distributions should not be tested.
+func (aggregate *AggregateReport) Add(report AnsibleReport) bool {
+	aggregate.lock.Lock()
+	defer aggregate.lock.Unlock()
+	aggregate.Reports = append(aggregate.Reports, report)
+	aggregate.Finished = time.Now()
+	return !aggregate.FailFast || report.ExitCode() == OKCode
+}
+
+// Passed will identify if the tests passed on every distribution.
+func (aggregate *AggregateReport) Passed() bool {
+	return aggregate.ExitCode() == OKCode
+}
+
+// ExitCode will return the exit code of the first distribution
+// which failed, or OKCode if every distribution passed.
+func (aggregate *AggregateReport) ExitCode() int {
+	for i := range aggregate.Reports {
+		if code := aggregate.Reports[i].ExitCode(); code != OKCode {
+			return code
+		}
+	}
+	return OKCode
+}
+
+// Duration will return the wall time of the whole run.
+func (aggregate *AggregateReport) Duration() time.Duration {
+	return aggregate.Finished.Sub(aggregate.Started)
+}
+
+// Slowest will return the report of the distribution whose stages
+// took the longest, or nil if there are no reports.
+func (aggregate *AggregateReport) Slowest() *AnsibleReport {
+	var slowest *AnsibleReport
+	for i := range aggregate.Reports {
+		if slowest == nil || aggregate.Reports[i].duration() > slowest.duration() {
+			slowest = &aggregate.Reports[i]
+		}
+	}
+	return slowest
+}
+
+// summaryColors are the ANSI colours of each stage status in the summary.
+var summaryColors = map[string]string{
+	StagePassed:  "\x1b[32m",
+	StageFailed:  "\x1b[31m",
+	StageSkipped: "\x1b[90m",
+	StageNotRun:  "\x1b[90m",
+}
+
+// summaryStatus is the text of each stage status in the summary.
+var summaryStatus = map[string]string{
+	StagePassed:  "PASS",
+	StageFailed:  "FAIL",
+	StageSkipped: "SKIP",
+	StageNotRun:  "SKIP",
+}
+
+// summaryCell is a cell of the summary table, which is coloured
+// after the columns are aligned on the width of the text.
+type summaryCell struct {
+	text  string
+	color string
+}
+
+// Summary will return a table of the result of each stage of each
+// distribution, followed by the overall result of the run. The status
+// of each stage is coloured when color is true.
+func (aggregate *AggregateReport) Summary(color bool) string {
+
+	// The columns are every stage of any distribution, in order.
+	stages := []string{}
+	seen := map[string]bool{}
+	for i := range aggregate.Reports {
+		for _, stage := range aggregate.Reports[i].stageResults() {
+			if !seen[stage.name] {
+				seen[stage.name] = true
+				stages = append(stages, stage.name)
+			}
+		}
+	}
+
+	header := []summaryCell{{text: "DISTRIBUTION"}}
+	for _, stage := range stages {
+		header = append(header, summaryCell{text: strings.ToUpper(stage)})
+	}
+	header = append(header, summaryCell{text: "DURATION"})
+	rows := [][]summaryCell{header}
+
+	for i := range aggregate.Reports {
+		report := &aggregate.Reports[i]
+		cells := map[string]summaryCell{}
+		for _, stage := range report.stageResults() {
+			status := report.stageStatus(stage)
+			cell := summaryCell{text: summaryStatus[status], color: summaryColors[status]}
+			if status == StagePassed || status == StageFailed {
+				cell.text += " " + stage.time.Round(time.Second).String()
+			}
+			cells[stage.name] = cell
+		}
+
+		row := []summaryCell{{text: report.distributionName()}}
+		for _, stage := range stages {
+			cell, ok := cells[stage]
+			if !ok {
+				cell = summaryCell{text: "-"}
+			}
+			row = append(row, cell)
+		}
+		row = append(row, summaryCell{text: report.duration().Round(time.Second).String()})
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell.text) > widths[i] {
+				widths[i] = len(cell.text)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, row := range rows {
+		for i, cell := range row {
+			text := cell.text
+			if color && cell.color != "" {
+				text = cell.color + text + "\x1b[0m"
+			}
+			if i < len(row)-1 {
+				text += strings.Repeat(" ", widths[i]-len(cell.text)+2)
+			}
+			buf.WriteString(text)
+		}
+		buf.WriteString("\n")
+	}
+
+	result, resultColor := "PASS", summaryColors[StagePassed]
+	if !aggregate.Passed() {
+		result, resultColor = "FAIL", summaryColors[StageFailed]
+	}
+	if color {
+		result = resultColor + result + "\x1b[0m"
+	}
+	fmt.Fprintf(&buf, "\n%v: %v distributions in %v", result, len(aggregate.Reports), aggregate.Duration().Round(time.Second))
+	if slowest := aggregate.Slowest(); slowest != nil && len(aggregate.Reports) > 1 {
+		fmt.Fprintf(&buf, ", slowest %v (%v)", slowest.distributionName(), slowest.duration().Round(time.Second))
+	}
+	fmt.Fprintln(&buf)
+	if len(aggregate.Skipped) > 0 {
+		fmt.Fprintf(&buf, "Skipped: %v\n", strings.Join(aggregate.Skipped, ", "))
+	}
+
+	return buf.String()
+}
+
+// Terminal will identify if the file is a terminal, which the
+// summary is only coloured for.
+func Terminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// resultStatus is the status of each stage in the result line.
+var resultStatus = map[string]string{
+	StagePassed:  "pass",
+	StageFailed:  "fail",
+	StageSkipped: "skip",
+	StageNotRun:  "not_run",
+}
+
+// ResultLine will return the result of the distribution as a single
+// line for scripts, ie "RESULT distribution=centos7 syntax=pass
+// converge=pass idempotence=fail duration=51s".
+func (report *AnsibleReport) ResultLine() string {
+	fields := []string{"RESULT", "distribution=" + report.distributionName()}
+	for _, stage := range report.stageResults() {
+		fields = append(fields, stage.name+"="+resultStatus[report.stageStatus(stage)])
+	}
+	fields = append(fields, fmt.Sprintf("duration=%ds", int(report.duration().Seconds())))
+	return strings.Join(fields, " ")
+}
+
+// duration will return the time the stages of the report took.
+func (report *AnsibleReport) duration() time.Duration {
+	var total time.Duration
+	for _, stage := range report.stageResults() {
+		total += stage.time
+	}
+	return total
+}
+
+// failedStage will return the name of the stage which failed,
+// or an empty string if none did.
+func (report *AnsibleReport) failedStage() string {
+	for _, stage := range report.stageResults() {
+		if report.stageStatus(stage) == StageFailed {
+			return stage.name
+		}
+	}
+	return report.Ansible.Timeout.Stage
+}
@@ -0,0 +1,144 @@
+package util
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// aggregateReports will return a passing report for ubuntu1804 and a
+// report for centos7 which failed the idempotence test.
+func aggregateReports() (AnsibleReport, AnsibleReport) {
+
+	passed := AnsibleReport{}
+	passed.Ansible.Distribution = Ubuntu1804
+	passed.Ansible.Stages = []string{"syntax", "converge", "idempotence"}
+	passed.Ansible.Syntax = true
+	passed.Ansible.Run.Result, passed.Ansible.Run.Time = true, 42*time.Second
+	passed.Ansible.Idempotence.Result, passed.Ansible.Idempotence.Time = true, 12*time.Second
+
+	failed := AnsibleReport{}
+	failed.Ansible.Distribution = CentOS7
+	failed.Ansible.Stages = []string{"syntax", "converge", "idempotence"}
+	failed.Ansible.Syntax = true
+	failed.Ansible.Run.Result, failed.Ansible.Run.Time = true, 40*time.Second
+	failed.Ansible.Idempotence.Time = 11 * time.Second
+
+	return passed, failed
+}
+
+func TestAggregateReport(t *testing.T) {
+
+	Convey("Aggregating the reports of a run", t, func() {
+
+		Convey("The run passes when every distribution passed", func() {
+			passed, _ := aggregateReports()
+			aggregate := NewAggregateReport(false)
+			So(aggregate.Add(passed), ShouldBeTrue)
+			So(aggregate.Passed(), ShouldBeTrue)
+			So(aggregate.ExitCode(), ShouldEqual, OKCode)
+		})
+
+		Convey("The run fails when any distribution failed", func() {
+			passed, failed := aggregateReports()
+			aggregate := NewAggregateReport(false)
+			aggregate.Add(failed)
+			So(aggregate.Add(passed), ShouldBeTrue)
+			So(aggregate.Passed(), ShouldBeFalse)
+			So(aggregate.ExitCode(), ShouldEqual, AnsibleIdempotenceCode)
+		})
+
+		Convey("Fail fast stops at the first failure", func() {
+			passed, failed := aggregateReports()
+			aggregate := NewAggregateReport(true)
+			So(aggregate.Add(passed), ShouldBeTrue)
+			So(aggregate.Add(failed), ShouldBeFalse)
+		})
+
+		Convey("Reports are added concurrently", func() {
+			passed, _ := aggregateReports()
+			aggregate := NewAggregateReport(false)
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					aggregate.Add(passed)
+				}()
+			}
+			wg.Wait()
+			So(aggregate.Reports, ShouldHaveLength, 20)
+		})
+
+		Convey("The slowest distribution is found", func() {
+			passed, failed := aggregateReports()
+			aggregate := NewAggregateReport(false)
+			aggregate.Add(failed)
+			aggregate.Add(passed)
+			So(aggregate.Slowest().distributionName(), ShouldEqual, "ubuntu1804")
+			So(NewAggregateReport(false).Slowest(), ShouldBeNil)
+		})
+
+		Convey("The summary has a row per distribution", func() {
+			passed, failed := aggregateReports()
+			failed.Ansible.Distribution = Distribution{Distro: "fubarhouse/docker-ansible:centos-7"}
+			aggregate := &AggregateReport{Reports: []AnsibleReport{passed, failed}}
+			So(aggregate.Summary(false), ShouldEqual, ""+
+				"DISTRIBUTION                        SYNTAX   CONVERGE  IDEMPOTENCE  DURATION\n"+
+				"ubuntu1804                          PASS 0s  PASS 42s  PASS 12s     54s\n"+
+				"fubarhouse/docker-ansible:centos-7  PASS 0s  PASS 40s  FAIL 11s     51s\n"+
+				"\n"+
+				"FAIL: 2 distributions in 0s, slowest ubuntu1804 (54s)\n")
+		})
+
+		Convey("The summary is coloured on a terminal", func() {
+			passed, failed := aggregateReports()
+			failed.Ansible.Skipped = []string{"syntax"}
+			summary := (&AggregateReport{Reports: []AnsibleReport{passed, failed}}).Summary(true)
+			So(summary, ShouldContainSubstring, "\x1b[32mPASS 42s\x1b[0m  ")
+			So(summary, ShouldContainSubstring, "\x1b[31mFAIL 11s\x1b[0m     ")
+			So(summary, ShouldContainSubstring, "centos7       \x1b[90mSKIP\x1b[0m     ")
+			So(ansiPattern.ReplaceAllString(summary, ""), ShouldContainSubstring, "centos7       SKIP     PASS 40s")
+		})
+
+		Convey("The JSON report contains every distribution", func() {
+			passed, failed := aggregateReports()
+			aggregate := &AggregateReport{Reports: []AnsibleReport{passed, failed}}
+			aggregate.Finished = aggregate.Started.Add(2 * time.Minute)
+
+			result := aggregate.NewJSONReport()
+			So(result.Version, ShouldEqual, JSONReportVersion)
+			So(result.Passed, ShouldBeFalse)
+			So(result.Duration, ShouldEqual, 120.0)
+			So(result.SlowestDistribution, ShouldEqual, "ubuntu1804")
+			So(result.Distributions, ShouldHaveLength, 2)
+			So(result.Distributions[1].Passed, ShouldBeFalse)
+
+			data, err := json.Marshal(result)
+			So(err, ShouldBeNil)
+			fields := map[string]interface{}{}
+			So(json.Unmarshal(data, &fields), ShouldBeNil)
+			for _, field := range []string{"version", "passed", "duration_seconds", "slowest_distribution", "distributions"} {
+				So(fields, ShouldContainKey, field)
+			}
+		})
+
+		Convey("The JUnit report has a testsuite per distribution", func() {
+			passed, failed := aggregateReports()
+			data, err := (&AggregateReport{Reports: []AnsibleReport{passed, failed}}).JUnit()
+			So(err, ShouldBeNil)
+			So(string(data), ShouldContainSubstring, `<testsuite name="ubuntu1804" tests="3" failures="0"`)
+			So(string(data), ShouldContainSubstring, `<testsuite name="centos7" tests="3" failures="1"`)
+		})
+
+		Convey("The result line has the status of each stage", func() {
+			passed, failed := aggregateReports()
+			failed.Ansible.Run.Time = 3 * time.Minute
+			So(passed.ResultLine(), ShouldEqual, "RESULT distribution=ubuntu1804 syntax=pass converge=pass idempotence=pass duration=54s")
+			So(failed.ResultLine(), ShouldEqual, "RESULT distribution=centos7 syntax=pass converge=pass idempotence=fail duration=191s")
+		})
+	})
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+
+	// githubEscaper escapes the message of a workflow command.
+	githubEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+	// githubPropertyEscaper escapes the properties of a workflow command.
+	githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// GitHubActions will identify if the tests are running in GitHub Actions.
+func GitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// GitHubCommands will return the GitHub Actions workflow commands for
+// the report, which are an error for each failed stage and a warning
+// for each Ansible warning, shown as annotations on the workflow run.
+func (report *AnsibleReport) GitHubCommands() string {
+
+	var buf bytes.Buffer
+	name := githubPropertyEscaper.Replace(report.distributionName())
+
+	for _, stage := range report.stageResults() {
+		if report.stageStatus(stage) != StageFailed {
+			continue
+		}
+		message := fmt.Sprintf("The %v stage failed on %v", stage.name, name)
+		if tasks := report.stageFailedTasks(stage.name); len(tasks) > 0 {
+			message += ": " + strings.Join(tasks, ", ")
+		}
+		fmt.Fprintf(&buf, "::error title=%v %v::%v\n", name, stage.name, githubEscaper.Replace(message))
+	}
+
+	for _, warning := range report.Ansible.Warnings {
+		fmt.Fprintf(&buf, "::warning title=%v::%v\n", name, githubEscaper.Replace(warning))
+	}
+
+	return buf.String()
+}
+
+// WriteGitHubSummary will append the markdown summary of the reports to
+// the job summary file, which is named by GITHUB_STEP_SUMMARY.
+func (aggregate *AggregateReport) WriteGitHubSummary() error {
+
+	filename := os.Getenv("GITHUB_STEP_SUMMARY")
+	if filename == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(aggregate.Markdown())
+	return err
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGitHubReport(t *testing.T) {
+
+	Convey("Reporting to GitHub Actions", t, func() {
+
+		Convey("Failed stages and warnings are annotations", func() {
+			report := AnsibleReport{}
+			report.Ansible.Distribution = CentOS7
+			report.Ansible.Stages = []string{"syntax", "converge"}
+			report.Ansible.Syntax = true
+			report.Ansible.FailedTasks = map[string][]string{"converge": {"web : Install packages"}}
+			report.Ansible.Warnings = []string{"[WARNING]: 100% of\nhosts"}
+
+			So(report.GitHubCommands(), ShouldEqual, "::error title=centos7 converge::The converge stage failed on centos7: web : Install packages\n"+
+				"::warning title=centos7::[WARNING]: 100%25 of%0Ahosts\n")
+		})
+
+		Convey("The summary is appended to the step summary file", func() {
+			dir, _ := ioutil.TempDir("", "ansible-role-tester")
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, "summary.md")
+			ioutil.WriteFile(filename, []byte("# Build\n"), 0644)
+
+			os.Setenv("GITHUB_STEP_SUMMARY", filename)
+			defer os.Unsetenv("GITHUB_STEP_SUMMARY")
+
+			report := AnsibleReport{}
+			So((&AggregateReport{Reports: []AnsibleReport{report}}).WriteGitHubSummary(), ShouldBeNil)
+			data, _ := ioutil.ReadFile(filename)
+			So(string(data), ShouldStartWith, "# Build\n## Ansible Role Tester\n")
+		})
+	})
+}
@@ -0,0 +1,385 @@
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// JSONReportVersion is the version of the JSON report format, which
+// changes only when fields are removed or their meaning changes.
+const JSONReportVersion = 2
+
+// JSONAggregateReport is the format of the JSON report, which contains
+// the overall result of the run and the report of each distribution.
+type JSONAggregateReport struct {
+
+	// Version is the version of the report format.
+	Version int `json:"version"`
+
+	// Passed indicates the tests passed on every distribution.
+	Passed bool `json:"passed"`
+
+	// Duration is the wall time of the whole run in seconds.
+	Duration float64 `json:"duration_seconds"`
+
+	// SlowestDistribution is the name of the distribution whose
+	// stages took the longest.
+	SlowestDistribution string `json:"slowest_distribution"`
+
+	// Distributions are the reports of each distribution in the
+	// order they were tested.
+	Distributions []JSONReport `json:"distributions"`
+
+	// SkippedPlatforms are the platforms which were not tested, as
+	// there is no distribution of them.
+	SkippedPlatforms []string `json:"skipped_platforms,omitempty"`
+}
+
+// JSONReport is the report of a distribution in the JSON report. It is
+// kept separate from AnsibleReport so the field names are stable.
+type JSONReport struct {
+
+	// Version is the version of the report format.
+	Version int `json:"version"`
+
+	// Timestamp is the time the tests started.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Passed indicates every stage passed or was skipped.
+	Passed bool `json:"passed"`
+
+	// Distribution is the distribution which was tested.
+	Distribution JSONDistribution `json:"distribution"`
+
+	// ContainerID is the name of the container which was tested.
+	ContainerID string `json:"container_id"`
+
+	// RunID is the id of the run, which containers are labelled with.
+	RunID string `json:"run_id"`
+
+	// ContainerKept indicates the container was intentionally
+	// left running for debugging.
+	ContainerKept bool `json:"container_kept"`
+
+	// Network is the docker network of the container.
+	Network string `json:"network"`
+
+	// Ports are the ports of the container which are published to the
+	// host, so verify scripts can find ports which docker assigned.
+	Ports []JSONPort `json:"ports"`
+
+	// ContainerReused indicates the container of a previous run was
+	// reused, so the state of earlier runs may affect idempotence.
+	ContainerReused bool `json:"container_reused"`
+
+	// ContainerKilled indicates the container did not stop within the
+	// stop timeout, so it was killed.
+	ContainerKilled bool `json:"container_killed"`
+
+	// AnsibleVersion is the version of Ansible which ran the role.
+	AnsibleVersion string `json:"ansible_version"`
+
+	// AnsibleInstalled indicates Ansible was installed in the container
+	// as the image doesn't include it.
+	AnsibleInstalled bool `json:"ansible_installed,omitempty"`
+
+	// Metadata is what else the role was tested with.
+	Metadata JSONMetadata `json:"metadata"`
+
+	// Stages are the stages of the tests in the order they run.
+	Stages []JSONStage `json:"stages"`
+
+	// Warnings are the Ansible warnings found in strict mode
+	// or when running in GitHub Actions.
+	Warnings []string `json:"warnings"`
+
+	// SlowestTasks are the slowest tasks of the converge, slowest first.
+	SlowestTasks []JSONTaskTiming `json:"slowest_tasks,omitempty"`
+
+	// TimedOut is the stage which was killed by the timeout, if any.
+	TimedOut string `json:"timed_out,omitempty"`
+
+	// Diagnostics are the logs and journal of the container which were
+	// collected because a stage failed, keyed by container and journal.
+	Diagnostics map[string]string `json:"diagnostics,omitempty"`
+
+	// Interrupted indicates the run was interrupted, ie by Ctrl-C, so
+	// the report only has the stages which ran until then.
+	Interrupted bool `json:"interrupted,omitempty"`
+
+	// BudgetExceeded are the stages which exceeded their duration
+	// budget, and total if the stages together exceeded theirs.
+	BudgetExceeded []string `json:"budget_exceeded,omitempty"`
+}
+
+// JSONDistribution is the distribution in the JSON report.
+type JSONDistribution struct {
+
+	// Name is the name of the distribution, ie centos7.
+	Name string `json:"name"`
+
+	// Image is the image the container was created from.
+	Image string `json:"image"`
+
+	// Privileged indicates the container was privileged.
+	Privileged bool `json:"privileged"`
+
+	// InitCommand is the command the container was started with.
+	InitCommand string `json:"init_command"`
+
+	// Platform is the platform of the container, which is empty for
+	// the platform of the host.
+	Platform string `json:"platform"`
+
+	// EOL is the end of life of the release upstream, as YYYY-MM-DD.
+	EOL string `json:"eol,omitempty"`
+
+	// Deprecated indicates the release is past its end of life.
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+// JSONMetadata is what the role was tested with in the JSON report,
+// where anything which could not be found is empty.
+type JSONMetadata struct {
+
+	// ToolVersion is the version of ansible-role-tester.
+	ToolVersion string `json:"tool_version"`
+
+	// ImageDigest is the digest of the image of the container.
+	ImageDigest string `json:"image_digest"`
+
+	// HostAnsibleVersion is the version of Ansible on the host.
+	HostAnsibleVersion string `json:"host_ansible_version"`
+
+	// Repository is the origin of the role's git repository.
+	Repository string `json:"repository"`
+
+	// Commit is the git commit of the role.
+	Commit string `json:"commit"`
+
+	// RoleName is the name the role was linked as in the roles path.
+	RoleName string `json:"role_name"`
+
+	// LocalChanges indicates the role has uncommitted changes.
+	LocalChanges bool `json:"local_changes"`
+
+	// Volumes are the volumes which were mounted into the container.
+	Volumes []string `json:"volumes"`
+
+	// Tmpfs are the tmpfs mounts of the container.
+	Tmpfs []string `json:"tmpfs"`
+
+	// Transfer is how the role got into the container, which is
+	// mount or copy.
+	Transfer string `json:"transfer"`
+
+	// MemoryLimit is the memory limit of the container in bytes,
+	// where 0 is no limit.
+	MemoryLimit int64 `json:"memory_limit_bytes"`
+
+	// CPULimit is the CPU limit of the container, where 0 is no limit.
+	CPULimit float64 `json:"cpu_limit"`
+
+	// DockerWarnings are the warnings of docker when the container was
+	// created, ie limits which the kernel can't apply.
+	DockerWarnings []string `json:"docker_warnings"`
+
+	// Dockerfile is the Dockerfile the image was built from.
+	Dockerfile string `json:"dockerfile"`
+
+	// ImageBuilt indicates the image was built for the tests.
+	ImageBuilt bool `json:"image_built"`
+
+	// PullPolicy is when the image is pulled, ie always, missing or never.
+	PullPolicy string `json:"pull_policy"`
+
+	// ImagePulled indicates the image was pulled for the tests.
+	ImagePulled bool `json:"image_pulled"`
+}
+
+// JSONPort is a port of the container which is published to the host.
+type JSONPort struct {
+
+	// ContainerPort is the port in the container.
+	ContainerPort int `json:"container_port"`
+
+	// Protocol is the protocol of the port, ie tcp or udp.
+	Protocol string `json:"protocol"`
+
+	// HostIP is the address of the host the port is published on.
+	HostIP string `json:"host_ip"`
+
+	// HostPort is the port on the host.
+	HostPort int `json:"host_port"`
+}
+
+// JSONStage is the result of a stage in the JSON report.
+type JSONStage struct {
+
+	// Name is the name of the stage, ie syntax, converge or idempotence.
+	Name string `json:"name"`
+
+	// Status is one of passed, failed, skipped or not_run.
+	Status string `json:"status"`
+
+	// Duration is the time the stage took in seconds.
+	Duration float64 `json:"duration_seconds"`
+
+	// Stats are the recap counts of each host, keyed by host.
+	Stats map[string]JSONHostStats `json:"stats,omitempty"`
+
+	// Budget is the duration budget of the stage in seconds, if any.
+	Budget float64 `json:"budget_seconds,omitempty"`
+
+	// LogFile is the file the output of the stage was written to.
+	LogFile string `json:"log_file,omitempty"`
+}
+
+// JSONHostStats are the recap counts of a host in the JSON report.
+type JSONHostStats struct {
+	Ok          int `json:"ok"`
+	Changed     int `json:"changed"`
+	Failed      int `json:"failed"`
+	Unreachable int `json:"unreachable"`
+	Skipped     int `json:"skipped"`
+	Rescued     int `json:"rescued"`
+	Ignored     int `json:"ignored"`
+}
+
+// JSONTaskTiming is the time a task took in the JSON report.
+type JSONTaskTiming struct {
+
+	// Task is the name of the task.
+	Task string `json:"task"`
+
+	// Duration is the time the task took in seconds.
+	Duration float64 `json:"duration_seconds"`
+}
+
+// NewJSONReport will convert the report of a distribution to the JSON format,
+// which includes the stages which completed when the run failed.
+func (report *AnsibleReport) NewJSONReport() JSONReport {
+
+	result := JSONReport{
+		Version:   JSONReportVersion,
+		Timestamp: report.Meta.Timestamp,
+		Passed:    report.Ansible.Timeout.Stage == "" && !report.Ansible.Interrupted && len(report.Ansible.BudgetExceeded) == 0,
+		Distribution: JSONDistribution{
+			Name:        report.Ansible.Distribution.Distro,
+			Image:       report.Ansible.Distribution.Container,
+			Privileged:  report.Ansible.Distribution.Privileged,
+			InitCommand: report.Ansible.Distribution.Family.Initialise,
+			Platform:    report.Docker.Platform,
+			Deprecated:  report.Ansible.Distribution.Deprecated(),
+		},
+		ContainerID:      report.Ansible.Distribution.CID,
+		RunID:            report.Meta.RunID,
+		ContainerKept:    report.Docker.Kept,
+		ContainerReused:  report.Docker.Reused,
+		ContainerKilled:  report.Docker.Killed,
+		Network:          report.Docker.Network,
+		Ports:            []JSONPort{},
+		AnsibleVersion:   report.Ansible.Version,
+		AnsibleInstalled: report.Ansible.Installed,
+		Metadata: JSONMetadata{
+			ToolVersion:        report.Meta.ToolVersion,
+			ImageDigest:        report.Meta.ImageDigest,
+			HostAnsibleVersion: report.Meta.HostAnsibleVersion,
+			Repository:         report.Meta.Repository,
+			Commit:             report.Meta.CommitHash,
+			RoleName:           report.Ansible.RoleName,
+			LocalChanges:       report.Meta.LocalChanges,
+			Volumes:            append([]string{}, report.Docker.Volumes...),
+			Tmpfs:              append([]string{}, report.Docker.Tmpfs...),
+			Transfer:           report.Docker.Transfer,
+			MemoryLimit:        report.Docker.Memory,
+			CPULimit:           report.Docker.CPUs,
+			DockerWarnings:     append([]string{}, report.Docker.Warnings...),
+			Dockerfile:         report.Docker.Dockerfile,
+			ImageBuilt:         report.Docker.Built,
+			PullPolicy:         report.Docker.PullPolicy,
+			ImagePulled:        report.Docker.Pulled,
+		},
+		Stages:         []JSONStage{},
+		Warnings:       append([]string{}, report.Ansible.Warnings...),
+		TimedOut:       report.Ansible.Timeout.Stage,
+		Interrupted:    report.Ansible.Interrupted,
+		Diagnostics:    report.Ansible.Diagnostics,
+		BudgetExceeded: report.Ansible.BudgetExceeded,
+	}
+
+	if eol := report.Ansible.Distribution.EOL; !eol.IsZero() {
+		result.Distribution.EOL = eol.Format("2006-01-02")
+	}
+
+	for _, port := range report.Docker.Ports {
+		result.Ports = append(result.Ports, JSONPort{
+			ContainerPort: port.ContainerPort,
+			Protocol:      port.Protocol,
+			HostIP:        port.HostIP,
+			HostPort:      port.HostPort,
+		})
+	}
+
+	for _, stage := range report.stageResults() {
+		status := report.stageStatus(stage)
+		if status != StagePassed && status != StageSkipped {
+			result.Passed = false
+		}
+
+		item := JSONStage{
+			Name:     stage.name,
+			Status:   status,
+			Duration: stage.time.Seconds(),
+			Budget:   report.Ansible.Config.StageBudgets[stage.name].Seconds(),
+			LogFile:  report.Ansible.Logs[stage.name],
+		}
+		if len(stage.stats) > 0 {
+			item.Stats = map[string]JSONHostStats{}
+			for host, stats := range stage.stats {
+				item.Stats[host] = JSONHostStats(stats)
+			}
+		}
+		result.Stages = append(result.Stages, item)
+	}
+
+	for _, timing := range report.Ansible.Run.SlowestTasks {
+		result.SlowestTasks = append(result.SlowestTasks, JSONTaskTiming{
+			Task:     timing.Task,
+			Duration: timing.Time.Seconds(),
+		})
+	}
+
+	return result
+}
+
+// NewJSONReport will convert the reports to the JSON report format.
+func (aggregate *AggregateReport) NewJSONReport() JSONAggregateReport {
+
+	result := JSONAggregateReport{
+		Version:          JSONReportVersion,
+		Passed:           aggregate.Passed(),
+		Duration:         aggregate.Duration().Seconds(),
+		Distributions:    []JSONReport{},
+		SkippedPlatforms: aggregate.Skipped,
+	}
+
+	if slowest := aggregate.Slowest(); slowest != nil {
+		result.SlowestDistribution = slowest.distributionName()
+	}
+	for i := range aggregate.Reports {
+		result.Distributions = append(result.Distributions, aggregate.Reports[i].NewJSONReport())
+	}
+
+	return result
+}
+
+// WriteJSON will write the reports in the JSON report format to the file.
+func (aggregate *AggregateReport) WriteJSON(filename string) error {
+	data, err := json.MarshalIndent(aggregate.NewJSONReport(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
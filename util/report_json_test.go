@@ -0,0 +1,55 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONReport(t *testing.T) {
+
+	Convey("Writing the report as JSON", t, func() {
+
+		Convey("Stages which completed are included when the run fails", func() {
+			report := AnsibleReport{}
+			report.Ansible.Distribution = CentOS7
+			report.Ansible.Distribution.CID = "test"
+			report.Ansible.Version = "2.9.27"
+			report.Ansible.Stages = []string{"syntax", "converge"}
+			report.Ansible.Syntax = true
+			report.Ansible.Run.Time = 42 * time.Second
+			report.Ansible.Run.Stats = PlayStats{"test": {Ok: 3, Failed: 1}}
+
+			result := report.NewJSONReport()
+			So(result.Passed, ShouldBeFalse)
+			So(result.Distribution, ShouldResemble, JSONDistribution{Name: "centos7", Image: "fubarhouse/docker-ansible:centos-7", Privileged: true, InitCommand: "/sbin/init", EOL: "2024-06-30", Deprecated: true})
+			So(result.ContainerID, ShouldEqual, "test")
+			So(result.Stages, ShouldHaveLength, 3)
+			So(result.Stages[0].Status, ShouldEqual, StagePassed)
+			So(result.Stages[1].Status, ShouldEqual, StageFailed)
+			So(result.Stages[1].Duration, ShouldEqual, 42.0)
+			So(result.Stages[1].Stats["test"], ShouldResemble, JSONHostStats{Ok: 3, Failed: 1})
+			So(result.Stages[2].Status, ShouldEqual, StageNotRun)
+		})
+
+		Convey("Field names are stable", func() {
+			report := AnsibleReport{}
+			report.Ansible.Stages = []string{"syntax", "converge", "idempotence"}
+			report.Ansible.Syntax = true
+			report.Ansible.Run.Result = true
+			report.Ansible.Idempotence.Result = true
+
+			data, err := json.Marshal(report.NewJSONReport())
+			So(err, ShouldBeNil)
+
+			fields := map[string]interface{}{}
+			So(json.Unmarshal(data, &fields), ShouldBeNil)
+			for _, field := range []string{"version", "timestamp", "passed", "distribution", "container_id", "ansible_version", "stages", "warnings"} {
+				So(fields, ShouldContainKey, field)
+			}
+			So(fields["passed"], ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite contains the tests of a single distribution.
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a single stage of the tests.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitFailure contains the output of a failed stage.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Output  string `xml:",cdata"`
+}
+
+// junitSkipped marks a stage which was not run.
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// JUnit will return the reports as JUnit XML, with a testsuite for each
+// distribution and a testcase for each stage. Failed stages include the
+// end of their output, and stages which were not run are skipped.
+func (aggregate *AggregateReport) JUnit() ([]byte, error) {
+
+	suites := junitTestSuites{Suites: []junitTestSuite{}}
+	for i := range aggregate.Reports {
+		suites.Suites = append(suites.Suites, aggregate.Reports[i].junitSuite())
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// junitSuite will return the testsuite of the distribution.
+func (report *AnsibleReport) junitSuite() junitTestSuite {
+
+	name := report.distributionName()
+
+	suite := junitTestSuite{Name: name}
+	var total time.Duration
+
+	for _, stage := range report.stageResults() {
+		testcase := junitTestCase{
+			Name:      stage.name,
+			Classname: name,
+			Time:      junitTime(stage.time),
+		}
+		total += stage.time
+
+		switch report.stageStatus(stage) {
+		case StageSkipped:
+			testcase.Skipped = &junitSkipped{Message: "skipped"}
+			suite.Skipped++
+		case StageNotRun:
+			testcase.Skipped = &junitSkipped{Message: "not run after an earlier failure"}
+			suite.Skipped++
+		case StageFailed:
+			testcase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%v failed", stage.name),
+				Output:  report.Ansible.Output[stage.name],
+			}
+			suite.Failures++
+		}
+
+		// The slowest tasks are included with the converge.
+		if stage.name == "converge" && len(report.Ansible.Run.SlowestTasks) > 0 {
+			testcase.SystemOut = "Slowest tasks:\n"
+			for _, timing := range report.Ansible.Run.SlowestTasks {
+				testcase.SystemOut += fmt.Sprintf("%v: %v\n", timing.Task, timing.Time)
+			}
+		}
+
+		suite.Cases = append(suite.Cases, testcase)
+	}
+
+	suite.Tests = len(suite.Cases)
+	suite.Time = junitTime(total)
+
+	return suite
+}
+
+// WriteJUnit will write the reports as JUnit XML to the file.
+func (aggregate *AggregateReport) WriteJUnit(filename string) error {
+	data, err := aggregate.JUnit()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// junitTime will format a duration in seconds as JUnit expects.
+func junitTime(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
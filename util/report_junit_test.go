@@ -0,0 +1,61 @@
+package util
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// update will rewrite the golden files with the current output.
+var update = flag.Bool("update", false, "update the golden files")
+
+// golden will compare the data to the golden file in testdata.
+func golden(name string, data []byte) string {
+	path := filepath.Join("testdata", name)
+	if *update {
+		ioutil.WriteFile(path, data, 0644)
+	}
+	expected, _ := ioutil.ReadFile(path)
+	return string(expected)
+}
+
+func TestJUnitReport(t *testing.T) {
+
+	Convey("Writing the report as JUnit XML", t, func() {
+
+		Convey("Passing stages are test cases", func() {
+			report := AnsibleReport{}
+			report.Ansible.Distribution = CentOS7
+			report.Ansible.Config.VerifyPlaybook = "tests/verify.yml"
+			report.Ansible.Stages = []string{"syntax", "converge", "idempotence", "verify"}
+			report.Ansible.Syntax = true
+			report.Ansible.Run.Result, report.Ansible.Run.Time = true, 42*time.Second
+			report.Ansible.Idempotence.Result, report.Ansible.Idempotence.Time = true, 12*time.Second
+			report.Ansible.Verify.Enabled = true
+			report.Ansible.Verify.Result, report.Ansible.Verify.Time = true, 1500*time.Millisecond
+
+			data, err := (&AggregateReport{Reports: []AnsibleReport{report}}).JUnit()
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, golden("junit_pass.xml", data))
+		})
+
+		Convey("Failed stages include their output and later stages are skipped", func() {
+			report := AnsibleReport{}
+			report.Ansible.Distribution = CentOS7
+			report.Ansible.Skipped = []string{"syntax"}
+			report.Ansible.Stages = []string{"converge", "idempotence"}
+			report.Ansible.Run.Result, report.Ansible.Run.Time = true, 42*time.Second
+			report.Ansible.Idempotence.Time = 12 * time.Second
+			report.Ansible.Config.VerifyPlaybook = "tests/verify.yml"
+			report.recordOutput(&AnsibleConfig{}, "idempotence", "TASK [backup : Rotate timestamped backup] ***\nchanged: [test]\n")
+
+			data, err := (&AggregateReport{Reports: []AnsibleReport{report}}).JUnit()
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, golden("junit_fail.xml", data))
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// markdownIcons are the icons of each stage status in the summary.
+var markdownIcons = map[string]string{
+	StagePassed:  "✅",
+	StageFailed:  "❌",
+	StageSkipped: "⏭",
+	StageNotRun:  "⏭",
+}
+
+// Markdown will return a summary of the reports as markdown, which is
+// a table of the stages of each distribution, the versions which were
+// used, and a collapsed section with the failed tasks and the end of
+// the output of every failed stage.
+func (aggregate *AggregateReport) Markdown() string {
+
+	reports := aggregate.Reports
+
+	// The columns are every stage of any distribution, in order.
+	columns := []string{}
+	seen := map[string]bool{}
+	for i := range reports {
+		for _, stage := range reports[i].stageResults() {
+			if !seen[stage.name] {
+				seen[stage.name] = true
+				columns = append(columns, stage.name)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "## Ansible Role Tester")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "| Distribution | %v |\n", strings.Join(columns, " | "))
+	fmt.Fprintf(&buf, "|---%v|\n", strings.Repeat("|---", len(columns)))
+
+	for i := range reports {
+		report := &reports[i]
+		cells := map[string]string{}
+		for _, stage := range report.stageResults() {
+			status := report.stageStatus(stage)
+			cells[stage.name] = markdownIcons[status]
+			if status == StagePassed || status == StageFailed {
+				cells[stage.name] += " " + stage.time.Round(time.Second).String()
+			}
+		}
+
+		row := []string{}
+		for _, column := range columns {
+			row = append(row, cells[column])
+		}
+		fmt.Fprintf(&buf, "| %v | %v |\n", report.distributionName(), strings.Join(row, " | "))
+	}
+
+	fmt.Fprintln(&buf)
+	for i := range reports {
+		report := &reports[i]
+		versions := []string{}
+		if report.Ansible.Version != "" {
+			versions = append(versions, "Ansible "+report.Ansible.Version)
+		}
+		if report.Docker.Version != "" {
+			versions = append(versions, "Docker "+report.Docker.Version)
+		}
+		if len(versions) > 0 {
+			fmt.Fprintf(&buf, "- %v: %v\n", report.distributionName(), strings.Join(versions, ", "))
+		}
+	}
+
+	for i := range reports {
+		report := &reports[i]
+		if len(report.Ansible.Run.SlowestTasks) == 0 {
+			continue
+		}
+		fmt.Fprintln(&buf)
+		fmt.Fprintf(&buf, "Slowest tasks on %v:\n", report.distributionName())
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, "| Task | Duration |")
+		fmt.Fprintln(&buf, "|---|---|")
+		for _, timing := range report.Ansible.Run.SlowestTasks {
+			fmt.Fprintf(&buf, "| %v | %v |\n", strings.Replace(timing.Task, "|", "\\|", -1), timing.Time)
+		}
+	}
+
+	for i := range reports {
+		report := &reports[i]
+		for _, stage := range report.stageResults() {
+			if report.stageStatus(stage) != StageFailed {
+				continue
+			}
+
+			fmt.Fprintln(&buf)
+			fmt.Fprintln(&buf, "<details>")
+			fmt.Fprintf(&buf, "<summary>%v %v %v</summary>\n", markdownIcons[StageFailed], report.distributionName(), stage.name)
+			fmt.Fprintln(&buf)
+			if tasks := report.stageFailedTasks(stage.name); len(tasks) > 0 {
+				fmt.Fprintln(&buf, "Failed tasks:")
+				for _, task := range tasks {
+					fmt.Fprintf(&buf, "- `%v`\n", task)
+				}
+				fmt.Fprintln(&buf)
+			}
+			if output := report.Ansible.Output[stage.name]; output != "" {
+				fmt.Fprintln(&buf, "```")
+				fmt.Fprintln(&buf, output)
+				fmt.Fprintln(&buf, "```")
+				fmt.Fprintln(&buf)
+			}
+			fmt.Fprintln(&buf, "</details>")
+		}
+	}
+
+	return buf.String()
+}
+
+// WriteMarkdown will write a markdown summary of the reports to the file.
+func (aggregate *AggregateReport) WriteMarkdown(filename string) error {
+	return ioutil.WriteFile(filename, []byte(aggregate.Markdown()), 0644)
+}
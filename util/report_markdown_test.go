@@ -0,0 +1,56 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMarkdownReport(t *testing.T) {
+
+	Convey("Writing a markdown summary", t, func() {
+
+		passed := AnsibleReport{}
+		passed.Ansible.Distribution = Ubuntu1804
+		passed.Ansible.Version = "2.9.27"
+		passed.Docker.Version = "20.10.7"
+		passed.Ansible.Stages = []string{"syntax", "converge", "idempotence"}
+		passed.Ansible.Syntax = true
+		passed.Ansible.Run.Result, passed.Ansible.Run.Time = true, 42*time.Second
+		passed.Ansible.Idempotence.Result, passed.Ansible.Idempotence.Time = true, 12*time.Second
+
+		failed := AnsibleReport{}
+		failed.Ansible.Distribution = CentOS7
+		failed.Ansible.Version = "2.9.27"
+		failed.Ansible.Skipped = []string{"syntax"}
+		failed.Ansible.Stages = []string{"converge", "idempotence"}
+		failed.Ansible.Run.Result, failed.Ansible.Run.Time = true, 40*time.Second
+		failed.Ansible.Idempotence.Time = 11 * time.Second
+		failed.Ansible.FailedIdempotenceTasks = map[string][]string{"test": {"backup : Rotate timestamped backup"}}
+		failed.recordOutput(&AnsibleConfig{}, "idempotence", "TASK [backup : Rotate timestamped backup] ***\nchanged: [test]\n")
+
+		So((&AggregateReport{Reports: []AnsibleReport{passed, failed}}).Markdown(), ShouldEqual, "## Ansible Role Tester\n"+
+			"\n"+
+			"| Distribution | syntax | converge | idempotence |\n"+
+			"|---|---|---|---|\n"+
+			"| ubuntu1804 | ✅ 0s | ✅ 42s | ✅ 12s |\n"+
+			"| centos7 | ⏭ | ✅ 40s | ❌ 11s |\n"+
+			"\n"+
+			"- ubuntu1804: Ansible 2.9.27, Docker 20.10.7\n"+
+			"- centos7: Ansible 2.9.27\n"+
+			"\n"+
+			"<details>\n"+
+			"<summary>❌ centos7 idempotence</summary>\n"+
+			"\n"+
+			"Failed tasks:\n"+
+			"- `backup : Rotate timestamped backup`\n"+
+			"\n"+
+			"```\n"+
+			"TASK [backup : Rotate timestamped backup] ***\n"+
+			"changed: [test]\n"+
+			"```\n"+
+			"\n"+
+			"</details>\n")
+	})
+}
@@ -0,0 +1,207 @@
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sarifLocationPattern matches the file, line and column which Ansible
+// includes in errors and warnings, ie "The error appears to be in
+// '/etc/ansible/roles/web/tasks/main.yml': line 5, column 3" or
+// "While constructing a mapping from /path/main.yml, line 5, column 3".
+var sarifLocationPattern = regexp.MustCompile(`'?(/[^\s',:]+\.ya?ml)'?(?:[:,] line (\d+)(?:, column (\d+))?)?`)
+
+// sarifRules are the rules of the findings in the SARIF report.
+var sarifRules = []sarifRule{
+	{ID: "ansible-syntax", ShortDescription: sarifMessage{Text: "Ansible syntax error"}},
+	{ID: "ansible-warning", ShortDescription: sarifMessage{Text: "Ansible warning"}},
+	{ID: "ansible-deprecation", ShortDescription: sarifMessage{Text: "Ansible deprecation warning"}},
+}
+
+// sarifLog is the root of a SARIF 2.1.0 document.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// sarifRun contains the findings of the tests.
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactURI `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+// sarifTool describes ansible-role-tester and its rules.
+type sarifTool struct {
+	Driver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	} `json:"driver"`
+}
+
+// sarifRule is a kind of finding.
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+// sarifMessage is the text of a rule or finding.
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// sarifResult is a single finding.
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+
+	// source is the output which names the file of the finding.
+	source string
+}
+
+// sarifLocation is the file and region of a finding.
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation sarifArtifactURI `json:"artifactLocation"`
+		Region           *sarifRegion     `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+// sarifArtifactURI is the location of a file.
+type sarifArtifactURI struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// sarifRegion is the line and column of a finding.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// SARIF will return the syntax errors and the warnings of the reports
+// as a SARIF 2.1.0 document, where the files in the container are
+// rewritten to files relative to the role on the host.
+func (aggregate *AggregateReport) SARIF() ([]byte, error) {
+
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "ansible-role-tester"
+	run.Tool.Driver.Version = Version
+	run.Tool.Driver.InformationURI = "https://github.com/fubarhouse/ansible-role-tester"
+	run.Tool.Driver.Rules = sarifRules
+
+	seen := map[string]bool{}
+	for i := range aggregate.Reports {
+		report := &aggregate.Reports[i]
+		config := &report.Ansible.Config
+		if config.HostPath != "" && run.OriginalURIBaseIDs == nil {
+			root, _ := filepath.Abs(config.HostPath)
+			run.OriginalURIBaseIDs = map[string]sarifArtifactURI{
+				"SRCROOT": {URI: "file://" + filepath.ToSlash(root) + "/"},
+			}
+		}
+
+		for _, result := range report.sarifResults() {
+			result.Locations = config.sarifLocations(result.source)
+			data, _ := json.Marshal(result)
+			if !seen[string(data)] {
+				seen[string(data)] = true
+				run.Results = append(run.Results, result)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return []byte{}, err
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteSARIF will write the findings of the reports as SARIF to the file.
+func (aggregate *AggregateReport) WriteSARIF(filename string) error {
+	data, err := aggregate.SARIF()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// sarifResults will return the findings of the report, which are the
+// syntax error when the syntax check failed and the warnings.
+func (report *AnsibleReport) sarifResults() []sarifResult {
+
+	results := []sarifResult{}
+
+	if report.Ran("syntax") && !report.Ansible.Syntax {
+		result := sarifResult{RuleID: "ansible-syntax", Level: "error"}
+		for _, line := range strings.Split(report.Ansible.Output["syntax"], "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "ERROR!") && result.Message.Text == "" {
+				result.Message.Text = strings.TrimSpace(strings.TrimPrefix(line, "ERROR!"))
+			}
+			if sarifLocationPattern.MatchString(line) && result.source == "" {
+				result.source = line
+			}
+		}
+		if result.Message.Text != "" {
+			results = append(results, result)
+		}
+	}
+
+	for _, warning := range report.Ansible.Warnings {
+		rule := "ansible-warning"
+		if strings.HasPrefix(warning, "[DEPRECATION WARNING]") {
+			rule = "ansible-deprecation"
+		}
+		message := strings.TrimSpace(warningPattern.ReplaceAllString(warning, ""))
+		message = strings.TrimSpace(strings.TrimPrefix(message, ":"))
+		results = append(results, sarifResult{RuleID: rule, Level: "warning", Message: sarifMessage{Text: message}, source: warning})
+	}
+
+	return results
+}
+
+// sarifLocations will return the location of the file in the message,
+// which is relative to the role on the host. Files outside of the role
+// have no location.
+func (config *AnsibleConfig) sarifLocations(message string) []sarifLocation {
+
+	match := sarifLocationPattern.FindStringSubmatch(message)
+	if match == nil {
+		return nil
+	}
+
+	hostPath, _ := filepath.Abs(config.HostPath)
+	path := ""
+	for _, root := range []string{config.RemotePath, hostPath} {
+		if root != "" && strings.HasPrefix(match[1], strings.TrimSuffix(root, "/")+"/") {
+			path = strings.TrimPrefix(match[1], strings.TrimSuffix(root, "/")+"/")
+			break
+		}
+	}
+	if path == "" {
+		return nil
+	}
+
+	location := sarifLocation{}
+	location.PhysicalLocation.ArtifactLocation = sarifArtifactURI{URI: filepath.ToSlash(path), URIBaseID: "SRCROOT"}
+	if line, err := strconv.Atoi(match[2]); err == nil {
+		location.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+		location.PhysicalLocation.Region.StartColumn, _ = strconv.Atoi(match[3])
+	}
+	return []sarifLocation{location}
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSARIFReport(t *testing.T) {
+
+	Convey("Writing the findings as SARIF", t, func() {
+
+		report := AnsibleReport{}
+		report.Ansible.Distribution = CentOS7
+		report.Ansible.Config.HostPath = "/home/user/web"
+		report.Ansible.Config.RemotePath = "/etc/ansible/roles/role_under_test"
+		report.Ansible.Stages = []string{"syntax"}
+		report.Ansible.Warnings = []string{
+			"[WARNING]: While constructing a mapping from /etc/ansible/roles/role_under_test/tasks/main.yml, line 5, column 3, found a duplicate dict key (name).",
+			"[DEPRECATION WARNING]: The 'include' module is deprecated, use 'include_tasks'.",
+		}
+		report.recordOutput(&AnsibleConfig{}, "syntax", "ERROR! conflicting action statements: apt, yum\n\n"+
+			"The error appears to be in '/etc/ansible/roles/role_under_test/tasks/install.yml': line 12, column 3, but may\n"+
+			"be elsewhere in the file depending on the exact syntax problem.\n")
+
+		Convey("Findings are rewritten to the files of the role", func() {
+			data, err := (&AggregateReport{Reports: []AnsibleReport{report, report}}).SARIF()
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, golden("report.sarif", data))
+		})
+
+		Convey("The document is SARIF 2.1.0", func() {
+			data, _ := (&AggregateReport{Reports: []AnsibleReport{report}}).SARIF()
+			document := sarifLog{}
+			So(json.Unmarshal(data, &document), ShouldBeNil)
+			So(document.Version, ShouldEqual, "2.1.0")
+			So(document.Runs, ShouldHaveLength, 1)
+			So(document.Runs[0].Results, ShouldHaveLength, 3)
+			So(document.Runs[0].Results[0].Level, ShouldEqual, "error")
+			So(document.Runs[0].Results[2].Locations, ShouldBeEmpty)
+		})
+
+		Convey("Files outside of the role have no location", func() {
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test"}
+			So(config.sarifLocations("from /usr/lib/python3/site.yml, line 1"), ShouldBeEmpty)
+			So(config.sarifLocations("from /home/user/web/handlers/main.yml, line 1")[0].PhysicalLocation.ArtifactLocation.URI, ShouldEqual, "handlers/main.yml")
+		})
+	})
+}
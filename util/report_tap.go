@@ -0,0 +1,76 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// TAP will return the report as a TAP version 13 stream, with a test
+// point for each stage of the distribution. Every test point has a YAML
+// diagnostic block with the duration and the names of the failed tasks.
+func (report *AnsibleReport) TAP() string {
+
+	name := report.distributionName()
+
+	stages := report.stageResults()
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "TAP version 13")
+	fmt.Fprintf(&buf, "1..%v\n", len(stages))
+
+	for i, stage := range stages {
+		status := report.stageStatus(stage)
+
+		switch status {
+		case StageFailed:
+			fmt.Fprintf(&buf, "not ok %v - %v %v\n", i+1, name, stage.name)
+		case StageSkipped:
+			fmt.Fprintf(&buf, "ok %v - %v %v # SKIP skipped\n", i+1, name, stage.name)
+		case StageNotRun:
+			fmt.Fprintf(&buf, "ok %v - %v %v # SKIP not run after an earlier failure\n", i+1, name, stage.name)
+		default:
+			fmt.Fprintf(&buf, "ok %v - %v %v\n", i+1, name, stage.name)
+		}
+
+		if status == StageSkipped || status == StageNotRun {
+			continue
+		}
+
+		fmt.Fprintln(&buf, "  ---")
+		fmt.Fprintf(&buf, "  duration: %v\n", stage.time)
+		if tasks := report.stageFailedTasks(stage.name); status == StageFailed && len(tasks) > 0 {
+			fmt.Fprintln(&buf, "  failed_tasks:")
+			for _, task := range tasks {
+				fmt.Fprintf(&buf, "    - %v\n", strconv.Quote(task))
+			}
+		}
+		fmt.Fprintln(&buf, "  ...")
+	}
+
+	return buf.String()
+}
+
+// stageFailedTasks will return the names of the tasks which caused the
+// stage to fail, including the changed tasks of the idempotence test.
+func (report *AnsibleReport) stageFailedTasks(stage string) []string {
+
+	tasks := append([]string{}, report.Ansible.FailedTasks[stage]...)
+	if stage != "idempotence" {
+		return tasks
+	}
+
+	seen := map[string]bool{}
+	for _, task := range tasks {
+		seen[task] = true
+	}
+	for _, host := range sortedKeys(report.Ansible.FailedIdempotenceTasks) {
+		for _, task := range report.Ansible.FailedIdempotenceTasks[host] {
+			if !seen[task] {
+				seen[task] = true
+				tasks = append(tasks, task)
+			}
+		}
+	}
+	return tasks
+}
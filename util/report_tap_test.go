@@ -0,0 +1,41 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTAPReport(t *testing.T) {
+
+	Convey("Writing the report as TAP", t, func() {
+		report := AnsibleReport{}
+		report.Ansible.Distribution = Ubuntu1804
+		report.Ansible.Stages = []string{"syntax", "converge", "idempotence"}
+		report.Ansible.Syntax = true
+		report.Ansible.Run.Result, report.Ansible.Run.Time = true, 42*time.Second
+		report.Ansible.Idempotence.Time = 12 * time.Second
+		report.Ansible.FailedIdempotenceTasks = map[string][]string{"test": {"backup : Rotate timestamped backup"}}
+		report.Ansible.Config.VerifyPlaybook = "tests/verify.yml"
+
+		So(report.TAP(), ShouldEqual, `TAP version 13
+1..4
+ok 1 - ubuntu1804 syntax
+  ---
+  duration: 0s
+  ...
+ok 2 - ubuntu1804 converge
+  ---
+  duration: 42s
+  ...
+not ok 3 - ubuntu1804 idempotence
+  ---
+  duration: 12s
+  failed_tasks:
+    - "backup : Rotate timestamped backup"
+  ...
+ok 4 - ubuntu1804 verify # SKIP not run after an earlier failure
+`)
+	})
+}
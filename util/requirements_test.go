@@ -0,0 +1,90 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequirements(t *testing.T) {
+
+	Convey("Installing the requirements of the role", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		remote := "/etc/ansible/roles/role_under_test"
+		requirements := remote + "/tests/requirements.yml"
+		install := "test ansible-galaxy install -r " + requirements + " -p /etc/ansible/roles"
+
+		Convey("The requirements file of the role is found", func() {
+			dir, _ := ioutil.TempDir("", "requirements")
+			defer os.RemoveAll(dir)
+
+			config := AnsibleConfig{HostPath: dir, RemotePath: remote}
+			MapRequirements(&config)
+			So(config.RequirementsFile, ShouldEqual, "")
+
+			os.MkdirAll(filepath.Join(dir, "tests"), 0755)
+			ioutil.WriteFile(filepath.Join(dir, "tests", "requirements.yml"), []byte("- src: geerlingguy.java\n"), 0644)
+			MapRequirements(&config)
+			So(config.RequirementsFile, ShouldEqual, requirements)
+
+			config = AnsibleConfig{HostPath: dir, RemotePath: remote, Remote: true}
+			MapRequirements(&config)
+			So(config.RequirementsFile, ShouldEqual, filepath.Join(dir, "tests", "requirements.yml"))
+		})
+
+		Convey("The requirements are installed into the roles path", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+
+			_, err := dist.RoleInstall(&AnsibleConfig{Quiet: true, RequirementsFile: requirements})
+			So(err, ShouldBeNil)
+			So(fake.commands[0], ShouldResemble, []string{"exec", "--tty", "test", "ansible-galaxy", "install", "-r", requirements, "-p", "/etc/ansible/roles"})
+
+			fake.commands = nil
+			_, err = dist.RoleInstall(&AnsibleConfig{Quiet: true, RequirementsFile: requirements, ForceRequirements: true})
+			So(err, ShouldBeNil)
+			So(fake.commands[0][len(fake.commands[0])-1], ShouldEqual, "--force")
+		})
+
+		Convey("The requirements are installed before the syntax check", func() {
+			dist := Ubuntu1804
+			config := AnsibleConfig{Quiet: true, RequirementsFile: requirements}
+			report := AnsibleReport{}
+			stages := dist.Stages(&config, &report)
+			So(stages[0].Name, ShouldEqual, "requirements")
+			So(stages[1].Name, ShouldEqual, "syntax")
+			So(report.stageResults()[0].name, ShouldEqual, "requirements")
+
+			So(dist.Stages(&AnsibleConfig{Quiet: true}, &AnsibleReport{})[0].Name, ShouldEqual, "syntax")
+		})
+
+		Convey("A requirement which can't be resolved fails the run", func() {
+			fake := &fakeEngine{
+				outputs: map[string][]string{install: {"ERROR! - you can use --ignore-errors to skip failed roles and finish processing the list."}},
+				failing: map[string]bool{install: true},
+			}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+			config := AnsibleConfig{Quiet: true, RequirementsFile: requirements}
+			report := AnsibleReport{}
+
+			So(report.RunStages(&config, dist.Stages(&config, &report)), ShouldBeFalse)
+			So(report.Ansible.Stages, ShouldResemble, []string{"requirements"})
+			So(report.Ansible.Requirements.Result, ShouldBeFalse)
+			So(report.Ansible.Output["requirements"], ShouldContainSubstring, "--ignore-errors")
+			So(report.ExitCode(), ShouldEqual, AnsibleRequirementsCode)
+		})
+	})
+}
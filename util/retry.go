@@ -0,0 +1,70 @@
+package util
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// retryBackoff is the delay before the first retry of a
+// failed playbook, which doubles for every retry after it.
+var retryBackoff = 5 * time.Second
+
+// AttemptReport contains the result of a single attempt to run
+// a playbook when the role run is retried on failure.
+type AttemptReport struct {
+	File    string
+	Attempt int
+	Result  bool
+	Time    time.Duration
+}
+
+// retryRun will call run for the playbook until it succeeds or the
+// configured number of retries have been used, waiting longer before
+// each retry. Every attempt is recorded in the report, and only the
+// stats and task timings of the final attempt are kept. Nothing is
+// retried once the run was interrupted.
+func (report *AnsibleReport) retryRun(config *AnsibleConfig, playbook string, run func() (bool, time.Duration)) (bool, time.Duration) {
+
+	now := time.Now()
+	backoff := retryBackoff
+	stats := report.Ansible.Run.Stats
+	tasks := report.Ansible.Run.SlowestTasks
+
+	for attempt := 1; ; attempt++ {
+		report.Ansible.Run.Stats = stats
+		report.Ansible.Run.SlowestTasks = tasks
+		result, duration := run()
+
+		if config.Retries > 0 {
+			report.Ansible.Run.Attempts = append(report.Ansible.Run.Attempts, AttemptReport{
+				File:    playbook,
+				Attempt: attempt,
+				Result:  result,
+				Time:    duration,
+			})
+		}
+
+		if result {
+			return true, time.Since(now)
+		}
+
+		// An interrupted run is not a failed attempt, and the attempts
+		// share the timeout of the stage.
+		if attempt > config.Retries || Interrupted() || config.stageContext().Err() != nil {
+			return false, time.Since(now)
+		}
+
+		if !config.Quiet {
+			log.Warnf("Attempt %v of %v to run %v failed, retrying in %v", attempt, config.Retries+1, playbook, backoff)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-interruption():
+			return false, time.Since(now)
+		case <-config.stageContext().Done():
+			return false, time.Since(now)
+		}
+		backoff *= 2
+	}
+}
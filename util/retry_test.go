@@ -0,0 +1,63 @@
+package util
+
+import (
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetryRun(t *testing.T) {
+
+	Convey("Retrying a failed role run", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+		retryBackoff = 0
+
+		// flaky returns a run which only passes on the second call.
+		flaky := func(calls *int) func() (bool, time.Duration) {
+			return func() (bool, time.Duration) {
+				*calls++
+				return *calls == 2, time.Millisecond
+			}
+		}
+
+		Convey("Failures are not retried by default", func() {
+			calls := 0
+			report := AnsibleReport{}
+			result, _ := report.retryRun(&AnsibleConfig{}, "playbook.yml", flaky(&calls))
+			So(result, ShouldBeFalse)
+			So(calls, ShouldEqual, 1)
+			So(report.Ansible.Run.Attempts, ShouldBeEmpty)
+		})
+
+		Convey("Interrupted runs are not retried", func() {
+			defer atomic.StoreInt32(&interrupted, 0)
+			calls := 0
+			report := AnsibleReport{}
+			result, _ := report.retryRun(&AnsibleConfig{Retries: 3}, "playbook.yml", func() (bool, time.Duration) {
+				calls++
+				Interrupt()
+				return false, time.Millisecond
+			})
+			So(result, ShouldBeFalse)
+			So(calls, ShouldEqual, 1)
+			So(report.Ansible.Run.Attempts, ShouldHaveLength, 1)
+		})
+
+		Convey("Each attempt is recorded until it passes", func() {
+			calls := 0
+			report := AnsibleReport{}
+			result, _ := report.retryRun(&AnsibleConfig{Retries: 3}, "playbook.yml", flaky(&calls))
+			So(result, ShouldBeTrue)
+			So(calls, ShouldEqual, 2)
+			So(report.Ansible.Run.Attempts, ShouldResemble, []AttemptReport{
+				{File: "playbook.yml", Attempt: 1, Result: false, Time: time.Millisecond},
+				{File: "playbook.yml", Attempt: 2, Result: true, Time: time.Millisecond},
+			})
+		})
+	})
+}
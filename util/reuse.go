@@ -0,0 +1,68 @@
+package util
+
+import (
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reuseLabels will return the labels which identify the reusable
+// container of the role and the image of the distribution.
+func reuseLabels(dist *Distribution, config *AnsibleConfig) []string {
+	role, err := filepath.Abs(config.HostPath)
+	if err != nil {
+		role = config.HostPath
+	}
+	return []string{
+		containerLabel + ".reuse=true",
+		containerLabel + ".role=" + role,
+		containerLabel + ".image=" + dist.Container,
+	}
+}
+
+// DockerReuse will find the container which a previous run with Reuse
+// created for the role and distribution, which is started when it was
+// stopped. It returns false when there is no container to reuse.
+func (dist *Distribution) DockerReuse(config *AnsibleConfig, report *AnsibleReport) bool {
+
+	ps := psCommand{All: true}
+	for _, label := range reuseLabels(dist, config) {
+		ps.Filters = append(ps.Filters, "label="+label)
+	}
+
+	out, err := config.dockerExec(ps, false)
+	names := strings.Fields(out)
+	if err != nil || len(names) == 0 {
+		return false
+	}
+	dist.CID = names[0]
+
+	if !dist.DockerCheck() {
+		if !config.Quiet {
+			log.Printf("Starting %v", dist.CID)
+		}
+		if _, err := config.dockerExec(startCommand{Container: dist.CID}, false); err != nil {
+			return false
+		}
+	}
+
+	if !config.Quiet {
+		log.Printf("Reusing %v", dist.CID)
+	}
+	report.Docker.Reused = true
+
+	// Copied roles are synced with the changes since the last run.
+	report.Docker.Transfer = config.transfer()
+	if config.Copy && !dist.DockerSync(config, report) {
+		return false
+	}
+
+	if len(config.Publish) > 0 {
+		if err := dist.DockerPorts(report); err != nil {
+			log.Errorln(err)
+		}
+	}
+
+	return dist.DockerCheck()
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerReuse(t *testing.T) {
+
+	Convey("Reusing the container of a previous run", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		dist := func() Distribution {
+			return Distribution{CID: "1600000000", Container: "fubarhouse/docker-ansible:bionic", Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+		}
+		config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", Reuse: true, Quiet: true}
+
+		Convey("Reusable containers are labelled with the role and image", func() {
+			d := dist()
+			args := buildRunCommand(&d, &config, &AnsibleReport{}).args()
+			So(args, ShouldContain, "--label=ansible-role-tester.reuse=true")
+			So(args, ShouldContain, "--label=ansible-role-tester.role=/home/user/web")
+			So(args, ShouldContain, "--label=ansible-role-tester.image=fubarhouse/docker-ansible:bionic")
+		})
+
+		Convey("A running container is found by its labels", func() {
+			fake := &fakeEngine{running: []string{"previous"}, labelled: []string{"previous"}}
+			engine = fake
+			d := dist()
+			report := AnsibleReport{}
+
+			So(d.DockerReuse(&config, &report), ShouldBeTrue)
+			So(d.CID, ShouldEqual, "previous")
+			So(report.Docker.Reused, ShouldBeTrue)
+			So(fake.commands[0], ShouldContain, "label=ansible-role-tester.role=/home/user/web")
+			So(fake.commands, ShouldNotContain, []string{"start", "previous"})
+		})
+
+		Convey("A stopped container is started", func() {
+			fake := &fakeEngine{labelled: []string{"previous"}}
+			engine = fake
+			d := dist()
+			report := AnsibleReport{}
+
+			So(d.DockerReuse(&config, &report), ShouldBeTrue)
+			So(fake.commands, ShouldContain, []string{"start", "previous"})
+		})
+
+		Convey("Nothing is reused without a container", func() {
+			engine = &fakeEngine{}
+			d := dist()
+			report := AnsibleReport{}
+
+			So(d.DockerReuse(&config, &report), ShouldBeFalse)
+			So(d.CID, ShouldEqual, "1600000000")
+			So(report.Docker.Reused, ShouldBeFalse)
+		})
+
+		Convey("Reused containers are kept unless destroyed", func() {
+			fake := &fakeEngine{running: []string{"previous"}}
+			engine = fake
+			d := dist()
+			d.CID = "previous"
+			report := AnsibleReport{}
+
+			d.DockerCleanup(&config, &report)
+			So(report.Docker.Kept, ShouldBeTrue)
+			So(fake.commands, ShouldNotContain, []string{"stop", "previous"})
+
+			destroy := config
+			destroy.Destroy = true
+			report = AnsibleReport{}
+			d.DockerCleanup(&destroy, &report)
+			So(report.Docker.Kept, ShouldBeFalse)
+			So(fake.commands, ShouldContain, []string{"stop", "previous"})
+		})
+
+		Convey("The prepare stage is skipped in reused containers", func() {
+			d := dist()
+			report := AnsibleReport{}
+			report.Ansible.Prepare.Enabled = true
+			report.Docker.Reused = true
+			for _, stage := range d.Stages(&config, &report) {
+				if stage.Name == "prepare" {
+					So(stage.Skip, ShouldBeTrue)
+				}
+			}
+		})
+	})
+}
@@ -2,8 +2,12 @@ package util
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
 	"os"
 	"time"
 )
@@ -19,145 +23,492 @@ func (config *AnsibleConfig) IsAnsibleRole() bool {
 	return true
 }
 
-// RoleInstall will install the requirements if the file is configured.
-func (dist *Distribution) RoleInstall(config *AnsibleConfig) bool {
+// roleNamePrefixes are the prefixes of the names of the repositories of
+// roles which are not part of the name of the role, like Galaxy does.
+var roleNamePrefixes = []string{"ansible-role-", "ansible_role_", "ansible-"}
 
-	if config.RequirementsFile != "" {
-		req := fmt.Sprintf("%v/%v", config.RemotePath, config.RequirementsFile)
-		log.Printf("Installing requirements from %v\n", req)
-		args := []string{
-			"exec",
-			"--tty",
-			dist.CID,
-			"ansible-galaxy",
-			"install",
-			"-r",
-			req,
+// roleDirectoryName will return the name of the role from the name of
+// its directory, without the common prefixes of repositories of roles.
+func roleDirectoryName(path string) string {
+	name := filepath.Base(path)
+	for _, prefix := range roleNamePrefixes {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return strings.TrimPrefix(name, prefix)
 		}
+	}
+	return name
+}
+
+// ResolveRoleName will return the name the role should be referenced by.
+// The configured name is preferred, followed by the galaxy metadata
+// in meta/main.yml and finally the directory name of the role without
+// its prefix, ie web for ansible-role-web. The namespace of the galaxy
+// metadata is prefixed to the name, ie acme.web.
+func (config *AnsibleConfig) ResolveRoleName() string {
+
+	if config.RoleName != "" {
+		return config.RoleName
+	}
+
+	meta := struct {
+		GalaxyInfo struct {
+			RoleName  string `yaml:"role_name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"galaxy_info"`
+	}{}
+
+	data, err := ioutil.ReadFile(filepath.Join(config.HostPath, "meta", "main.yml"))
+	if err != nil || yaml.Unmarshal(data, &meta) != nil {
+		return roleDirectoryName(config.HostPath)
+	}
+
+	name := meta.GalaxyInfo.RoleName
+	if name == "" {
+		name = roleDirectoryName(config.HostPath)
+	}
+	if meta.GalaxyInfo.Namespace != "" {
+		return meta.GalaxyInfo.Namespace + "." + name
+	}
+	return name
+}
+
+// RoleLink will create a symlink to the role inside of the roles path,
+// so playbooks can reference the role by name. For remote runs the link
+// is created on the host, because that is where Ansible finds roles.
+// Existing files are never replaced. The returned function will remove
+// any temporary files and should be called once testing is complete.
+func (dist *Distribution) RoleLink(config *AnsibleConfig) (bool, func()) {
 
-		// Add inventory file if configured
-		if config.Inventory != "" {
-			args = append(args, fmt.Sprintf("-i=%v", config.Inventory))
+	name := config.ResolveRoleName()
+	cleanup := func() {}
+
+	if !config.Remote {
+		path := config.galaxyRolesPath()
+		if !config.Quiet {
+			log.Infof("Linking role as %v/%v", path, name)
 		}
+		if _, err := config.dockerExec(dist.containerExec(
+			"sh",
+			"-c",
+			`mkdir -p "$1" && { [ -e "$1/$2" ] || ln -s "$0" "$1/$2"; }`,
+			config.RemotePath,
+			path,
+			name,
+		), false); err != nil {
+			log.Errorln(err)
+			return false, cleanup
+		}
+		return true, cleanup
+	}
 
-		// Add verbose if configured
-		if config.Verbose {
-			args = append(args, "-vvvv")
+	if config.RolesPath == "" {
+		dir, err := ioutil.TempDir("", "ansible-role-tester-roles")
+		if err != nil {
+			log.Errorln(err)
+			return false, cleanup
+		}
+		config.RolesPath = dir
+		cleanup = func() {
+			os.RemoveAll(dir)
 		}
+	}
 
-		if !config.Quiet {
-			_, err := DockerExec(args, true)
-			if err != nil {
-				log.Errorln(err)
-				return false
-			}
-		} else {
-			_, err := DockerExec(args, false)
-			if err != nil {
-				log.Errorln(err)
-				return false
-			}
+	link := filepath.Join(config.RolesPath, name)
+	if !config.Quiet {
+		log.Infof("Linking role as %v", link)
+	}
+	if _, err := os.Lstat(link); os.IsNotExist(err) {
+		if err := os.MkdirAll(config.RolesPath, 0755); err != nil {
+			log.Errorln(err)
+			return false, cleanup
 		}
+		if err := os.Symlink(config.HostPath, link); err != nil {
+			log.Errorln(err)
+			return false, cleanup
+		}
+	}
 
-	} else {
+	return true, cleanup
+}
+
+// galaxyRolesPath will return the roles path the role is linked into,
+// which the requirements are also installed into. Remote runs without a
+// roles path use the roles path of the host.
+func (config *AnsibleConfig) galaxyRolesPath() string {
+	if config.RolesPath == "" && !config.Remote {
+		return rolesPath
+	}
+	return config.RolesPath
+}
+
+// RoleInstall will install the requirements into the roles path with
+// ansible-galaxy, inside of the container or on the host when the run
+// is remote. Installed roles are only replaced with ForceRequirements.
+// The output of ansible-galaxy is returned, and an error is returned
+// when any of the requirements can't be resolved.
+func (dist *Distribution) RoleInstall(config *AnsibleConfig) (string, error) {
+
+	if config.RequirementsFile == "" {
 		if !config.Quiet {
 			log.Warnln("Requirements file is not configured (empty/null), skipping...")
-			return false
 		}
+		return "", nil
 	}
-	return true
+
+	if !config.Quiet {
+		log.Printf("Installing requirements from %v\n", config.RequirementsFile)
+	}
+	args := []string{"install", "-r", config.RequirementsFile}
+	if path := config.galaxyRolesPath(); path != "" {
+		args = append(args, "-p", path)
+	}
+
+	// Replace the roles which are already installed.
+	if config.ForceRequirements {
+		args = append(args, "--force")
+	}
+
+	// Add verbose if configured
+	if config.Verbose {
+		args = append(args, "-vvvv")
+	}
+
+	return dist.ansibleGalaxy(config, args)
+}
+
+// ansibleGalaxy will run ansible-galaxy inside of the container, or on
+// the host when the run is remote.
+func (dist *Distribution) ansibleGalaxy(config *AnsibleConfig, args []string) (string, error) {
+	if config.Remote {
+		return config.ansibleGalaxy(args, !config.Quiet)
+	}
+	return config.dockerExec(buildExecCommand(dist, config, append([]string{"ansible-galaxy"}, args...)...), !config.Quiet)
+}
+
+// RoleRequirements will install the roles and collections the role
+// requires before it is tested, writing the output of ansible-galaxy to
+// the report. The role can't be tested when any of its requirements
+// can't be resolved.
+func (dist *Distribution) RoleRequirements(config *AnsibleConfig, report *AnsibleReport) (bool, time.Duration) {
+
+	now := time.Now()
+	var out string
+	var err error
+	if config.RequirementsFile != "" {
+		out, err = dist.RoleInstall(config)
+	}
+	if err == nil && config.installsDependencies() {
+		var dependencies string
+		dependencies, err = dist.DependencyInstall(config)
+		out += dependencies
+	}
+	if err == nil && config.installsCollections() {
+		var collections string
+		collections, err = dist.CollectionInstall(config)
+		out += collections
+	}
+	report.recordOutput(config, "requirements", out)
+	if err != nil {
+		log.Errorln(err)
+		log.Errorln("Requirements: FAIL")
+		return false, time.Since(now)
+	}
+
+	if !config.Quiet {
+		log.Infof("Requirements were installed in %v", time.Since(now))
+	}
+	return true, time.Since(now)
 }
 
 // RoleSyntaxCheck will run a syntax check of the mounted volume inside
 // of the active container. This helps with pure isolation of the syntax
 // to separate it from other potential Ansible versions.
-func (dist *Distribution) RoleSyntaxCheck(config *AnsibleConfig) bool {
+func (dist *Distribution) RoleSyntaxCheck(config *AnsibleConfig, report *AnsibleReport) bool {
 
 	// Ansible syntax check.
 	if !config.Quiet {
 		log.Infoln("Checking role syntax...")
 	}
 
-	args := []string{
-		"exec",
-		"--tty",
-		dist.CID,
-		"ansible-playbook",
-		"--syntax-check",
-		fmt.Sprintf("%v/%v", config.RemotePath, config.PlaybookFile),
+	exec := buildExecCommand(dist, config, "ansible-playbook", "--syntax-check")
+
+	// Check every playbook which will be run.
+	for _, playbook := range config.playbooks() {
+		exec.Cmd = append(exec.Cmd, fmt.Sprintf("%v/%v", config.RemotePath, playbook))
 	}
 
 	// Add inventory file if configured
 	if config.Inventory != "" {
-		args = append(args, fmt.Sprintf("-i=%v", config.Inventory))
+		exec.Cmd = append(exec.Cmd, fmt.Sprintf("-i=%v", config.Inventory))
 	}
 
+	// Hosts in the mounted inventory file all refer to this container.
+	if config.Inventory == inventoryPath {
+		exec.Cmd = append(exec.Cmd, "--connection=local")
+	}
+
+	// Add the arguments common to every playbook run.
+	exec.Cmd = append(exec.Cmd, buildAnsibleArgs(config)...)
+
 	// Add verbose if configured
 	if config.Verbose {
-		args = append(args, "-vvvv")
+		exec.Cmd = append(exec.Cmd, "-vvvv")
 	}
 
+	// Add the extra arguments after every other argument.
+	exec = withAnsibleExecArgs(config, exec)
+
+	var out string
+	var err error
 	if !config.Quiet {
-		_, err := DockerExec(args, true)
-		if err != nil {
+		out, err = config.dockerExec(exec, true)
+	} else {
+		out, err = config.dockerExec(exec, false)
+	}
+	report.recordOutput(config, "syntax", out)
+	if err != nil {
+		if !config.Quiet {
 			log.Errorln("Syntax check: FAIL")
-			return false
 		} else {
-			log.Infoln("Syntax check: PASS")
-			return true
-		}
-	} else {
-		_, err := DockerExec(args, false)
-		if err != nil {
 			log.Errorln(err)
-			return false
 		}
+		return false
+	}
+
+	// Warnings fail the syntax check in strict mode.
+	if !report.checkWarnings(config, out) {
+		log.Errorln("Syntax check: FAIL")
+		return false
+	}
+
+	if !config.Quiet {
+		log.Infoln("Syntax check: PASS")
 	}
 	return true
 }
 
-// RoleTest will execute the specified playbook inside
-// the container once. It will assemble a request to
-// pass into the Docker execution function DockerRun.
-func (dist *Distribution) RoleTest(config *AnsibleConfig) (bool, time.Duration) {
+// RoleTest will execute the specified playbooks inside
+// the container once, in order. It will assemble a request
+// to pass into the Docker execution function DockerRun.
+// The run stops at the first playbook which fails.
+func (dist *Distribution) RoleTest(config *AnsibleConfig, report *AnsibleReport) (bool, time.Duration) {
 
 	// Test role.
 	if !config.Quiet {
 		log.Infoln("Running the role...")
 	}
 
-	args := []string{
-		"exec",
-		"--tty",
-		dist.CID,
-		"ansible-playbook",
-		fmt.Sprintf("%v/%v", config.RemotePath, config.PlaybookFile),
+	now := time.Now()
+	for _, playbook := range config.playbooks() {
+		result, duration := report.retryRun(config, playbook, func() (bool, time.Duration) {
+			return dist.roleTestPlaybook(config, report, playbook)
+		})
+		report.Ansible.Run.Playbooks = append(report.Ansible.Run.Playbooks, PlaybookReport{
+			File:   playbook,
+			Result: result,
+			Time:   duration,
+		})
+		if !result {
+			return false, time.Since(now)
+		}
 	}
 
+	if !config.Quiet {
+		log.Infof("Role ran in %v", time.Since(now))
+	}
+	return true, time.Since(now)
+}
+
+// roleTestPlaybook will execute a single playbook inside the container.
+func (dist *Distribution) roleTestPlaybook(config *AnsibleConfig, report *AnsibleReport, playbook string) (bool, time.Duration) {
+
+	exec := buildExecCommand(dist, config.profiled(), "ansible-playbook", fmt.Sprintf("%v/%v", config.RemotePath, playbook))
+
 	// Add inventory file if configured
 	if config.Inventory != "" {
-		args = append(args, fmt.Sprintf("-i=%v", config.Inventory))
+		exec.Cmd = append(exec.Cmd, fmt.Sprintf("-i=%v", config.Inventory))
+	}
+
+	// Hosts in the mounted inventory file all refer to this container.
+	if config.Inventory == inventoryPath {
+		exec.Cmd = append(exec.Cmd, "--connection=local")
 	}
 
+	// Add diff if configured
+	if config.Diff {
+		exec.Cmd = append(exec.Cmd, "--diff")
+	}
+
+	// Add the arguments for the role run.
+	exec.Cmd = append(exec.Cmd, buildRunArgs(config)...)
+
+	// Add the arguments common to every playbook run.
+	exec.Cmd = append(exec.Cmd, buildAnsibleArgs(config)...)
+
 	// Add verbose if configured
 	if config.Verbose {
-		args = append(args, "-vvvv")
+		exec.Cmd = append(exec.Cmd, "-vvvv")
 	}
 
+	// Add the extra arguments after every other argument.
+	exec = withAnsibleExecArgs(config, exec)
+
+	// Record the run which will reuse the cached facts.
+	report.factCacheReused(config)
+
+	var out string
+	var err error
 	now := time.Now()
 	if !config.Quiet {
-		if _, err := DockerExec(args, true); err != nil {
-			log.Errorln(err)
+		out, err = config.dockerExec(exec, true)
+	} else {
+		out, err = config.dockerExec(exec, false)
+	}
+	report.recordOutput(config, "converge", out)
+	report.recordTaskTimings(config, out)
+	if err == ErrTimeout {
+		report.timedOut("run", out)
+	}
+	if err != nil {
+		// Unreachable hosts exit with an error, but are still recorded.
+		stats, _ := config.playStats(out)
+		report.checkUnreachable("run", stats)
+		log.Errorln(err)
+		return false, time.Since(now)
+	}
+
+	// Warnings fail the role run in strict mode.
+	if !report.checkWarnings(config, out) {
+		return false, time.Since(now)
+	}
+
+	// The recap reports failed and unreachable hosts, which
+	// is only required with the json callback.
+	stats, err := config.playStats(out)
+	if err != nil && config.StdoutCallback == "json" {
+		log.Errorln(err)
+		return false, time.Since(now)
+	}
+	if err == nil {
+		report.Ansible.Run.Stats = report.Ansible.Run.Stats.Add(stats)
+		if !report.checkUnreachable("run", stats) || config.failedStats(stats) {
 			return false, time.Since(now)
 		}
+	}
+
+	return true, time.Since(now)
+}
+
+// RoleVerify will run the verify playbook inside the container
+// after the idempotence test, any failed task fails the tests.
+func (dist *Distribution) RoleVerify(config *AnsibleConfig, report *AnsibleReport) (bool, time.Duration) {
+
+	report.Ansible.Verify.Enabled = true
+
+	if !config.Quiet {
+		log.Infoln("Verifying the role...")
+	}
+
+	exec := buildExecCommand(dist, config, "ansible-playbook", fmt.Sprintf("%v/%v", config.RemotePath, config.VerifyPlaybook))
+
+	// Add inventory file if configured
+	if config.Inventory != "" {
+		exec.Cmd = append(exec.Cmd, fmt.Sprintf("-i=%v", config.Inventory))
+	}
+
+	// Hosts in the mounted inventory file all refer to this container.
+	if config.Inventory == inventoryPath {
+		exec.Cmd = append(exec.Cmd, "--connection=local")
+	}
+
+	// Add the arguments common to every playbook run.
+	exec.Cmd = append(exec.Cmd, buildAnsibleArgs(config)...)
+
+	// Add verbose if configured
+	if config.Verbose {
+		exec.Cmd = append(exec.Cmd, "-vvvv")
+	}
+
+	var out string
+	var err error
+	now := time.Now()
+	if !config.Quiet {
+		out, err = config.dockerExec(exec, true)
 	} else {
-		if _, err := DockerExec(args, false); err != nil {
-			log.Errorln(err)
+		out, err = config.dockerExec(exec, false)
+	}
+	report.recordOutput(config, "verify", out)
+	if err == ErrTimeout {
+		report.timedOut("verify", out)
+	}
+	if err != nil {
+		// Unreachable hosts exit with an error, but are still recorded.
+		stats, _ := config.playStats(out)
+		report.checkUnreachable("verify", stats)
+		log.Errorln("Verify: FAIL")
+		return false, time.Since(now)
+	}
+
+	// The recap reports failed and unreachable hosts, which
+	// is only required with the json callback.
+	stats, err := config.playStats(out)
+	if err != nil && config.StdoutCallback == "json" {
+		log.Errorln(err)
+		return false, time.Since(now)
+	}
+	if err == nil {
+		report.Ansible.Verify.Stats = stats
+		if !report.checkUnreachable("verify", stats) || config.failedStats(stats) {
+			log.Errorln("Verify: FAIL")
 			return false, time.Since(now)
 		}
 	}
+
 	if !config.Quiet {
-		log.Infof("Role ran in %v", time.Since(now))
+		log.Infoln("Verify: PASS")
+		log.Infof("Role was verified in %v", time.Since(now))
+	}
+	return true, time.Since(now)
+}
+
+// CheckTest will execute the specified playbook inside the
+// container once in check mode, reporting the changes which
+// would be made without applying them. Tasks which don't
+// support check mode are skipped and are not failures.
+func (dist *Distribution) CheckTest(config *AnsibleConfig) (bool, time.Duration) {
+
+	// Test role in check mode.
+	if !config.Quiet {
+		log.Infoln("Running the role in check mode...")
+	}
+
+	exec := buildExecCommand(dist, config, "ansible-playbook", fmt.Sprintf("%v/%v", config.RemotePath, config.PlaybookFile), "--check", "--diff")
+
+	// Add inventory file if configured
+	if config.Inventory != "" {
+		exec.Cmd = append(exec.Cmd, fmt.Sprintf("-i=%v", config.Inventory))
+	}
+
+	// Hosts in the mounted inventory file all refer to this container.
+	if config.Inventory == inventoryPath {
+		exec.Cmd = append(exec.Cmd, "--connection=local")
+	}
+
+	// Add the arguments common to every playbook run.
+	exec.Cmd = append(exec.Cmd, buildAnsibleArgs(config)...)
+
+	// Add verbose if configured
+	if config.Verbose {
+		exec.Cmd = append(exec.Cmd, "-vvvv")
+	}
+
+	now := time.Now()
+	if _, err := config.dockerExec(exec, !config.Quiet); err != nil {
+		log.Errorln(err)
+		return false, time.Since(now)
+	}
+	if !config.Quiet {
+		log.Infof("Check mode ran in %v", time.Since(now))
 	}
 	return true, time.Since(now)
 }
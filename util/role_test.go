@@ -0,0 +1,86 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoleName(t *testing.T) {
+
+	Convey("Referencing the role by its galaxy name", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		role := func(dir, meta string) string {
+			path := filepath.Join(dir, "ansible-role-web")
+			os.MkdirAll(path, 0755)
+			if meta != "" {
+				writeFixture(path, "meta/main.yml", meta)
+			}
+			return path
+		}
+
+		Convey("The name is read from the galaxy metadata", func() {
+			dir, _ := ioutil.TempDir("", "role")
+			defer os.RemoveAll(dir)
+
+			config := AnsibleConfig{HostPath: role(dir, `
+galaxy_info:
+  role_name: nginx
+  namespace: acme
+`)}
+			So(config.ResolveRoleName(), ShouldEqual, "acme.nginx")
+
+			config = AnsibleConfig{HostPath: role(dir, `
+galaxy_info:
+  namespace: acme
+`)}
+			So(config.ResolveRoleName(), ShouldEqual, "acme.web")
+
+			config.RoleName = "web_server"
+			So(config.ResolveRoleName(), ShouldEqual, "web_server")
+		})
+
+		Convey("The prefix of the directory is left out", func() {
+			So(roleDirectoryName("/src/ansible-role-web"), ShouldEqual, "web")
+			So(roleDirectoryName("/src/ansible_role_web"), ShouldEqual, "web")
+			So(roleDirectoryName("/src/ansible-web"), ShouldEqual, "web")
+			So(roleDirectoryName("/src/web"), ShouldEqual, "web")
+			So(roleDirectoryName("/src/ansible-"), ShouldEqual, "ansible-")
+
+			dir, _ := ioutil.TempDir("", "role")
+			defer os.RemoveAll(dir)
+			config := AnsibleConfig{HostPath: role(dir, "")}
+			So(config.ResolveRoleName(), ShouldEqual, "web")
+		})
+
+		Convey("The role is linked by its name in the container", func() {
+			dir, _ := ioutil.TempDir("", "role")
+			defer os.RemoveAll(dir)
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Ubuntu1804
+			dist.CID = "test"
+			meta := `
+galaxy_info:
+  role_name: web
+  namespace: acme
+`
+			config := AnsibleConfig{Quiet: true, HostPath: role(dir, meta), RemotePath: "/etc/ansible/roles/role_under_test"}
+
+			linked, unlink := dist.RoleLink(&config)
+			defer unlink()
+			So(linked, ShouldBeTrue)
+			So(fake.commands[0][5:], ShouldResemble, []string{"/etc/ansible/roles/role_under_test", "/etc/ansible/roles", "acme.web"})
+			So(NewReport(&config).Ansible.RoleName, ShouldEqual, "acme.web")
+		})
+	})
+}
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"strings"
+)
+
+// RoleContainer will return the name of the most recent running
+// container of the role, which is found by its labels. When the
+// distribution is not empty, only its containers are considered.
+func RoleContainer(config *AnsibleConfig, distribution string) string {
+
+	ps := psCommand{Filters: []string{"label=" + roleLabel + "=" + config.ResolveRoleName()}}
+	if distribution != "" {
+		ps.Filters = append(ps.Filters, "label="+distributionLabel+"="+distribution)
+	}
+
+	// Containers are listed with the most recent first.
+	out, err := config.dockerExec(ps, false)
+	names := strings.Fields(out)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// shell will return the shell of the container, which is bash unless
+// the image is too minimal to have it.
+func (dist *Distribution) shell() string {
+	if _, err := dockerExec(dist.containerExec("sh", "-c", "command -v bash"), false); err != nil {
+		return "sh"
+	}
+	return "bash"
+}
+
+// DockerShell will run an interactive shell in the container as the
+// user, or the user of the image when it is empty. The shell starts in
+// the directory the role is mounted at.
+func (dist *Distribution) DockerShell(config *AnsibleConfig, user string) error {
+
+	exec := execCommand{
+		Container:   dist.CID,
+		Cmd:         []string{dist.shell()},
+		TTY:         true,
+		Interactive: true,
+		User:        user,
+		Workdir:     config.RemotePath,
+	}
+
+	_, err := config.dockerExec(exec, true)
+	return err
+}
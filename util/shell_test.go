@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerShell(t *testing.T) {
+
+	Convey("Running a shell in the container of the role", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", Quiet: true}
+
+		Convey("The most recent container of the role is found by its labels", func() {
+			fake := &scriptedEngine{outputs: map[string]string{
+				"ps --filter label=art.role=web --format {{.Names}}":                                            "art-web-centos7-2\nart-web-ubuntu1804-1",
+				"ps --filter label=art.role=web --filter label=art.distribution=ubuntu1804 --format {{.Names}}": "art-web-ubuntu1804-1",
+			}}
+			engine = fake
+			So(RoleContainer(&config, ""), ShouldEqual, "art-web-centos7-2")
+			So(RoleContainer(&config, "ubuntu1804"), ShouldEqual, "art-web-ubuntu1804-1")
+			So(RoleContainer(&config, "debian9"), ShouldEqual, "")
+		})
+
+		Convey("Shells start in the role as the user", func() {
+			fake := &scriptedEngine{}
+			engine = fake
+			dist := Distribution{CID: "test"}
+
+			So(dist.DockerShell(&config, "ansible"), ShouldBeNil)
+			So(fake.commands[1], ShouldResemble, []string{"exec", "--tty", "--interactive", "--user", "ansible", "--workdir", "/etc/ansible/roles/role_under_test", "test", "bash"})
+		})
+
+		Convey("Minimal images without bash use sh", func() {
+			fake := &scriptedEngine{failures: map[string]bool{"exec test sh -c command -v bash": true}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+
+			So(dist.DockerShell(&config, ""), ShouldBeNil)
+			So(fake.commands[1], ShouldResemble, []string{"exec", "--tty", "--interactive", "--workdir", "/etc/ansible/roles/role_under_test", "test", "sh"})
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+
+	// ErrInterrupted is returned when a command was killed because
+	// the run was interrupted, ie by Ctrl-C.
+	ErrInterrupted = errors.New("the command was interrupted")
+
+	// interruptLock guards interruptChannel.
+	interruptLock sync.Mutex
+
+	// interruptChannel is closed when the run is interrupted, which
+	// stops the commands which are running at the time.
+	interruptChannel = make(chan struct{})
+
+	// interrupted is set once the run was interrupted.
+	interrupted int32
+
+	// signalsHandled is set when the signals are handled, which
+	// starts the commands in their own process group.
+	signalsHandled int32
+)
+
+// interruption will return the channel which is closed when the run is
+// interrupted while the commands which are started now are running.
+func interruption() <-chan struct{} {
+	interruptLock.Lock()
+	defer interruptLock.Unlock()
+	return interruptChannel
+}
+
+// Interrupt will stop the commands which are running and mark the run
+// as interrupted, so no further stages are run. Commands which are
+// started afterwards run as usual, so the container can be cleaned up
+// and the reports written.
+func Interrupt() {
+	interruptLock.Lock()
+	defer interruptLock.Unlock()
+	atomic.StoreInt32(&interrupted, 1)
+	close(interruptChannel)
+	interruptChannel = make(chan struct{})
+}
+
+// Interrupted will return true when the run was interrupted.
+func Interrupted() bool {
+	return atomic.LoadInt32(&interrupted) == 1
+}
+
+// HandleSignals will interrupt the run on the first SIGINT or SIGTERM,
+// and exit immediately with InterruptedCode on the second one.
+func HandleSignals() {
+
+	atomic.StoreInt32(&signalsHandled, 1)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		log.Warnln("Interrupted, cleaning up the container (interrupt again to exit immediately)")
+		Interrupt()
+		<-signals
+		Exit(InterruptedCode)
+	}()
+}
+
+// commandContext will return the context of a command, which is done
+// when the parent is done, ie once the timeout of the stage is reached,
+// or the run is interrupted. Without either the context is never done.
+func commandContext(parent context.Context) (context.Context, context.CancelFunc) {
+
+	if parent.Done() == nil && atomic.LoadInt32(&signalsHandled) == 0 {
+		return parent, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+
+	go func(interrupted <-chan struct{}) {
+		select {
+		case <-interrupted:
+			cancel()
+		case <-ctx.Done():
+		}
+	}(interruption())
+
+	return ctx, cancel
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInterrupt(t *testing.T) {
+
+	Convey("Interrupting the run", t, func() {
+
+		atomic.StoreInt32(&signalsHandled, 1)
+		defer func() {
+			atomic.StoreInt32(&signalsHandled, 0)
+			atomic.StoreInt32(&interrupted, 0)
+		}()
+
+		Convey("Running commands are killed", func() {
+			time.AfterFunc(50*time.Millisecond, Interrupt)
+			start := time.Now()
+			ctx, cancel := commandContext(context.Background())
+			defer cancel()
+			err := runCommand(ctx, exec.Command("sleep", "5"))
+			So(err, ShouldEqual, ErrInterrupted)
+			So(time.Since(start), ShouldBeLessThan, 2*time.Second)
+			So(Interrupted(), ShouldBeTrue)
+		})
+
+		Convey("Only commands which don't read the terminal get a process group of their own", func() {
+			ctx, cancel := commandContext(context.Background())
+			defer cancel()
+			cmd := exec.Command("true")
+			So(runCommand(ctx, cmd), ShouldBeNil)
+			So(cmd.SysProcAttr.Setpgid, ShouldBeTrue)
+
+			reader, writer, _ := os.Pipe()
+			defer reader.Close()
+			defer writer.Close()
+			cmd = exec.Command("true")
+			cmd.Stdin = reader
+			So(foreground(cmd), ShouldBeFalse)
+
+			if tty, err := os.Open("/dev/tty"); err == nil {
+				defer tty.Close()
+				cmd = exec.Command("true")
+				cmd.Stdin = tty
+				So(foreground(cmd), ShouldBeTrue)
+				So(runCommand(ctx, cmd), ShouldBeNil)
+				So(cmd.SysProcAttr, ShouldBeNil)
+			}
+		})
+
+		Convey("Commands started afterwards run, so the container can be cleaned up", func() {
+			Interrupt()
+			ctx, cancel := commandContext(context.Background())
+			defer cancel()
+			So(runCommand(ctx, exec.Command("true")), ShouldBeNil)
+		})
+
+		Convey("Requests of the Docker Engine API are cancelled", func() {
+			server, api := fakeDockerAPI(map[string]http.HandlerFunc{
+				"GET /version": func(w http.ResponseWriter, r *http.Request) {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(5 * time.Second):
+					}
+				},
+			})
+			defer server.Close()
+
+			ctx, cancel := commandContext(context.Background())
+			defer cancel()
+			time.AfterFunc(50*time.Millisecond, Interrupt)
+			var out bytes.Buffer
+			So(api.Command(ctx, versionCommand{}, false, &out), ShouldEqual, ErrInterrupted)
+		})
+
+		Convey("The remaining stages are not run", func() {
+			atomic.StoreInt32(&interrupted, 0)
+			report := AnsibleReport{}
+			ran := []string{}
+			result := report.RunStages(&AnsibleConfig{Quiet: true}, []Stage{
+				{Name: "syntax", Run: func() bool { ran = append(ran, "syntax"); return true }},
+				{Name: "converge", Run: func() bool { ran = append(ran, "converge"); Interrupt(); return false }},
+				{Name: "idempotence", Run: func() bool { ran = append(ran, "idempotence"); return true }},
+			})
+			So(result, ShouldBeFalse)
+			So(ran, ShouldResemble, []string{"syntax", "converge"})
+			So(report.Ansible.Interrupted, ShouldBeTrue)
+			So(report.ExitCode(), ShouldEqual, InterruptedCode)
+			So(report.NewJSONReport().Interrupted, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,245 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+
+	// ansiPattern matches the colour codes in coloured output.
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+	// recapPattern matches the line of a host in the play recap,
+	// ie "web : ok=5 changed=2 unreachable=0 failed=0".
+	recapPattern = regexp.MustCompile(`^\s*(\S+)\s+:\s+((?:\w+=\d+\s*)+)$`)
+)
+
+// HostStats are the task counts for a single host as
+// reported in the stats block of the json stdout callback.
+type HostStats struct {
+	Ok          int `json:"ok"`
+	Changed     int `json:"changed"`
+	Failed      int `json:"failures"`
+	Unreachable int `json:"unreachable"`
+	Skipped     int `json:"skipped"`
+	Rescued     int `json:"rescued"`
+	Ignored     int `json:"ignored"`
+}
+
+// PlayStats are the task counts of a playbook run keyed by host.
+type PlayStats map[string]HostStats
+
+// jsonDocument will return the document of the json stdout callback
+// in the output of a playbook. Warnings can be printed before or after
+// the document and may contain braces, ie "{{ item }}", so the document
+// is the last object which starts a line and has the plays or the stats
+// of the callback.
+func jsonDocument(output string) (json.RawMessage, error) {
+
+	lines := strings.SplitAfter(output, "\n")
+	offset := len(output)
+	for i := len(lines) - 1; i >= 0; i-- {
+		offset -= len(lines[i])
+		if !strings.HasPrefix(lines[i], "{") {
+			continue
+		}
+
+		var document json.RawMessage
+		if err := json.NewDecoder(strings.NewReader(output[offset:])).Decode(&document); err != nil {
+			continue
+		}
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(document, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields["plays"]; ok {
+			return document, nil
+		}
+		if _, ok := fields["stats"]; ok {
+			return document, nil
+		}
+	}
+
+	return nil, errors.New("no json output was found")
+}
+
+// ParsePlayStats will unmarshal the stats block from the output
+// of a playbook which was run with the json stdout callback.
+func ParsePlayStats(output string) (PlayStats, error) {
+
+	result := struct {
+		Stats PlayStats `json:"stats"`
+	}{}
+
+	document, err := jsonDocument(output)
+	if err != nil {
+		return PlayStats{}, err
+	}
+
+	if err := json.Unmarshal(document, &result); err != nil {
+		return PlayStats{}, err
+	}
+
+	if len(result.Stats) == 0 {
+		return PlayStats{}, errors.New("no hosts were found in the playbook stats")
+	}
+
+	return result.Stats, nil
+}
+
+// ParseRecap will read the task counts of each host from the play
+// recap of the default stdout callback, which is used when the json
+// callback is not enabled.
+func ParseRecap(output string) (PlayStats, error) {
+
+	stats := PlayStats{}
+	recap := false
+
+	for _, line := range strings.Split(ansiPattern.ReplaceAllString(output, ""), "\n") {
+		if strings.HasPrefix(line, "PLAY RECAP") {
+			recap = true
+			continue
+		}
+		if !recap {
+			continue
+		}
+
+		match := recapPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+
+		host := HostStats{}
+		for _, field := range strings.Fields(match[2]) {
+			pair := strings.SplitN(field, "=", 2)
+			count, _ := strconv.Atoi(pair[1])
+			switch pair[0] {
+			case "ok":
+				host.Ok = count
+			case "changed":
+				host.Changed = count
+			case "failed":
+				host.Failed = count
+			case "unreachable":
+				host.Unreachable = count
+			case "skipped":
+				host.Skipped = count
+			case "rescued":
+				host.Rescued = count
+			case "ignored":
+				host.Ignored = count
+			}
+		}
+		stats[match[1]] = host
+	}
+
+	if len(stats) == 0 {
+		return stats, errors.New("no play recap was found in the output")
+	}
+
+	return stats, nil
+}
+
+// playStats will return the stats of a playbook using the parser
+// which matches the configured stdout callback.
+func (config *AnsibleConfig) playStats(output string) (PlayStats, error) {
+	if config.StdoutCallback == "json" {
+		return ParsePlayStats(output)
+	}
+	return ParseRecap(output)
+}
+
+// Failed will identify if any host has failed or unreachable tasks.
+func (stats PlayStats) Failed() bool {
+	for _, host := range stats {
+		if host.Failed > 0 || host.Unreachable > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Unreachable will return the names of the hosts which were unreachable.
+func (stats PlayStats) Unreachable() []string {
+	hosts := []string{}
+	for name, host := range stats {
+		if host.Unreachable > 0 {
+			hosts = append(hosts, name)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// failedStats will identify if the stats of a playbook are a failure,
+// which includes tasks with ignored errors when FailOnIgnored is set.
+func (config *AnsibleConfig) failedStats(stats PlayStats) bool {
+	if stats.Failed() {
+		return true
+	}
+	if ignored := stats.Total().Ignored; config.FailOnIgnored && ignored > 0 {
+		log.Errorf("%v tasks failed with ignored errors", ignored)
+		return true
+	}
+	return false
+}
+
+// Changed will identify if any host has changed tasks.
+func (stats PlayStats) Changed() bool {
+	for _, host := range stats {
+		if host.Changed > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// TotalChanged will return the number of changed tasks across all hosts.
+func (stats PlayStats) TotalChanged() int {
+	changed := 0
+	for _, host := range stats {
+		changed += host.Changed
+	}
+	return changed
+}
+
+// Total will return the sum of the task counts of every host.
+func (stats PlayStats) Total() HostStats {
+	total := HostStats{}
+	for _, host := range stats {
+		total.Ok += host.Ok
+		total.Changed += host.Changed
+		total.Failed += host.Failed
+		total.Unreachable += host.Unreachable
+		total.Skipped += host.Skipped
+		total.Rescued += host.Rescued
+		total.Ignored += host.Ignored
+	}
+	return total
+}
+
+// Add will return the sum of the task counts of both stats.
+func (stats PlayStats) Add(other PlayStats) PlayStats {
+	sum := PlayStats{}
+	for _, set := range []PlayStats{stats, other} {
+		for name, host := range set {
+			total := sum[name]
+			total.Ok += host.Ok
+			total.Changed += host.Changed
+			total.Failed += host.Failed
+			total.Unreachable += host.Unreachable
+			total.Skipped += host.Skipped
+			total.Rescued += host.Rescued
+			total.Ignored += host.Ignored
+			sum[name] = total
+		}
+	}
+	return sum
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParsePlayStats(t *testing.T) {
+
+	Convey("Parsing the stats of the json stdout callback", t, func() {
+
+		Convey("Counts are read for every host", func() {
+			out := `{"plays": [], "stats": {
+				"web": {"changed": 1, "failures": 0, "ignored": 2, "ok": 5, "rescued": 1, "skipped": 3, "unreachable": 0},
+				"db": {"changed": 0, "failures": 1, "ignored": 0, "ok": 2, "rescued": 0, "skipped": 0, "unreachable": 0}
+			}}`
+			stats, err := ParsePlayStats(out)
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, PlayStats{
+				"web": HostStats{Ok: 5, Changed: 1, Skipped: 3, Rescued: 1, Ignored: 2},
+				"db":  HostStats{Ok: 2, Failed: 1},
+			})
+			So(stats.Changed(), ShouldBeTrue)
+			So(stats.Failed(), ShouldBeTrue)
+		})
+
+		Convey("Unreachable hosts have failed", func() {
+			stats, err := ParsePlayStats(`{"stats": {"test": {"ok": 0, "unreachable": 1}}}`)
+			So(err, ShouldBeNil)
+			So(stats.Failed(), ShouldBeTrue)
+		})
+
+		Convey("Warnings with braces are skipped", func() {
+			out := `[WARNING]: conditional statements should not include jinja2 templating delimiters such as {{ }} or {% %}. Found: {{ item }}
+{
+    "plays": [],
+    "stats": {
+        "test": {"changed": 1, "failures": 0, "ok": 4, "unreachable": 0}
+    }
+}
+{{ lookup('env', 'HOME') }} was templated after the play`
+			stats, err := ParsePlayStats(out)
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, PlayStats{"test": HostStats{Ok: 4, Changed: 1}})
+		})
+
+		Convey("Output without stats is an error", func() {
+			_, err := ParsePlayStats("ERROR! the playbook could not be found")
+			So(err, ShouldNotBeNil)
+			_, err = ParsePlayStats(`{"plays": [], "stats": {}}`)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Stats of many playbooks are summed", func() {
+			first := PlayStats{"test": HostStats{Ok: 3, Changed: 2}}
+			second := PlayStats{"test": HostStats{Ok: 1, Skipped: 1}, "other": HostStats{Ok: 1}}
+			So(PlayStats(nil).Add(first).Add(second), ShouldResemble, PlayStats{
+				"test":  HostStats{Ok: 4, Changed: 2, Skipped: 1},
+				"other": HostStats{Ok: 1},
+			})
+		})
+
+		Convey("The play recap is read without the json callback", func() {
+			stats, err := ParseRecap(idempotenceOutput)
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, PlayStats{"test": HostStats{Ok: 3, Changed: 1}})
+		})
+
+		Convey("Rescued and ignored tasks are read from the recap", func() {
+			stats, err := ParseRecap(`PLAY RECAP *********************************************************************
+web                        : ok=6    changed=1    unreachable=0    failed=0    skipped=2    rescued=1    ignored=2
+db                         : ok=2    changed=0    unreachable=0    failed=0    skipped=0    rescued=0    ignored=1`)
+			So(err, ShouldBeNil)
+			So(stats.Total(), ShouldResemble, HostStats{Ok: 8, Changed: 1, Skipped: 2, Rescued: 1, Ignored: 3})
+		})
+
+		Convey("Recaps without rescued and ignored columns still parse", func() {
+			stats, err := ParseRecap(`PLAY RECAP *********************************************************************
+web                        : ok=6    changed=1    unreachable=0    failed=0`)
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, PlayStats{"web": HostStats{Ok: 6, Changed: 1}})
+		})
+
+		Convey("Ignored tasks only fail when configured", func() {
+			stats := PlayStats{"web": HostStats{Ok: 6, Ignored: 1}}
+			config := AnsibleConfig{}
+			So(config.failedStats(stats), ShouldBeFalse)
+			config.FailOnIgnored = true
+			So(config.failedStats(stats), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package util
+
+import (
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// keepsContainer will return true when the container may be left after
+// the tests, so the daemon must not remove it once it stops.
+func (config *AnsibleConfig) keepsContainer() bool {
+	return config.KeepOnFailure || config.KeepAlways || config.Reuse
+}
+
+// autoRemoveArg will return true when the argument of docker run
+// removes the container once it stops.
+func autoRemoveArg(arg string) bool {
+	return arg == "--rm" || arg == "--rm=true"
+}
+
+// extraDockerArgs will return the extra arguments of docker run, where
+// the daemon isn't allowed to remove containers which may be kept.
+func extraDockerArgs(config *AnsibleConfig) []string {
+	args := []string{}
+	for _, arg := range config.DockerArgs {
+		if autoRemoveArg(arg) && config.keepsContainer() {
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// autoRemoved will return true when the daemon removes the container
+// once it stops.
+func (config *AnsibleConfig) autoRemoved() bool {
+	if config.keepsContainer() {
+		return false
+	}
+	if config.AutoRemove {
+		return true
+	}
+	for _, arg := range config.DockerArgs {
+		if autoRemoveArg(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// logRemoval will log how the container is removed after the tests.
+func (config *AnsibleConfig) logRemoval(dist *Distribution) {
+
+	if config.Quiet {
+		return
+	}
+
+	removes := config.AutoRemove
+	for _, arg := range config.DockerArgs {
+		removes = removes || autoRemoveArg(arg)
+	}
+	if removes && config.keepsContainer() {
+		log.Warnf("Container %v may be kept after the tests, so it is not removed by the daemon when it stops (--rm=false)", dist.CID)
+	} else if config.autoRemoved() {
+		log.Printf("Container %v is removed by the daemon when it stops", dist.CID)
+	}
+}
+
+// stopSeconds will return the seconds docker waits for the container
+// to stop, rounded up, where zero is the default of docker.
+func stopSeconds(timeout time.Duration) int {
+	return int(math.Ceil(timeout.Seconds()))
+}
+
+// DockerStop will stop the container, giving the init system the stop
+// timeout of the config to shut down before docker kills it, and remove
+// it. Containers which are still running are killed, which is recorded
+// in the report.
+func (dist *Distribution) DockerStop(config *AnsibleConfig, report *AnsibleReport) bool {
+
+	if dist.CID == "" {
+		if !config.Quiet {
+			log.Errorln("container name was not specified")
+		}
+		return false
+	}
+
+	if !dist.DockerCheck() {
+		if !config.Quiet {
+			log.Errorf("container %v is not running\n", dist.CID)
+		}
+		return false
+	}
+
+	stop := stopCommand{Container: dist.CID}
+	if config.StopTimeout > 0 {
+		stop.Timeout = stopSeconds(config.StopTimeout)
+		if !config.Quiet {
+			log.Printf("Stopping %v (timeout: %v)\n", dist.CID, config.StopTimeout)
+		}
+	} else if !config.Quiet {
+		log.Printf("Stopping %v\n", dist.CID)
+	}
+	if _, err := config.dockerExec(stop, false); err != nil {
+		log.Errorln(err)
+	}
+
+	if dist.DockerCheck() {
+		log.Warnf("Container %v did not stop in time, killing it", dist.CID)
+		report.Docker.Killed = true
+		if _, err := config.dockerExec(killCommand{Container: dist.CID}, false); err != nil {
+			log.Errorln(err)
+		}
+	}
+
+	// The daemon removes the container itself.
+	if config.autoRemoved() {
+		return true
+	}
+
+	if !config.Quiet {
+		log.Printf("Removing %v\n", dist.CID)
+	}
+	if _, err := config.dockerExec(rmCommand{Container: dist.CID}, false); err != nil {
+		log.Errorln(err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerStop(t *testing.T) {
+
+	Convey("Stopping the container after the tests", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Containers are stopped with the stop timeout and removed", func() {
+			fake := &fakeEngine{running: []string{"test"}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+			report := AnsibleReport{}
+
+			So(dist.DockerStop(&AnsibleConfig{StopTimeout: 1500 * time.Millisecond, Quiet: true}, &report), ShouldBeTrue)
+			So(fake.commands, ShouldContain, []string{"stop", "--time", "2", "test"})
+			So(fake.commands, ShouldContain, []string{"rm", "test"})
+			So(report.Docker.Killed, ShouldBeFalse)
+		})
+
+		Convey("Containers which don't stop in time are killed", func() {
+			fake := &fakeEngine{running: []string{"test"}, stubborn: map[string]bool{"test": true}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+			report := AnsibleReport{}
+
+			dist.DockerCleanup(&AnsibleConfig{StopTimeout: time.Second, Quiet: true}, &report)
+			So(fake.commands, ShouldContain, []string{"kill", "test"})
+			So(report.Docker.Killed, ShouldBeTrue)
+			So(report.Docker.Kill, ShouldBeTrue)
+			So(report.NewJSONReport().ContainerKilled, ShouldBeTrue)
+		})
+
+		Convey("Containers which the daemon removes aren't removed again", func() {
+			fake := &fakeEngine{running: []string{"test"}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+
+			So(dist.DockerStop(&AnsibleConfig{AutoRemove: true, Quiet: true}, &AnsibleReport{}), ShouldBeTrue)
+			So(fake.commands, ShouldContain, []string{"stop", "test"})
+			So(fake.commands, ShouldNotContain, []string{"rm", "test"})
+		})
+
+		Convey("Containers which may be kept aren't removed by the daemon", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test", AutoRemove: true}
+			So(buildRunCommand(&dist, &config, &AnsibleReport{}).args(), ShouldContain, "--rm")
+			So(config.autoRemoved(), ShouldBeTrue)
+
+			config.KeepOnFailure = true
+			config.DockerArgs = []string{"--rm", "--shm-size=1g"}
+			args := buildRunCommand(&dist, &config, &AnsibleReport{}).args()
+			So(args, ShouldNotContain, "--rm")
+			So(args, ShouldContain, "--shm-size=1g")
+			So(config.autoRemoved(), ShouldBeFalse)
+		})
+	})
+}
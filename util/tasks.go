@@ -0,0 +1,214 @@
+package util
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TaskChange is a task which reported changes on a host.
+type TaskChange struct {
+	Task string
+	Host string
+}
+
+// changedTasks will return the names of the tasks which reported
+// changes on any host in the output of a playbook, in order.
+func (config *AnsibleConfig) changedTasks(output string) []string {
+	tasks := []string{}
+	seen := map[string]bool{}
+	for _, change := range config.taskChanges(output) {
+		if !seen[change.Task] {
+			seen[change.Task] = true
+			tasks = append(tasks, change.Task)
+		}
+	}
+	return tasks
+}
+
+// taskChanges will return every task which reported changes along
+// with the host it changed on, in the order they were reported.
+func (config *AnsibleConfig) taskChanges(output string) []TaskChange {
+	if config.StdoutCallback == "json" {
+		return taskResultsJSON(output, "changed")
+	}
+	return taskResultsText(output, "changed")
+}
+
+// failedTasks will return the names of the tasks which failed on
+// any host in the output of a playbook, in order.
+func (config *AnsibleConfig) failedTasks(output string) []string {
+
+	var results []TaskChange
+	if config.StdoutCallback == "json" {
+		results = taskResultsJSON(output, "failed")
+	} else {
+		results = taskResultsText(output, "fatal", "failed")
+	}
+
+	tasks := []string{}
+	seen := map[string]bool{}
+	for _, result := range results {
+		if !seen[result.Task] {
+			seen[result.Task] = true
+			tasks = append(tasks, result.Task)
+		}
+	}
+	return tasks
+}
+
+// taskResultsText will find the tasks with one of the statuses in the
+// output of the default stdout callback, where each task header is
+// followed by the result of every host, ie "TASK [role : name] ***"
+// and "changed: [host]".
+func taskResultsText(output string, statuses ...string) []TaskChange {
+
+	results := []TaskChange{}
+	seen := map[TaskChange]bool{}
+	task := ""
+
+	for _, line := range strings.Split(ansiPattern.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimSpace(line)
+
+		for _, prefix := range []string{"TASK [", "RUNNING HANDLER ["} {
+			if strings.HasPrefix(line, prefix) {
+				end := strings.LastIndex(line, "]")
+				if end > len(prefix) {
+					task = line[len(prefix):end]
+				}
+			}
+		}
+
+		host := ""
+		for _, status := range statuses {
+			if strings.HasPrefix(line, status+": [") {
+				host = strings.TrimPrefix(line, status+": [")
+			}
+		}
+		if host == "" || task == "" {
+			continue
+		}
+
+		// The host can be followed by a delegated host or loop item,
+		// ie "changed: [web -> localhost] => (item=nginx)".
+		if end := strings.Index(host, "]"); end >= 0 {
+			host = host[:end]
+		}
+		if i := strings.Index(host, " -> "); i >= 0 {
+			host = host[:i]
+		}
+
+		result := TaskChange{Task: task, Host: host}
+		if !seen[result] {
+			seen[result] = true
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// taskResultsJSON will find the tasks with the status (ie changed or
+// failed) in the output of the json stdout callback, which lists the
+// result of each host per task.
+func taskResultsJSON(output, status string) []TaskChange {
+
+	result := struct {
+		Plays []struct {
+			Tasks []struct {
+				Task struct {
+					Name string `json:"name"`
+				} `json:"task"`
+				Hosts map[string]map[string]interface{} `json:"hosts"`
+			} `json:"tasks"`
+		} `json:"plays"`
+	}{}
+
+	results := []TaskChange{}
+	document, err := jsonDocument(output)
+	if err != nil {
+		return results
+	}
+	if err := json.Unmarshal(document, &result); err != nil {
+		return results
+	}
+
+	for _, play := range result.Plays {
+		for _, task := range play.Tasks {
+			hosts := []string{}
+			for host, values := range task.Hosts {
+				if value, ok := values[status].(bool); ok && value {
+					hosts = append(hosts, host)
+				}
+			}
+			sort.Strings(hosts)
+			for _, host := range hosts {
+				results = append(results, TaskChange{Task: task.Task.Name, Host: host})
+			}
+		}
+	}
+
+	return results
+}
+
+// recordFailedIdempotence will find the tasks which caused the
+// idempotence test to fail for each host, which are logged and
+// stored in the report. Tasks which are allowed to change are skipped.
+func (report *AnsibleReport) recordFailedIdempotence(config *AnsibleConfig, output string) {
+
+	failed := map[string][]string{}
+	hosts := []string{}
+	for _, change := range config.taskChanges(output) {
+		if config.allowedChange(change.Task) {
+			continue
+		}
+		if _, ok := failed[change.Host]; !ok {
+			hosts = append(hosts, change.Host)
+		}
+		failed[change.Host] = append(failed[change.Host], change.Task)
+	}
+
+	if len(failed) == 0 {
+		return
+	}
+	report.Ansible.FailedIdempotenceTasks = failed
+
+	for _, host := range hosts {
+		tasks := []string{}
+		for _, task := range failed[host] {
+			tasks = append(tasks, "["+task+"]")
+		}
+		if len(hosts) > 1 {
+			log.Errorf("Non-idempotent tasks on %v: %v", host, strings.Join(tasks, ", "))
+		} else {
+			log.Errorf("Non-idempotent tasks: %v", strings.Join(tasks, ", "))
+		}
+	}
+}
+
+// allowedChange will identify if the task matches one of the tasks
+// which are allowed to change, either by name or by a glob pattern.
+// Names of tasks in roles are matched with and without the role prefix.
+func (config *AnsibleConfig) allowedChange(task string) bool {
+
+	names := []string{task}
+	if i := strings.Index(task, " : "); i >= 0 {
+		names = append(names, task[i+3:])
+	}
+
+	for _, pattern := range config.AllowedChangedTasks {
+		for _, name := range names {
+			if pattern == name {
+				return true
+			}
+			if matched, err := path.Match(pattern, name); err == nil && matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+package util
+
+import (
+	"io/ioutil"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// idempotenceOutput is the output of an idempotence run with the default
+// stdout callback where a single task reported changes.
+const idempotenceOutput = `
+PLAY [all] *********************************************************************
+
+TASK [Gathering Facts] *********************************************************
+ok: [test]
+
+TASK [backup : Install packages] ***********************************************
+ok: [test]
+
+TASK [backup : Rotate timestamped backup] **************************************
+changed: [test]
+
+PLAY RECAP *********************************************************************
+test                       : ok=3    changed=1    unreachable=0    failed=0    skipped=0    rescued=0    ignored=0
+`
+
+func TestAllowedChangedTasks(t *testing.T) {
+
+	Convey("Tasks which are allowed to change", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Changed tasks are found in the output", func() {
+			config := AnsibleConfig{}
+			So(config.changedTasks(idempotenceOutput), ShouldResemble, []string{"backup : Rotate timestamped backup"})
+		})
+
+		Convey("Tasks are matched by name or pattern", func() {
+			config := AnsibleConfig{AllowedChangedTasks: []string{"Rotate * backup"}}
+			So(config.allowedChange("backup : Rotate timestamped backup"), ShouldBeTrue)
+			So(config.allowedChange("backup : Install packages"), ShouldBeFalse)
+
+			config.AllowedChangedTasks = []string{"backup : Rotate timestamped backup"}
+			So(config.allowedChange("backup : Rotate timestamped backup"), ShouldBeTrue)
+		})
+
+		Convey("Allowed changes pass the idempotence test", func() {
+			report := AnsibleReport{}
+			config := AnsibleConfig{}
+			So(config.idempotenceResult(idempotenceOutput, &report), ShouldBeFalse)
+
+			config.AllowedChangedTasks = []string{"Rotate timestamped backup"}
+			So(config.idempotenceResult(idempotenceOutput, &report), ShouldBeTrue)
+			So(report.Ansible.Idempotence.AllowedChanges, ShouldResemble, []string{"backup : Rotate timestamped backup"})
+		})
+
+		Convey("Changes of other tasks count against the threshold", func() {
+			report := AnsibleReport{}
+			config := AnsibleConfig{AllowedChangedTasks: []string{"Install packages"}, IdempotenceMaxChanged: 1}
+			So(config.idempotenceResult(multiHostOutput, &report), ShouldBeFalse)
+
+			config.IdempotenceMaxChanged = 2
+			So(config.idempotenceResult(multiHostOutput, &report), ShouldBeTrue)
+		})
+	})
+}
+
+// multiHostOutput is the output of an idempotence run against two hosts
+// where tasks reported changes on different hosts.
+const multiHostOutput = `
+TASK [web : Install packages] **************************************************
+ok: [web1]
+changed: [web2]
+
+TASK [web : Template config] ***************************************************
+changed: [web1] => (item=nginx.conf)
+changed: [web1] => (item=site.conf)
+changed: [web2 -> localhost]
+
+PLAY RECAP *********************************************************************
+web1                       : ok=2    changed=1    unreachable=0    failed=0
+web2                       : ok=2    changed=2    unreachable=0    failed=0
+`
+
+func TestFailedIdempotenceTasks(t *testing.T) {
+
+	Convey("Tasks which failed the idempotence test", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Changed tasks are attributed to each host", func() {
+			config := AnsibleConfig{}
+			report := AnsibleReport{}
+			report.recordFailedIdempotence(&config, multiHostOutput)
+			So(report.Ansible.FailedIdempotenceTasks, ShouldResemble, map[string][]string{
+				"web1": {"web : Template config"},
+				"web2": {"web : Install packages", "web : Template config"},
+			})
+		})
+
+		Convey("Tasks which are allowed to change are not reported", func() {
+			config := AnsibleConfig{AllowedChangedTasks: []string{"Template config"}}
+			report := AnsibleReport{}
+			report.recordFailedIdempotence(&config, multiHostOutput)
+			So(report.Ansible.FailedIdempotenceTasks, ShouldResemble, map[string][]string{
+				"web2": {"web : Install packages"},
+			})
+		})
+
+		Convey("Changed tasks are found with the json callback", func() {
+			config := AnsibleConfig{StdoutCallback: "json"}
+			output := `[WARNING]: The loop variable {{ item }} is already in use
+{"plays": [{"tasks": [{"task": {"name": "web : Install packages"}, "hosts": {"web2": {"changed": true}, "web1": {"changed": false}}}]}]}`
+			So(config.taskChanges(output), ShouldResemble, []TaskChange{{Task: "web : Install packages", Host: "web2"}})
+		})
+	})
+}
+
+func TestFailedTasks(t *testing.T) {
+
+	Convey("Tasks which failed", t, func() {
+
+		Convey("Failed tasks are found in the output", func() {
+			config := AnsibleConfig{}
+			output := `
+TASK [web : Install packages] **************************************************
+ok: [web1]
+fatal: [web2]: FAILED! => {"changed": false, "msg": "No package matching 'nginx' is available"}
+
+TASK [web : Template config] ***************************************************
+failed: [web1] (item=nginx.conf) => {"changed": false, "msg": "Destination directory does not exist"}`
+			So(config.failedTasks(output), ShouldResemble, []string{"web : Install packages", "web : Template config"})
+		})
+
+		Convey("Failed tasks are found with the json callback", func() {
+			config := AnsibleConfig{StdoutCallback: "json"}
+			output := `{"plays": [{"tasks": [{"task": {"name": "web : Install packages"}, "hosts": {"web2": {"changed": false, "failed": true}}}]}]}`
+			So(config.failedTasks(output), ShouldResemble, []string{"web : Install packages"})
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl will run the ioctl request on the file.
+func ioctl(file *os.File, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw will put the terminal in raw mode, so every key is sent to
+// an interactive command as it is typed, and will return the function
+// which restores the terminal.
+func makeRaw(file *os.File) (func(), error) {
+
+	var state syscall.Termios
+	if err := ioctl(file, ioctlGetTermios, unsafe.Pointer(&state)); err != nil {
+		return nil, err
+	}
+
+	raw := state
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(file, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ioctl(file, ioctlSetTermios, unsafe.Pointer(&state))
+	}, nil
+}
+
+// terminalSize will return the height and width of the terminal.
+func terminalSize(file *os.File) (int, int, error) {
+	var size struct {
+		rows, columns, x, y uint16
+	}
+	if err := ioctl(file, syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil {
+		return 0, 0, err
+	}
+	return int(size.rows), int(size.columns), nil
+}
@@ -0,0 +1,9 @@
+package util
+
+import "syscall"
+
+// The ioctl requests of the terminal state.
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
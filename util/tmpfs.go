@@ -0,0 +1,60 @@
+package util
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// TmpfsNone given as the only tmpfs mount leaves out the tmpfs mounts
+// of the distribution.
+const TmpfsNone = "none"
+
+// CheckTmpfs will return an error when a tmpfs mount isn't an absolute
+// path in the container, which are given as PATH[:OPTIONS].
+func CheckTmpfs(values []string) error {
+	for _, value := range values {
+		if value == TmpfsNone {
+			continue
+		}
+		if target := strings.SplitN(value, ":", 2)[0]; !path.IsAbs(target) {
+			return fmt.Errorf("invalid tmpfs mount '%v', expected an absolute path as PATH[:OPTIONS]", value)
+		}
+	}
+	return nil
+}
+
+// tmpfsMounts will return the tmpfs mounts of the container, which are
+// those of the distribution unless they are given. Containers without
+// an init system don't need them, and systemd needs /run as tmpfs when
+// the cgroups are mounted for cgroup v2.
+func tmpfsMounts(dist *Distribution, config *AnsibleConfig) []string {
+
+	mounts := []string{}
+	if len(config.Tmpfs) > 0 {
+		for _, mount := range config.Tmpfs {
+			if mount != TmpfsNone {
+				mounts = append(mounts, mount)
+			}
+		}
+		return mounts
+	}
+
+	if dist.Family.Initialise == NoInitCommand {
+		return mounts
+	}
+	mounts = append(mounts, dist.Tmpfs...)
+
+	if _, cgroupns := cgroupSetup(dist.Family.Volume, config.CgroupV2); cgroupns != "" {
+		mounted := map[string]bool{}
+		for _, mount := range mounts {
+			mounted[strings.SplitN(mount, ":", 2)[0]] = true
+		}
+		for _, mount := range SystemdTmpfs {
+			if !mounted[mount] {
+				mounts = append(mounts, mount)
+			}
+		}
+	}
+	return mounts
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTmpfs(t *testing.T) {
+
+	Convey("Mounting tmpfs for systemd", t, func() {
+
+		newConfig := func() AnsibleConfig {
+			return AnsibleConfig{HostPath: "/home/user/web", RemotePath: "/etc/ansible/roles/role_under_test"}
+		}
+
+		Convey("Systemd distributions mount /run as tmpfs", func() {
+			config := newConfig()
+			dist := Ubuntu1804
+			dist.CID = "test"
+			report := AnsibleReport{}
+			args := buildRunCommand(&dist, &config, &report).args()
+			So(args, ShouldContain, "--tmpfs=/run")
+			So(args, ShouldContain, "--tmpfs=/run/lock")
+			So(report.Docker.Tmpfs, ShouldResemble, []string{"/run", "/run/lock"})
+		})
+
+		Convey("Distributions without systemd are untouched", func() {
+			config := newConfig()
+			dist := CentOS6
+			dist.CID = "test"
+			So(buildRunCommand(&dist, &config, &AnsibleReport{}).args(), ShouldNotContain, "--tmpfs=/run")
+
+			dist = Ubuntu1804
+			dist.Override(nil, true, "")
+			So(tmpfsMounts(&dist, &config), ShouldBeEmpty)
+		})
+
+		Convey("The tmpfs mounts can be given instead", func() {
+			config := newConfig()
+			dist := Ubuntu1804
+			config.Tmpfs = []string{"/tmp:size=64m"}
+			So(tmpfsMounts(&dist, &config), ShouldResemble, []string{"/tmp:size=64m"})
+
+			config.Tmpfs = []string{TmpfsNone}
+			So(tmpfsMounts(&dist, &config), ShouldBeEmpty)
+		})
+
+		Convey("Cgroup v2 always mounts /run as tmpfs", func() {
+			config := newConfig()
+			dist := Distribution{Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+			So(tmpfsMounts(&dist, &config), ShouldBeEmpty)
+
+			config.CgroupV2 = true
+			So(tmpfsMounts(&dist, &config), ShouldResemble, []string{"/run", "/run/lock"})
+
+			dist.Tmpfs = []string{"/run:exec"}
+			So(tmpfsMounts(&dist, &config), ShouldResemble, []string{"/run:exec", "/run/lock"})
+		})
+
+		Convey("Tmpfs mounts must be absolute paths", func() {
+			So(CheckTmpfs([]string{"/run", "/tmp:size=64m", TmpfsNone}), ShouldBeNil)
+			So(CheckTmpfs([]string{"run"}), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,266 @@
+package util
+
+import (
+	"bufio"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+
+	// TransferMount mounts the role into the container, which is
+	// the default.
+	TransferMount = "mount"
+
+	// TransferCopy copies the role into the container once it is
+	// created, so the tests can't write into the role.
+	TransferCopy = "copy"
+
+	// artignoreFile lists the files of the role which aren't copied
+	// into the container, one pattern per line.
+	artignoreFile = ".artignore"
+)
+
+// transfer will return how the role gets into the container.
+func (config *AnsibleConfig) transfer() string {
+	if config.Copy {
+		return TransferCopy
+	}
+	return TransferMount
+}
+
+// readArtignore will return the patterns of the .artignore file of the
+// role, where blank lines and lines starting with # are ignored. A role
+// without the file has no patterns.
+func readArtignore(dir string) ([]string, error) {
+
+	file, err := os.Open(filepath.Join(dir, artignoreFile))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(strings.Trim(line, "/"), ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%v' in %v: %v", line, artignoreFile, err)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// artignored will return true when the file of the role, relative to
+// the role, matches any of the patterns. Patterns with a slash match
+// the path from the role, others match the name at any depth, and
+// patterns ending with a slash only match directories.
+func artignored(patterns []string, relative string, dir bool) bool {
+
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if !dir {
+				continue
+			}
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+
+		name := path.Base(relative)
+		if strings.Contains(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+			name = relative
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// roleChecksums will return the md5 checksums of the files of the role
+// which are copied into the container, keyed by the path relative to
+// the role.
+func roleChecksums(dir string) (map[string]string, error) {
+
+	patterns, err := readArtignore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := map[string]string{}
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel(dir, file)
+		relative = filepath.ToSlash(relative)
+		if relative == "." {
+			return nil
+		}
+		if artignored(patterns, relative, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		hash := md5.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return err
+		}
+		checksums[relative] = fmt.Sprintf("%x", hash.Sum(nil))
+		return nil
+	})
+	return checksums, err
+}
+
+// containerChecksums will return the md5 checksums of the files of the
+// role in the container, keyed by the path relative to the role.
+func (dist *Distribution) containerChecksums(config *AnsibleConfig) (map[string]string, error) {
+
+	out, err := config.dockerExec(dist.containerExec("find", config.RemotePath, "-type", "f", "-exec", "md5sum", "{}", "+"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := map[string]string{}
+	prefix := strings.TrimSuffix(config.RemotePath, "/") + "/"
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "  ", 2)
+		if len(fields) == 2 && strings.HasPrefix(fields[1], prefix) {
+			checksums[strings.TrimPrefix(fields[1], prefix)] = fields[0]
+		}
+	}
+	return checksums, nil
+}
+
+// stageFiles will copy the files of the role into a new temporary
+// directory, which is removed by the caller.
+func stageFiles(dir string, files []string) (string, error) {
+
+	stage, err := ioutil.TempDir("", "ansible-role-tester")
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		source := filepath.Join(dir, filepath.FromSlash(file))
+		target := filepath.Join(stage, filepath.FromSlash(file))
+		info, err := os.Stat(source)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+		}
+		var data []byte
+		if err == nil {
+			data, err = ioutil.ReadFile(source)
+		}
+		if err == nil {
+			err = ioutil.WriteFile(target, data, info.Mode().Perm())
+		}
+		if err != nil {
+			os.RemoveAll(stage)
+			return "", err
+		}
+	}
+	return stage, nil
+}
+
+// DockerSync will copy the role into the container, instead of mounting
+// it, leaving out the files of the .artignore file. Reused containers
+// already have the role, so only the files which changed are copied and
+// the files which were removed from the role are removed.
+func (dist *Distribution) DockerSync(config *AnsibleConfig, report *AnsibleReport) bool {
+
+	checksums, err := roleChecksums(config.HostPath)
+	if err != nil {
+		log.Errorf("unable to read the role: %v", err)
+		return false
+	}
+
+	existing := map[string]string{}
+	if report.Docker.Reused {
+		if existing, err = dist.containerChecksums(config); err != nil {
+			return false
+		}
+	}
+
+	changed := []string{}
+	for file, checksum := range checksums {
+		if existing[file] != checksum {
+			changed = append(changed, file)
+		}
+	}
+	removed := []string{}
+	for file := range existing {
+		if _, ok := checksums[file]; !ok {
+			removed = append(removed, path.Join(config.RemotePath, file))
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+
+	if !config.Quiet {
+		log.Printf("Copying %v files of the role to %v", len(changed), config.RemotePath)
+	}
+
+	if len(changed) > 0 {
+		stage, err := stageFiles(config.HostPath, changed)
+		if err != nil {
+			log.Errorf("unable to copy the role: %v", err)
+			return false
+		}
+		defer os.RemoveAll(stage)
+
+		// The docker CLI requires the parent directory to exist.
+		if _, err := config.dockerExec(dist.containerExec("mkdir", "-p", path.Dir(config.RemotePath)), false); err != nil {
+			return false
+		}
+		if _, err := config.dockerExec(cpCommand{Source: stage + string(filepath.Separator) + ".", Container: dist.CID, Destination: config.RemotePath}, false); err != nil {
+			return false
+		}
+	}
+
+	if len(removed) > 0 {
+		if _, err := config.dockerExec(dist.containerExec(append([]string{"rm", "-f"}, removed...)...), false); err != nil {
+			return false
+		}
+	}
+
+	// The role is linked into the roles path so the playbooks can
+	// call it by name, as it is when it is mounted.
+	if !config.Remote {
+		link := path.Join("/etc/ansible/roles", filepath.Base(config.HostPath))
+		if link != path.Clean(config.RemotePath) {
+			if _, err := config.dockerExec(dist.containerExec("mkdir", "-p", "/etc/ansible/roles"), false); err != nil {
+				return false
+			}
+			if _, err := config.dockerExec(dist.containerExec("ln", "-sfn", config.RemotePath, link), false); err != nil {
+				return false
+			}
+		}
+	}
+
+	return true
+}
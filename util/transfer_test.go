@@ -0,0 +1,117 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTransfer(t *testing.T) {
+
+	Convey("Copying the role into the container", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		role, _ := ioutil.TempDir("", "role")
+		defer os.RemoveAll(role)
+		os.MkdirAll(filepath.Join(role, "tasks"), 0755)
+		os.MkdirAll(filepath.Join(role, "molecule", "default"), 0755)
+		ioutil.WriteFile(filepath.Join(role, "tasks", "main.yml"), []byte("---\n"), 0644)
+		ioutil.WriteFile(filepath.Join(role, "tasks", "debug.log"), []byte("junk\n"), 0644)
+		ioutil.WriteFile(filepath.Join(role, "molecule", "default", "molecule.yml"), []byte("---\n"), 0644)
+		ioutil.WriteFile(filepath.Join(role, ".artignore"), []byte("# test output\n*.log\nmolecule/\n"), 0644)
+
+		Convey("Files matching the .artignore are left out", func() {
+			checksums, err := roleChecksums(role)
+			So(err, ShouldBeNil)
+			So(checksums, ShouldContainKey, "tasks/main.yml")
+			So(checksums, ShouldContainKey, ".artignore")
+			So(len(checksums), ShouldEqual, 2)
+
+			So(artignored([]string{"/tasks/*.yml"}, "tasks/main.yml", false), ShouldBeTrue)
+			So(artignored([]string{"/tasks/*.yml"}, "handlers/tasks/main.yml", false), ShouldBeFalse)
+			So(artignored([]string{"tasks/"}, "tasks", false), ShouldBeFalse)
+		})
+
+		Convey("The role is not mounted when it is copied", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: "/sys/fs/cgroup:/sys/fs/cgroup:ro"}}
+			config := AnsibleConfig{HostPath: role, RemotePath: "/etc/ansible/roles/role_under_test", Copy: true}
+			report := AnsibleReport{}
+			args := buildRunCommand(&dist, &config, &report).args()
+			So(args, ShouldNotContain, "--volume="+role+":/etc/ansible/roles/role_under_test")
+			So(report.Docker.Transfer, ShouldEqual, TransferCopy)
+
+			config.Copy = false
+			report = AnsibleReport{}
+			buildRunCommand(&dist, &config, &report).args()
+			So(report.Docker.Transfer, ShouldEqual, TransferMount)
+		})
+
+		Convey("New containers get every file of the role", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{CID: "test"}
+			config := AnsibleConfig{HostPath: role, RemotePath: "/etc/ansible/roles/role_under_test", Copy: true, Quiet: true}
+			So(dist.DockerSync(&config, &AnsibleReport{}), ShouldBeTrue)
+
+			copied := [][]string{}
+			for _, command := range fake.commands {
+				if command[0] == "cp" {
+					copied = append(copied, command)
+				}
+			}
+			So(len(copied), ShouldEqual, 1)
+			So(copied[0][1], ShouldEndWith, string(filepath.Separator)+".")
+			So(copied[0][2], ShouldEqual, "test:/etc/ansible/roles/role_under_test")
+			So(fake.commands, ShouldContain, []string{"exec", "test", "ln", "-sfn", "/etc/ansible/roles/role_under_test", "/etc/ansible/roles/" + filepath.Base(role)})
+		})
+
+		Convey("Reused containers only get the changes", func() {
+			checksums, _ := roleChecksums(role)
+			listing := []string{
+				checksums[".artignore"] + "  /etc/ansible/roles/role_under_test/.artignore",
+				"0123456789abcdef0123456789abcdef  /etc/ansible/roles/role_under_test/tasks/main.yml",
+				"0123456789abcdef0123456789abcdef  /etc/ansible/roles/role_under_test/tasks/old.yml",
+			}
+			fake := &fakeEngine{outputs: map[string][]string{
+				"find /etc/ansible/roles/role_under_test -type f -exec md5sum {} +": {strings.Join(listing, "\n")},
+			}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+			config := AnsibleConfig{HostPath: role, RemotePath: "/etc/ansible/roles/role_under_test", Copy: true, Remote: true, Quiet: true}
+			report := AnsibleReport{}
+			report.Docker.Reused = true
+
+			changed, err := dist.containerChecksums(&config)
+			So(err, ShouldBeNil)
+			So(len(changed), ShouldEqual, 3)
+
+			So(dist.DockerSync(&config, &report), ShouldBeTrue)
+			So(fake.commands, ShouldContain, []string{"exec", "test", "rm", "-f", "/etc/ansible/roles/role_under_test/tasks/old.yml"})
+		})
+
+		Convey("Unchanged roles aren't copied again", func() {
+			checksums, _ := roleChecksums(role)
+			listing := []string{}
+			for file, checksum := range checksums {
+				listing = append(listing, checksum+"  /etc/ansible/roles/role_under_test/"+file)
+			}
+			fake := &fakeEngine{outputs: map[string][]string{
+				"find /etc/ansible/roles/role_under_test -type f -exec md5sum {} +": {strings.Join(listing, "\n")},
+			}}
+			engine = fake
+			dist := Distribution{CID: "test"}
+			config := AnsibleConfig{HostPath: role, RemotePath: "/etc/ansible/roles/role_under_test", Copy: true, Remote: true, Quiet: true}
+			report := AnsibleReport{}
+			report.Docker.Reused = true
+
+			So(dist.DockerSync(&config, &report), ShouldBeTrue)
+			So(len(fake.commands), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// userPattern matches the names of users which can be created in the
+// container, which are portable between useradd and adduser.
+var userPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+
+// sudoersFile allows the user which is created to use sudo without
+// a password, so the playbooks can become root.
+const sudoersFile = "/etc/sudoers.d/ansible-role-tester"
+
+// CheckExecUser will return an error when the user Ansible runs as
+// can't be created, which only matters when it is created.
+func CheckExecUser(user string, create bool) error {
+	if create && user == "" {
+		return fmt.Errorf("a user must be given to create it")
+	}
+	if create && (!userPattern.MatchString(user) || user == "root") {
+		return fmt.Errorf("invalid user '%v', expected a lowercase name other than root", user)
+	}
+	return nil
+}
+
+// DockerCreateUser will add the user Ansible runs as to the container,
+// with passwordless sudo, when CreateUser is set. Existing users only
+// get the sudo rule.
+func (dist *Distribution) DockerCreateUser(config *AnsibleConfig) error {
+
+	if !config.CreateUser || config.ExecUser == "" {
+		return nil
+	}
+
+	if !config.Quiet {
+		log.Printf("Creating user %v with passwordless sudo", config.ExecUser)
+	}
+
+	if _, err := config.dockerExec(dist.containerExec("sh", "-c", "command -v sudo"), false); err != nil {
+		return fmt.Errorf("sudo is not installed in %v, which the user %v needs to become root", dist.CID, config.ExecUser)
+	}
+
+	script := fmt.Sprintf("id -u %[1]v >/dev/null 2>&1 || useradd --create-home %[1]v || adduser -D %[1]v", config.ExecUser)
+	if _, err := config.dockerExec(dist.containerExec("sh", "-c", script), false); err != nil {
+		return fmt.Errorf("unable to create user %v: %v", config.ExecUser, err)
+	}
+
+	script = fmt.Sprintf("mkdir -p /etc/sudoers.d && echo '%[1]v ALL=(ALL) NOPASSWD:ALL' > %[2]v && chmod 0440 %[2]v", config.ExecUser, sudoersFile)
+	if _, err := config.dockerExec(dist.containerExec("sh", "-c", script), false); err != nil {
+		return fmt.Errorf("unable to allow user %v to use sudo: %v", config.ExecUser, err)
+	}
+
+	return nil
+}
+
+// unreadableFiles will return the files and directories of the role
+// which other users can't read, relative to the role. The user Ansible
+// runs as in the container is another user than the owner of the files
+// on this host, so it can't read them.
+func unreadableFiles(dir string) []string {
+
+	unreadable := []string{}
+	filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		relative, _ := filepath.Rel(dir, file)
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		mode := info.Mode().Perm()
+		if (info.IsDir() && mode&0005 != 0005) || (info.Mode().IsRegular() && mode&0004 == 0) {
+			unreadable = append(unreadable, filepath.ToSlash(relative))
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return unreadable
+}
+
+// warnUnreadable will warn about the files of the role which the user
+// Ansible runs as may not be able to read in the container.
+func (report *AnsibleReport) warnUnreadable(config *AnsibleConfig) {
+
+	if config.ExecUser == "" || config.ExecUser == "root" || config.Remote {
+		return
+	}
+
+	unreadable := unreadableFiles(config.HostPath)
+	if len(unreadable) == 0 {
+		return
+	}
+
+	examples := unreadable
+	if len(examples) > 3 {
+		examples = examples[:3]
+	}
+	warning := fmt.Sprintf("%v files of the role are not readable by other users, which %v may not be able to read in the container, ie %v", len(unreadable), config.ExecUser, examples)
+	if !config.Quiet {
+		log.Warnln(warning)
+	}
+	report.Docker.Warnings = append(report.Docker.Warnings, warning)
+}
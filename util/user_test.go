@@ -0,0 +1,75 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExecUser(t *testing.T) {
+
+	Convey("Running Ansible as an unprivileged user", t, func() {
+
+		previous := engine
+		defer func() { engine = previous }()
+
+		Convey("Commands in the container run as the user with become", func() {
+			dist := Distribution{CID: "test"}
+			config := AnsibleConfig{ExecUser: "deploy"}
+			args := buildExecCommand(&dist, &config).args()
+			So(args[len(args)-3:], ShouldResemble, []string{"--user", "deploy", "test"})
+			So(buildAnsibleArgs(&config), ShouldContain, "--become")
+			So(buildAnsibleArgs(&config), ShouldNotContain, "--user")
+
+			config.Remote = true
+			So(buildAnsibleArgs(&config), ShouldContain, "--user")
+		})
+
+		Convey("Users which are created must be valid", func() {
+			So(CheckExecUser("", false), ShouldBeNil)
+			So(CheckExecUser("Deploy", false), ShouldBeNil)
+			So(CheckExecUser("deploy", true), ShouldBeNil)
+			So(CheckExecUser("", true), ShouldNotBeNil)
+			So(CheckExecUser("root", true), ShouldNotBeNil)
+			So(CheckExecUser("deploy; rm -rf /", true), ShouldNotBeNil)
+		})
+
+		Convey("The user is created with passwordless sudo", func() {
+			fake := &fakeEngine{}
+			engine = fake
+			dist := Distribution{CID: "test"}
+			So(dist.DockerCreateUser(&AnsibleConfig{ExecUser: "deploy", Quiet: true}), ShouldBeNil)
+			So(len(fake.commands), ShouldEqual, 0)
+
+			So(dist.DockerCreateUser(&AnsibleConfig{ExecUser: "deploy", CreateUser: true, Quiet: true}), ShouldBeNil)
+			So(len(fake.commands), ShouldEqual, 3)
+			So(fake.commands[1][4], ShouldContainSubstring, "useradd --create-home deploy")
+			So(fake.commands[2][4], ShouldContainSubstring, "deploy ALL=(ALL) NOPASSWD:ALL")
+		})
+
+		Convey("Files other users can't read are warned about", func() {
+			role, _ := ioutil.TempDir("", "role")
+			defer os.RemoveAll(role)
+			os.MkdirAll(filepath.Join(role, "tasks"), 0755)
+			ioutil.WriteFile(filepath.Join(role, "tasks", "main.yml"), []byte("---\n"), 0644)
+			os.Chmod(role, 0755)
+
+			report := AnsibleReport{}
+			config := AnsibleConfig{HostPath: role, ExecUser: "deploy", Quiet: true}
+			report.warnUnreadable(&config)
+			So(report.Docker.Warnings, ShouldBeEmpty)
+
+			ioutil.WriteFile(filepath.Join(role, "tasks", "secret.yml"), []byte("---\n"), 0600)
+			So(unreadableFiles(role), ShouldResemble, []string{"tasks/secret.yml"})
+			report.warnUnreadable(&config)
+			So(len(report.Docker.Warnings), ShouldEqual, 1)
+
+			config.ExecUser = ""
+			report.warnUnreadable(&config)
+			So(len(report.Docker.Warnings), ShouldEqual, 1)
+		})
+	})
+}
@@ -1,8 +1,12 @@
 package util
 
 import (
+	"context"
 	"net"
+	"os"
 	"os/exec"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -15,11 +19,14 @@ var (
 	// ansible-playbook from the host machine.
 	ansibleplaybook string
 
+	// ansibleplaybookLock guards ansibleplaybook, as distributions
+	// are tested concurrently.
+	ansibleplaybookLock sync.Mutex
+
 	// docker is simply the path to the Docker binary.
-	// this will be located using exec.LookPath().
-	// this must validate to a working binary named
-	// docker in $PATH, otherwise the program will
-	// report a fatal error.
+	// this will be located using exec.LookPath(), and
+	// is only needed when the docker CLI is requested
+	// or for commands the Docker Engine API can't run.
 	docker string
 
 	// dockerFound is a simple boolean which is set
@@ -27,6 +34,49 @@ var (
 	// the docker string above to identify if the
 	// docker binary is found to simplify flow control.
 	dockerFound = false
+
+	// vaultPasswordPath is the location the vault password
+	// file is mounted to inside of the container.
+	vaultPasswordPath = "/etc/ansible/.vault_password"
+
+	// ansibleCfgPath is the location the ansible.cfg
+	// file is mounted to inside of the container.
+	ansibleCfgPath = "/etc/ansible/.ansible_role_tester.cfg"
+
+	// inventoryPath is the location the inventory
+	// file is mounted to inside of the container.
+	inventoryPath = "/etc/ansible/.inventory"
+
+	// pythonRequirementsPath is the location the Python requirements
+	// file is mounted to inside of the container.
+	pythonRequirementsPath = "/etc/ansible/.python_requirements.txt"
+
+	// rolesPath is the default roles path inside of the container.
+	rolesPath = "/etc/ansible/roles"
+
+	// collectionsPath is the default collections path inside of the
+	// container, which Ansible searches by default.
+	collectionsPath = "/usr/share/ansible/collections"
+
+	// offlineCollectionsPath is the location the directory of the
+	// collection tarballs is mounted to inside of the container.
+	offlineCollectionsPath = "/etc/ansible/.collections"
+
+	// factCachePath is the location of the fact cache inside
+	// of the container, which is removed with the container.
+	factCachePath = "/tmp/ansible-role-tester-facts"
+
+	// defaultAnsibleRolesPath is the default value of roles_path
+	// in Ansible, which is kept when the roles path is changed.
+	defaultAnsibleRolesPath = "~/.ansible/roles:/usr/share/ansible/roles:/etc/ansible/roles"
+
+	// defaultAnsibleCollectionsPath is the default value of
+	// collections_path in Ansible, which is kept when it is changed.
+	defaultAnsibleCollectionsPath = "~/.ansible/collections:/usr/share/ansible/collections"
+
+	// outputLines is the number of lines at the end of the output
+	// of each stage which are kept for the test reports.
+	outputLines = 50
 )
 
 // AnsibleConfig represents a series of configuration options
@@ -42,10 +92,25 @@ type AnsibleConfig struct {
 	// Example: 'container_name,' or './tests/inventory.
 	Inventory string
 
+	// InventoryFile is the path to an inventory file on the host which
+	// will be used instead of an inventory containing only the container.
+	// All hosts in the inventory will be resolved to the container.
+	InventoryFile string
+
 	// RemotePath is the path to the roles folder on the container
 	// which should represent the roles folder (ie /etc/ansible/roles)
 	RemotePath string
 
+	// RoleName is the name the role will be linked to inside of the roles
+	// path, so playbooks can reference the role by its galaxy name.
+	// When empty, the name is found from meta/main.yml or the directory.
+	RoleName string
+
+	// RolesPath is the roles path the role will be linked into. For runs
+	// inside of the container this defaults to /etc/ansible/roles, and
+	// for remote runs a temporary directory is created on the host.
+	RolesPath string
+
 	// ExtraRolesPath is the path to the roles folder on the host which will
 	// be mounted on the container to "/root/.ansible/roles" and available to the playbook
 	// as dependencies. This is a useful workaround for CI/CD environments where the roles
@@ -56,15 +121,382 @@ type AnsibleConfig struct {
 	// be mounted on the container to "/root/.ansible/library".
 	LibraryPath string
 
+	// ModulePath is the path to custom modules shipped with the role,
+	// relative to HostPath, which is exported as ANSIBLE_LIBRARY.
+	ModulePath string
+
+	// FilterPluginsPath is the path to filter plugins shipped with the role,
+	// relative to HostPath, which is exported as ANSIBLE_FILTER_PLUGINS.
+	FilterPluginsPath string
+
 	// The path to the requirements file relative to HostPath.
 	// Requirements will not attempt installation if the field
 	// does not have a value (when value == "")
 	RequirementsFile string
 
+	// CollectionsFile is the path to the requirements file of the
+	// collections, which is the requirements file when it has a
+	// collections section or collections/requirements.yml of the role.
+	CollectionsFile string
+
+	// CollectionsPath is the path the collections are installed into,
+	// which is exported as ANSIBLE_COLLECTIONS_PATH when it is set.
+	CollectionsPath string
+
+	// CollectionsOffline is the path to a directory on the host with
+	// collection tarballs, which are installed instead of using Galaxy.
+	CollectionsOffline string
+
+	// PythonRequirements is the path to the requirements file of the
+	// Python libraries the modules of the role need, which are
+	// installed in the container with pip after the bootstrap.
+	PythonRequirements string
+
+	// PipFlags are flags added to pip install for the Python
+	// requirements, ie --no-cache-dir or --cache-dir.
+	PipFlags string
+
+	// GalaxyServers are the URLs of the Galaxy servers, ie a private
+	// Automation Hub, the requirements are installed from, in the
+	// order they are tried.
+	GalaxyServers []string
+
+	// GalaxyToken is the token of the Galaxy servers. It is a secret,
+	// so it is left out of reports and never on the command line.
+	GalaxyToken string `json:"-" yaml:"-"`
+
+	// GalaxyServerTokens are the tokens of the Galaxy servers which
+	// have one of their own, by URL. They are secrets too.
+	GalaxyServerTokens map[string]string `json:"-" yaml:"-"`
+
+	// DepsFrom is a directory on the host with the roles the role
+	// depends on, ie the sibling roles of a monorepo, which are mounted
+	// into the roles path instead of being installed from Galaxy.
+	DepsFrom string
+
+	// Dependencies are the dependencies of meta/main.yml of the role
+	// which are installed from Galaxy.
+	Dependencies []MetaDependency
+
+	// LocalDependencies are the paths of the dependencies of the role
+	// which were found in DepsFrom, by the name of the dependency.
+	LocalDependencies map[string]string
+
+	// ForceRequirements indicates the requirements are installed
+	// with --force, replacing the roles which are already installed.
+	ForceRequirements bool
+
 	// PlaybookFile is the path to the playbook located in the
 	// tests file relative to HostPath (ie HostPath/tests/playbook.yml)
 	PlaybookFile string
 
+	// PlaybookFiles is a list of playbooks which are run in order
+	// against the same container, such as converge.yml followed by
+	// verify.yml. The first playbook replaces PlaybookFile.
+	PlaybookFiles []string
+
+	// PreparePlaybook is the path to a playbook which is run before
+	// the role to prepare the container, ie to create test fixtures.
+	PreparePlaybook string
+
+	// CleanupPlaybook is the path to a playbook which is run after
+	// the idempotence test to clean up after the role.
+	CleanupPlaybook string
+
+	// VerifyPlaybook is the path to a playbook of assertions which
+	// is run after the idempotence test. The stage is skipped when
+	// the file does not exist.
+	VerifyPlaybook string
+
+	// IdempotencePasses is the number of times the playbook is run for
+	// the idempotence test, only the final pass must report no changes.
+	IdempotencePasses int
+
+	// AllowedChangedTasks is a list of task names or glob patterns of
+	// tasks which may report changes during the idempotence test.
+	AllowedChangedTasks []string
+
+	// IdempotenceAllPlaybooks indicates the idempotence test should
+	// run every playbook in PlaybookFiles instead of only the first.
+	IdempotenceAllPlaybooks bool
+
+	// IdempotenceMaxChanged is the number of changed tasks across all
+	// hosts which the idempotence test allows, the default is none.
+	IdempotenceMaxChanged int
+
+	// SkipSyntax indicates the syntax check stage should be skipped.
+	SkipSyntax bool
+
+	// SkipConverge indicates the converge stage, which runs the role,
+	// should be skipped along with the prepare and check mode stages.
+	SkipConverge bool
+
+	// SkipIdempotence indicates the idempotence stage should be skipped.
+	SkipIdempotence bool
+
+	// ExtraVars is a set of variables which will be passed to
+	// ansible-playbook as a JSON encoded --extra-vars argument.
+	ExtraVars map[string]string
+
+	// ExtraVarsFiles is a list of variable files which will be passed
+	// to ansible-playbook using the @ prefix (ie --extra-vars @vars.yml)
+	ExtraVarsFiles []string
+
+	// Tags is a list of tags to limit the playbook run to.
+	// The same tags are used for the idempotence run so the
+	// results of both runs can be compared.
+	Tags []string
+
+	// SkipTags is a list of tags to exclude from the playbook run.
+	SkipTags []string
+
+	// Limit is a host pattern used to restrict the playbook run
+	// to the plays which target the container.
+	Limit string
+
+	// AnsibleCfg is the path to an ansible.cfg file on the host which
+	// will be used for every Ansible command through ANSIBLE_CONFIG.
+	// It is mounted read-only into the container unless run remotely.
+	AnsibleCfg string
+
+	// VaultPasswordFile is the path to a file on the host containing
+	// the vault password. It is mounted read-only into the container
+	// unless the playbook is run remotely.
+	VaultPasswordFile string
+
+	// Forks is the number of parallel processes used by Ansible.
+	// Ansible's own default is used when the value is zero.
+	Forks int
+
+	// Become indicates privilege escalation should be used
+	// when running the playbook (ie --become).
+	Become bool
+
+	// BecomeUser is the user to become when running the playbook.
+	// Setting a user implies Become. This is independent from the
+	// user associated to the distribution image.
+	BecomeUser string
+
+	// BecomeMethod is the privilege escalation method (ie sudo, su).
+	BecomeMethod string
+
+	// ExecUser is the user Ansible runs as in the container, instead
+	// of root, which implies Become.
+	ExecUser string
+
+	// CreateUser will create ExecUser with passwordless sudo when the
+	// container is created.
+	CreateUser bool
+
+	// StartAtTask is the name of the task to start the role run at.
+	// It is not used for the syntax check or idempotence test.
+	StartAtTask string
+
+	// Step indicates the role run should prompt for confirmation
+	// before each task, which requires an interactive terminal.
+	Step bool
+
+	// Diff indicates the role and idempotence runs should report
+	// the differences of any changed files and templates.
+	Diff bool
+
+	// StdoutCallback is the Ansible stdout callback plugin to use for
+	// every Ansible command (ie yaml, debug or json). When the callback
+	// is json, results are parsed from the structured output.
+	StdoutCallback string
+
+	// AnsibleBinary is the path to the ansible-playbook binary used to
+	// run playbooks from the host, which takes precedence over $PATH.
+	AnsibleBinary string
+
+	// MinAnsibleVersion is the oldest version of Ansible the role
+	// can be tested with (ie 2.9), older versions abort the tests.
+	MinAnsibleVersion string
+
+	// Connection is the connection plugin used to run playbooks from
+	// the host against the container (ie docker or community.docker.docker).
+	// The available plugin is detected when it is not set.
+	Connection string
+
+	// FactCache indicates facts should be gathered once and cached
+	// so the role and idempotence runs can reuse them.
+	FactCache bool
+
+	// FactCachePath is the directory of the jsonfile fact cache,
+	// which is set when the fact cache is prepared.
+	FactCachePath string
+
+	// Strict indicates warnings and deprecation warnings from Ansible
+	// fail the syntax check and role run.
+	Strict bool
+
+	// FailOnIgnored indicates the role run fails when any task failed
+	// with ignore_errors, which Ansible counts as ignored in the recap.
+	FailOnIgnored bool
+
+	// ProfileTasks is the number of the slowest tasks of the converge
+	// to report, which are timed with the profile_tasks callback.
+	ProfileTasks int
+
+	// LogDir is a directory to write the output of every stage to,
+	// in a directory for each distribution.
+	LogDir string
+
+	// NoDiagnostics will not collect the logs and journal of the
+	// container when a stage fails.
+	NoDiagnostics bool
+
+	// PrefixOutput will prefix every printed line of output with
+	// the time and the distribution which it came from.
+	PrefixOutput bool
+
+	// Output is where the output of the commands of the distribution
+	// is written, which is the terminal when nil.
+	Output *Output `json:"-" yaml:"-"`
+
+	// GitHub indicates the results are reported as GitHub Actions
+	// workflow commands, which includes the Ansible warnings.
+	GitHub bool
+
+	// CollectWarnings indicates the Ansible warnings are collected in
+	// the report for the report writers, ie the SARIF report.
+	CollectWarnings bool
+
+	// IgnoreWarnings is a list of text which ignores any warnings
+	// containing it in strict mode.
+	IgnoreWarnings []string
+
+	// Retries is the number of times a failed role run is retried,
+	// the idempotence test is never retried.
+	Retries int
+
+	// Timeout is the longest time each stage is allowed to run for,
+	// after which the stage is killed and fails. Zero is no timeout.
+	Timeout time.Duration
+
+	// stage is done once the timeout of the stage which runs is
+	// reached, which the commands of the stage share.
+	stage context.Context
+
+	// MaxDuration is the time the stages may take together before the
+	// run fails once they complete, where 0 is no limit.
+	MaxDuration time.Duration
+
+	// StageBudgets is the time each stage may take before the run
+	// fails once the stages complete, keyed by stage.
+	StageBudgets map[string]time.Duration
+
+	// Env is a set of environment variables for every Ansible command,
+	// such as ANSIBLE_ROLES_PATH. Variables with an empty value are unset.
+	Env map[string]string
+
+	// Volumes are additional volumes of the container in the
+	// format host:container[:ro].
+	Volumes []string
+
+	// Network is the docker network of the container, which is
+	// created when it doesn't exist, ie a user-defined network or host.
+	Network string
+
+	// Memory is the memory limit of the container in bytes,
+	// where 0 is no limit.
+	Memory int64
+
+	// CPUs is the CPU limit of the container, where 0 is no limit.
+	CPUs float64
+
+	// Publish are the ports of the container which are published
+	// to the host in the format hostPort:containerPort/proto.
+	Publish []string
+
+	// DockerArgs are extra arguments of docker run, which are added
+	// after the arguments of the tool.
+	DockerArgs []string
+
+	// AnsibleArgs are extra arguments of ansible-playbook, which are
+	// added after the arguments of the tool.
+	AnsibleArgs []string
+
+	// DockerEnv is the environment of the container in the format
+	// KEY=VALUE. It may contain secrets, so it is left out of reports.
+	DockerEnv []string `json:"-" yaml:"-"`
+
+	// KeepOnFailure will leave the container running for debugging
+	// when the tests fail.
+	KeepOnFailure bool
+
+	// KeepAlways will leave the container running after the tests.
+	KeepAlways bool
+
+	// Reuse will reuse the container of a previous run with Reuse
+	// for the role and distribution, which is kept after the tests.
+	Reuse bool
+
+	// Destroy will remove a reused container after the tests.
+	Destroy bool
+
+	// StopTimeout is how long the init system of the container has
+	// to shut down when it is stopped before it is killed. Zero is
+	// the default of docker.
+	StopTimeout time.Duration
+
+	// AutoRemove will have the daemon remove the container once it
+	// stops, unless it may be kept.
+	AutoRemove bool
+
+	// Copy will copy the role into the container instead of mounting
+	// it, leaving out the files of its .artignore file.
+	Copy bool
+
+	// Tmpfs are the tmpfs mounts of the container as PATH[:OPTIONS],
+	// instead of those of the distribution, where "none" leaves them
+	// out.
+	Tmpfs []string
+
+	// CgroupV2 indicates the docker daemon uses cgroup v2, so the
+	// cgroups of systemd distributions are mounted for it.
+	CgroupV2 bool
+
+	// Platform is the platform of the container, ie linux/arm64,
+	// which is the platform of the host by default.
+	Platform string
+
+	// ReadyTimeout is the longest time to wait for the init process
+	// of the container to be ready. Zero disables the check.
+	ReadyTimeout time.Duration
+
+	// SkipBootstrap indicates the bootstrap commands of the
+	// distribution are not run once the container is ready.
+	SkipBootstrap bool
+
+	// EOLMirrors indicates distributions past their end of life use
+	// the archive of their repositories, ie vault.centos.org.
+	EOLMirrors bool
+
+	// InstallAnsible indicates Ansible is installed in the container
+	// when the image doesn't include it.
+	InstallAnsible bool
+
+	// InstallAnsibleVersion is the version of Ansible which is
+	// installed with pip, ie 9.*, instead of the package manager.
+	InstallAnsibleVersion string
+
+	// Dockerfile is the Dockerfile which the image of the container
+	// is built from, instead of the image of the distribution.
+	Dockerfile string
+
+	// BuildArgs are the build arguments of the Dockerfile in the
+	// format KEY=VALUE.
+	BuildArgs []string
+
+	// BuildAlways will build the image even when it already exists.
+	BuildAlways bool
+
+	// PullPolicy is when the image is pulled, which is always,
+	// missing or never. The default is the policy of the
+	// distribution, or missing.
+	PullPolicy string
+
 	// Remote indicates the playbook will be run on a remote host
 	// likely which is inputted to the inventory field.
 	Remote bool
@@ -84,22 +516,22 @@ type Container interface {
 	DockerRun(config *AnsibleConfig)
 	DockerKill()
 	RoleInstall(config *AnsibleConfig)
-	RoleTest(config *AnsibleConfig)
+	RoleTest(config *AnsibleConfig, report *AnsibleReport)
 }
 
 func init() {
-	d, e := exec.LookPath("docker")
-	if e != nil {
-		log.Errorln("executable 'docker' was not found in $PATH.")
-	}
-
-	docker = d
+	// The docker CLI is optional, the Docker Engine API is used
+	// unless the CLI is requested.
+	docker, _ = exec.LookPath("docker")
 	dockerFound = true
 
-	if dockerFound {
-		c, err := net.Dial("unix", "/var/run/docker.sock")
+	// Daemons which can only be reached by the docker CLI, such as
+	// ssh:// daemons, are checked by the first docker command.
+	if network, address := dockerAddress(dockerHost()); dockerFound && network != "" {
+		c, err := net.Dial(network, address)
 		if err != nil {
-			log.Fatalf("unable to connect to docker: %v", err)
+			log.Errorf("unable to connect to docker: %v", err)
+			os.Exit(DockerRunCode)
 		}
 		defer c.Close()
 	}
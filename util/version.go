@@ -0,0 +1,145 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ansibleVersionPattern matches the version in the output of --version,
+// ie "ansible-playbook 2.9.27" or "ansible-playbook [core 2.16.3]".
+var ansibleVersionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// MapAnsibleBinary will resolve the configured ansible-playbook binary,
+// which takes precedence over the binary found in $PATH for playbooks
+// run from the host. The other Ansible binaries are found next to it.
+func MapAnsibleBinary(config *AnsibleConfig) {
+
+	if config.AnsibleBinary == "" {
+		return
+	}
+
+	path, err := exec.LookPath(config.AnsibleBinary)
+	if err != nil {
+		ConfigError("Specified ansible-playbook binary %v was not found.", config.AnsibleBinary)
+	}
+
+	if path, err = filepath.Abs(path); err != nil {
+		ConfigError("Specified ansible-playbook binary %v was not found.", config.AnsibleBinary)
+	}
+
+	config.AnsibleBinary = path
+	ansibleplaybookLock.Lock()
+	ansibleplaybook = path
+	ansibleplaybookLock.Unlock()
+}
+
+// ansiblePlaybookPath will return the path of ansible-playbook, which
+// is looked up in $PATH unless a binary was configured.
+func ansiblePlaybookPath() string {
+	ansibleplaybookLock.Lock()
+	defer ansibleplaybookLock.Unlock()
+	if ansibleplaybook == "" {
+		a, e := exec.LookPath("ansible-playbook")
+		if e != nil {
+			log.Errorln("executable 'ansible-playbook' was not found in $PATH.")
+		}
+		ansibleplaybook = a
+	}
+	return ansibleplaybook
+}
+
+// ansibleTool will find an Ansible binary (ie ansible-doc), preferring
+// the binary next to the configured ansible-playbook binary.
+func ansibleTool(name string) (string, error) {
+	ansibleplaybookLock.Lock()
+	playbook := ansibleplaybook
+	ansibleplaybookLock.Unlock()
+	if playbook != "" {
+		path := filepath.Join(filepath.Dir(playbook), name)
+		if _, err := exec.LookPath(path); err == nil {
+			return path, nil
+		}
+	}
+	return exec.LookPath(name)
+}
+
+// AnsibleVersion will return the version of ansible-playbook which will
+// run the role, which is inside the container unless the run is remote.
+func (dist *Distribution) AnsibleVersion(config *AnsibleConfig) (string, error) {
+
+	var out string
+	var err error
+	if config.Remote {
+		out, err = config.ansiblePlaybook([]string{"--version"}, buildAnsibleEnv(config), false)
+	} else {
+		out, err = config.dockerExec(buildExecCommand(dist, config, "ansible-playbook", "--version"), false)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return parseAnsibleVersion(out)
+}
+
+// parseAnsibleVersion will return the version from the output of --version.
+func parseAnsibleVersion(output string) (string, error) {
+	line := strings.SplitN(strings.TrimSpace(output), "\n", 2)[0]
+	version := ansibleVersionPattern.FindString(line)
+	if version == "" {
+		return "", fmt.Errorf("could not find the Ansible version in '%v'", line)
+	}
+	return version, nil
+}
+
+// CheckAnsibleVersion will return an error if the version is older
+// than the minimum version, both of which are in the form 2.9.27.
+func CheckAnsibleVersion(version, minimum string) error {
+
+	current, err := splitVersion(version)
+	if err != nil {
+		return err
+	}
+
+	required, err := splitVersion(minimum)
+	if err != nil {
+		return err
+	}
+
+	for i := range required {
+		if current[i] > required[i] {
+			return nil
+		}
+		if current[i] < required[i] {
+			return fmt.Errorf("Ansible %v is older than the minimum version %v", version, minimum)
+		}
+	}
+
+	return nil
+}
+
+// splitVersion will return the major, minor and patch numbers of a
+// version, a missing patch number is zero.
+func splitVersion(version string) ([3]int, error) {
+
+	parts := [3]int{}
+	match := ansibleVersionPattern.FindStringSubmatch(version)
+	if match == nil || match[0] != version {
+		return parts, errors.New("invalid version " + version + ", expected a version like 2.9.27")
+	}
+
+	for i, part := range match[1:] {
+		if part == "" {
+			continue
+		}
+		parts[i], _ = strconv.Atoi(part)
+	}
+
+	return parts, nil
+}
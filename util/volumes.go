@@ -0,0 +1,77 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cgroupVolume is the volume of the cgroups which systemd needs,
+// which is the volume of custom distributions by default.
+const cgroupVolume = "/sys/fs/cgroup:/sys/fs/cgroup:ro"
+
+// parseVolume will validate a volume in the format host:container[:ro],
+// where a leading ~ or a relative host path is expanded to an absolute
+// path. Volumes which would shadow the role at remotePath are rejected.
+func parseVolume(volume, remotePath string) (string, error) {
+
+	parts := strings.Split(volume, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return volume, fmt.Errorf("invalid volume '%v', expected HOST:CONTAINER[:ro]", volume)
+	}
+	if len(parts) == 3 && parts[2] != "ro" && parts[2] != "rw" {
+		return volume, fmt.Errorf("invalid volume '%v', the mode must be ro or rw", volume)
+	}
+
+	host := parts[0]
+	if host == "~" || strings.HasPrefix(host, "~/") {
+		host = filepath.Join(os.Getenv("HOME"), host[1:])
+	}
+	host, err := filepath.Abs(host)
+	if err != nil {
+		return volume, err
+	}
+	parts[0] = host
+
+	container := path.Clean(parts[1])
+	if !path.IsAbs(container) {
+		return volume, fmt.Errorf("invalid volume '%v', the container path must be absolute", volume)
+	}
+	if remotePath != "" {
+		role := path.Clean(remotePath)
+		if container == role || strings.HasPrefix(role, strings.TrimSuffix(container, "/")+"/") {
+			return volume, fmt.Errorf("invalid volume '%v', it would shadow the role at %v", volume, remotePath)
+		}
+	}
+	parts[1] = container
+
+	return strings.Join(parts, ":"), nil
+}
+
+// MapVolumes will validate the additional volumes and resolve their
+// host paths, and will fail if any of them are invalid.
+func MapVolumes(config *AnsibleConfig) {
+
+	for i, volume := range config.Volumes {
+		mapped, err := parseVolume(volume, config.RemotePath)
+		if err != nil {
+			ConfigError("%v", err)
+		}
+		config.Volumes[i] = mapped
+	}
+
+}
+
+// CgroupVolume will return the volume of the cgroups for custom
+// distributions, which is the volume which mounts /sys/fs/cgroup
+// when one is given, or the read-only cgroups of the host.
+func CgroupVolume(volumes []string) string {
+	for _, volume := range volumes {
+		if parts := strings.Split(volume, ":"); len(parts) > 1 && path.Clean(parts[1]) == "/sys/fs/cgroup" {
+			return volume
+		}
+	}
+	return cgroupVolume
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseVolume(t *testing.T) {
+
+	Convey("Validating additional volumes", t, func() {
+
+		role := "/etc/ansible/roles/role_under_test"
+
+		Convey("Absolute volumes are kept", func() {
+			volume, err := parseVolume("/srv/fixtures:/srv/fixtures:ro", role)
+			So(err, ShouldBeNil)
+			So(volume, ShouldEqual, "/srv/fixtures:/srv/fixtures:ro")
+		})
+
+		Convey("Relative and home host paths are expanded", func() {
+			pwd, _ := os.Getwd()
+			volume, err := parseVolume("tests/fixtures:/srv/fixtures", role)
+			So(err, ShouldBeNil)
+			So(volume, ShouldEqual, filepath.Join(pwd, "tests/fixtures")+":/srv/fixtures")
+
+			volume, err = parseVolume("~/secrets:/srv/secrets:ro", role)
+			So(err, ShouldBeNil)
+			So(volume, ShouldEqual, filepath.Join(os.Getenv("HOME"), "secrets")+":/srv/secrets:ro")
+		})
+
+		Convey("Invalid volumes are rejected", func() {
+			for _, volume := range []string{"/srv", ":/srv", "/srv:", "/srv:/srv:ro:z", "/srv:/srv:rx", "/srv:srv"} {
+				_, err := parseVolume(volume, role)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Volumes which shadow the role are rejected", func() {
+			for _, volume := range []string{"/srv:" + role, "/srv:/etc/ansible/roles", "/srv:/etc/ansible/roles/", "/srv:/"} {
+				_, err := parseVolume(volume, role)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "shadow the role")
+			}
+			_, err := parseVolume("/srv:"+role+"/files/secrets", role)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Volumes are added to the container", func() {
+			dist := Distribution{CID: "test", Container: "image", Family: Family{Initialise: "/bin/systemd", Volume: cgroupVolume}}
+			config := AnsibleConfig{HostPath: "/home/user/web", RemotePath: role, Volumes: []string{"/srv/fixtures:/srv/fixtures:ro"}}
+			report := AnsibleReport{}
+			So(buildRunCommand(&dist, &config, &report).args(), ShouldContain, "--volume=/srv/fixtures:/srv/fixtures:ro")
+			So(report.NewJSONReport().Metadata.Volumes, ShouldContain, "/srv/fixtures:/srv/fixtures:ro")
+		})
+
+		Convey("Custom distributions mount the cgroups unless a volume does", func() {
+			So(CgroupVolume([]string{"/srv:/srv"}), ShouldEqual, cgroupVolume)
+			So(CgroupVolume([]string{"/sys/fs/cgroup:/sys/fs/cgroup:rw"}), ShouldEqual, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+
+	// warningPattern matches the warnings and deprecation
+	// warnings which Ansible prints, ie "[WARNING]: ...".
+	warningPattern = regexp.MustCompile(`^\s*\[(DEPRECATION )?WARNING\]`)
+
+	// DefaultIgnoredWarnings are warnings which are expected when testing
+	// in a container, such as the python interpreter discovery warning.
+	DefaultIgnoredWarnings = []string{
+		"discovered Python interpreter",
+	}
+)
+
+// parseWarnings will return the warnings in the output of Ansible.
+func parseWarnings(output string) []string {
+	warnings := []string{}
+	for _, line := range strings.Split(ansiPattern.ReplaceAllString(output, ""), "\n") {
+		if warningPattern.MatchString(line) {
+			warnings = append(warnings, strings.TrimSpace(line))
+		}
+	}
+	return warnings
+}
+
+// ignoredWarning will identify if the warning contains any of the
+// configured text which should be ignored.
+func (config *AnsibleConfig) ignoredWarning(warning string) bool {
+	for _, text := range config.IgnoreWarnings {
+		if text != "" && strings.Contains(warning, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWarnings will collect the warnings from the output into the
+// report when strict mode, GitHub Actions output or collecting the
+// warnings is enabled, and will
+// return false in strict mode if any warnings were found which are not
+// ignored.
+func (report *AnsibleReport) checkWarnings(config *AnsibleConfig, output string) bool {
+
+	if !config.Strict && !config.GitHub && !config.CollectWarnings {
+		return true
+	}
+
+	found := false
+	for _, warning := range parseWarnings(output) {
+		if config.ignoredWarning(warning) {
+			continue
+		}
+		found = true
+		if config.Strict {
+			log.Errorf("Strict mode: %v", warning)
+		}
+
+		duplicate := false
+		for _, existing := range report.Ansible.Warnings {
+			if existing == warning {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			report.Ansible.Warnings = append(report.Ansible.Warnings, warning)
+		}
+	}
+
+	return !config.Strict || !found
+}
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"io/ioutil"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckWarnings(t *testing.T) {
+
+	Convey("Warnings in strict mode", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		out := `[WARNING]: Platform linux on host test is using the discovered Python interpreter at /usr/bin/python3
+[DEPRECATION WARNING]: The 'include' module is deprecated, use 'include_tasks'.
+PLAY RECAP *********************************************************************`
+
+		Convey("Warnings are ignored by default", func() {
+			report := AnsibleReport{}
+			So(report.checkWarnings(&AnsibleConfig{}, out), ShouldBeTrue)
+			So(report.Ansible.Warnings, ShouldBeEmpty)
+		})
+
+		Convey("Warnings which are not ignored fail", func() {
+			report := AnsibleReport{}
+			config := AnsibleConfig{Strict: true, IgnoreWarnings: DefaultIgnoredWarnings}
+			So(report.checkWarnings(&config, out), ShouldBeFalse)
+			So(report.Ansible.Warnings, ShouldResemble, []string{
+				"[DEPRECATION WARNING]: The 'include' module is deprecated, use 'include_tasks'.",
+			})
+		})
+	})
+}
+
+func TestCollectWarnings(t *testing.T) {
+
+	Convey("Warnings in GitHub Actions", t, func() {
+		// Send testing output to /dev/null
+		log.SetOutput(ioutil.Discard)
+
+		Convey("Warnings are collected without failing", func() {
+			report := AnsibleReport{}
+			config := AnsibleConfig{GitHub: true}
+			So(report.checkWarnings(&config, "[WARNING]: No inventory was parsed"), ShouldBeTrue)
+			So(report.Ansible.Warnings, ShouldResemble, []string{"[WARNING]: No inventory was parsed"})
+		})
+
+		Convey("Warnings are collected for the SARIF report", func() {
+			report := AnsibleReport{}
+			config := AnsibleConfig{CollectWarnings: true}
+			So(report.checkWarnings(&config, "[WARNING]: No inventory was parsed"), ShouldBeTrue)
+			So(report.Ansible.Warnings, ShouldHaveLength, 1)
+		})
+	})
+}